@@ -28,6 +28,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/big"
 	"mime"
 	"net/http"
@@ -230,6 +231,13 @@ func (a *API) Store(ctx context.Context, data io.Reader, size int64, toEncrypt b
 	return a.fileStore.Store(ctx, data, size, toEncrypt)
 }
 
+// Reencrypt stores the content with the address encrypted under the master key,
+// decrypting it with the master key from the context, see storage.FileStore.Reencrypt
+func (a *API) Reencrypt(ctx context.Context, addr storage.Address, masterKey []byte) (newAddr storage.Address, wait func(ctx context.Context) error, err error) {
+	log.Debug("api.reencrypt", "addr", addr)
+	return a.fileStore.Reencrypt(ctx, addr, masterKey)
+}
+
 // Resolve a name into a content-addressed hash
 // where address could be an ENS/RNS name, or a content addressed hash
 func (a *API) Resolve(ctx context.Context, address string) (storage.Address, error) {
@@ -536,66 +544,106 @@ func (a *API) GetDirectoryTar(ctx context.Context, decrypt DecryptFunc, uri *URI
 // GetManifestList lists the manifest entries for the specified address and prefix
 // and returns it as a ManifestList
 func (a *API) GetManifestList(ctx context.Context, decryptor DecryptFunc, addr storage.Address, prefix string) (list ManifestList, err error) {
+	return a.GetManifestListPage(ctx, decryptor, addr, ManifestListOptions{Prefix: prefix})
+}
+
+// GetManifestListPage lists the manifest entries for the specified address
+// with the options and returns it as a ManifestList, with the continuation
+// of the next page if the list is limited and there are more entries
+func (a *API) GetManifestListPage(ctx context.Context, decryptor DecryptFunc, addr storage.Address, opts ManifestListOptions) (list ManifestList, err error) {
+	list.Continuation, err = a.WalkManifestList(ctx, decryptor, addr, opts, func(entry *ManifestEntry, commonPrefix string) error {
+		if entry == nil {
+			list.CommonPrefixes = append(list.CommonPrefixes, commonPrefix)
+		} else {
+			list.Entries = append(list.Entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return ManifestList{}, err
+	}
+	return list, nil
+}
+
+// errManifestListLimit is returned by the walk function of WalkManifestList
+// when the limit of the listed entries is reached
+var errManifestListLimit = errors.New("manifest list limit reached")
+
+// WalkManifestList calls listFn for the entries, or common prefixes with
+// nil entries, of the manifest listed with the options in the order of their
+// paths, without keeping them in memory. It returns the continuation of
+// the next page if the list is limited and there are more entries.
+func (a *API) WalkManifestList(ctx context.Context, decryptor DecryptFunc, addr storage.Address, opts ManifestListOptions, listFn func(entry *ManifestEntry, commonPrefix string) error) (continuation string, err error) {
 	apiManifestListCount.Inc(1)
 	walker, err := a.NewManifestWalker(ctx, addr, decryptor, nil)
 	if err != nil {
 		apiManifestListFail.Inc(1)
-		return ManifestList{}, err
+		return "", err
 	}
 
-	err = walker.Walk(func(entry *ManifestEntry) error {
-		// handle non-manifest files
-		if entry.ContentType != ManifestType {
-			// ignore the file if it doesn't have the specified prefix
-			if !strings.HasPrefix(entry.Path, prefix) {
-				return nil
-			}
-
-			// if the path after the prefix contains a slash, add a
-			// common prefix to the list, otherwise add the entry
-			suffix := strings.TrimPrefix(entry.Path, prefix)
-			if index := strings.Index(suffix, "/"); index > -1 {
-				list.CommonPrefixes = append(list.CommonPrefixes, prefix+suffix[:index+1])
-				return nil
-			}
-			if entry.Path == "" {
-				entry.Path = "/"
-			}
-			list.Entries = append(list.Entries, entry)
+	var count int
+	var last string // path or common prefix listed last
+	list := func(entry *ManifestEntry, commonPrefix string) error {
+		path := commonPrefix
+		if entry != nil {
+			path = entry.Path
+		}
+		// skip the paths of the previous pages and the common prefix listed last
+		if path < opts.Continuation || (count > 0 && path == last) {
 			return nil
 		}
+		if opts.Limit > 0 && count == opts.Limit {
+			continuation = path
+			return errManifestListLimit
+		}
+		count++
+		last = path
+		if entry != nil && entry.Path == "" {
+			entry.Path = "/"
+		}
+		return listFn(entry, commonPrefix)
+	}
 
-		// if the manifest's path is a prefix of the specified prefix
-		// then just recurse into the manifest by returning nil and
-		// continuing the walk
-		if strings.HasPrefix(prefix, entry.Path) {
+	err = walker.WalkFrom(opts.Continuation, func(entry *ManifestEntry) error {
+		// skip the entries and manifests without the prefix, unless
+		// the manifest's path is a prefix of the specified prefix,
+		// in which case recurse into the manifest
+		if !strings.HasPrefix(entry.Path, opts.Prefix) {
+			if entry.ContentType == ManifestType && !strings.HasPrefix(opts.Prefix, entry.Path) {
+				return ErrSkipManifest
+			}
 			return nil
 		}
 
-		// if the manifest's path has the specified prefix, then if the
-		// path after the prefix contains a slash, add a common prefix
-		// to the list and skip the manifest, otherwise recurse into
-		// the manifest by returning nil and continuing the walk
-		if strings.HasPrefix(entry.Path, prefix) {
-			suffix := strings.TrimPrefix(entry.Path, prefix)
+		// if the path after the prefix contains a slash, list a common
+		// prefix instead of the entry and skip the manifest
+		if !opts.Recursive {
+			suffix := strings.TrimPrefix(entry.Path, opts.Prefix)
 			if index := strings.Index(suffix, "/"); index > -1 {
-				list.CommonPrefixes = append(list.CommonPrefixes, prefix+suffix[:index+1])
-				return ErrSkipManifest
+				if err := list(nil, opts.Prefix+suffix[:index+1]); err != nil {
+					return err
+				}
+				if entry.ContentType == ManifestType {
+					return ErrSkipManifest
+				}
+				return nil
 			}
-			return nil
 		}
 
-		// the manifest neither has the prefix or needs recursing in to
-		// so just skip it
-		return ErrSkipManifest
+		// recurse into the manifest, otherwise list the entry
+		if entry.ContentType == ManifestType {
+			return nil
+		}
+		return list(entry, "")
 	})
-
+	if err == errManifestListLimit {
+		return continuation, nil
+	}
 	if err != nil {
 		apiManifestListFail.Inc(1)
-		return ManifestList{}, err
+		return "", err
 	}
-
-	return list, nil
+	return "", nil
 }
 
 func (a *API) UpdateManifest(ctx context.Context, addr storage.Address, update func(mw *ManifestWriter) error) (storage.Address, error) {
@@ -703,6 +751,8 @@ func (a *API) UploadTar(ctx context.Context, bodyReader io.ReadCloser, manifestP
 	tr := tar.NewReader(bodyReader)
 	defer bodyReader.Close()
 	var defaultPathFound bool
+	var rules *IgnoreRules
+	var filesAdded bool
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
@@ -712,11 +762,41 @@ func (a *API) UploadTar(ctx context.Context, bodyReader io.ReadCloser, manifestP
 			return nil, fmt.Errorf("error reading tar stream: %s", err)
 		}
 
+		// skip the entries excluded by the ignore rules
+		if rules.Match(hdr.Name, hdr.FileInfo().IsDir()) {
+			continue
+		}
+
 		// only store regular files
 		if !hdr.FileInfo().Mode().IsRegular() {
 			continue
 		}
 
+		// the ignore rules in the root of the tar stream must precede the files
+		// they apply to, as the entries are stored while the stream is read
+		var content io.Reader = tr
+		if path.Clean(hdr.Name) == IgnoreFileName {
+			if filesAdded {
+				apiUploadTarFail.Inc(1)
+				return nil, fmt.Errorf("%s must precede the other files in the tar stream", IgnoreFileName)
+			}
+			if hdr.Size > MaxIgnoreFileSize {
+				apiUploadTarFail.Inc(1)
+				return nil, fmt.Errorf("%s is larger than %d bytes", IgnoreFileName, MaxIgnoreFileSize)
+			}
+			data, err := ioutil.ReadAll(io.LimitReader(tr, MaxIgnoreFileSize))
+			if err != nil {
+				apiUploadTarFail.Inc(1)
+				return nil, fmt.Errorf("error reading tar stream: %s", err)
+			}
+			rules, err = ParseIgnoreRules(bytes.NewReader(data))
+			if err != nil {
+				apiUploadTarFail.Inc(1)
+				return nil, err
+			}
+			content = bytes.NewReader(data)
+		}
+
 		// add the entry under the path from the request
 		manifestPath := path.Join(manifestPath, hdr.Name)
 		contentType := hdr.Xattrs["user.swarm.content-type"]
@@ -731,11 +811,12 @@ func (a *API) UploadTar(ctx context.Context, bodyReader io.ReadCloser, manifestP
 			Size:        hdr.Size,
 			ModTime:     hdr.ModTime,
 		}
-		contentKey, err = mw.AddEntry(ctx, tr, entry)
+		contentKey, err = mw.AddEntry(ctx, content, entry)
 		if err != nil {
 			apiUploadTarFail.Inc(1)
 			return nil, fmt.Errorf("error adding manifest entry from tar stream: %s", err)
 		}
+		filesAdded = true
 		if hdr.Name == defaultPath {
 			contentType := hdr.Xattrs["user.swarm.content-type"]
 			if contentType == "" {
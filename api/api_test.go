@@ -17,6 +17,7 @@
 package api
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
 	crand "crypto/rand"
@@ -26,6 +27,7 @@ import (
 	"io/ioutil"
 	"math/big"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -645,3 +647,98 @@ func putString(ctx context.Context, a *API, content string, contentType string,
 		return waitManifest(ctx)
 	}, nil
 }
+
+// TestUploadTarIgnoreFile tests that the ignore rules in the root of the tar
+// stream exclude the matching entries and that the tar stream is rejected
+// if the ignore file is too large or does not precede the other files.
+func TestUploadTarIgnoreFile(t *testing.T) {
+	type file struct {
+		name    string
+		content string
+	}
+	ignore := file{IgnoreFileName, "*.log\nsecret/\n"}
+	files := []file{
+		{"index.html", "<h1>index</h1>"},
+		{"debug.log", "log"},
+		{"secret/key", "key"},
+		{"img/logo.png", "logo"},
+	}
+	tarStream := func(files []file) io.ReadCloser {
+		buf := new(bytes.Buffer)
+		tw := tar.NewWriter(buf)
+		for _, f := range files {
+			hdr := &tar.Header{
+				Name:     f.name,
+				Mode:     0644,
+				Size:     int64(len(f.content)),
+				Typeflag: tar.TypeReg,
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write([]byte(f.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return ioutil.NopCloser(buf)
+	}
+	testAPI(t, func(api *API, _ *chunk.Tags, toEncrypt bool) {
+		ctx := context.Background()
+		upload := func(files []file) (storage.Address, error) {
+			addr, err := api.NewManifest(ctx, toEncrypt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return api.UpdateManifest(ctx, addr, func(mw *ManifestWriter) error {
+				_, err := api.UploadTar(ctx, tarStream(files), "", "", mw)
+				return err
+			})
+		}
+
+		t.Run("first", func(t *testing.T) {
+			addr, err := upload(append([]file{ignore}, files...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			walker, err := api.NewManifestWalker(ctx, addr, NOOPDecrypt, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var paths []string
+			err = walker.Walk(func(entry *ManifestEntry) error {
+				if entry.ContentType != ManifestType {
+					paths = append(paths, entry.Path)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{IgnoreFileName, "img/logo.png", "index.html"}
+			if !reflect.DeepEqual(paths, want) {
+				t.Errorf("got paths %v, want %v", paths, want)
+			}
+		})
+
+		t.Run("too large", func(t *testing.T) {
+			large := file{IgnoreFileName, strings.Repeat("#", MaxIgnoreFileSize+1)}
+			_, err := upload(append([]file{large}, files...))
+			if err == nil {
+				t.Fatal("expected error for ignore file larger than the maximal size")
+			}
+		})
+
+		t.Run("last", func(t *testing.T) {
+			_, err := upload(append(files, ignore))
+			if err == nil {
+				t.Fatal("expected error for ignore file after the other files")
+			}
+			if !strings.Contains(err.Error(), IgnoreFileName) {
+				t.Errorf("got error %q, want it to mention %s", err, IgnoreFileName)
+			}
+		})
+	})
+}
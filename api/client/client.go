@@ -45,6 +45,7 @@ import (
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/spancontext"
 	"github.com/ethersphere/swarm/storage/feed"
+	"github.com/ethersphere/swarm/storage/pin"
 	"github.com/pborman/uuid"
 )
 
@@ -278,6 +279,9 @@ func (c *Client) DownloadDirectory(hash, path, destDir, credentials string) erro
 		} else if n != hdr.Size {
 			return fmt.Errorf("expected %s to be %d bytes but got %d", hdr.Name, hdr.Size, n)
 		}
+		if err := api.RestoreFileMetadata(dstPath, hdr.Mode, hdr.ModTime); err != nil {
+			return err
+		}
 	}
 }
 
@@ -411,7 +415,28 @@ func (c *Client) DownloadManifest(hash string) (*api.Manifest, bool, error) {
 //
 // where entries ending with "/" are common prefixes.
 func (c *Client) List(hash, prefix, credentials string) (*api.ManifestList, error) {
-	req, err := http.NewRequest(http.MethodGet, c.Gateway+"/bzz-list:/"+hash+"/"+prefix, nil)
+	return c.ListPage(hash, api.ManifestListOptions{Prefix: prefix}, credentials)
+}
+
+// ListPage lists files in a swarm manifest with the options, as List does
+// with the prefix. If the list is limited and there are more files, they are
+// listed by passing the continuation of the returned list in the options.
+func (c *Client) ListPage(hash string, opts api.ManifestListOptions, credentials string) (*api.ManifestList, error) {
+	query := url.Values{}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Continuation != "" {
+		query.Set("continuation", opts.Continuation)
+	}
+	if opts.Recursive {
+		query.Set("recursive", "1")
+	}
+	uri := c.Gateway + "/bzz-list:/" + hash + "/" + opts.Prefix
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -466,16 +491,25 @@ func (d *DirectoryUploader) Tag() string {
 	return filepath.Base(d.Dir)
 }
 
-// Upload performs the upload of the directory and default path
+// Upload performs the upload of the directory and default path, skipping
+// the paths excluded by the ignore file in the directory. The ignore file
+// is uploaded first, so that the rules also apply on the server.
 func (d *DirectoryUploader) Upload(upload UploadFn) error {
-	return filepath.Walk(d.Dir, func(path string, f os.FileInfo, err error) error {
+	rules, err := api.LoadIgnoreRules(d.Dir)
+	if err != nil {
+		return err
+	}
+	if rules != nil {
+		file, err := Open(filepath.Join(d.Dir, api.IgnoreFileName))
 		if err != nil {
 			return err
 		}
-		if f.IsDir() {
-			return nil
+		file.Path = api.IgnoreFileName
+		if err := upload(file); err != nil {
+			return err
 		}
-		file, err := Open(path)
+	}
+	return filepath.Walk(d.Dir, func(path string, f os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -483,7 +517,21 @@ func (d *DirectoryUploader) Upload(upload UploadFn) error {
 		if err != nil {
 			return err
 		}
-		file.Path = filepath.ToSlash(relPath)
+		relPath = filepath.ToSlash(relPath)
+		if relPath != "." && rules.Match(relPath, f.IsDir()) {
+			if f.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if f.IsDir() || (rules != nil && relPath == api.IgnoreFileName) {
+			return nil
+		}
+		file, err := Open(path)
+		if err != nil {
+			return err
+		}
+		file.Path = relPath
 		return upload(file)
 	})
 }
@@ -694,6 +742,57 @@ func (c *Client) TagByHash(hash string) (*chunk.Tag, error) {
 	return tag, err
 }
 
+// Pin pins the content with the hash in the local store of the node. If
+// recursive is true, the hash is of a manifest and the whole manifest tree
+// with all its files is pinned, otherwise only the file with the hash.
+func (c *Client) Pin(hash string, recursive bool) error {
+	uri := c.Gateway + "/bzz-pin:/" + hash + "?recursive=" + strconv.FormatBool(recursive)
+	req, err := http.NewRequest(http.MethodPost, uri, nil)
+	if err != nil {
+		return err
+	}
+	return c.doPinRequest(req)
+}
+
+// Unpin unpins the content with the hash pinned with Pin
+func (c *Client) Unpin(hash string) error {
+	req, err := http.NewRequest(http.MethodDelete, c.Gateway+"/bzz-pin:/"+hash, nil)
+	if err != nil {
+		return err
+	}
+	return c.doPinRequest(req)
+}
+
+func (c *Client) doPinRequest(req *http.Request) error {
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	return nil
+}
+
+// ListPins returns the information about all content pinned in the local store
+// of the node, including the size of the pinned files
+func (c *Client) ListPins() ([]pin.PinInfo, error) {
+	res, err := c.httpClient.Get(c.Gateway + "/bzz-pin:/")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	var pins []pin.PinInfo
+	if err := json.NewDecoder(res.Body).Decode(&pins); err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
+
 // ErrNoFeedUpdatesFound is returned when Swarm cannot find updates of the given feed
 var ErrNoFeedUpdatesFound = errors.New("No updates found for this feed")
 
@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -337,6 +338,113 @@ func testClientFileList(toEncrypt bool, t *testing.T) {
 	}
 }
 
+// TestClientFileListPage tests listing files in a swarm manifest in pages,
+// with and without grouping the files into common prefixes
+func TestClientFileListPage(t *testing.T) {
+	srv := swarmhttp.NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	client := NewClient(srv.URL)
+	hash, err := client.UploadDirectory(dir, "", "", false, false, true)
+	if err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+
+	ls := func(opts api.ManifestListOptions) (paths []string) {
+		for {
+			list, err := client.ListPage(hash, opts, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts.Limit > 0 && len(list.CommonPrefixes)+len(list.Entries) > opts.Limit {
+				t.Fatalf("got %d paths, want at most %d", len(list.CommonPrefixes)+len(list.Entries), opts.Limit)
+			}
+			paths = append(paths, list.CommonPrefixes...)
+			for _, entry := range list.Entries {
+				paths = append(paths, entry.Path)
+			}
+			if list.Continuation == "" {
+				return paths
+			}
+			opts.Continuation = list.Continuation
+		}
+	}
+
+	for _, tc := range []struct {
+		prefix    string
+		recursive bool
+		expected  []string
+	}{
+		{"", false, []string{"dir1/", "dir2/", "file1.txt", "file2.txt"}},
+		{"dir2/", false, []string{"dir2/dir3/", "dir2/dir4/", "dir2/file5.txt"}},
+		{"", true, []string{"dir1/file3.txt", "dir1/file4.txt", "dir2/dir3/file6.txt", "dir2/dir4/file7.txt", "dir2/dir4/file8.txt", "dir2/file5.txt", "file1.txt", "file2.txt"}},
+		{"dir2/dir", true, []string{"dir2/dir3/file6.txt", "dir2/dir4/file7.txt", "dir2/dir4/file8.txt"}},
+	} {
+		for _, limit := range []int{0, 1, 2, 3} {
+			actual := ls(api.ManifestListOptions{Prefix: tc.prefix, Limit: limit, Recursive: tc.recursive})
+			sort.Strings(actual)
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected prefix %q, recursive %v, limit %d to return %v, got %v", tc.prefix, tc.recursive, limit, tc.expected, actual)
+			}
+		}
+	}
+}
+
+// TestClientPin tests pinning and unpinning a directory recursively
+// and listing it with the size of its files
+func TestClientPin(t *testing.T) {
+	srv := swarmhttp.NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	client := NewClient(srv.URL)
+	hash, err := client.UploadDirectory(dir, "", "", false, false, true)
+	if err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+
+	if err := client.Pin(hash, true); err != nil {
+		t.Fatal(err)
+	}
+	pins, err := client.ListPins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 {
+		t.Fatalf("expected 1 pin, got %d", len(pins))
+	}
+	var size int
+	for _, file := range testDirFiles {
+		size += len(file)
+	}
+	if pins[0].Address.Hex() != hash || pins[0].IsRaw || pins[0].PinCounter != 1 || pins[0].FileSize != uint64(size) {
+		t.Fatalf("unexpected pin %+v of %s with size %d", pins[0], hash, size)
+	}
+
+	if err := client.Unpin(hash); err != nil {
+		t.Fatal(err)
+	}
+	if pins, err = client.ListPins(); err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 0 {
+		t.Fatalf("expected no pins, got %d", len(pins))
+	}
+
+	// unpinning content that is not pinned and pinning missing content fails
+	if err := client.Unpin(hash); err == nil {
+		t.Fatal("expected error unpinning content that is not pinned")
+	}
+	if err := client.Pin(strings.Repeat("00", 32), false); err == nil {
+		t.Fatal("expected error pinning missing content")
+	}
+}
+
 // TestClientMultipartUpload tests uploading files to swarm using a multipart
 // upload
 func TestClientMultipartUpload(t *testing.T) {
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -69,6 +70,7 @@ type Config struct {
 	EnsRoot            common.Address
 	EnsAPIs            []string
 	RnsAPI             string
+	FallbackGateways   []string // trusted HTTP gateways to fetch chunks from if they can not be retrieved from the network
 	Path               string
 	ListenAddr         string
 	Port               string
@@ -79,9 +81,17 @@ type Config struct {
 	SyncEnabled        bool
 	PushSyncEnabled    bool
 	LightNodeEnabled   bool
+	LightNoRetrieve    bool // light node does not serve retrieve requests
+	LightNoSync        bool // light node does not serve sync streams
+	RelayRequests      bool // request chunks of peers that can not be connected to through relaying peers
+	SyncWithinRadius   bool // pull-sync only bins holding chunks within the storage radius, relying on push-sync for the rest
+	SyncMinBatchSize   uint // lower bound of the pull-sync batch size adapted to peers, 0 for the default
+	SyncMaxBatchSize   uint // upper bound of the pull-sync batch size adapted to peers, 0 for the default
+	SyncMaxInFlight    int  // pull-sync history batches requested from a peer on a stream at a time, 0 for the default
 	BootnodeMode       bool
 	DisableAutoConnect bool
 	EnablePinning      bool
+	TagsRetention      time.Duration // time completed upload tags are kept for, 0 keeps them
 	Cors               string
 	BzzAccount         string
 	GlobalStoreAPI     string
@@ -112,6 +122,7 @@ func NewConfig() *Config {
 		SyncEnabled:             true,
 		PushSyncEnabled:         true,
 		EnablePinning:           false,
+		TagsRetention:           24 * time.Hour,
 	}
 }
 
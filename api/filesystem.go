@@ -25,6 +25,7 @@ import (
 	"path"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethersphere/swarm/log"
@@ -44,6 +45,8 @@ func NewFileSystem(api *API) *FileSystem {
 // Upload replicates a local directory as a manifest file and uploads it
 // using FileStore store
 // This function waits the chunks to be stored.
+// The paths matching the ignore rules of the directory, see IgnoreFileName,
+// are excluded, and the mode and modification time of files are preserved.
 // TODO: localpath should point to a manifest
 //
 // DEPRECATED: Use the HTTP API instead
@@ -67,7 +70,20 @@ func (fs *FileSystem) Upload(lpath, index string, toEncrypt bool) (string, error
 	if stat.IsDir() {
 		start = len(localpath)
 		log.Debug(fmt.Sprintf("uploading '%s'", localpath))
+		rules, err := LoadIgnoreRules(localpath)
+		if err != nil {
+			return "", err
+		}
 		err = filepath.Walk(localpath, func(path string, info os.FileInfo, err error) error {
+			if err == nil && path != localpath {
+				if rel, err := filepath.Rel(localpath, path); err == nil && rules.Match(filepath.ToSlash(rel), info.IsDir()) {
+					log.Trace("fs.Upload: ignoring path", "path", rel)
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+			}
 			if (err == nil) && !info.IsDir() {
 				if len(path) <= start {
 					return fmt.Errorf("Path is too short")
@@ -129,6 +145,9 @@ func (fs *FileSystem) Upload(lpath, index string, toEncrypt bool) (string, error
 			if hash != nil {
 				list[i].Hash = hash.Hex()
 			}
+			list[i].Mode = int64(stat.Mode())
+			list[i].Size = stat.Size()
+			list[i].ModTime = stat.ModTime()
 			if err := wait(ctx); err != nil {
 				errors[i] = err
 				return
@@ -210,8 +229,10 @@ func (fs *FileSystem) Download(bzzpath, localpath string) error {
 	}
 
 	type downloadListEntry struct {
-		addr storage.Address
-		path string
+		addr    storage.Address
+		path    string
+		mode    int64
+		modTime time.Time
 	}
 
 	var list []*downloadListEntry
@@ -229,7 +250,7 @@ func (fs *FileSystem) Download(bzzpath, localpath string) error {
 			prevPath = dir
 		}
 		if (mde == nil) && (path != dir+"/") {
-			list = append(list, &downloadListEntry{addr: addr, path: path})
+			list = append(list, &downloadListEntry{addr: addr, path: path, mode: entry.Mode, modTime: entry.ModTime})
 		}
 	})
 	if err != nil {
@@ -249,6 +270,9 @@ func (fs *FileSystem) Download(bzzpath, localpath string) error {
 		go func(i int, entry *downloadListEntry) {
 			defer wg.Done()
 			err := retrieveToFile(quitC, fs.api.fileStore, entry.addr, entry.path)
+			if err == nil {
+				err = RestoreFileMetadata(entry.path, entry.mode, entry.modTime)
+			}
 			if err != nil {
 				select {
 				case errC <- err:
@@ -290,3 +314,17 @@ func retrieveToFile(quitC chan bool, fileStore *storage.FileStore, addr storage.
 	}
 	return f.Close()
 }
+
+// RestoreFileMetadata sets the mode and modification time of the file
+// from its manifest entry, if they are preserved in it
+func RestoreFileMetadata(path string, mode int64, modTime time.Time) error {
+	if mode != 0 {
+		if err := os.Chmod(path, os.FileMode(mode).Perm()); err != nil {
+			return err
+		}
+	}
+	if !modTime.IsZero() {
+		return os.Chtimes(path, modTime, modTime)
+	}
+	return nil
+}
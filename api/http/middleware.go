@@ -1,6 +1,7 @@
 package http
 
 import (
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"runtime/debug"
@@ -14,6 +15,7 @@ import (
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/sctx"
 	"github.com/ethersphere/swarm/spancontext"
+	"github.com/ethersphere/swarm/storage/encryption"
 	"github.com/ethersphere/swarm/storage/pin"
 	"github.com/pborman/uuid"
 )
@@ -53,6 +55,63 @@ func SetRequestHost(h http.Handler) http.Handler {
 	})
 }
 
+// SetChunker is a middleware that injects the name of the chunker
+// uploaded content is split with into the request context
+func SetChunker(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if chunker := r.Header.Get(ChunkerHeaderName); chunker != "" {
+			r = r.WithContext(sctx.SetChunker(r.Context(), chunker))
+			log.Trace("setting chunker", "ruid", GetRUID(r.Context()), "chunker", chunker)
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// SetEncryptionKey is a middleware that injects the master key content
+// is encrypted under or decrypted with into the request context
+func SetEncryptionKey(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if header := r.Header.Get(EncryptionKeyHeaderName); header != "" {
+			key, err := parseEncryptionKey(header)
+			if err != nil {
+				respondError(w, r, fmt.Sprintf("invalid %s header, want %d hex encoded bytes", EncryptionKeyHeaderName, encryption.KeyLength), http.StatusBadRequest)
+				return
+			}
+			r = r.WithContext(sctx.SetEncryptionKey(r.Context(), key))
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// parseEncryptionKey decodes the hex encoded master key from the header value
+func parseEncryptionKey(header string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimPrefix(header, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != encryption.KeyLength {
+		return nil, fmt.Errorf("got %d bytes, want %d", len(key), encryption.KeyLength)
+	}
+	return key, nil
+}
+
+// redactHeader returns a copy of the request header without the
+// values of the headers with encryption keys, to be safely logged
+func redactHeader(header http.Header) http.Header {
+	redacted := make(http.Header, len(header))
+	for name, values := range header {
+		redacted[name] = values
+	}
+	for _, name := range []string{EncryptionKeyHeaderName, NewEncryptionKeyHeaderName} {
+		if _, ok := redacted[http.CanonicalHeaderKey(name)]; ok {
+			redacted[http.CanonicalHeaderKey(name)] = []string{"<redacted>"}
+		}
+	}
+	return redacted
+}
+
 // ParseURI is a middleware that parses the request URI
 // to a Swarm URI object that dissects the content presented after the HTTP URI's first slash
 func ParseURI(h http.Handler) http.Handler {
@@ -191,7 +250,7 @@ func RecoverPanic(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Error("panic recovery!", "stack trace", string(debug.Stack()), "url", r.URL.String(), "headers", r.Header)
+				log.Error("panic recovery!", "stack trace", string(debug.Stack()), "url", r.URL.String(), "headers", redactHeader(r.Header))
 			}
 		}()
 		h.ServeHTTP(w, r)
@@ -45,6 +45,7 @@ import (
 	"github.com/ethersphere/swarm/sctx"
 	"github.com/ethersphere/swarm/spancontext"
 	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/encryption"
 	"github.com/ethersphere/swarm/storage/feed"
 	"github.com/ethersphere/swarm/storage/pin"
 	"github.com/rs/cors"
@@ -79,6 +80,10 @@ const (
 	TagHeaderName       = "x-swarm-tag"       // Presence of this in header indicates the tag
 	AnonymousHeaderName = "x-swarm-anonymous" // Presence of this in header indicates only pull sync should be used for upload
 	PinHeaderName       = "x-swarm-pin"       // Presence of this in header indicates pinning required
+	ChunkerHeaderName   = "x-swarm-chunker"   // Name of the chunker uploaded content is split with, storage.ChunkerPyramid by default
+
+	EncryptionKeyHeaderName    = "x-swarm-encryption-key"     // Hex encoded master key content is encrypted under on upload and decrypted with on download
+	NewEncryptionKeyHeaderName = "x-swarm-new-encryption-key" // Hex encoded master key content is re-encrypted under on a raw POST request to its address
 
 	encryptAddr    = "encrypt"
 	tarContentType = "application/x-tar"
@@ -116,6 +121,7 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 		InitLoggingResponseWriter,
 		ParseURI,
 		InstrumentOpenTracing,
+		SetEncryptionKey,
 	}
 
 	tagAdapter := Adapter(func(h http.Handler) http.Handler {
@@ -128,7 +134,7 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 		})
 	}
 
-	defaultPostMiddlewares := append(defaultMiddlewares, tagAdapter)
+	defaultPostMiddlewares := append(defaultMiddlewares, tagAdapter, SetChunker)
 
 	mux := http.NewServeMux()
 	mux.Handle("/bzz:/", methodHandler{
@@ -287,7 +293,9 @@ func (s *Server) HandleRootPaths(w http.ResponseWriter, r *http.Request) {
 }
 
 // HandlePostRaw handles a POST request to a raw bzz-raw:/ URI, stores the request
-// body in swarm and returns the resulting storage address as a text/plain response
+// body in swarm and returns the resulting storage address as a text/plain response.
+// A POST request to bzz-raw:/<key> with the NewEncryptionKeyHeaderName header
+// re-encrypts the content stored at the key, see handleReencrypt.
 func (s *Server) HandlePostRaw(w http.ResponseWriter, r *http.Request) {
 	ruid := GetRUID(r.Context())
 	log.Debug("handle.post.raw", "ruid", ruid)
@@ -315,6 +323,11 @@ func (s *Server) HandlePostRaw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if header := r.Header.Get(NewEncryptionKeyHeaderName); header != "" {
+		s.handleReencrypt(w, r, header)
+		return
+	}
+
 	if uri.Addr != "" && uri.Addr != encryptAddr {
 		postRawFail.Inc(1)
 		respondError(w, r, "raw POST request addr can only be empty or \"encrypt\"", http.StatusBadRequest)
@@ -358,6 +371,44 @@ func (s *Server) HandlePostRaw(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, addr)
 }
 
+// handleReencrypt stores the content at the key of the request URI encrypted
+// under the master key from the NewEncryptionKeyHeaderName header, so that it
+// can be shared without the key it is encrypted under, and returns the address
+// of the re-encrypted content as a text/plain response. The content is decrypted
+// with the master key from the EncryptionKeyHeaderName header if it is set.
+func (s *Server) handleReencrypt(w http.ResponseWriter, r *http.Request, header string) {
+	ruid := GetRUID(r.Context())
+	uri := GetURI(r.Context())
+
+	key, err := parseEncryptionKey(header)
+	if err != nil {
+		postRawFail.Inc(1)
+		respondError(w, r, fmt.Sprintf("invalid %s header, want %d hex encoded bytes", NewEncryptionKeyHeaderName, encryption.KeyLength), http.StatusBadRequest)
+		return
+	}
+
+	addr, err := s.api.Resolve(r.Context(), uri.Addr)
+	if err != nil {
+		postRawFail.Inc(1)
+		respondError(w, r, fmt.Sprintf("cannot resolve %s: %s", uri.Addr, err), http.StatusNotFound)
+		return
+	}
+
+	newAddr, wait, err := s.api.Reencrypt(r.Context(), addr, key)
+	if err != nil {
+		postRawFail.Inc(1)
+		respondError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	wait(r.Context())
+
+	log.Debug("reencrypted content", "ruid", ruid, "key", addr, "new key", newAddr)
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, newAddr)
+}
+
 // HandlePostFiles handles a POST request to
 // bzz:/<hash>/<path> which contains either a single file or multiple files
 // (either a tar archive or multipart form), adds those files either to an
@@ -821,7 +872,7 @@ func (s *Server) HandleGet(w http.ResponseWriter, r *http.Request) {
 			fileName = found
 		}
 		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", fileName))
-		http.ServeContent(w, r, fileName, time.Now(), langos.NewBufferedReadSeeker(reader, getFileBufferSize))
+		http.ServeContent(w, r, fileName, time.Now(), newFileReadSeeker(reader))
 
 	case uri.Hash():
 		w.Header().Set("Content-Type", "text/plain")
@@ -833,7 +884,12 @@ func (s *Server) HandleGet(w http.ResponseWriter, r *http.Request) {
 
 // HandleGetList handles a GET request to bzz-list:/<manifest>/<path> and returns
 // a list of all files contained in <manifest> under <path> grouped into
-// common prefixes using "/" as a delimiter
+// common prefixes using "/" as a delimiter.
+// The list is limited to the number of entries and common prefixes in the
+// limit query parameter, and the continuation in the response is passed in
+// the continuation query parameter to get the next page. With the recursive=1
+// query parameter all files under <path> are listed without grouping, and
+// JSON responses are streamed.
 func (s *Server) HandleGetList(w http.ResponseWriter, r *http.Request) {
 	ruid := GetRUID(r.Context())
 	uri := GetURI(r.Context())
@@ -855,8 +911,22 @@ func (s *Server) HandleGetList(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Debug("handle.get.list: resolved", "ruid", ruid, "key", addr)
 
-	list, err := s.api.GetManifestList(r.Context(), s.api.Decryptor(r.Context(), credentials), addr, uri.Path)
-	if err != nil {
+	opts := api.ManifestListOptions{
+		Prefix:       uri.Path,
+		Continuation: r.URL.Query().Get("continuation"),
+		Recursive:    r.URL.Query().Get("recursive") == "1",
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		opts.Limit, err = strconv.Atoi(limit)
+		if err != nil || opts.Limit < 0 {
+			getListFail.Inc(1)
+			respondError(w, r, fmt.Sprintf("invalid limit: %s", limit), http.StatusBadRequest)
+			return
+		}
+	}
+	decryptor := s.api.Decryptor(r.Context(), credentials)
+
+	respondListError := func(err error) {
 		getListFail.Inc(1)
 		if isDecryptError(err) {
 			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", addr.String()))
@@ -864,12 +934,29 @@ func (s *Server) HandleGetList(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		respondError(w, r, err.Error(), http.StatusInternalServerError)
+	}
+
+	isHTML := strings.Contains(r.Header.Get("Accept"), "text/html")
+	if opts.Recursive && !isHTML {
+		s.streamList(w, r, addr, decryptor, opts, respondListError)
+		return
+	}
+
+	list, err := s.api.GetManifestListPage(r.Context(), decryptor, addr, opts)
+	if err != nil {
+		respondListError(err)
 		return
 	}
 
 	// if the client wants HTML (e.g. a browser) then render the list as a
 	// HTML index with relative URLs
-	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+	if isHTML {
+		var next string
+		if list.Continuation != "" {
+			query := r.URL.Query()
+			query.Set("continuation", list.Continuation)
+			next = "?" + query.Encode()
+		}
 		w.Header().Set("Content-Type", "text/html")
 		err := TemplatesMap["bzz-list"].Execute(w, &htmlListData{
 			URI: &api.URI{
@@ -878,6 +965,7 @@ func (s *Server) HandleGetList(w http.ResponseWriter, r *http.Request) {
 				Path:   uri.Path,
 			},
 			List: &list,
+			Next: next,
 		})
 		if err != nil {
 			getListFail.Inc(1)
@@ -890,6 +978,46 @@ func (s *Server) HandleGetList(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(&list)
 }
 
+// streamList writes the entries of the manifest listed with the options
+// as a JSON encoded api.ManifestList while they are walked, so that long
+// lists are not kept in memory. Errors after the first entry is written
+// can not be responded with and end the response.
+func (s *Server) streamList(w http.ResponseWriter, r *http.Request, addr storage.Address, decryptor api.DecryptFunc, opts api.ManifestListOptions, respondListError func(error)) {
+	var started bool
+	start := func() {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"entries":[`)
+		started = true
+	}
+	enc := json.NewEncoder(w)
+	continuation, err := s.api.WalkManifestList(r.Context(), decryptor, addr, opts, func(entry *api.ManifestEntry, _ string) error {
+		if !started {
+			start()
+		} else if _, err := io.WriteString(w, ","); err != nil {
+			return err
+		}
+		return enc.Encode(entry)
+	})
+	if err != nil {
+		if !started {
+			respondListError(err)
+			return
+		}
+		getListFail.Inc(1)
+		log.Error("error streaming list", "ruid", GetRUID(r.Context()), "err", err)
+		return
+	}
+	if !started {
+		start()
+	}
+	io.WriteString(w, "]")
+	if continuation != "" {
+		c, _ := json.Marshal(continuation)
+		fmt.Fprintf(w, `,"continuation":%s`, c)
+	}
+	io.WriteString(w, "}")
+}
+
 // HandleGetFile handles a GET request to bzz://<manifest>/<path> and responds
 // with the content of the file at <path> from the given <manifest>
 func (s *Server) HandleGetFile(w http.ResponseWriter, r *http.Request) {
@@ -988,7 +1116,7 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", fileName))
 
-	http.ServeContent(w, r, fileName, time.Now(), langos.NewBufferedReadSeeker(reader, getFileBufferSize))
+	http.ServeContent(w, r, fileName, time.Now(), newFileReadSeeker(reader))
 }
 
 // HandleGetTag responds to the following request
@@ -1051,7 +1179,10 @@ func (s *Server) HandleGetTag(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// HandlePin takes a root hash as argument and pins a given file or collection in the local Swarm DB
+// HandlePin takes a root hash as argument and pins a given file or collection in the local Swarm DB.
+// With the recursive=true query parameter, or by default, the root hash is of a manifest and the
+// whole manifest tree with all its files is pinned. With recursive=false, or the legacy raw=true,
+// only the file with the root hash is pinned.
 func (s *Server) HandlePin(w http.ResponseWriter, r *http.Request) {
 	postPinCount.Inc(1)
 	ruid := GetRUID(r.Context())
@@ -1070,11 +1201,24 @@ func (s *Server) HandlePin(w http.ResponseWriter, r *http.Request) {
 	if strings.ToLower(isRawString) == "true" {
 		isRaw = true
 	}
+	if recursiveString := r.URL.Query().Get("recursive"); recursiveString != "" {
+		recursive, err := strconv.ParseBool(recursiveString)
+		if err != nil {
+			postPinFail.Inc(1)
+			respondError(w, r, fmt.Sprintf("invalid recursive flag: %s", recursiveString), http.StatusBadRequest)
+			return
+		}
+		isRaw = !recursive
+	}
 
 	err := s.pinAPI.PinFiles(fileAddr, isRaw, "")
 	if err != nil {
 		postPinFail.Inc(1)
-		respondError(w, r, fmt.Sprintf("error pinning file %s: %s", fileAddr.Hex(), err), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if err == pin.ErrContentNotFound {
+			status = http.StatusNotFound
+		}
+		respondError(w, r, fmt.Sprintf("error pinning file %s: %s", fileAddr.Hex(), err), status)
 		return
 	}
 
@@ -1100,7 +1244,11 @@ func (s *Server) HandleUnpin(w http.ResponseWriter, r *http.Request) {
 	err := s.pinAPI.UnpinFiles(fileAddr, "")
 	if err != nil {
 		deletePinFail.Inc(1)
-		respondError(w, r, fmt.Sprintf("error pinning file %s: %s", fileAddr.Hex(), err), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if err == pin.ErrNotPinned {
+			status = http.StatusNotFound
+		}
+		respondError(w, r, fmt.Sprintf("error unpinning file %s: %s", fileAddr.Hex(), err), status)
 		return
 	}
 
@@ -1109,7 +1257,8 @@ func (s *Server) HandleUnpin(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// HandleGetPins return information about all the hashes pinned at this moment
+// HandleGetPins return information about all the hashes pinned at this moment,
+// with the size of the pinned file or the total size of the files in the pinned collection
 func (s *Server) HandleGetPins(w http.ResponseWriter, r *http.Request) {
 	getPinCount.Inc(1)
 	ruid := GetRUID(r.Context())
@@ -1155,6 +1304,21 @@ func calculateNumberOfChunks(contentLength int64, isEncrypted bool) int64 {
 // Recommended value is 4 times the io.Copy default buffer value which is 32kB.
 const getFileBufferSize = 4 * 32 * 1024
 
+// The number of chunks following the data read from LazyChunkReader that are
+// retrieved in the background in HandleGetFile, so that sequential reads of
+// ranges, such as of streamed media, do not wait for their retrieval.
+const getFilePrefetchChunks = 32
+
+// newFileReadSeeker returns the reader passed to http.ServeContent in HandleGetFile,
+// which only reads the requested ranges of the file, reading ahead the data
+// following them with langos and prefetching the chunks after it.
+func newFileReadSeeker(reader storage.LazySectionReader) io.ReadSeeker {
+	if r, ok := reader.(*storage.LazyChunkReader); ok {
+		r.SetPrefetch(getFilePrefetchChunks)
+	}
+	return langos.NewBufferedLangos(reader, getFileBufferSize)
+}
+
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
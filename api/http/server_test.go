@@ -30,11 +30,13 @@ import (
 	"math/big"
 	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -50,6 +52,8 @@ import (
 	"github.com/ethersphere/swarm/storage/feed/lookup"
 	"github.com/ethersphere/swarm/storage/pin"
 	"github.com/ethersphere/swarm/testutil"
+	opentracing "github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
 )
 
 func init() {
@@ -139,6 +143,332 @@ func TestGetTagUsingHash(t *testing.T) {
 
 }
 
+// TestBzzRawRange tests that byte ranges of a file are served on range requests
+func TestBzzRawRange(t *testing.T) {
+	srv := NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+
+	size := 1000000
+	data := testutil.RandomBytes(1, size)
+	resp, err := http.Post(fmt.Sprintf("%s/bzz-raw:/", srv.URL), "text/plain", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("err %s", resp.Status)
+	}
+	rootHash, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		start, end int
+	}{
+		{0, 99},
+		{4000, 9000},
+		{500000, 700000},
+		{size - 10, size - 1},
+	} {
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/bzz-raw:/%s", srv.URL, rootHash), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", tc.start, tc.end))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			t.Fatalf("range %d-%d: got status %s, want %v", tc.start, tc.end, resp.Status, http.StatusPartialContent)
+		}
+		if !bytes.Equal(got, data[tc.start:tc.end+1]) {
+			t.Fatalf("range %d-%d: got %d bytes of different data", tc.start, tc.end, len(got))
+		}
+	}
+}
+
+// TestBzzRawEncryptionKey tests that content uploaded with a master encryption
+// key is only downloaded with it
+func TestBzzRawEncryptionKey(t *testing.T) {
+	srv := NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+
+	key := hex.EncodeToString(testutil.RandomBytes(2, 32))
+	data := testutil.RandomBytes(1, 10000)
+	req, err := http.NewRequest("POST", srv.URL+"/bzz-raw:/", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(EncryptionKeyHeaderName, key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("err %s", resp.Status)
+	}
+	rootHash, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(key string) []byte {
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/bzz-raw:/%s", srv.URL, rootHash), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != "" {
+			req.Header.Set(EncryptionKeyHeaderName, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		got, _ := ioutil.ReadAll(resp.Body)
+		return got
+	}
+	if got := get(key); !bytes.Equal(got, data) {
+		t.Fatal("content not downloaded with the encryption key")
+	}
+	if got := get(""); bytes.Equal(got, data) {
+		t.Fatal("content downloaded without the encryption key")
+	}
+}
+
+// TestBzzRawReencrypt tests that content re-encrypted under a new master key
+// is only downloaded with the new key from the returned address
+func TestBzzRawReencrypt(t *testing.T) {
+	srv := NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+
+	key := hex.EncodeToString(testutil.RandomBytes(1, 32))
+	newKey := hex.EncodeToString(testutil.RandomBytes(2, 32))
+	data := testutil.RandomBytes(3, 10000)
+
+	post := func(url string, body []byte, headers map[string]string) (string, int) {
+		req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		got, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(got), resp.StatusCode
+	}
+	get := func(addr, key string) []byte {
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/bzz-raw:/%s", srv.URL, addr), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(EncryptionKeyHeaderName, key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		got, _ := ioutil.ReadAll(resp.Body)
+		return got
+	}
+
+	addr, code := post(srv.URL+"/bzz-raw:/", data, map[string]string{EncryptionKeyHeaderName: key})
+	if code != http.StatusOK {
+		t.Fatalf("upload: got status %v, want %v", code, http.StatusOK)
+	}
+
+	newAddr, code := post(fmt.Sprintf("%s/bzz-raw:/%s", srv.URL, addr), nil, map[string]string{
+		EncryptionKeyHeaderName:    key,
+		NewEncryptionKeyHeaderName: newKey,
+	})
+	if code != http.StatusOK {
+		t.Fatalf("reencrypt: got status %v, want %v", code, http.StatusOK)
+	}
+	if newAddr == addr {
+		t.Fatal("reencrypted content has the same address")
+	}
+	if got := get(newAddr, newKey); !bytes.Equal(got, data) {
+		t.Fatal("reencrypted content not downloaded with the new encryption key")
+	}
+	if got := get(newAddr, key); bytes.Equal(got, data) {
+		t.Fatal("reencrypted content downloaded with the old encryption key")
+	}
+
+	_, code = post(fmt.Sprintf("%s/bzz-raw:/%s", srv.URL, addr), nil, map[string]string{
+		NewEncryptionKeyHeaderName: newKey[:16],
+	})
+	if code != http.StatusBadRequest {
+		t.Fatalf("invalid key: got status %v, want %v", code, http.StatusBadRequest)
+	}
+}
+
+// TestEncryptionKeyHeaderNotRecorded tests that the values of the headers
+// with encryption keys are not recorded by request logging and tracing
+func TestEncryptionKeyHeaderNotRecorded(t *testing.T) {
+	logs := new(syncBuffer)
+	handler := log.Root().GetHandler()
+	log.Root().SetHandler(log.StreamHandler(logs, log.LogfmtFormat()))
+	defer log.Root().SetHandler(handler)
+
+	tracer := &recordingTracer{spans: new(syncBuffer)}
+	globalTracer := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(globalTracer)
+
+	srv := NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+
+	key := hex.EncodeToString(testutil.RandomBytes(1, 32))
+	newKey := hex.EncodeToString(testutil.RandomBytes(2, 32))
+	setKeys := func(req *http.Request) {
+		req.Header.Set(EncryptionKeyHeaderName, key)
+		req.Header.Set(NewEncryptionKeyHeaderName, newKey)
+	}
+
+	req, err := http.NewRequest("POST", srv.URL+"/bzz-raw:/", bytes.NewReader(testutil.RandomBytes(3, 10000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(EncryptionKeyHeaderName, key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []string{"GET", "POST"} {
+		req, err := http.NewRequest(method, fmt.Sprintf("%s/bzz-raw:/%s", srv.URL, addr), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setKeys(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	// the headers are logged on panic recovery
+	panicHandler := Adapt(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("test")
+		}),
+		RecoverPanic,
+		SetRequestID,
+		InitLoggingResponseWriter,
+		ParseURI,
+		InstrumentOpenTracing,
+		SetEncryptionKey,
+	)
+	req = httptest.NewRequest("GET", "/bzz-raw:/"+string(addr), nil)
+	setKeys(req)
+	panicHandler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.Contains(logs.String(), "panic recovery") {
+		t.Fatal("panic recovery not logged")
+	}
+	if !strings.Contains(tracer.spans.String(), "http.POST.bzz-raw") {
+		t.Fatal("requests not traced")
+	}
+	for _, k := range []string{key, newKey} {
+		if strings.Contains(logs.String(), k) {
+			t.Errorf("encryption key %s logged", k)
+		}
+		if strings.Contains(tracer.spans.String(), k) {
+			t.Errorf("encryption key %s traced", k)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// recordingTracer is an opentracing.Tracer that writes the operation
+// names, tags, logs and baggage items of the spans to a buffer
+type recordingTracer struct {
+	opentracing.NoopTracer
+	spans *syncBuffer
+}
+
+func (t *recordingTracer) record(v ...interface{}) {
+	fmt.Fprintln(t.spans, v...)
+}
+
+func (t *recordingTracer) StartSpan(name string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	var o opentracing.StartSpanOptions
+	for _, opt := range opts {
+		opt.Apply(&o)
+	}
+	t.record(name, o.Tags)
+	return &recordingSpan{Span: t.NoopTracer.StartSpan(name), tracer: t}
+}
+
+type recordingSpan struct {
+	opentracing.Span
+	tracer *recordingTracer
+}
+
+func (s *recordingSpan) SetOperationName(name string) opentracing.Span {
+	s.tracer.record(name)
+	return s
+}
+
+func (s *recordingSpan) SetTag(key string, value interface{}) opentracing.Span {
+	s.tracer.record(key, value)
+	return s
+}
+
+func (s *recordingSpan) LogFields(fields ...otlog.Field) {
+	for _, f := range fields {
+		s.tracer.record(f)
+	}
+}
+
+func (s *recordingSpan) LogKV(kv ...interface{}) {
+	s.tracer.record(kv...)
+}
+
+func (s *recordingSpan) SetBaggageItem(key, value string) opentracing.Span {
+	s.tracer.record(key, value)
+	return s
+}
+
 // TestGetTag uploads a file, retrieves the tag using http GET and check if it matches
 func TestGetTagUsingTagId(t *testing.T) {
 	srv := NewTestSwarmServer(t, serverFunc, nil, nil)
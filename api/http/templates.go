@@ -28,6 +28,7 @@ import (
 type htmlListData struct {
 	URI  *api.URI
 	List *api.ManifestList
+	Next string // relative URL of the next page of the list, if any
 }
 
 var TemplatesMap = make(map[string]*template.Template)
@@ -102,6 +103,9 @@ const bzzList = `{{ define "content" }}
 		</tr>
 		{{ end }}
 </table>
+{{ if .Next }}
+<a class="normal-link" href="{{ .Next }}">Next page</a>
+{{ end }}
 <hr>
 
  {{ end }}`
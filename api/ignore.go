@@ -0,0 +1,140 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFileName is the name of the file in the root of an uploaded directory
+// with the patterns of the paths excluded from the upload
+const IgnoreFileName = ".swarmignore"
+
+// MaxIgnoreFileSize is the maximal size in bytes of the ignore file in an
+// uploaded tar stream, as it is read into memory
+const MaxIgnoreFileSize = 1024 * 1024
+
+// IgnoreRules matches paths against .gitignore style patterns, one per line:
+//   - blank lines and lines starting with # are skipped
+//   - patterns are matched with path.Match
+//   - patterns with a slash other than a trailing one are matched against
+//     the path from the root, the others against the name at any level
+//   - patterns ending with a slash only match directories
+//   - patterns starting with ! include the paths excluded by previous patterns,
+//     unless a parent directory of the path is excluded
+//
+// The paths under an excluded directory are excluded.
+type IgnoreRules struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	pattern  string
+	negate   bool // include matching paths
+	dirOnly  bool // only match directories
+	anchored bool // match the path from the root
+}
+
+// ParseIgnoreRules parses the patterns of the ignore rules from the reader.
+func ParseIgnoreRules(r io.Reader) (*IgnoreRules, error) {
+	rules := &IgnoreRules{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var p ignorePattern
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			p.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %v", line, err)
+		}
+		p.pattern = line
+		rules.patterns = append(rules.patterns, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// LoadIgnoreRules parses the ignore rules from the ignore file in the directory.
+// It returns nil rules, which match no paths, if there is no ignore file.
+func LoadIgnoreRules(dir string) (*IgnoreRules, error) {
+	f, err := os.Open(filepath.Join(dir, IgnoreFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseIgnoreRules(f)
+}
+
+// Match returns whether the slash separated path relative to the root
+// is excluded, isDir telling whether it is a directory.
+func (r *IgnoreRules) Match(name string, isDir bool) bool {
+	if r == nil {
+		return false
+	}
+	elems := strings.Split(strings.Trim(path.Clean("/"+name), "/"), "/")
+	for i := range elems {
+		if r.match(elems[:i+1], i < len(elems)-1 || isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns whether the path of the elements is excluded
+// by the last pattern matching it, regardless of its parents
+func (r *IgnoreRules) match(elems []string, isDir bool) (ignored bool) {
+	for _, p := range r.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		name := elems[len(elems)-1]
+		if p.anchored {
+			name = strings.Join(elems, "/")
+		}
+		if ok, _ := path.Match(p.pattern, name); ok {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	rules, err := ParseIgnoreRules(strings.NewReader(`
+# build artifacts
+*.o
+build/
+/dist
+docs/*.tmp
+*.log
+!keep.log
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"main.go", false, false},
+		{"main.o", false, true},
+		{"src/lib/util.o", false, true},
+		{"build", true, true},
+		{"build", false, false},
+		{"build/out.bin", false, true},
+		{"src/build/out.bin", false, true},
+		{"dist/app.js", false, true},
+		{"src/dist/app.js", false, false},
+		{"docs/a.tmp", false, true},
+		{"src/docs/a.tmp", false, false},
+		{"error.log", false, true},
+		{"keep.log", false, false},
+		{"build/keep.log", false, true},
+		{"./main.o", false, true},
+	} {
+		if got := rules.Match(tc.path, tc.isDir); got != tc.want {
+			t.Errorf("%s (dir %v): got %v, want %v", tc.path, tc.isDir, got, tc.want)
+		}
+	}
+
+	var none *IgnoreRules
+	if none.Match("main.o", false) {
+		t.Error("nil rules match path")
+	}
+
+	if _, err := ParseIgnoreRules(strings.NewReader("[")); err == nil {
+		t.Error("no error parsing invalid pattern")
+	}
+}
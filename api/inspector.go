@@ -56,6 +56,12 @@ func (i *Inspector) KademliaInfo() network.KademliaInfo {
 	return i.hive.KademliaInfo()
 }
 
+// KademliaStatus returns the structured connectivity status of the Kademlia
+// for monitoring, including bins, depth and saturation
+func (i *Inspector) KademliaStatus() network.KademliaStatus {
+	return i.hive.KademliaStatus()
+}
+
 func (i *Inspector) IsPushSynced(tagname string) bool {
 	tags := i.api.Tags.All()
 
@@ -101,12 +107,13 @@ func (i *Inspector) DeliveriesPerPeer() map[string]int64 {
 // the chunk stored with the given address (true), or not (false)
 func (i *Inspector) Has(chunkAddresses []storage.Address) string {
 	hostChunks := []string{}
-	for _, addr := range chunkAddresses {
-		has, err := i.netStore.Has(context.Background(), addr)
-		if err != nil {
-			log.Error(err.Error())
-		}
-		if has {
+	has, err := i.netStore.HasMulti(context.Background(), chunkAddresses...)
+	if err != nil {
+		log.Error(err.Error())
+		has = make([]bool, len(chunkAddresses))
+	}
+	for _, h := range has {
+		if h {
 			hostChunks = append(hostChunks, "1")
 		} else {
 			hostChunks = append(hostChunks, "0")
@@ -131,3 +138,10 @@ func (i *Inspector) PeerStreams() (string, error) {
 func (i *Inspector) StorageIndices() (map[string]int, error) {
 	return i.ls.DebugIndices()
 }
+
+// Localstore returns index sizes, garbage collection state,
+// per-bin chunk counts and the most recent garbage collection
+// rounds of the local chunk store.
+func (i *Inspector) Localstore() (*localstore.Inspection, error) {
+	return i.ls.Inspect()
+}
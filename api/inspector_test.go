@@ -1,12 +1,15 @@
 package api
 
 import (
+	"context"
 	"crypto/rand"
 	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
 
+	"github.com/ethersphere/swarm/chunk"
+	chunktesting "github.com/ethersphere/swarm/chunk/testing"
 	"github.com/ethersphere/swarm/network"
 	"github.com/ethersphere/swarm/network/stream"
 	"github.com/ethersphere/swarm/storage"
@@ -107,3 +110,51 @@ func TestInspectorStorageIndices(t *testing.T) {
 		t.Fatalf("expected gcSize to be %d but got %d", 0, indiceInfo["gcSize"])
 	}
 }
+
+// TestInspectorHas validates that response from RPC has function
+// reports presence of every requested chunk in order.
+func TestInspectorHas(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	baseKey := make([]byte, 32)
+	_, err = rand.Read(baseKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// using the same key in for underlay address as well as it is not important for test
+	baseAddress := network.NewBzzAddr(baseKey, baseKey)
+	localStore, err := localstore.New(dir, baseKey, &localstore.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	netStore := storage.NewNetStore(localStore, baseAddress)
+
+	i := NewInspector(nil, nil, netStore, nil, localStore)
+
+	chunks := chunktesting.GenerateTestRandomChunks(3)
+	if _, err := netStore.Put(context.Background(), chunk.ModePutUpload, chunks[0], chunks[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("inspector", i); err != nil {
+		t.Fatal(err)
+	}
+
+	client := rpc.DialInProc(server)
+
+	var has string
+
+	err = client.Call(&has, "inspector_has", []storage.Address{chunks[0].Address(), chunks[1].Address(), chunks[2].Address()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has != "101" {
+		t.Errorf("got %q, want %q", has, "101")
+	}
+}
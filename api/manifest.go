@@ -25,6 +25,8 @@ import (
 
 	"io"
 	"net/http"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -63,6 +65,15 @@ type ManifestEntry struct {
 type ManifestList struct {
 	CommonPrefixes []string         `json:"common_prefixes,omitempty"`
 	Entries        []*ManifestEntry `json:"entries,omitempty"`
+	Continuation   string           `json:"continuation,omitempty"` // path the next page starts at, if the list is limited
+}
+
+// ManifestListOptions are the options of listing files in a manifest
+type ManifestListOptions struct {
+	Prefix       string // only list the paths with the prefix
+	Continuation string // only list the paths from the continuation of a previous page
+	Limit        int    // maximal number of entries and common prefixes, unlimited if 0
+	Recursive    bool   // list all entries with the prefix instead of the common prefixes of paths with a slash after it
 }
 
 // NewManifest creates and stores a new, empty manifest
@@ -151,6 +162,134 @@ func (m *ManifestWriter) Store() (storage.Address, error) {
 	return m.trie.ref, m.trie.recalcAndStore()
 }
 
+// ManifestChange is a change of the entry at the path of a manifest,
+// the entry is removed if Entry is nil
+type ManifestChange struct {
+	Path  string         `json:"path"`
+	Entry *ManifestEntry `json:"entry,omitempty"`
+}
+
+// ManifestChanges is a list of changes of a manifest, which are applied with
+// API.UpdateManifest, storing only the submanifests with changed entries:
+//
+//	addr, err := api.UpdateManifest(ctx, addr, changes.Apply)
+type ManifestChanges []ManifestChange
+
+// Apply adds and removes the entries of the changes with the manifest writer.
+// The content of the added entries is expected to be stored already.
+func (c ManifestChanges) Apply(mw *ManifestWriter) error {
+	for _, change := range c {
+		if change.Entry == nil {
+			if err := mw.RemoveEntry(change.Path); err != nil {
+				return err
+			}
+			continue
+		}
+		entry := *change.Entry
+		entry.Path = change.Path
+		if _, err := mw.AddEntry(context.TODO(), nil, &entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DiffManifests returns the changes, ordered by path, which turn the manifest
+// at address from into the manifest at address to. Submanifests with the same
+// hash in both manifests are not retrieved.
+func (a *API) DiffManifests(ctx context.Context, from, to storage.Address) (ManifestChanges, error) {
+	fromTrie, err := loadManifest(ctx, a.fileStore, from, nil, NOOPDecrypt)
+	if err != nil {
+		return nil, fmt.Errorf("error loading manifest %s: %s", from, err)
+	}
+	toTrie, err := loadManifest(ctx, a.fileStore, to, nil, NOOPDecrypt)
+	if err != nil {
+		return nil, fmt.Errorf("error loading manifest %s: %s", to, err)
+	}
+	var changes ManifestChanges
+	if err := diffManifestTries(fromTrie, toTrie, "", &changes); err != nil {
+		return nil, err
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// diffManifestTries appends the changes between the tries under the prefix,
+// descending into the submanifests at the same path in both of them and
+// comparing the entries under the other ones by their full path
+func diffManifestTries(from, to *manifestTrie, prefix string, changes *ManifestChanges) error {
+	fromEntries := make(map[string]*ManifestEntry)
+	toEntries := make(map[string]*ManifestEntry)
+	for i := range &from.entries {
+		fromEntry, toEntry := from.entries[i], to.entries[i]
+		if fromEntry != nil && toEntry != nil && fromEntry.Path == toEntry.Path &&
+			fromEntry.ContentType == ManifestType && toEntry.ContentType == ManifestType {
+			if fromEntry.Hash != "" && fromEntry.Hash == toEntry.Hash {
+				continue
+			}
+			if err := from.loadSubTrie(fromEntry, nil); err != nil {
+				return err
+			}
+			if err := to.loadSubTrie(toEntry, nil); err != nil {
+				return err
+			}
+			if err := diffManifestTries(fromEntry.subtrie, toEntry.subtrie, prefix+fromEntry.Path, changes); err != nil {
+				return err
+			}
+			continue
+		}
+		if fromEntry != nil {
+			if err := from.collectEntries(fromEntry, prefix, fromEntries); err != nil {
+				return err
+			}
+		}
+		if toEntry != nil {
+			if err := to.collectEntries(toEntry, prefix, toEntries); err != nil {
+				return err
+			}
+		}
+	}
+	for path, fromEntry := range fromEntries {
+		toEntry, ok := toEntries[path]
+		if !ok {
+			*changes = append(*changes, ManifestChange{Path: path})
+		} else if !reflect.DeepEqual(fromEntry, toEntry) {
+			*changes = append(*changes, ManifestChange{Path: path, Entry: toEntry})
+		}
+	}
+	for path, toEntry := range toEntries {
+		if _, ok := fromEntries[path]; !ok {
+			*changes = append(*changes, ManifestChange{Path: path, Entry: toEntry})
+		}
+	}
+	return nil
+}
+
+// collectEntries adds the entry of the trie and, if it is a submanifest,
+// all entries under it to the entries by their full path
+func (mt *manifestTrie) collectEntries(entry *manifestTrieEntry, prefix string, entries map[string]*ManifestEntry) error {
+	if entry.ContentType != ManifestType {
+		e := entry.ManifestEntry
+		e.Path = prefix + entry.Path
+		entries[e.Path] = &e
+		return nil
+	}
+	if err := mt.loadSubTrie(entry, nil); err != nil {
+		return err
+	}
+	for _, e := range &entry.subtrie.entries {
+		if e == nil {
+			continue
+		}
+		if err := entry.subtrie.collectEntries(e, prefix+entry.Path, entries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ManifestWalker is used to recursively walk the entries in the manifest and
 // all of its submanifests
 type ManifestWalker struct {
@@ -181,6 +320,51 @@ func (m *ManifestWalker) Walk(walkFn WalkFn) error {
 	return m.walk(m.trie, "", walkFn)
 }
 
+// WalkFrom recursively walks the manifest like Walk, but in the order of the
+// paths, starting at the given path, and without modifying the manifest.
+// Submanifests are visited before their entries, and submanifests with
+// only paths before start are neither visited nor retrieved.
+func (m *ManifestWalker) WalkFrom(start string, walkFn WalkFn) error {
+	return m.walkFrom(m.trie, "", start, walkFn)
+}
+
+func (m *ManifestWalker) walkFrom(trie *manifestTrie, prefix, start string, walkFn WalkFn) error {
+	for i := range &trie.entries {
+		// the entry with the empty path precedes the others
+		entry := trie.entries[(i+len(trie.entries)-1)%len(trie.entries)]
+		if entry == nil {
+			continue
+		}
+		e := entry.ManifestEntry
+		e.Path = prefix + entry.Path
+		if entry.ContentType != ManifestType {
+			if e.Path < start {
+				continue
+			}
+			if err := walkFn(&e); err != nil {
+				return err
+			}
+			continue
+		}
+		if e.Path < start && !strings.HasPrefix(start, e.Path) {
+			continue
+		}
+		if err := walkFn(&e); err != nil {
+			if err == ErrSkipManifest {
+				continue
+			}
+			return err
+		}
+		if err := trie.loadSubTrie(entry, m.quitC); err != nil {
+			return err
+		}
+		if err := m.walkFrom(entry.subtrie, e.Path, start, walkFn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *ManifestWalker) walk(trie *manifestTrie, prefix string, walkFn WalkFn) error {
 	for _, entry := range &trie.entries {
 		if entry == nil {
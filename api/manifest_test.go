@@ -18,10 +18,12 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -174,3 +176,59 @@ func TestReadManifestOverSizeLimit(t *testing.T) {
 		t.Fatalf("got error mesage %q, expected %q", got, want)
 	}
 }
+
+// TestDiffManifests tests that the changes between manifests are
+// found and applied to turn one manifest into the other.
+func TestDiffManifests(t *testing.T) {
+	testAPI(t, func(api *API, _ *chunk.Tags, toEncrypt bool) {
+		ctx := context.TODO()
+		addr, err := api.NewManifest(ctx, toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entries ManifestChanges
+		for i, path := range []string{"index.html", "css/a.css", "css/b.css", "img/x.png", "img/y.png"} {
+			entries = append(entries, ManifestChange{Path: path, Entry: &ManifestEntry{Hash: fmt.Sprintf("%064x", i)}})
+		}
+		from, err := api.UpdateManifest(ctx, addr, entries.Apply)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		changes := ManifestChanges{
+			{Path: "css/a.css", Entry: &ManifestEntry{Path: "css/a.css", Hash: fmt.Sprintf("%064x", 10)}},
+			{Path: "img/y.png"},
+			{Path: "js/app.js", Entry: &ManifestEntry{Path: "js/app.js", Hash: fmt.Sprintf("%064x", 11)}},
+		}
+		to, err := api.UpdateManifest(ctx, from, changes.Apply)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		diff, err := api.DiffManifests(ctx, from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(diff, changes) {
+			t.Fatalf("got changes %v, want %v", diff, changes)
+		}
+
+		diff, err = api.DiffManifests(ctx, to, from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reverted, err := api.UpdateManifest(ctx, to, diff.Apply)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff, err = api.DiffManifests(ctx, from, reverted); err != nil {
+			t.Fatal(err)
+		}
+		if len(diff) != 0 {
+			t.Fatalf("got changes %v from reverted manifest", diff)
+		}
+		if !toEncrypt && !bytes.Equal(reverted, from) {
+			t.Fatalf("got reverted manifest %s, want %s", reverted, from)
+		}
+	})
+}
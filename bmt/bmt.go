@@ -82,7 +82,7 @@ type BaseHasherFunc func() hash.Hash
 // - the same hasher instance is synchronously reuseable
 // - Sum gives back the tree to the pool and guaranteed to leave
 //   the tree and itself in a state reusable for hashing a new chunk
-// - generates and verifies segment inclusion proofs (TODO:)
+// - generates and verifies segment inclusion proofs
 type Hasher struct {
 	mtx     sync.Mutex // protects Hasher.size increments (temporary solution)
 	pool    *TreePool  // BMT resource pool
@@ -0,0 +1,102 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package bmt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrProofIndex is returned if the proof is requested for
+// a segment index outside of the BMT.
+var ErrProofIndex = errors.New("segment index out of range")
+
+// Proof is an inclusion proof of a segment of a chunk hashed by the BMT Hasher.
+// Together with the span of the chunk, the sister hashes on the path from
+// the segment to the root of the BMT are enough to recompute the chunk hash.
+type Proof struct {
+	Index   int      // index of the proven segment
+	Segment []byte   // segment data, zero padded to the segment size
+	Sisters [][]byte // sister hashes ordered from the base level to the root
+	Span    []byte   // span of the chunk
+}
+
+// Proof returns an inclusion proof of the segment at index i
+// of the chunk data with the given span.
+func (h *Hasher) Proof(span, data []byte, i int) (*Proof, error) {
+	p := h.pool
+	if len(data) > p.Size {
+		return nil, fmt.Errorf("data length %d exceeds maximum %d", len(data), p.Size)
+	}
+	count := 1 << uint(p.Depth)
+	if i < 0 || i >= count {
+		return nil, ErrProofIndex
+	}
+
+	// data shorter than the BMT is hashed as if it had zero padding
+	padded := make([]byte, count*p.SegmentSize)
+	copy(padded, data)
+	level := make([][]byte, count)
+	for j := range level {
+		level[j] = padded[j*p.SegmentSize : (j+1)*p.SegmentSize]
+	}
+
+	proof := &Proof{
+		Index:   i,
+		Segment: level[i],
+		Span:    make([]byte, len(ZeroSpan)),
+	}
+	copy(proof.Span, span)
+
+	bh := p.hasher()
+	for len(level) > 1 {
+		proof.Sisters = append(proof.Sisters, level[i^1])
+		next := make([][]byte, len(level)/2)
+		for j := range next {
+			next[j] = doSum(bh, nil, level[2*j], level[2*j+1])
+		}
+		level = next
+		i /= 2
+	}
+	return proof, nil
+}
+
+// VerifyProof returns true if the proof is a valid inclusion
+// proof of its segment in the chunk with the given BMT hash.
+func (h *Hasher) VerifyProof(hash []byte, proof *Proof) bool {
+	p := h.pool
+	if len(proof.Segment) != p.SegmentSize || len(proof.Sisters) != p.Depth {
+		return false
+	}
+	if proof.Index < 0 || proof.Index >= 1<<uint(p.Depth) {
+		return false
+	}
+
+	bh := p.hasher()
+	s := proof.Segment
+	i := proof.Index
+	for _, sister := range proof.Sisters {
+		if i%2 == 0 {
+			s = doSum(bh, nil, s, sister)
+		} else {
+			s = doSum(bh, nil, sister, s)
+		}
+		i /= 2
+	}
+	return bytes.Equal(doSum(bh, nil, proof.Span, s), hash)
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package bmt
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	bmttestutil "github.com/ethersphere/swarm/bmt/testutil"
+	"github.com/ethersphere/swarm/testutil"
+	"golang.org/x/crypto/sha3"
+)
+
+// TestProof tests that inclusion proofs of all segments of a chunk
+// verify against its BMT hash and that tampered proofs do not.
+func TestProof(t *testing.T) {
+	hasher := sha3.NewLegacyKeccak256
+	size := hasher().Size()
+	data := testutil.RandomBytes(1, bmttestutil.BufferSize)
+
+	for _, count := range bmttestutil.Counts {
+		for _, n := range []int{1, size + 1, count * size / 2, count * size} {
+			if n == 0 || n > count*size {
+				continue
+			}
+			t.Run(fmt.Sprintf("segments_%v_length_%v", count, n), func(t *testing.T) {
+				pool := NewTreePool(hasher, count, 1)
+				defer pool.Drain(0)
+				bmt := New(pool)
+				d := data[:n]
+				hash := syncHash(bmt, n, d)
+				span := LengthToSpan(n)
+
+				for i := 0; i < 1<<uint(pool.Depth); i++ {
+					proof, err := bmt.Proof(span, d, i)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bmt.VerifyProof(hash, proof) {
+						t.Fatalf("proof of segment %d not valid", i)
+					}
+
+					// swapping equal segments, as in zero padding, keeps the proof valid
+					if !bytes.Equal(proof.Segment, proof.Sisters[0]) {
+						proof.Index ^= 1
+						if bmt.VerifyProof(hash, proof) {
+							t.Fatalf("proof of segment %d valid for wrong index", i)
+						}
+						proof.Index ^= 1
+					}
+
+					proof.Segment = append([]byte{proof.Segment[0] + 1}, proof.Segment[1:]...)
+					if bmt.VerifyProof(hash, proof) {
+						t.Fatalf("proof of segment %d valid for wrong segment", i)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestProofIndex tests that proofs can not be created
+// for segments outside of the BMT.
+func TestProofIndex(t *testing.T) {
+	pool := NewTreePool(sha3.NewLegacyKeccak256, bmttestutil.SegmentCount, 1)
+	defer pool.Drain(0)
+	bmt := New(pool)
+	for _, i := range []int{-1, bmttestutil.SegmentCount} {
+		if _, err := bmt.Proof(ZeroSpan, []byte("foo"), i); err != ErrProofIndex {
+			t.Errorf("segment %d: got error %v, want %v", i, err, ErrProofIndex)
+		}
+	}
+}
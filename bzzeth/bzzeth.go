@@ -132,7 +132,7 @@ func (b *BzzEth) handleNewBlockHeaders(ctx context.Context, p *Peer, msg *NewBlo
 		addresses[i] = h.Hash.Bytes()
 		log.Trace("Received hashes ", "Header", hex.EncodeToString(h.Hash.Bytes()))
 	}
-	yes, err := b.netStore.Store.HasMulti(ctx, addresses...)
+	yes, err := b.netStore.HasMulti(ctx, addresses...)
 	if err != nil {
 		return fmt.Errorf("checking hashesh in store: %w", err)
 	}
@@ -268,47 +268,3 @@ type Store interface {
 	SubscribePull(ctx context.Context, bin uint8, since, until uint64) (c <-chan Descriptor, stop func())
 	Close() (err error)
 }
-
-// Validator validates a chunk.
-type Validator interface {
-	Validate(ch Chunk) bool
-}
-
-// ValidatorStore encapsulates Store by decorating the Put method
-// with validators check.
-type ValidatorStore struct {
-	Store
-	validators []Validator
-}
-
-// NewValidatorStore returns a new ValidatorStore which uses
-// provided validators to validate chunks on Put.
-func NewValidatorStore(store Store, validators ...Validator) (s *ValidatorStore) {
-	return &ValidatorStore{
-		Store:      store,
-		validators: validators,
-	}
-}
-
-// Put overrides Store put method with validators check. For Put to succeed,
-// all provided chunks must be validated with true by one of the validators.
-func (s *ValidatorStore) Put(ctx context.Context, mode ModePut, chs ...Chunk) (exist []bool, err error) {
-	for _, ch := range chs {
-		if !s.validate(ch) {
-			return nil, ErrChunkInvalid
-		}
-	}
-	return s.Store.Put(ctx, mode, chs...)
-}
-
-// validate returns true if one of the validators
-// return true. If all validators return false,
-// the chunk is considered invalid.
-func (s *ValidatorStore) validate(ch Chunk) bool {
-	for _, v := range s.validators {
-		if v.Validate(ch) {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,136 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package chunk
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// SOCIDSize is the length of the single owner chunk id.
+	SOCIDSize = 32
+	// SOCSignatureSize is the length of the owner signature.
+	SOCSignatureSize = 65
+	// SOCHeaderSize is the length of the id and signature
+	// prepended to the data of the wrapped chunk.
+	SOCHeaderSize = SOCIDSize + SOCSignatureSize
+	// MaxSOCWrappedDataSize is the maximal length of the wrapped
+	// chunk data, so that single owner chunks fit in MaxDataSize.
+	MaxSOCWrappedDataSize = MaxDataSize - SOCHeaderSize
+)
+
+// SOC is a single owner chunk. It wraps a content addressed chunk
+// with an id and the signature of the owner over the id and the
+// wrapped chunk address. The address of a single owner chunk depends
+// only on the id and the owner, so that the owner controls which content
+// is stored under it, and it can be retrieved as any other chunk:
+//
+//	address = keccak256(id || owner)
+//	data    = id || signature || wrapped chunk data
+//	signature signs keccak256(id || wrapped chunk address)
+type SOC struct {
+	ID        []byte
+	Owner     []byte // ethereum address of the owner
+	Signature []byte
+	Wrapped   Chunk // wrapped content addressed chunk
+}
+
+// SOCAddress returns the address of the single
+// owner chunk with the given id and owner.
+func SOCAddress(id, owner []byte) Address {
+	return crypto.Keccak256(id, owner)
+}
+
+// NewSOC wraps the content addressed chunk in a single owner
+// chunk with the id, signed with the owner private key.
+func NewSOC(id []byte, wrapped Chunk, key *ecdsa.PrivateKey) (*SOC, error) {
+	if len(id) != SOCIDSize {
+		return nil, fmt.Errorf("invalid id length %d", len(id))
+	}
+	if l := len(wrapped.Data()); l > MaxSOCWrappedDataSize {
+		return nil, fmt.Errorf("wrapped chunk data length %d exceeds maximum %d", l, MaxSOCWrappedDataSize)
+	}
+	sig, err := crypto.Sign(socDigest(id, wrapped.Address()), key)
+	if err != nil {
+		return nil, err
+	}
+	return &SOC{
+		ID:        id,
+		Owner:     crypto.PubkeyToAddress(key.PublicKey).Bytes(),
+		Signature: sig,
+		Wrapped:   wrapped,
+	}, nil
+}
+
+// Address returns the address of the single owner chunk.
+func (s *SOC) Address() Address {
+	return SOCAddress(s.ID, s.Owner)
+}
+
+// Chunk returns the single owner chunk as a Chunk
+// that can be stored and synced as any other chunk.
+func (s *SOC) Chunk() Chunk {
+	data := make([]byte, 0, SOCHeaderSize+len(s.Wrapped.Data()))
+	data = append(data, s.ID...)
+	data = append(data, s.Signature...)
+	data = append(data, s.Wrapped.Data()...)
+	return NewChunk(s.Address(), data)
+}
+
+// ParseSOC parses the single owner chunk from the chunk data and recovers
+// the owner from its signature. The address of the wrapped chunk is computed
+// by the provided function, which is usually the content address hasher
+// and returns nil if the wrapped chunk data is not valid.
+// It returns ErrChunkInvalid if the chunk is not a valid single owner chunk.
+func ParseSOC(ch Chunk, contentAddress func(data []byte) Address) (*SOC, error) {
+	data := ch.Data()
+	if len(data) <= SOCHeaderSize || len(data) > MaxDataSize {
+		return nil, ErrChunkInvalid
+	}
+	id := data[:SOCIDSize]
+	sig := data[SOCIDSize:SOCHeaderSize]
+	addr := contentAddress(data[SOCHeaderSize:])
+	if addr == nil {
+		return nil, ErrChunkInvalid
+	}
+	wrapped := NewChunk(addr, data[SOCHeaderSize:])
+
+	pub, err := crypto.SigToPub(socDigest(id, wrapped.Address()), sig)
+	if err != nil {
+		return nil, ErrChunkInvalid
+	}
+	s := &SOC{
+		ID:        id,
+		Owner:     crypto.PubkeyToAddress(*pub).Bytes(),
+		Signature: sig,
+		Wrapped:   wrapped,
+	}
+	if !bytes.Equal(s.Address(), ch.Address()) {
+		return nil, ErrChunkInvalid
+	}
+	return s, nil
+}
+
+// socDigest returns the digest of the id and the
+// wrapped chunk address signed by the owner.
+func socDigest(id []byte, addr Address) []byte {
+	return crypto.Keccak256(id, addr)
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package chunk
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TestSOC tests that a single owner chunk can be parsed from
+// its chunk data and that tampered chunks are invalid.
+func TestSOC(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// keccak256 stands in for the content address hasher
+	contentAddress := func(data []byte) Address {
+		return crypto.Keccak256(data)
+	}
+	data := []byte("foo")
+	id := make([]byte, SOCIDSize)
+	id[0] = 1
+
+	s, err := NewSOC(id, NewChunk(contentAddress(data), data), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := crypto.PubkeyToAddress(key.PublicKey).Bytes()
+	if !bytes.Equal(s.Address(), SOCAddress(id, owner)) {
+		t.Fatalf("got address %x, want %x", s.Address(), SOCAddress(id, owner))
+	}
+
+	ch := s.Chunk()
+	got, err := ParseSOC(ch, contentAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Owner, owner) {
+		t.Errorf("got owner %x, want %x", got.Owner, owner)
+	}
+	if !bytes.Equal(got.ID, id) {
+		t.Errorf("got id %x, want %x", got.ID, id)
+	}
+	if !bytes.Equal(got.Wrapped.Data(), data) {
+		t.Errorf("got wrapped data %q, want %q", got.Wrapped.Data(), data)
+	}
+
+	// changed wrapped data
+	tampered := append([]byte{}, ch.Data()...)
+	tampered[len(tampered)-1]++
+	if _, err := ParseSOC(NewChunk(ch.Address(), tampered), contentAddress); err != ErrChunkInvalid {
+		t.Errorf("changed data: got error %v, want %v", err, ErrChunkInvalid)
+	}
+
+	// changed id
+	tampered = append([]byte{}, ch.Data()...)
+	tampered[0]++
+	if _, err := ParseSOC(NewChunk(ch.Address(), tampered), contentAddress); err != ErrChunkInvalid {
+		t.Errorf("changed id: got error %v, want %v", err, ErrChunkInvalid)
+	}
+
+	// no wrapped data
+	if _, err := ParseSOC(NewChunk(ch.Address(), ch.Data()[:SOCHeaderSize]), contentAddress); err != ErrChunkInvalid {
+		t.Errorf("no wrapped data: got error %v, want %v", err, ErrChunkInvalid)
+	}
+
+	// too much wrapped data
+	if _, err := NewSOC(id, NewChunk(nil, make([]byte, MaxSOCWrappedDataSize+1)), key); err == nil {
+		t.Error("wrapped data too long: got no error")
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/binary"
 	"errors"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -29,10 +30,11 @@ import (
 )
 
 var (
-	errExists      = errors.New("already exists")
-	errNA          = errors.New("not available yet")
-	errNoETA       = errors.New("unable to calculate ETA")
-	errTagNotFound = errors.New("tag not found")
+	errExists         = errors.New("already exists")
+	errNA             = errors.New("not available yet")
+	errNoETA          = errors.New("unable to calculate ETA")
+	errTagNotFound    = errors.New("tag not found")
+	errBufferTooShort = errors.New("buffer too short")
 )
 
 // State is the enum type for chunk states
@@ -65,6 +67,19 @@ type Tag struct {
 	ctx      context.Context  // tracing context
 	span     opentracing.Span // tracing root span
 	spanOnce sync.Once        // make sure we close root span only once
+
+	receipts   map[string]*Receipt // receipts of synced chunks by chunk address
+	receiptsMu sync.RWMutex
+
+	completedAt int64 // unix time in nanoseconds the tag was found complete, zero if not yet
+}
+
+// Receipt is a statement of custody of a chunk signed by the storer node
+// closest to the chunk, received by the uploader when the chunk is synced
+type Receipt struct {
+	Address   Address // chunk address
+	Storer    []byte  // overlay address of the storer node
+	Signature []byte  // storer signature over the chunk address
 }
 
 // NewTag creates a new tag, and returns it
@@ -136,6 +151,38 @@ func (t *Tag) Get(state State) int64 {
 	return atomic.LoadInt64(v)
 }
 
+// AddReceipt records the receipt of a synced chunk of the tag
+func (t *Tag) AddReceipt(r *Receipt) {
+	t.receiptsMu.Lock()
+	defer t.receiptsMu.Unlock()
+
+	if t.receipts == nil {
+		t.receipts = make(map[string]*Receipt)
+	}
+	t.receipts[r.Address.Hex()] = r
+}
+
+// Receipt returns the receipt recorded for the chunk address,
+// or nil if the chunk is not synced with a receipt
+func (t *Tag) Receipt(addr Address) *Receipt {
+	t.receiptsMu.RLock()
+	defer t.receiptsMu.RUnlock()
+
+	return t.receipts[addr.Hex()]
+}
+
+// Receipts returns all receipts recorded for the chunks of the tag
+func (t *Tag) Receipts() []*Receipt {
+	t.receiptsMu.RLock()
+	defer t.receiptsMu.RUnlock()
+
+	receipts := make([]*Receipt, 0, len(t.receipts))
+	for _, r := range t.receipts {
+		receipts = append(receipts, r)
+	}
+	return receipts
+}
+
 // GetTotal returns the total count
 func (t *Tag) TotalCounter() int64 {
 	return atomic.LoadInt64(&t.Total)
@@ -209,34 +256,102 @@ func (t *Tag) ETA(state State) (time.Time, error) {
 	return t.StartedAt.Add(dur), nil
 }
 
+// Complete returns true if all chunks of the tag reached their final state,
+// synced for tags that are push synced and sent for anonymous tags that
+// are only pull synced
+func (t *Tag) Complete() bool {
+	if t.Anonymous {
+		return t.Done(StateSent)
+	}
+	return t.Done(StateSynced)
+}
+
+// CompletedAt returns the time the tag was first found complete by
+// Tags.DeleteCompleted, or the zero time if it was not
+func (t *Tag) CompletedAt() time.Time {
+	n := atomic.LoadInt64(&t.completedAt)
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// markCompleted records the time now as the completion time of the tag
+// the first time it is found complete and returns the completion time
+func (t *Tag) markCompleted(now time.Time) (completedAt time.Time, ok bool) {
+	if !t.Complete() {
+		return time.Time{}, false
+	}
+	atomic.CompareAndSwapInt64(&t.completedAt, 0, now.UnixNano())
+	return t.CompletedAt(), true
+}
+
+// spanCarrier returns the context of the root span
+// in a format that can be persisted
+func (t *Tag) spanCarrier() opentracing.TextMapCarrier {
+	c := opentracing.TextMapCarrier{}
+	if t.span == nil {
+		return c
+	}
+	if err := opentracing.GlobalTracer().Inject(t.span.Context(), opentracing.TextMap, c); err != nil {
+		return opentracing.TextMapCarrier{}
+	}
+	return c
+}
+
+// restoreSpan starts the root span of a tag loaded from persistence,
+// following from the persisted span context if the tracer can extract it
+func (t *Tag) restoreSpan(c opentracing.TextMapCarrier) {
+	tracer := opentracing.GlobalTracer()
+	var opts []opentracing.StartSpanOption
+	if len(c) > 0 {
+		if sctx, err := tracer.Extract(opentracing.TextMap, c); err == nil {
+			opts = append(opts, opentracing.FollowsFrom(sctx))
+		}
+	}
+	t.span = tracer.StartSpan("new.upload.tag", opts...)
+	t.ctx = spancontext.WithContext(context.Background(), t.span.Context())
+}
+
 // MarshalBinary marshals the tag into a byte slice
 func (tag *Tag) MarshalBinary() (data []byte, err error) {
 	buffer := make([]byte, 4)
 	binary.BigEndian.PutUint32(buffer, tag.Uid)
-	encodeInt64Append(&buffer, tag.Total)
-	encodeInt64Append(&buffer, tag.Split)
-	encodeInt64Append(&buffer, tag.Seen)
-	encodeInt64Append(&buffer, tag.Stored)
-	encodeInt64Append(&buffer, tag.Sent)
-	encodeInt64Append(&buffer, tag.Synced)
-
-	intBuffer := make([]byte, 8)
-
-	n := binary.PutVarint(intBuffer, tag.StartedAt.Unix())
-	buffer = append(buffer, intBuffer[:n]...)
-
-	n = binary.PutVarint(intBuffer, int64(len(tag.Address)))
-	buffer = append(buffer, intBuffer[:n]...)
-	buffer = append(buffer, tag.Address[:]...)
-	buffer = append(buffer, []byte(tag.Name)...)
+	encodeInt64Append(&buffer, atomic.LoadInt64(&tag.Total))
+	for _, state := range []State{StateSplit, StateSeen, StateStored, StateSent, StateSynced} {
+		encodeInt64Append(&buffer, tag.Get(state))
+	}
+	encodeInt64Append(&buffer, tag.StartedAt.UnixNano())
+	encodeInt64Append(&buffer, atomic.LoadInt64(&tag.completedAt))
 
+	var anonymous byte
+	if tag.Anonymous {
+		anonymous = 1
+	}
+	buffer = append(buffer, anonymous)
+	encodeBytesAppend(&buffer, tag.Address)
+	encodeBytesAppend(&buffer, []byte(tag.Name))
+
+	// the root span context is persisted as sorted key value pairs
+	c := tag.spanCarrier()
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	encodeInt64Append(&buffer, int64(len(keys)))
+	for _, k := range keys {
+		encodeBytesAppend(&buffer, []byte(k))
+		encodeBytesAppend(&buffer, []byte(c[k]))
+	}
 	return buffer, nil
 }
 
 // UnmarshalBinary unmarshals a byte slice into a tag
+// and starts its root span following from the persisted one
 func (tag *Tag) UnmarshalBinary(buffer []byte) error {
 	if len(buffer) < 13 {
-		return errors.New("buffer too short")
+		return errBufferTooShort
 	}
 	tag.Uid = binary.BigEndian.Uint32(buffer)
 	buffer = buffer[4:]
@@ -247,23 +362,47 @@ func (tag *Tag) UnmarshalBinary(buffer []byte) error {
 	tag.Stored = decodeInt64Splice(&buffer)
 	tag.Sent = decodeInt64Splice(&buffer)
 	tag.Synced = decodeInt64Splice(&buffer)
+	tag.StartedAt = time.Unix(0, decodeInt64Splice(&buffer))
+	tag.completedAt = decodeInt64Splice(&buffer)
+
+	if len(buffer) == 0 {
+		return errBufferTooShort
+	}
+	tag.Anonymous = buffer[0] == 1
+	buffer = buffer[1:]
 
-	t, n := binary.Varint(buffer)
-	tag.StartedAt = time.Unix(t, 0)
-	buffer = buffer[n:]
+	address, err := decodeBytesSplice(&buffer)
+	if err != nil {
+		return err
+	}
+	if len(address) > 0 {
+		tag.Address = address
+	}
+	name, err := decodeBytesSplice(&buffer)
+	if err != nil {
+		return err
+	}
+	tag.Name = string(name)
 
-	t, n = binary.Varint(buffer)
-	buffer = buffer[n:]
-	if t > 0 {
-		tag.Address = buffer[:t]
+	c := opentracing.TextMapCarrier{}
+	for n := decodeInt64Splice(&buffer); n > 0; n-- {
+		k, err := decodeBytesSplice(&buffer)
+		if err != nil {
+			return err
+		}
+		v, err := decodeBytesSplice(&buffer)
+		if err != nil {
+			return err
+		}
+		c[string(k)] = string(v)
 	}
-	tag.Name = string(buffer[t:])
+	tag.restoreSpan(c)
 
 	return nil
 }
 
 func encodeInt64Append(buffer *[]byte, val int64) {
-	intBuffer := make([]byte, 8)
+	intBuffer := make([]byte, binary.MaxVarintLen64)
 	n := binary.PutVarint(intBuffer, val)
 	*buffer = append(*buffer, intBuffer[:n]...)
 }
@@ -273,3 +412,19 @@ func decodeInt64Splice(buffer *[]byte) int64 {
 	*buffer = (*buffer)[n:]
 	return val
 }
+
+func encodeBytesAppend(buffer *[]byte, val []byte) {
+	encodeInt64Append(buffer, int64(len(val)))
+	*buffer = append(*buffer, val...)
+}
+
+func decodeBytesSplice(buffer *[]byte) ([]byte, error) {
+	l, n := binary.Varint(*buffer)
+	if n <= 0 || l < 0 || int64(len(*buffer)-n) < l {
+		return nil, errBufferTooShort
+	}
+	val := make([]byte, l)
+	copy(val, (*buffer)[n:])
+	*buffer = (*buffer)[n+int(l):]
+	return val, nil
+}
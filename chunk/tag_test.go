@@ -109,6 +109,29 @@ func TestTagETA(t *testing.T) {
 	}
 }
 
+// TestTagReceipts tests that receipts of synced chunks are recorded on the tag
+func TestTagReceipts(t *testing.T) {
+	tg := &Tag{Total: 10}
+
+	addr := Address(bytes.Repeat([]byte{1}, 32))
+	if r := tg.Receipt(addr); r != nil {
+		t.Fatalf("got receipt %v before it was added", r)
+	}
+
+	r := &Receipt{
+		Address:   addr,
+		Storer:    bytes.Repeat([]byte{2}, 32),
+		Signature: []byte{3},
+	}
+	tg.AddReceipt(r)
+	if got := tg.Receipt(addr); got != r {
+		t.Fatalf("got receipt %v, want %v", got, r)
+	}
+	if got := tg.Receipts(); len(got) != 1 || got[0] != r {
+		t.Fatalf("got receipts %v, want [%v]", got, r)
+	}
+}
+
 // TestTagConcurrentIncrements tests Inc calls concurrently
 func TestTagConcurrentIncrements(t *testing.T) {
 	tg := &Tag{}
@@ -159,9 +182,9 @@ func TestTagsMultipleConcurrentIncrementsSyncMap(t *testing.T) {
 	}
 	wg.Wait()
 	i := 0
-	ts.Range(func(k, v interface{}) bool {
+	ts.Range(func(v *Tag) bool {
 		i++
-		uid := k.(uint32)
+		uid := v.Uid
 		for _, f := range allStates {
 			tag, err := ts.Get(uid)
 			if err != nil {
@@ -169,7 +192,7 @@ func TestTagsMultipleConcurrentIncrementsSyncMap(t *testing.T) {
 			}
 			stateVal := tag.Get(f)
 			if stateVal != int64(n) {
-				t.Fatalf("expected tag %v state %v to be %v, got %v", uid, f, n, v)
+				t.Fatalf("expected tag %v state %v to be %v, got %v", uid, f, n, stateVal)
 			}
 		}
 		return true
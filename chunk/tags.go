@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/ethersphere/swarm/sctx"
+	"github.com/ethersphere/swarm/state"
 )
 
 var (
@@ -35,6 +36,31 @@ var (
 	TagNotFoundErr = errors.New("tag not found")
 )
 
+const (
+	// tagKeyPrefix is the prefix of the state store keys of persisted tags
+	tagKeyPrefix = "tags_"
+	// legacyTagsKey is the state store key under which all
+	// tags were persisted together by earlier versions
+	legacyTagsKey = "tags"
+)
+
+// DeletePolicy decides if a tag completed at the time completedAt
+// is deleted at the time now
+type DeletePolicy func(completedAt, now time.Time) bool
+
+// KeepCompleted is a DeletePolicy that keeps all completed tags
+func KeepCompleted(_, _ time.Time) bool {
+	return false
+}
+
+// DeleteCompletedAfter returns a DeletePolicy that deletes
+// the tags completed for longer than the duration d
+func DeleteCompletedAfter(d time.Duration) DeletePolicy {
+	return func(completedAt, now time.Time) bool {
+		return now.Sub(completedAt) >= d
+	}
+}
+
 // Tags hold tag information indexed by a unique random uint32
 type Tags struct {
 	tags *sync.Map
@@ -109,9 +135,12 @@ func (ts *Tags) GetFromContext(ctx context.Context) (*Tag, error) {
 	return t.(*Tag), nil
 }
 
-// Range exposes sync.Map's iterator
-func (ts *Tags) Range(fn func(k, v interface{}) bool) {
-	ts.tags.Range(fn)
+// Range calls fn for all tags in no particular order
+// until fn returns false
+func (ts *Tags) Range(fn func(t *Tag) bool) {
+	ts.tags.Range(func(_, v interface{}) bool {
+		return fn(v.(*Tag))
+	})
 }
 
 func (ts *Tags) Delete(k interface{}) {
@@ -120,13 +149,10 @@ func (ts *Tags) Delete(k interface{}) {
 
 func (ts *Tags) MarshalJSON() (out []byte, err error) {
 	m := make(map[string]*Tag)
-	ts.Range(func(k, v interface{}) bool {
-		key := fmt.Sprintf("%d", k)
-		val := v.(*Tag)
-
+	ts.Range(func(t *Tag) bool {
 		// don't persist tags which were already done
-		if !val.Done(StateSynced) {
-			m[key] = val
+		if !t.Done(StateSynced) {
+			m[fmt.Sprintf("%d", t.Uid)] = t
 		}
 		return true
 	})
@@ -145,12 +171,98 @@ func (ts *Tags) UnmarshalJSON(value []byte) error {
 			return err
 		}
 
-		// prevent a condition where a chunk was sent before shutdown
-		// and the node was turned off before the receipt was received
-		v.Sent = v.Synced
-
-		ts.tags.Store(key, v)
+		v.restoreSpan(nil)
+		ts.restore(uint32(key), v)
 	}
 
 	return err
 }
+
+// restore stores a tag loaded from persistence
+func (ts *Tags) restore(uid uint32, t *Tag) {
+	// prevent a condition where a chunk was sent before shutdown
+	// and the node was turned off before the receipt was received,
+	// the pusher sends it again, anonymous tags are counted sent
+	// by pull sync only once
+	if !t.Anonymous {
+		t.Sent = t.Synced
+	}
+	ts.tags.Store(uid, t)
+}
+
+// Load loads the tags persisted in the store,
+// tags persisted by earlier versions are migrated
+func (ts *Tags) Load(store state.Store) error {
+	if err := store.Iterate(tagKeyPrefix, func(_, value []byte) (stop bool, err error) {
+		t := new(Tag)
+		if err := t.UnmarshalBinary(value); err != nil {
+			return true, err
+		}
+		ts.restore(t.Uid, t)
+		return false, nil
+	}); err != nil {
+		return err
+	}
+
+	legacy := NewTags()
+	switch err := store.Get(legacyTagsKey, legacy); err {
+	case nil:
+	case state.ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+	legacy.Range(func(t *Tag) bool {
+		ts.tags.LoadOrStore(t.Uid, t)
+		return true
+	})
+	if err := ts.Save(store); err != nil {
+		return err
+	}
+	return store.Delete(legacyTagsKey)
+}
+
+// Save persists the tags in the store
+// and deletes the persisted tags that were deleted
+func (ts *Tags) Save(store state.Store) (err error) {
+	batch := new(state.StoreBatch)
+	keys := make(map[string]struct{})
+	ts.Range(func(t *Tag) bool {
+		key := tagKey(t.Uid)
+		if err = batch.Put(key, t); err != nil {
+			return false
+		}
+		keys[key] = struct{}{}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if err := store.Iterate(tagKeyPrefix, func(key, _ []byte) (stop bool, err error) {
+		if _, ok := keys[string(key)]; !ok {
+			batch.Delete(string(key))
+		}
+		return false, nil
+	}); err != nil {
+		return err
+	}
+	return store.WriteBatch(batch)
+}
+
+// DeleteCompleted deletes the completed tags according to the policy and
+// returns the number of deleted tags, the time now is recorded as the time
+// of completion of the tags found complete for the first time
+func (ts *Tags) DeleteCompleted(policy DeletePolicy, now time.Time) (n int) {
+	ts.Range(func(t *Tag) bool {
+		if completedAt, ok := t.markCompleted(now); ok && policy(completedAt, now) {
+			ts.Delete(t.Uid)
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+func tagKey(uid uint32) string {
+	return tagKeyPrefix + strconv.FormatUint(uint64(uid), 10)
+}
@@ -18,6 +18,9 @@ package chunk
 
 import (
 	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/state"
 )
 
 func TestAll(t *testing.T) {
@@ -46,3 +49,163 @@ func TestAll(t *testing.T) {
 		t.Fatalf("expected length to be 3 got %d", len(all))
 	}
 }
+
+// TestTagsPersistence tests that tags are restored from the state store
+// with their counts and timestamps, and that deleted tags are removed
+func TestTagsPersistence(t *testing.T) {
+	store := state.NewInmemoryStore()
+	defer store.Close()
+
+	ts := NewTags()
+	tag, err := ts.Create("upload", 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag.Address = Address{1, 2, 3}
+	tag.IncN(StateSplit, 10)
+	tag.IncN(StateStored, 10)
+	tag.IncN(StateSent, 8)
+	tag.IncN(StateSynced, 5)
+	anon, err := ts.Create("anonymous", 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anon.IncN(StateStored, 3)
+	anon.IncN(StateSent, 3)
+	now := time.Now()
+	if n := ts.DeleteCompleted(KeepCompleted, now); n != 0 {
+		t.Fatalf("got %v tags deleted, want 0", n)
+	}
+	deleted, err := ts.Create("deleted", 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Save(store); err != nil {
+		t.Fatal(err)
+	}
+	ts.Delete(deleted.Uid)
+	if err := ts.Save(store); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewTags()
+	if err := restored.Load(store); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(restored.All()); n != 2 {
+		t.Fatalf("got %v restored tags, want 2", n)
+	}
+	got, err := restored.Get(tag.Uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != tag.Name || got.Anonymous || !got.StartedAt.Equal(tag.StartedAt) || string(got.Address) != string(tag.Address) {
+		t.Fatalf("got restored tag %+v, want %+v", got, tag)
+	}
+	if got.Context() == nil {
+		t.Fatal("restored tag has no tracing context")
+	}
+	// chunks sent but not yet synced are sent again after the restart
+	checkTagCounts(t, got, 10, 10, 0, 5, 5, 10)
+
+	got, err = restored.Get(anon.Uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Anonymous || !got.CompletedAt().Equal(time.Unix(0, now.UnixNano())) {
+		t.Fatalf("got restored anonymous tag %+v completed at %v, want completed at %v", got, got.CompletedAt(), now)
+	}
+	checkTagCounts(t, got, 0, 3, 0, 3, 0, 3)
+}
+
+// TestTagsLoadLegacy tests that tags persisted together by earlier
+// versions are loaded and persisted separately
+func TestTagsLoadLegacy(t *testing.T) {
+	store := state.NewInmemoryStore()
+	defer store.Close()
+
+	ts := NewTags()
+	tag, err := ts.Create("upload", 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(legacyTagsKey, ts); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewTags()
+	if err := restored.Load(store); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restored.Get(tag.Uid); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Get(legacyTagsKey, NewTags()); err != state.ErrNotFound {
+		t.Fatalf("got error %v getting legacy tags, want %v", err, state.ErrNotFound)
+	}
+	restored = NewTags()
+	if err := restored.Load(store); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restored.Get(tag.Uid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestTagsDeleteCompleted tests that completed tags are deleted according
+// to the policy counting from the time they were found completed
+func TestTagsDeleteCompleted(t *testing.T) {
+	ts := NewTags()
+	complete, err := ts.Create("complete", 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	complete.Inc(StateStored)
+	complete.Inc(StateSent)
+	complete.Inc(StateSynced)
+	incomplete, err := ts.Create("incomplete", 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	incomplete.Inc(StateStored)
+
+	policy := DeleteCompletedAfter(time.Hour)
+	now := time.Now()
+	if n := ts.DeleteCompleted(policy, now); n != 0 {
+		t.Fatalf("got %v tags deleted, want 0", n)
+	}
+	if n := ts.DeleteCompleted(policy, now.Add(59*time.Minute)); n != 0 {
+		t.Fatalf("got %v tags deleted, want 0", n)
+	}
+	if n := ts.DeleteCompleted(policy, now.Add(time.Hour)); n != 1 {
+		t.Fatalf("got %v tags deleted, want 1", n)
+	}
+	if _, err := ts.Get(complete.Uid); err != TagNotFoundErr {
+		t.Fatalf("got error %v getting deleted tag, want %v", err, TagNotFoundErr)
+	}
+	if _, err := ts.Get(incomplete.Uid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkTagCounts checks the counts of all states and the total of the tag
+func checkTagCounts(t *testing.T, tag *Tag, split, stored, seen, sent, synced, total int64) {
+	t.Helper()
+	for _, c := range []struct {
+		state State
+		want  int64
+	}{
+		{StateSplit, split},
+		{StateStored, stored},
+		{StateSeen, seen},
+		{StateSent, sent},
+		{StateSynced, synced},
+	} {
+		if got := tag.Get(c.state); got != c.want {
+			t.Fatalf("tag %v: got state %v count %v, want %v", tag.Uid, c.state, got, c.want)
+		}
+	}
+	if got := tag.TotalCounter(); got != total {
+		t.Fatalf("tag %v: got total %v, want %v", tag.Uid, got, total)
+	}
+}
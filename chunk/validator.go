@@ -0,0 +1,131 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package chunk
+
+import (
+	"context"
+	"sync"
+)
+
+// MaxDataSize is the maximal length of chunk data,
+// the payload of DefaultSize with its 8 byte span
+const MaxDataSize = DefaultSize + 8
+
+// Validator validates a chunk.
+type Validator interface {
+	Validate(ch Chunk) bool
+}
+
+// ValidatorFunc is an adapter to allow the use of
+// an ordinary function as a Validator.
+type ValidatorFunc func(ch Chunk) bool
+
+// Validate calls f(ch).
+func (f ValidatorFunc) Validate(ch Chunk) bool {
+	return f(ch)
+}
+
+// SizeValidator accepts chunks with data not
+// empty and not longer than MaxDataSize.
+var SizeValidator = ValidatorFunc(func(ch Chunk) bool {
+	l := len(ch.Data())
+	return l > 0 && l <= MaxDataSize
+})
+
+// ValidatorChain validates chunks on ingest. A chunk is valid if it is
+// accepted by all required validators, such as SizeValidator, and by one
+// of the validators of chunk types, such as the content address check of
+// content addressed chunks and the signature check of single owner chunks.
+// Custom validators can be registered at any time.
+type ValidatorChain struct {
+	required []Validator
+	types    []Validator
+	mu       sync.RWMutex
+}
+
+// NewValidatorChain returns a ValidatorChain with the provided
+// validators of chunk types, which requires chunks to be accepted
+// by SizeValidator.
+func NewValidatorChain(types ...Validator) *ValidatorChain {
+	return &ValidatorChain{
+		required: []Validator{SizeValidator},
+		types:    types,
+	}
+}
+
+// Require registers validators that must accept all chunks.
+func (c *ValidatorChain) Require(validators ...Validator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.required = append(c.required, validators...)
+}
+
+// Register registers validators of chunk types,
+// one of which must accept a chunk.
+func (c *ValidatorChain) Register(validators ...Validator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.types = append(c.types, validators...)
+}
+
+// Validate returns true if the chunk is accepted by all
+// required validators and one of the chunk type validators.
+func (c *ValidatorChain) Validate(ch Chunk) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, v := range c.required {
+		if !v.Validate(ch) {
+			return false
+		}
+	}
+	for _, v := range c.types {
+		if v.Validate(ch) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatorStore encapsulates Store by decorating the Put method
+// with validators check.
+type ValidatorStore struct {
+	Store
+	*ValidatorChain
+}
+
+// NewValidatorStore returns a new ValidatorStore which uses
+// a ValidatorChain of provided validators to validate chunks on Put.
+func NewValidatorStore(store Store, validators ...Validator) (s *ValidatorStore) {
+	return &ValidatorStore{
+		Store:          store,
+		ValidatorChain: NewValidatorChain(validators...),
+	}
+}
+
+// Put overrides Store put method with validators check. For Put to succeed,
+// all provided chunks must be accepted by the ValidatorChain.
+func (s *ValidatorStore) Put(ctx context.Context, mode ModePut, chs ...Chunk) (exist []bool, err error) {
+	for _, ch := range chs {
+		if !s.Validate(ch) {
+			return nil, ErrChunkInvalid
+		}
+	}
+	return s.Store.Put(ctx, mode, chs...)
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package chunk
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestValidatorChain tests that a chunk is valid only if accepted
+// by all required validators and one of the chunk type validators.
+func TestValidatorChain(t *testing.T) {
+	prefixValidator := func(prefix byte) Validator {
+		return ValidatorFunc(func(ch Chunk) bool {
+			return ch.Data()[0] == prefix
+		})
+	}
+	addr := make([]byte, AddressLength)
+
+	c := NewValidatorChain(prefixValidator(1))
+
+	for _, tc := range []struct {
+		name  string
+		data  []byte
+		valid bool
+	}{
+		{name: "empty", data: nil, valid: false},
+		{name: "too long", data: append([]byte{1}, make([]byte, MaxDataSize)...), valid: false},
+		{name: "type 1", data: []byte{1, 0}, valid: true},
+		{name: "type 2", data: []byte{2, 0}, valid: false},
+	} {
+		if got := c.Validate(NewChunk(addr, tc.data)); got != tc.valid {
+			t.Errorf("%s: got valid %v, want %v", tc.name, got, tc.valid)
+		}
+	}
+
+	c.Register(prefixValidator(2))
+	if !c.Validate(NewChunk(addr, []byte{2, 0})) {
+		t.Error("chunk of registered type not valid")
+	}
+
+	c.Require(ValidatorFunc(func(ch Chunk) bool {
+		return bytes.HasSuffix(ch.Data(), []byte{0})
+	}))
+	if c.Validate(NewChunk(addr, []byte{2, 1})) {
+		t.Error("chunk rejected by required validator is valid")
+	}
+	if !c.Validate(NewChunk(addr, []byte{1, 0})) {
+		t.Error("chunk accepted by all validators not valid")
+	}
+}
@@ -22,9 +22,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"math/rand"
-	"os"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -258,19 +256,8 @@ func checkChunksVsMostProxHosts(addrs []storage.Address, allHostChunks map[strin
 }
 
 func getAllRefs(testData []byte) (storage.AddressCollection, error) {
-	datadir, err := ioutil.TempDir("", "chunk-debug")
-	if err != nil {
-		return nil, fmt.Errorf("unable to create temp dir: %v", err)
-	}
-	defer os.RemoveAll(datadir)
-	fileStore, cleanup, err := storage.NewLocalFileStore(datadir, make([]byte, 32), chunk.NewTags())
-	if err != nil {
-		return nil, err
-	}
-	defer cleanup()
-
-	reader := bytes.NewReader(testData)
-	return fileStore.GetAllReferences(context.Background(), reader)
+	fileStore := storage.NewFileStore(&storage.FakeChunkStore{}, &storage.FakeChunkStore{}, storage.NewFileStoreParams(), chunk.NewTags())
+	return fileStore.References(context.Background(), bytes.NewReader(testData))
 }
 
 func uploadAndSync(c *cli.Context, randomBytes []byte) error {
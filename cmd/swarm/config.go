@@ -76,8 +76,14 @@ const (
 	SwarmEnvSwapLogPath             = "SWARM_SWAP_LOG_PATH"
 	SwarmEnvSwapLogLevel            = "SWARM_SWAP_LOG_LEVEL"
 	SwarmEnvLightNodeEnable         = "SWARM_LIGHT_NODE_ENABLE"
+	SwarmEnvLightNodeNoRetrieve     = "SWARM_LIGHT_NODE_NO_RETRIEVE"
+	SwarmEnvLightNodeNoSync         = "SWARM_LIGHT_NODE_NO_SYNC"
+	SwarmEnvRelayRequests           = "SWARM_RELAY_REQUESTS"
+	SwarmEnvSyncWithinRadius        = "SWARM_SYNC_WITHIN_RADIUS"
+	SwarmEnvErasureParities         = "SWARM_ERASURE_PARITIES"
 	SwarmEnvENSAPI                  = "SWARM_ENS_API"
 	SwarmEnvRNSAPI                  = "SWARM_RNS_API"
+	SwarmEnvFallbackGateways        = "SWARM_FALLBACK_GATEWAYS"
 	SwarmEnvENSAddr                 = "SWARM_ENS_ADDR"
 	SwarmEnvCORS                    = "SWARM_CORS"
 	SwarmEnvBootnodes               = "SWARM_BOOTNODES"
@@ -235,6 +241,21 @@ func flagsOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Confi
 	if ctx.GlobalIsSet(SwarmLightNodeEnabled.Name) {
 		currentConfig.LightNodeEnabled = true
 	}
+	if ctx.GlobalIsSet(SwarmLightNodeNoRetrieveFlag.Name) {
+		currentConfig.LightNoRetrieve = true
+	}
+	if ctx.GlobalIsSet(SwarmLightNodeNoSyncFlag.Name) {
+		currentConfig.LightNoSync = true
+	}
+	if ctx.GlobalIsSet(SwarmRelayRequestsFlag.Name) {
+		currentConfig.RelayRequests = true
+	}
+	if ctx.GlobalIsSet(SwarmSyncWithinRadiusFlag.Name) {
+		currentConfig.SyncWithinRadius = true
+	}
+	if parities := ctx.GlobalInt(SwarmErasureParitiesFlag.Name); parities != 0 {
+		currentConfig.FileStoreParams.Parities = parities
+	}
 	if ctx.GlobalIsSet(EnsAPIFlag.Name) {
 		ensAPIs := ctx.GlobalStringSlice(EnsAPIFlag.Name)
 		// preserve backward compatibility to disable ENS with --ens-api=""
@@ -249,6 +270,9 @@ func flagsOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Confi
 	if rns := ctx.GlobalString(RnsAPIFlag.Name); rns != "" {
 		currentConfig.RnsAPI = rns
 	}
+	if ctx.GlobalIsSet(FallbackGatewaysFlag.Name) {
+		currentConfig.FallbackGateways = ctx.GlobalStringSlice(FallbackGatewaysFlag.Name)
+	}
 	if cors := ctx.GlobalString(CorsStringFlag.Name); cors != "" {
 		currentConfig.Cors = cors
 	}
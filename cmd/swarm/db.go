@@ -82,9 +82,15 @@ pv(1) tool to get a progress bar:
 The import may be quite large, consider piping the input through the Unix
 pv(1) tool to get a progress bar:
 
-    pv chunks.tar | swarm db import ~/.ethereum/swarm/bzz-KEY/chunks -`,
+    pv chunks.tar | swarm db import ~/.ethereum/swarm/bzz-KEY/chunks -
+
+An interrupted import of a file can be continued with the --resume flag,
+skipping chunks that are already imported:
+
+    swarm db import --resume ~/.ethereum/swarm/bzz-KEY/chunks chunks.tar`,
 			Flags: []cli.Flag{
 				SwarmLegacyFlag,
+				SwarmResumeFlag,
 			},
 		},
 	},
@@ -125,7 +131,11 @@ func dbExport(ctx *cli.Context) {
 	}
 	defer store.Close()
 
-	count, err := store.Export(out)
+	count, err := store.ExportWithOptions(out, &localstore.ExportOptions{
+		Progress: func(count int64, last chunk.Address) {
+			log.Info("exporting chunks", "count", count, "last", last)
+		},
+	})
 	if err != nil {
 		utils.Fatalf("error exporting local chunk database: %s", err)
 	}
@@ -139,7 +149,17 @@ func dbImport(ctx *cli.Context) {
 		utils.Fatalf("invalid arguments, please specify both <chunkdb> (path to a local chunk database), <file> (path to read the tar archive from, - for stdin) and the base key")
 	}
 
-	legacy := ctx.IsSet(SwarmLegacyFlag.Name)
+	var resumeKey string
+	if ctx.IsSet(SwarmResumeFlag.Name) {
+		if args[1] == "-" {
+			utils.Fatalf("import from stdin can not be resumed")
+		}
+		p, err := filepath.Abs(args[1])
+		if err != nil {
+			utils.Fatalf("error resolving input file path: %s", err)
+		}
+		resumeKey = p
+	}
 
 	store, err := openLDBStore(args[0], common.Hex2Bytes(args[2]))
 	if err != nil {
@@ -159,7 +179,12 @@ func dbImport(ctx *cli.Context) {
 		in = f
 	}
 
-	count, err := store.Import(in, legacy)
+	count, err := store.ImportWithOptions(in, &localstore.ImportOptions{
+		ResumeKey: resumeKey,
+		Progress: func(count int64, last chunk.Address) {
+			log.Debug("importing chunks", "count", count, "last", last)
+		},
+	})
 	if err != nil {
 		utils.Fatalf("error importing local chunk database: %s", err)
 	}
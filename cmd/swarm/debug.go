@@ -17,7 +17,9 @@
 package main
 
 import (
+	"net/http"
 	"runtime"
+	"sync"
 
 	"gopkg.in/urfave/cli.v1"
 )
@@ -81,3 +83,39 @@ var debugFlags = []cli.Flag{
 	pprofFlag, pprofAddrFlag, pprofPortFlag,
 	memprofilerateFlag, blockprofilerateFlag, cpuprofileFlag, traceFlag,
 }
+
+// swarmDebug serves the debug endpoints of the Swarm service
+// on the pprof server, which serves the default HTTP mux
+var swarmDebug = new(swarmDebugHandler)
+
+// swarmDebugHandler passes requests to the debug handler of the Swarm
+// service, which is replaced when the service is constructed again
+// on node restart
+type swarmDebugHandler struct {
+	handler http.Handler
+	mu      sync.RWMutex
+}
+
+func (h *swarmDebugHandler) set(handler http.Handler) {
+	h.mu.Lock()
+	h.handler = handler
+	h.mu.Unlock()
+}
+
+func (h *swarmDebugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	handler := h.handler
+	h.mu.RUnlock()
+	if handler == nil {
+		http.Error(w, "swarm is not started", http.StatusServiceUnavailable)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// registerSwarmDebugHandler serves localstore inspection on /debug/localstore
+// and kademlia status on /debug/kademlia paths of the pprof server.
+func registerSwarmDebugHandler() {
+	http.Handle("/debug/localstore", swarmDebug)
+	http.Handle("/debug/kademlia", swarmDebug)
+}
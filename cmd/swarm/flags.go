@@ -114,11 +114,41 @@ var (
 		Usage:  "Enable Swarm LightNode (default false)",
 		EnvVar: SwarmEnvLightNodeEnable,
 	}
+	SwarmLightNodeNoRetrieveFlag = cli.BoolFlag{
+		Name:   "lightnode-no-retrieve",
+		Usage:  "Light node does not serve retrieve requests of other peers",
+		EnvVar: SwarmEnvLightNodeNoRetrieve,
+	}
+	SwarmLightNodeNoSyncFlag = cli.BoolFlag{
+		Name:   "lightnode-no-sync",
+		Usage:  "Light node does not serve sync streams to other peers",
+		EnvVar: SwarmEnvLightNodeNoSync,
+	}
+	SwarmRelayRequestsFlag = cli.BoolFlag{
+		Name:   "relay-requests",
+		Usage:  "Request chunks stored by peers that can not be connected to, such as nodes behind NAT, through relaying full nodes",
+		EnvVar: SwarmEnvRelayRequests,
+	}
+	SwarmSyncWithinRadiusFlag = cli.BoolFlag{
+		Name:   "sync-within-radius",
+		Usage:  "Pull-sync only chunks within the storage radius, chunks outside of it would be garbage collected first",
+		EnvVar: SwarmEnvSyncWithinRadius,
+	}
+	SwarmErasureParitiesFlag = cli.IntFlag{
+		Name:   "erasure-parities",
+		Usage:  "Number of Reed-Solomon parity chunks of every branching chunk of uploaded unencrypted content, 0 disables erasure coding",
+		EnvVar: SwarmEnvErasureParities,
+	}
 	EnsAPIFlag = cli.StringSliceFlag{
 		Name:   "ens-api",
 		Usage:  "ENS API endpoint for a TLD and with contract address, can be repeated, format [tld:][contract-addr@]url",
 		EnvVar: SwarmEnvENSAPI,
 	}
+	FallbackGatewaysFlag = cli.StringSliceFlag{
+		Name:   "fallback-gateway",
+		Usage:  "Trusted HTTP gateway URL to fetch chunks from if they can not be retrieved from the network, can be repeated",
+		EnvVar: SwarmEnvFallbackGateways,
+	}
 	RnsAPIFlag = cli.StringFlag{
 		Name:   "rns-api",
 		Usage:  "RNS API endpoint for RKS domains contract address, format [contract-addr@]url",
@@ -228,6 +258,10 @@ var (
 		Name:  "legacy",
 		Usage: "Use this flag when importing a db export from a legacy local store database dump (for schemas older than 'sanctuary')",
 	}
+	SwarmResumeFlag = cli.BoolFlag{
+		Name:  "resume",
+		Usage: "Resume an interrupted import of the same file, skipping already imported chunks",
+	}
 	SwarmPinFlag = cli.BoolFlag{
 		Name:  "pin",
 		Usage: "Use this flag to pin the file after upload is complete. This flag is used when uploading a file.",
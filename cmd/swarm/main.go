@@ -17,6 +17,7 @@
 package main
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"encoding/hex"
 	"fmt"
@@ -29,6 +30,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
@@ -178,6 +180,7 @@ func init() {
 		CorsStringFlag,
 		EnsAPIFlag,
 		RnsAPIFlag,
+		FallbackGatewaysFlag,
 		SwarmTomlConfigPathFlag,
 		//swap flags
 		SwarmSwapEnabledFlag,
@@ -193,6 +196,11 @@ func init() {
 		// end of swap flags
 		SwarmNoSyncFlag,
 		SwarmLightNodeEnabled,
+		SwarmLightNodeNoRetrieveFlag,
+		SwarmLightNodeNoSyncFlag,
+		SwarmRelayRequestsFlag,
+		SwarmSyncWithinRadiusFlag,
+		SwarmErasureParitiesFlag,
 		SwarmListenAddrFlag,
 		SwarmPortFlag,
 		SwarmAccountFlag,
@@ -246,6 +254,9 @@ func init() {
 		}); err != nil {
 			return err
 		}
+		if ctx.GlobalBool(pprofFlag.Name) {
+			registerSwarmDebugHandler()
+		}
 		swarmmetrics.Setup(swarmmetrics.Options{
 			Endoint:       ctx.GlobalString(flags.MetricsInfluxDBEndpointFlag.Name),
 			Database:      ctx.GlobalString(flags.MetricsInfluxDBDatabaseFlag.Name),
@@ -366,10 +377,21 @@ func bzzd(ctx *cli.Context) error {
 		stack.Stop()
 	}()
 
-	// add swarm bootnodes, because swarm doesn't use p2p package's discovery discv5
+	// add swarm bootnodes, because swarm doesn't use p2p package's discovery discv5,
+	// unless the node reconnected to enough of the peers it was connected to before the restart
 	go func() {
 		s := stack.Server()
 
+		var sw *swarm.Swarm
+		if err := stack.Service(&sw); err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), bzzconfig.HiveParams.WarmRestartTimeout+time.Second)
+			defer cancel()
+			if sw.WaitWarmRestart(ctx) {
+				log.Info("Reconnected to persisted peers, not connecting to bootnodes")
+				return
+			}
+		}
+
 		for _, n := range cfg.P2P.BootstrapNodes {
 			s.AddPeer(n)
 		}
@@ -393,7 +415,12 @@ func registerBzzService(bzzconfig *bzzapi.Config, stack *node.Node) {
 			// create a node store for this swarm key on global store
 			nodeStore = globalStore.NewNodeStore(common.HexToAddress(bzzconfig.BzzKey))
 		}
-		return swarm.NewSwarm(bzzconfig, nodeStore)
+		s, err := swarm.NewSwarm(bzzconfig, nodeStore)
+		if err != nil {
+			return nil, err
+		}
+		swarmDebug.set(s.DebugHandler())
+		return s, nil
 	}
 	//register within the ethereum node
 	if err := stack.Register(boot); err != nil {
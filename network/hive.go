@@ -19,12 +19,15 @@ package network
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/network/capability"
 	"github.com/ethersphere/swarm/state"
@@ -32,6 +35,7 @@ import (
 
 const connectionsKey = "conns"
 const addressesKey = "peers"
+const knownPeersKey = "known_peers"
 
 /*
 Hive is the logistic manager of the swarm
@@ -48,6 +52,13 @@ type HiveParams struct {
 	PeersBroadcastSetSize uint8 // how many peers to use when relaying
 	MaxPeersPerRequest    uint8 // max size for peer address batches
 	KeepAliveInterval     time.Duration
+	PersistInterval       time.Duration // how often known peers are saved to the state store, zero saves them only on stop
+	WarmRestartTimeout    time.Duration // how long to wait for reconnection to previously connected peers on start
+	WarmRestartMaxAge     time.Duration // peers last seen connected longer ago are not reconnected on start
+	WarmRestartMinPeers   int           // number of peers to connect on start for the warm restart to succeed
+	PingInterval          time.Duration // how often round trip times to peers are measured and bins over their max size balanced, zero disables it
+	ConnectBatchSize      int           // maximum number of peers dialed on each keep alive tick to meet the bin targets
+	StorageInterval       time.Duration // how often changes of the storage info are advertised to peers, zero disables it
 }
 
 // NewHiveParams returns hive config with only the
@@ -57,6 +68,13 @@ func NewHiveParams() *HiveParams {
 		PeersBroadcastSetSize: 3,
 		MaxPeersPerRequest:    5,
 		KeepAliveInterval:     500 * time.Millisecond,
+		PersistInterval:       time.Minute,
+		WarmRestartTimeout:    20 * time.Second,
+		WarmRestartMaxAge:     24 * time.Hour,
+		WarmRestartMinPeers:   3,
+		PingInterval:          30 * time.Second,
+		ConnectBatchSize:      3,
+		StorageInterval:       30 * time.Second,
 	}
 }
 
@@ -67,11 +85,27 @@ type Hive struct {
 	Store       state.Store       // storage interface to save peers across sessions
 	addPeer     func(*enode.Node) // server callback to connect to a peer
 	// bookkeeping
-	lock    sync.Mutex
-	peers   map[enode.ID]*BzzPeer
-	ticker  *time.Ticker
-	done    chan struct{}
-	started bool
+	lock     sync.Mutex
+	peers    map[enode.ID]*BzzPeer
+	lastSeen map[string]time.Time // last time known peers were connected by overlay address
+	ticker   *time.Ticker
+	done     chan struct{}
+	warm     chan struct{} // closed when reconnection to previously connected peers is done
+	warmOnce sync.Once
+	warmOK   bool // enough previously connected peers were reconnected on start
+	started  bool
+	legacy   bool // peers were loaded from the legacy keys, which are deleted on the next save
+
+	storageInfo       func() StorageInfo // returns the storage info of this node, nil if it is not advertised
+	advertisedStorage *StorageInfo       // storage info last advertised to peers, only used by the connect loop
+}
+
+// knownPeer is a peer from the address book saved in the state store
+type knownPeer struct {
+	Addr      *BzzAddr
+	Bin       int       // proximity order of the peer to this node
+	LastSeen  time.Time // last time the peer was connected, zero if never
+	Connected bool      // peer was connected when saved
 }
 
 // NewHive constructs a new hive
@@ -84,6 +118,8 @@ func NewHive(params *HiveParams, kad *Kademlia, store state.Store) *Hive {
 		Kademlia:   kad,
 		Store:      store,
 		peers:      make(map[enode.ID]*BzzPeer),
+		lastSeen:   make(map[string]time.Time),
+		warm:       make(chan struct{}),
 	}
 }
 
@@ -101,6 +137,8 @@ func (h *Hive) start(server *p2p.Server, addPeerFunc func(*enode.Node)) error {
 	log.Info("Starting hive", "baseaddr", fmt.Sprintf("%x", h.BaseAddr()[:4]))
 	// assigns the p2p.Server#AddPeer function to connect to peers
 	h.addPeer = addPeerFunc
+	// done channel to signal the connect goroutines to return after Stop
+	h.done = make(chan struct{})
 	// if state store is specified, load peers to prepopulate the overlay address book
 	if h.Store != nil {
 		log.Info("Detected an existing store. trying to load peers")
@@ -108,11 +146,11 @@ func (h *Hive) start(server *p2p.Server, addPeerFunc func(*enode.Node)) error {
 			log.Error(fmt.Sprintf("%08x hive encoutered an error trying to load peers", h.BaseAddr()[:4]))
 			return err
 		}
+	} else {
+		h.warmRestarted(false)
 	}
 	// ticker to keep the hive alive
 	h.ticker = time.NewTicker(h.KeepAliveInterval)
-	// done channel to signal the connect goroutine to return after Stop
-	h.done = make(chan struct{})
 	// this loop is doing bootstrapping and maintains a healthy table
 	if !h.DisableAutoConnect {
 		go h.connect()
@@ -155,29 +193,63 @@ func (h *Hive) Stop() error {
 // at each iteration, ask the overlay driver to suggest the most preferred peer to connect to
 // as well as advertises saturation depth if needed
 func (h *Hive) connect() {
+	var persist <-chan time.Time
+	if h.Store != nil && h.PersistInterval > 0 {
+		t := time.NewTicker(h.PersistInterval)
+		defer t.Stop()
+		persist = t.C
+	}
+	var ping <-chan time.Time
+	if h.PingInterval > 0 {
+		t := time.NewTicker(h.PingInterval)
+		defer t.Stop()
+		ping = t.C
+	}
+	var storage <-chan time.Time
+	if h.storageInfo != nil && h.StorageInterval > 0 {
+		t := time.NewTicker(h.StorageInterval)
+		defer t.Stop()
+		storage = t.C
+	}
 	for {
 		select {
 		case <-h.ticker.C:
 			h.tickHive()
+		case <-persist:
+			// save peers periodically so that they are not lost if the node is not stopped cleanly
+			if err := h.savePeers(); err != nil {
+				log.Warn(fmt.Sprintf("%08x hive could not save peers: %v", h.BaseAddr()[:4], err))
+			}
+		case <-ping:
+			// drop the slowest peers measured since the last ping, then measure again
+			h.balanceBins()
+			h.pingPeers()
+		case <-storage:
+			h.advertiseStorage()
 		case <-h.done:
 			return
 		}
 	}
 }
 
+// tickHive dials peers to meet the connection targets of the kademlia bins
 func (h *Hive) tickHive() {
-	addr, depth, changed := h.SuggestPeer()
+	n := h.ConnectBatchSize
+	if n < 1 {
+		n = 1
+	}
+	addrs, depth, changed := h.SuggestPeers(n)
 	if h.Discovery && changed {
 		h.NotifyDepth(uint8(depth))
 	}
-	if addr != nil {
+	for _, addr := range addrs {
 		log.Trace(fmt.Sprintf("%08x hive connect() suggested %08x", h.BaseAddr()[:4], addr.Address()[:4]))
 		underA := addr.Under()
 		s := string(underA)
 		under, err := enode.ParseV4(s)
 		if err != nil {
 			log.Warn(fmt.Sprintf("%08x unable to connect to bee %08x: invalid node URL: %v", h.BaseAddr()[:4], addr.Address()[:4], err))
-			return
+			continue
 		}
 		log.Trace(fmt.Sprintf("%08x attempt to connect to bee %08x", h.BaseAddr()[:4], addr.Address()[:4]))
 		h.addPeer(under)
@@ -209,12 +281,14 @@ func (h *Hive) Run(p *BzzPeer) error {
 func (h *Hive) trackPeer(p *BzzPeer) {
 	h.lock.Lock()
 	h.peers[p.ID()] = p
+	h.lastSeen[string(p.Address())] = time.Now()
 	h.lock.Unlock()
 }
 
 func (h *Hive) untrackPeer(p *BzzPeer) {
 	h.lock.Lock()
 	delete(h.peers, p.ID())
+	h.lastSeen[string(p.Address())] = time.Now()
 	h.lock.Unlock()
 }
 
@@ -252,40 +326,212 @@ func (h *Hive) Peer(id enode.ID) *BzzPeer {
 	return h.peers[id]
 }
 
+// BlockPeer adds an entry to the peer blocklist and disconnects
+// the connected peers matching it
+func (h *Hive) BlockPeer(e PeerFilterEntry) error {
+	if err := h.PeerFilter.Block(e); err != nil {
+		return err
+	}
+	h.dropFilteredPeers()
+	return nil
+}
+
+// UnblockPeer removes an entry from the peer blocklist
+func (h *Hive) UnblockPeer(e PeerFilterEntry) error {
+	return h.PeerFilter.Unblock(e)
+}
+
+// AllowPeer adds an entry to the peer allowlist and disconnects
+// the connected peers that are not allowed any more
+func (h *Hive) AllowPeer(e PeerFilterEntry) error {
+	if err := h.PeerFilter.Allow(e); err != nil {
+		return err
+	}
+	h.dropFilteredPeers()
+	return nil
+}
+
+// DisallowPeer removes an entry from the peer allowlist and disconnects
+// the connected peers that are not allowed any more
+func (h *Hive) DisallowPeer(e PeerFilterEntry) error {
+	if err := h.PeerFilter.Disallow(e); err != nil {
+		return err
+	}
+	h.dropFilteredPeers()
+	return nil
+}
+
+// PeerFilterLists returns the entries of the peer blocklist and allowlist
+func (h *Hive) PeerFilterLists() PeerFilterLists {
+	return h.PeerFilter.Lists()
+}
+
+// dropFilteredPeers disconnects the peers rejected by the peer filter
+func (h *Hive) dropFilteredPeers() {
+	type filtered struct {
+		peer *BzzPeer
+		err  error
+	}
+	var drop []filtered
+	h.lock.Lock()
+	for _, p := range h.peers {
+		if err := h.PeerFilter.Check(p.ID(), p.Over()); err != nil {
+			drop = append(drop, filtered{p, err})
+		}
+	}
+	h.lock.Unlock()
+	for _, f := range drop {
+		log.Info("dropping filtered peer", "peer", f.peer.ID(), "err", f.err)
+		f.peer.Drop(f.err.Error())
+	}
+}
+
 // loadPeers, savePeer implement persistence callback/
 func (h *Hive) loadPeers() error {
-	var as []*BzzAddr
-	err := h.Store.Get(addressesKey, &as)
+	var known []knownPeer
+	err := h.Store.Get(knownPeersKey, &known)
+	if err == state.ErrNotFound {
+		// peers saved before last seen times were persisted
+		known, err = h.loadLegacyPeers()
+		if err == nil {
+			h.lock.Lock()
+			h.legacy = true
+			h.lock.Unlock()
+		}
+	}
 	if err != nil {
+		h.warmRestarted(false)
 		if err == state.ErrNotFound {
 			log.Info(fmt.Sprintf("hive %08x: no persisted peers found", h.BaseAddr()[:4]))
 			return nil
 		}
 		return err
 	}
-	// workaround for old node stores not containing capabilities
-	for i := range as {
-		if as[i].Capabilities == nil {
+	as := make([]*BzzAddr, 0, len(known))
+	var conns []knownPeer
+	h.lock.Lock()
+	for _, kp := range known {
+		// workaround for old node stores not containing capabilities
+		if kp.Addr.Capabilities == nil {
 			caps := capability.NewCapabilities()
 			caps.Add(fullCapability)
-			as[i] = as[i].WithCapabilities(caps)
+			kp.Addr = kp.Addr.WithCapabilities(caps)
+		}
+		as = append(as, kp.Addr)
+		if !kp.LastSeen.IsZero() {
+			h.lastSeen[string(kp.Addr.Address())] = kp.LastSeen
+		}
+		// reconnect to peers that were healthy recently
+		if kp.Connected && (kp.LastSeen.IsZero() || h.WarmRestartMaxAge <= 0 || time.Since(kp.LastSeen) < h.WarmRestartMaxAge) {
+			conns = append(conns, kp)
 		}
 	}
+	h.lock.Unlock()
 	log.Info(fmt.Sprintf("hive %08x: peers loaded", h.BaseAddr()[:4]))
 	errRegistering := h.Register(as...)
+	if len(conns) == 0 {
+		log.Info(fmt.Sprintf("hive %08x: no persisted peer connections found", h.BaseAddr()[:4]))
+		h.warmRestarted(false)
+		return errRegistering
+	}
+	// the most recently seen peers are the most likely to be online
+	sort.SliceStable(conns, func(i, j int) bool {
+		return conns[i].LastSeen.After(conns[j].LastSeen)
+	})
+	addrs := make([]*BzzAddr, len(conns))
+	for i, kp := range conns {
+		addrs[i] = kp.Addr
+	}
+	go h.connectInitialPeers(addrs)
+	go h.waitWarmRestart()
+	return errRegistering
+}
+
+// loadLegacyPeers loads the peers and connections saved under
+// separate keys without last seen times
+func (h *Hive) loadLegacyPeers() ([]knownPeer, error) {
+	var as []*BzzAddr
+	if err := h.Store.Get(addressesKey, &as); err != nil {
+		return nil, err
+	}
 	var conns []*BzzAddr
-	err = h.Store.Get(connectionsKey, &conns)
-	if err != nil {
-		if err == state.ErrNotFound {
-			log.Info(fmt.Sprintf("hive %08x: no persisted peer connections found", h.BaseAddr()[:4]))
-		} else {
-			log.Warn(fmt.Sprintf("hive %08x: error loading connections: %v", h.BaseAddr()[:4], err))
+	if err := h.Store.Get(connectionsKey, &conns); err != nil && err != state.ErrNotFound {
+		log.Warn(fmt.Sprintf("hive %08x: error loading connections: %v", h.BaseAddr()[:4], err))
+	}
+	connected := make(map[string]bool, len(conns))
+	for _, a := range conns {
+		connected[string(a.Address())] = true
+	}
+	known := make([]knownPeer, 0, len(as))
+	for _, a := range as {
+		known = append(known, knownPeer{
+			Addr:      a,
+			Bin:       chunk.Proximity(h.BaseAddr(), a.Address()),
+			Connected: connected[string(a.Address())],
+		})
+	}
+	return known, nil
+}
+
+// waitWarmRestart waits for connections to WarmRestartMinPeers of the
+// previously connected peers for at most the WarmRestartTimeout
+func (h *Hive) waitWarmRestart() {
+	timeout := time.NewTimer(h.WarmRestartTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	minPeers := h.WarmRestartMinPeers
+	if minPeers < 1 {
+		minPeers = 1
+	}
+	for {
+		if h.connsCount() >= minPeers {
+			log.Info(fmt.Sprintf("hive %08x: reconnected to persisted peers", h.BaseAddr()[:4]))
+			h.warmRestarted(true)
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-timeout.C:
+			log.Info(fmt.Sprintf("hive %08x: could not reconnect to enough persisted peers", h.BaseAddr()[:4]))
+			h.warmRestarted(false)
+			return
+		case <-h.done:
+			h.warmRestarted(false)
+			return
 		}
+	}
+}
 
-	} else {
-		go h.connectInitialPeers(conns)
+// warmRestarted signals that the reconnection to previously connected peers
+// is done, only the first call has effect
+func (h *Hive) warmRestarted(ok bool) {
+	h.warmOnce.Do(func() {
+		h.warmOK = ok
+		close(h.warm)
+	})
+}
+
+// connsCount returns the number of connected peers
+func (h *Hive) connsCount() (n int) {
+	h.EachConn(nil, 255, func(_ *Peer, _ int) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// WaitWarmRestart blocks until the hive is done reconnecting to the peers
+// that were connected before the restart, or the context is done. It
+// returns true if at least WarmRestartMinPeers of them were reconnected, in
+// which case bootnodes are not needed to bootstrap the connectivity.
+func (h *Hive) WaitWarmRestart(ctx context.Context) bool {
+	select {
+	case <-h.warm:
+		return h.warmOK
+	case <-ctx.Done():
+		return false
 	}
-	return errRegistering
 }
 
 func (h *Hive) connectInitialPeers(conns []*BzzAddr) {
@@ -304,29 +550,58 @@ func (h *Hive) connectInitialPeers(conns []*BzzAddr) {
 
 // savePeers, savePeer implement persistence callback/
 func (h *Hive) savePeers() error {
-	var peers []*BzzAddr
-	var conns []*BzzAddr
+	connected := make(map[string]bool)
+	h.Kademlia.EachConn(nil, 256, func(p *Peer, i int) bool {
+		log.Trace("saving connected peer", "OAddr", hexutil.Encode(p.OAddr), "UAddr", p.UAddr)
+		connected[string(p.Address())] = true
+		return true
+	})
+
+	var addrs []*BzzAddr
 	h.Kademlia.EachAddr(nil, 256, func(pa *BzzAddr, i int) bool {
 		if pa == nil {
 			log.Warn(fmt.Sprintf("empty addr: %v", i))
 			return true
 		}
 		log.Trace("saving peer", "peer", pa)
-		peers = append(peers, pa)
+		addrs = append(addrs, pa)
 		return true
 	})
 
-	h.Kademlia.EachConn(nil, 256, func(p *Peer, i int) bool {
-		log.Trace("saving connected peer", "OAddr", hexutil.Encode(p.OAddr), "UAddr", p.UAddr)
-		conns = append(conns, p.BzzAddr)
-		return true
-	})
-	if err := h.Store.Put(addressesKey, peers); err != nil {
-		return fmt.Errorf("could not save peers: %v", err)
+	now := time.Now()
+	known := make([]knownPeer, 0, len(addrs))
+	h.lock.Lock()
+	for _, pa := range addrs {
+		kp := knownPeer{
+			Addr:      pa,
+			Bin:       chunk.Proximity(h.BaseAddr(), pa.Address()),
+			LastSeen:  h.lastSeen[string(pa.Address())],
+			Connected: connected[string(pa.Address())],
+		}
+		if kp.Connected {
+			kp.LastSeen = now
+		}
+		known = append(known, kp)
 	}
+	legacy := h.legacy
+	h.lock.Unlock()
 
-	if err := h.Store.Put(connectionsKey, conns); err != nil {
-		return fmt.Errorf("could not save peer connections: %v", err)
+	batch := new(state.StoreBatch)
+	if err := batch.Put(knownPeersKey, known); err != nil {
+		return fmt.Errorf("could not save peers: %v", err)
+	}
+	if legacy {
+		// the peers are migrated to the known peers key
+		batch.Delete(addressesKey)
+		batch.Delete(connectionsKey)
+	}
+	if err := h.Store.WriteBatch(batch); err != nil {
+		return fmt.Errorf("could not save peers: %v", err)
+	}
+	if legacy {
+		h.lock.Lock()
+		h.legacy = false
+		h.lock.Unlock()
 	}
 	return nil
 }
@@ -341,12 +616,79 @@ func (h *Hive) handleMsg(p *Peer) func(context.Context, interface{}) error {
 			return h.handlePeersMsg(p, msg)
 		case *subPeersMsg:
 			return h.handleSubPeersMsg(ctx, p, msg)
+		case *pingMsg:
+			go p.Send(ctx, &pongMsg{Nonce: msg.Nonce})
+			return nil
+		case *pongMsg:
+			p.handlePong(msg)
+			return nil
+		case *storageMsg:
+			p.setStorageInfo(StorageInfo{Radius: msg.Radius, FreeCapacity: msg.FreeCapacity})
+			return nil
 		}
 
 		return fmt.Errorf("unknown message type: %T", msg)
 	}
 }
 
+// pingPeers sends ping messages to all connected peers to measure round trip times
+func (h *Hive) pingPeers() {
+	h.EachConn(nil, 255, func(p *Peer, _ int) bool {
+		go func() {
+			if err := p.Ping(context.TODO()); err != nil {
+				log.Debug("hive ping failed", "peer", p.ID(), "err", err)
+			}
+		}()
+		return true
+	})
+}
+
+// balanceBins disconnects the slowest peers in bins over their max size
+// so that connections with lower latency are preferred
+func (h *Hive) balanceBins() {
+	for _, p := range h.slowestPeers() {
+		log.Debug("hive dropping slow peer", "peer", p.ID(), "rtt", p.RTT())
+		metrics.GetOrRegisterCounter("network/hive/balance/drop", nil).Inc(1)
+		p.Drop("surplus peer in a bin over max size")
+	}
+}
+
+// slowestPeers returns the peers with the highest round trip times in bins
+// shallower than the neighbourhood depth that have more connections than
+// the max of their bin target, so that only the fastest peers remain in them
+// Peers with round trip times not measured yet are kept
+func (h *Hive) slowestPeers() (slow []*Peer) {
+	depth := h.NeighbourhoodDepth()
+	bins := make(map[int][]*Peer)
+	h.EachConn(nil, 255, func(p *Peer, po int) bool {
+		// all peers in the neighbourhood must stay connected
+		if po < depth {
+			bins[po] = append(bins[po], p)
+		}
+		return true
+	})
+	for po, peers := range bins {
+		excess := len(peers) - h.BinTarget(po).Max
+		if excess <= 0 {
+			continue
+		}
+		var measured []*Peer
+		for _, p := range peers {
+			if p.RTT() > 0 {
+				measured = append(measured, p)
+			}
+		}
+		sort.Slice(measured, func(i, j int) bool {
+			return measured[i].RTT() > measured[j].RTT()
+		})
+		if excess > len(measured) {
+			excess = len(measured)
+		}
+		slow = append(slow, measured[:excess]...)
+	}
+	return slow
+}
+
 // NotifyDepth sends a message to all connections if depth of saturation is changed
 func (h *Hive) NotifyDepth(depth uint8) {
 	f := func(val *Peer, po int) bool {
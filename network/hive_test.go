@@ -17,8 +17,11 @@
 package network
 
 import (
+	"bytes"
+	"context"
 	"io/ioutil"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -27,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
 	p2ptest "github.com/ethersphere/swarm/p2p/testing"
 	"github.com/ethersphere/swarm/pot"
@@ -296,7 +300,444 @@ func TestHiveStateConnections(t *testing.T) {
 	})
 }
 
+// TestHiveWarmRestart tests that known peers are saved with their bins and
+// last seen times, and that on start the hive reconnects to the recently
+// connected peers, most recently seen first, and reports the warm restart.
+func TestHiveWarmRestart(t *testing.T) {
+	store := state.NewInmemoryStore()
+
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := PrivateKeyToBzzKey(prvkey)
+
+	now := time.Now()
+	recent := RandomBzzAddr()
+	older := RandomBzzAddr()
+	stale := RandomBzzAddr()
+	disconnected := RandomBzzAddr()
+	known := []knownPeer{
+		{Addr: older, LastSeen: now.Add(-time.Hour), Connected: true},
+		{Addr: stale, LastSeen: now.Add(-48 * time.Hour), Connected: true},
+		{Addr: recent, LastSeen: now.Add(-time.Minute), Connected: true},
+		{Addr: disconnected, LastSeen: now.Add(-time.Minute)},
+	}
+	if err := store.Put(knownPeersKey, known); err != nil {
+		t.Fatal(err)
+	}
+
+	params := NewHiveParams()
+	params.Discovery = false
+	params.DisableAutoConnect = true
+	params.WarmRestartMinPeers = 1
+	h := NewHive(params, NewKademlia(base, NewKadParams()), store)
+
+	var mu sync.Mutex
+	var dialed []string
+	s := p2ptest.NewProtocolTester(prvkey, 0, func(p *p2p.Peer, rw p2p.MsgReadWriter) error { return nil })
+	defer s.Stop()
+	if err := h.start(s.Server, func(node *enode.Node) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, a := range []*BzzAddr{recent, older, stale, disconnected} {
+			if node.ID() == a.ID() {
+				dialed = append(dialed, a.String())
+				if a == recent {
+					h.On(newConnPeerLocal(a.Address(), h.Kademlia))
+				}
+			}
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if !h.WaitWarmRestart(ctx) {
+		t.Fatal("warm restart not reported")
+	}
+
+	mu.Lock()
+	got := dialed
+	mu.Unlock()
+	want := []string{recent.String(), older.String()}
+	if len(got) != len(want) {
+		t.Fatalf("got dialed peers %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got dialed peers %v, want %v", got, want)
+		}
+	}
+
+	// all known peers are saved with their bins and last seen times
+	if err := h.savePeers(); err != nil {
+		t.Fatal(err)
+	}
+	var saved []knownPeer
+	if err := store.Get(knownPeersKey, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != len(known) {
+		t.Fatalf("got %v saved peers, want %v", len(saved), len(known))
+	}
+	for _, kp := range saved {
+		if kp.Bin != chunk.Proximity(base, kp.Addr.Address()) {
+			t.Errorf("peer %v: got bin %v, want %v", kp.Addr, kp.Bin, chunk.Proximity(base, kp.Addr.Address()))
+		}
+		connected := bytes.Equal(kp.Addr.Address(), recent.Address())
+		if kp.Connected != connected {
+			t.Errorf("peer %v: got connected %v, want %v", kp.Addr, kp.Connected, connected)
+		}
+		if connected && kp.LastSeen.Before(now) {
+			t.Errorf("peer %v: got last seen %v, want after %v", kp.Addr, kp.LastSeen, now)
+		}
+		if bytes.Equal(kp.Addr.Address(), stale.Address()) && !kp.LastSeen.Equal(now.Add(-48*time.Hour)) {
+			t.Errorf("peer %v: got last seen %v, want %v", kp.Addr, kp.LastSeen, now.Add(-48*time.Hour))
+		}
+	}
+}
+
+// TestHiveWarmRestartMinPeers tests that the warm restart is not reported
+// when fewer than the minimum number of persisted peers are reconnected
+func TestHiveWarmRestartMinPeers(t *testing.T) {
+	store := state.NewInmemoryStore()
+	now := time.Now()
+	online := RandomBzzAddr()
+	known := []knownPeer{
+		{Addr: online, LastSeen: now, Connected: true},
+		{Addr: RandomBzzAddr(), LastSeen: now, Connected: true},
+	}
+	if err := store.Put(knownPeersKey, known); err != nil {
+		t.Fatal(err)
+	}
+
+	params := NewHiveParams()
+	params.Discovery = false
+	params.DisableAutoConnect = true
+	params.WarmRestartMinPeers = 2
+	params.WarmRestartTimeout = 500 * time.Millisecond
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHive(params, NewKademlia(PrivateKeyToBzzKey(prvkey), NewKadParams()), store)
+	if err := h.start(nil, func(node *enode.Node) {
+		if node.ID() == online.ID() {
+			h.On(newConnPeerLocal(online.Address(), h.Kademlia))
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if h.WaitWarmRestart(ctx) {
+		t.Fatal("warm restart reported")
+	}
+	if ctx.Err() != nil {
+		t.Fatal("warm restart not done")
+	}
+}
+
+// TestHiveLegacyPeers tests that peers saved under the legacy keys are
+// loaded and that the legacy keys are deleted once the peers are saved
+// under the known peers key
+func TestHiveLegacyPeers(t *testing.T) {
+	store := state.NewInmemoryStore()
+	addrs := []*BzzAddr{RandomBzzAddr(), RandomBzzAddr()}
+	if err := store.Put(addressesKey, addrs); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(connectionsKey, addrs[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	params := NewHiveParams()
+	params.Discovery = false
+	params.DisableAutoConnect = true
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHive(params, NewKademlia(PrivateKeyToBzzKey(prvkey), NewKadParams()), store)
+	if err := h.start(nil, func(*enode.Node) {}); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Stop()
+	if err := h.savePeers(); err != nil {
+		t.Fatal(err)
+	}
+
+	var saved []knownPeer
+	if err := store.Get(knownPeersKey, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != len(addrs) {
+		t.Fatalf("got %v saved peers, want %v", len(saved), len(addrs))
+	}
+	for _, key := range []string{addressesKey, connectionsKey} {
+		var as []*BzzAddr
+		if err := store.Get(key, &as); err != state.ErrNotFound {
+			t.Errorf("key %q: got error %v, want %v", key, err, state.ErrNotFound)
+		}
+	}
+}
+
+// TestHiveColdRestart tests that a hive without persisted peers reports
+// that it could not reconnect to them
+func TestHiveColdRestart(t *testing.T) {
+	params := NewHiveParams()
+	params.Discovery = false
+	params.DisableAutoConnect = true
+
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHive(params, NewKademlia(PrivateKeyToBzzKey(prvkey), NewKadParams()), state.NewInmemoryStore())
+	s := p2ptest.NewProtocolTester(prvkey, 0, func(p *p2p.Peer, rw p2p.MsgReadWriter) error { return nil })
+	defer s.Stop()
+	if err := h.Start(s.Server); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if h.WaitWarmRestart(ctx) {
+		t.Fatal("warm restart reported")
+	}
+	if ctx.Err() != nil {
+		t.Fatal("warm restart not done")
+	}
+}
+
+// TestHivePersistInterval tests that known peers are saved periodically
+func TestHivePersistInterval(t *testing.T) {
+	store := state.NewInmemoryStore()
+	params := NewHiveParams()
+	params.Discovery = false
+	params.PersistInterval = 10 * time.Millisecond
+
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHive(params, NewKademlia(PrivateKeyToBzzKey(prvkey), NewKadParams()), store)
+	if err := h.start(nil, func(*enode.Node) {}); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Stop()
+
+	h.Register(RandomBzzAddr())
+
+	for i := 0; ; i++ {
+		var saved []knownPeer
+		err := store.Get(knownPeersKey, &saved)
+		if err == nil && len(saved) == 1 {
+			break
+		}
+		if err != nil && err != state.ErrNotFound {
+			t.Fatal(err)
+		}
+		if i == 100 {
+			t.Fatal("peers not saved")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 // Create a Peer with the suggested address and store the relationshsip enode -> BzzAddr for later retrieval
+// TestHivePingPong checks that ping messages are answered with
+// pong messages with the same nonce
+func TestHivePingPong(t *testing.T) {
+	params := NewHiveParams()
+	params.Discovery = false
+	s, _, err := newHiveTester(params, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	id := s.Nodes[0].ID()
+
+	err = s.TestExchanges(p2ptest.Exchange{
+		Label: "ping",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 2,
+				Msg:  &pingMsg{Nonce: 42},
+				Peer: id,
+			},
+		},
+		Expects: []p2ptest.Expect{
+			{
+				Code: 3,
+				Msg:  &pongMsg{Nonce: 42},
+				Peer: id,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestHiveStorageInfo checks that the storage info advertised by a peer
+// is recorded and that changes of the local storage info are advertised
+func TestHiveStorageInfo(t *testing.T) {
+	params := NewHiveParams()
+	params.Discovery = false
+	s, pp, err := newHiveTester(params, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	id := s.Nodes[0].ID()
+
+	err = s.TestExchanges(p2ptest.Exchange{
+		Label: "storage info from peer",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 4,
+				Msg:  &storageMsg{Radius: 3, FreeCapacity: 100},
+				Peer: id,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := StorageInfo{Radius: 3, FreeCapacity: 100}
+	var got StorageInfo
+	for i := 0; i < 100; i++ {
+		pp.EachConn(nil, 255, func(p *Peer, _ int) bool {
+			if p.ID() == id {
+				got, _ = p.StorageInfo()
+			}
+			return true
+		})
+		if got == want {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got != want {
+		t.Fatalf("got peer storage info %v, want %v", got, want)
+	}
+
+	info := StorageInfo{Radius: 2, FreeCapacity: 50}
+	pp.storageInfo = func() StorageInfo {
+		return info
+	}
+	pp.advertiseStorage()
+	err = s.TestExchanges(p2ptest.Exchange{
+		Label: "storage info to peer",
+		Expects: []p2ptest.Expect{
+			{
+				Code: 4,
+				Msg:  &storageMsg{Radius: 2, FreeCapacity: 50},
+				Peer: id,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unchanged storage info is not advertised again
+	pp.advertiseStorage()
+	info.FreeCapacity = 40
+	pp.advertiseStorage()
+	err = s.TestExchanges(p2ptest.Exchange{
+		Label: "changed storage info to peer",
+		Expects: []p2ptest.Expect{
+			{
+				Code: 4,
+				Msg:  &storageMsg{Radius: 2, FreeCapacity: 40},
+				Peer: id,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestHiveConnectBatch checks that the hive dials a batch
+// of suggested peers on each tick
+func TestHiveConnectBatch(t *testing.T) {
+	params := NewHiveParams()
+	params.ConnectBatchSize = 2
+	base := pot.RandomAddress()
+	k := NewKademlia(base[:], NewKadParams())
+	h := NewHive(params, k, nil)
+
+	var dialed []enode.ID
+	h.addPeer = func(n *enode.Node) {
+		dialed = append(dialed, n.ID())
+	}
+	for i := 0; i < 3; i++ {
+		addr := pot.RandomAddressAt(base, 0)
+		if err := k.Register(newConnPeerLocal(addr[:], k).BzzAddr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.tickHive()
+	if len(dialed) != 2 {
+		t.Fatalf("got %v peers dialed, want 2", len(dialed))
+	}
+	h.tickHive()
+	if len(dialed) != 3 {
+		t.Fatalf("got %v peers dialed, want 3", len(dialed))
+	}
+}
+
+// TestHiveSlowestPeers checks that peers with the highest round trip times
+// are selected for disconnection from bins over MaxBinSize outside of
+// the neighbourhood
+func TestHiveSlowestPeers(t *testing.T) {
+	kp := NewKadParams()
+	kp.MaxBinSize = 2
+	base := pot.RandomAddress()
+	k := NewKademlia(base[:], kp)
+	h := NewHive(NewHiveParams(), k, nil)
+
+	newPeer := func(po int, rtt time.Duration) *Peer {
+		addr := pot.RandomAddressAt(base, po)
+		p := newConnPeerLocal(addr[:], k)
+		p.rtt = rtt
+		k.On(p)
+		return p
+	}
+	// neighbourhood peers are over the max bin size, but they are kept
+	for i := 0; i < 3; i++ {
+		newPeer(5, time.Second)
+	}
+	newPeer(0, 10*time.Millisecond)
+	newPeer(0, 0) // not measured yet
+	slow := newPeer(0, 30*time.Millisecond)
+	slowest := newPeer(0, 40*time.Millisecond)
+	if d := k.NeighbourhoodDepth(); d != 1 {
+		t.Fatalf("got depth %v, want 1", d)
+	}
+
+	got := h.slowestPeers()
+	if len(got) != 2 {
+		t.Fatalf("got %v slowest peers, want 2", len(got))
+	}
+	for i, want := range []*Peer{slowest, slow} {
+		if got[i] != want {
+			t.Fatalf("got slowest peer %v rtt %v, want rtt %v", i, got[i].RTT(), want.RTT())
+		}
+	}
+}
+
 func testAddPeer(suggestedPeer *BzzAddr, h1 *Hive, nodeIdToBzzAddr map[string]*BzzAddr) {
 	byteAddresses := suggestedPeer.Address()
 	bzzPeer := newConnPeerLocal(byteAddresses, h1.Kademlia)
@@ -69,9 +69,23 @@ type KadParams struct {
 	RetryInterval     int64 // initial interval before a peer is first redialed
 	RetryExponent     int   // exponent to multiply retry intervals with
 	MaxRetries        int   // maximum number of redial attempts
+	// connection counts per proximity order bin, bins deeper than the last
+	// entry use the last entry; if empty, counts are derived from MinBinSize
+	// and MaxBinSize
+	BinTargets []BinTarget
 	// function to sanction or prevent suggesting a peer
 	Reachable    func(*BzzAddr) bool      `json:"-"`
 	Capabilities *capability.Capabilities `json:"-"`
+	// filter of peers allowed to connect, nil for no filtering
+	PeerFilter *PeerFilter `json:"-"`
+}
+
+// BinTarget holds the connection counts of a proximity order bin
+// outside of the neighbourhood, where all peers are connected
+type BinTarget struct {
+	Min    int // connections below which the bin is not saturated
+	Target int // connections dialed for by the hive
+	Max    int // connections over which the surplus peers are disconnected
 }
 
 // NewKadParams returns a params struct with default values
@@ -101,6 +115,7 @@ type Kademlia struct {
 	nDepthSig       []chan struct{}             // signals when neighbourhood depth nDepth is changed
 
 	onOffPeerPubSub *pubsubchannel.PubSubChannel // signals on and off peers in the table
+	eventsPubSub    *pubsubchannel.PubSubChannel // publishes events of changes in the table
 }
 
 type KademliaInfo struct {
@@ -128,12 +143,33 @@ func NewKademlia(addr []byte, params *KadParams) *Kademlia {
 		capabilityIndex: make(map[string]*capabilityIndex),
 		defaultIndex:    NewDefaultIndex(),
 		onOffPeerPubSub: pubsubchannel.New(100),
+		eventsPubSub:    pubsubchannel.New(100),
 	}
-	k.RegisterCapabilityIndex("full", *fullCapability)
-	k.RegisterCapabilityIndex("light", *lightCapability)
+	k.RegisterCapabilityIndex(CapabilityIndexFull, *fullCapability)
+	k.RegisterCapabilityIndex(CapabilityIndexLight, *lightCapability)
+	// light nodes advertise different capabilities depending on the services they provide
+	k.capabilityIndex[CapabilityIndexLight].match = isLightCapability
+	k.RegisterCapabilitySubsetIndex(CapabilityIndexRetrieve, *newCapabilityBits(capabilitiesRetrieve))
+	k.RegisterCapabilitySubsetIndex(CapabilityIndexSync, *newCapabilityBits(capabilitiesSync))
+	k.RegisterCapabilitySubsetIndex(CapabilityIndexStorer, *newCapabilityBits(capabilitiesStorer))
+	k.RegisterCapabilitySubsetIndex(CapabilityIndexRelayDelivery, *newCapabilityBits(capabilitiesRelayDelivery))
+	k.RegisterCapabilitySubsetIndex(CapabilityIndexRelayRetrieve, *newCapabilityBits(capabilitiesRelayRetrieve))
+	k.RegisterCapabilitySubsetIndex(CapabilityIndexRelayPush, *newCapabilityBits(capabilitiesRelayPush))
 	return k
 }
 
+// keys of the capability indices registered by default
+const (
+	CapabilityIndexFull          = "full"           // full nodes
+	CapabilityIndexLight         = "light"          // light nodes
+	CapabilityIndexStorer        = "storer"         // peers that store chunks
+	CapabilityIndexRetrieve      = "retrieve"       // peers that serve retrieve requests
+	CapabilityIndexSync          = "sync"           // peers that serve sync streams
+	CapabilityIndexRelayDelivery = "relay-delivery" // peers that relay chunk deliveries between peers
+	CapabilityIndexRelayRetrieve = "relay-retrieve" // peers that serve retrieve requests of other peers
+	CapabilityIndexRelayPush     = "relay-push"     // peers that relay push sync of other peers
+)
+
 type onOffPeerSignal struct {
 	peer *Peer
 	po   int
@@ -154,6 +190,30 @@ func (k *Kademlia) RegisterCapabilityIndex(s string, c capability.Capability) er
 	return nil
 }
 
+// RegisterCapabilitySubsetIndex adds an entry to the capability index of the kademlia
+// The capability index is associated with the supplied string s
+// Any peers having all bits set in the capability in the index, regardless of their other bits,
+// will be added to the index (or removed on removal)
+func (k *Kademlia) RegisterCapabilitySubsetIndex(s string, c capability.Capability) error {
+	if err := k.RegisterCapabilityIndex(s, c); err != nil {
+		return err
+	}
+	k.capabilityIndex[s].subset = true
+	return nil
+}
+
+// HasCapability returns true if the peer with the provided address matches
+// the capability index with the key capKey
+func (k *Kademlia) HasCapability(capKey string, a *BzzAddr) (bool, error) {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	c, ok := k.capabilityIndex[capKey]
+	if !ok {
+		return false, fmt.Errorf("unregistered capability index '%s'", capKey)
+	}
+	return c.matches(a), nil
+}
+
 // adds a peer to any capability indices it matches
 func (k *Kademlia) addToCapabilityIndex(p interface{}) {
 	var ok bool
@@ -166,17 +226,12 @@ func (k *Kademlia) addToCapabilityIndex(p interface{}) {
 		eAddr = p.(*entry).BzzAddr
 	}
 	for s, idxItem := range k.capabilityIndex {
-		for _, vCap := range eAddr.Capabilities.Caps {
-			if idxItem.Id != vCap.Id {
-				continue
-			}
-			if vCap.IsSameAs(idxItem.Capability) {
-				log.Trace("Added peer to capability index", "conn", ok, "s", s, "v", vCap, "p", p)
-				if ok {
-					k.capabilityIndex[s].conns, _, _ = pot.Add(idxItem.conns, newEntryFromPeer(ePeer), Pof)
-				} else {
-					k.capabilityIndex[s].addrs, _, _ = pot.Add(idxItem.addrs, newEntryFromBzzAddress(eAddr), Pof)
-				}
+		if idxItem.matches(eAddr) {
+			log.Trace("Added peer to capability index", "conn", ok, "s", s, "p", p)
+			if ok {
+				k.capabilityIndex[s].conns, _, _ = pot.Add(idxItem.conns, newEntryFromPeer(ePeer), Pof)
+			} else {
+				k.capabilityIndex[s].addrs, _, _ = pot.Add(idxItem.addrs, newEntryFromBzzAddress(eAddr), Pof)
 			}
 		}
 	}
@@ -246,9 +301,31 @@ func newEntryFromPeer(p *Peer) *entry {
 // index providing quick access to all peers having a certain capability set
 type capabilityIndex struct {
 	*capability.Capability
-	conns *pot.Pot
-	addrs *pot.Pot
-	depth int
+	conns  *pot.Pot
+	addrs  *pot.Pot
+	depth  int
+	subset bool                              // peers with all bits of the capability set match, not only those with the same capability
+	match  func(*capability.Capability) bool // custom match of the peer capability, overrides subset
+}
+
+// matches returns true if the peer with the provided address belongs to the index
+func (idx *capabilityIndex) matches(a *BzzAddr) bool {
+	if a.Capabilities == nil {
+		return false
+	}
+	for _, c := range a.Capabilities.Caps {
+		if c.Id != idx.Id {
+			continue
+		}
+		if idx.match != nil {
+			return idx.match(c)
+		}
+		if idx.subset {
+			return c.Match(idx.Capability)
+		}
+		return c.IsSameAs(idx.Capability)
+	}
+	return false
 }
 
 // NewDefaultIndex creates a new index for no capability
@@ -295,6 +372,11 @@ func (k *Kademlia) Register(peers ...*BzzAddr) error {
 		if bytes.Equal(p.Address(), k.base) {
 			return fmt.Errorf("add peers: %x is self", k.base)
 		}
+		// do not register peers that are not allowed to connect
+		if err := k.PeerFilter.checkAddr(p); err != nil {
+			log.Trace("kademlia not registering filtered peer", "addr", p, "err", err)
+			continue
+		}
 		index := k.defaultIndex
 		index.addrs, _, _, _ = pot.Swap(index.addrs, p, Pof, func(v pot.Val) pot.Val {
 			// if not found
@@ -433,7 +515,7 @@ func (k *Kademlia) SuggestPeer() (suggestedPeer *BzzAddr, saturationDepth int, c
 	}
 
 	if uint8(saturationDepth) < k.saturationDepth {
-		k.saturationDepth = uint8(saturationDepth)
+		k.setSaturationDepth(uint8(saturationDepth))
 		return suggestedPeer, saturationDepth, true
 	}
 	return suggestedPeer, 0, false
@@ -504,6 +586,11 @@ func (k *Kademlia) On(p *Peer) (uint8, bool) {
 	defer k.lock.Unlock()
 	metrics.GetOrRegisterCounter("kad/on", nil).Inc(1)
 
+	if err := k.PeerFilter.checkAddr(p.BzzAddr); err != nil {
+		log.Debug("kademlia not inserting filtered peer", "peer", p, "err", err)
+		return k.saturationDepth, false
+	}
+
 	var ins bool
 	index := k.defaultIndex
 	peerEntry := newEntryFromPeer(p)
@@ -529,14 +616,11 @@ func (k *Kademlia) On(p *Peer) (uint8, bool) {
 		index.addrs, _, _, _ = pot.Swap(index.addrs, a, Pof, func(v pot.Val) pot.Val {
 			return a
 		})
+		peerPo, _ := Pof(p, k.base, 0)
+		k.publishEvent(KademliaEvent{Type: PeerAdded, Peer: p, PO: peerPo})
 	}
 	// calculate if depth of saturation changed
-	depth := uint8(k.saturation())
-	var changed bool
-	if depth != k.saturationDepth {
-		changed = true
-		k.saturationDepth = depth
-	}
+	changed := k.setSaturationDepth(uint8(k.saturation()))
 	k.setNeighbourhoodDepth()
 	return k.saturationDepth, changed
 }
@@ -561,6 +645,9 @@ func (k *Kademlia) setNeighbourhoodDepth() {
 	}
 	k.nDepthMu.Unlock()
 
+	if changed {
+		k.publishEvent(KademliaEvent{Type: DepthChanged, Depth: nDepth})
+	}
 	if len(k.nDepthSig) > 0 && changed {
 		for _, c := range k.nDepthSig {
 			// Every nDepthSig channel has a buffer capacity of 1,
@@ -650,6 +737,8 @@ func (k *Kademlia) Off(p *Peer) {
 		return nil
 	})
 	k.removeFromCapabilityIndex(p, true)
+	po, _ := Pof(p, k.base, 0)
+	k.publishEvent(KademliaEvent{Type: PeerRemoved, Peer: p, PO: po})
 	k.setNeighbourhoodDepth()
 	k.onOffPeerPubSub.Publish(onOffPeerSignal{peer: p, po: -1, on: false})
 }
@@ -874,6 +963,10 @@ func (k *Kademlia) callable(e *entry) bool {
 		return false
 	}
 	// function to sanction or prevent suggesting a peer
+	if err := k.PeerFilter.checkAddr(e.BzzAddr); err != nil {
+		log.Trace(fmt.Sprintf("%08x: peer %v is not callable: %v", k.BaseAddr()[:4], e, err))
+		return false
+	}
 	if k.Reachable != nil && !k.Reachable(e.BzzAddr) {
 		log.Trace(fmt.Sprintf("%08x: peer %v is temporarily not callable", k.BaseAddr()[:4], e))
 		return false
@@ -887,6 +980,7 @@ func (k *Kademlia) callable(e *entry) bool {
 // IsClosestTo returns true if self is the closest peer to addr among filtered peers
 // ie. return false iff there is a peer that
 // - filter(bzzpeer) == true AND
+// - the peer does not advertise a storage radius that excludes addr or no free capacity AND
 // - pot.DistanceCmp(addr, peeraddress, selfaddress) == 1
 func (k *Kademlia) IsClosestTo(addr []byte, filter func(*BzzPeer) bool) (closest bool) {
 	myPo := chunk.Proximity(addr, k.BaseAddr())
@@ -896,6 +990,10 @@ func (k *Kademlia) IsClosestTo(addr []byte, filter func(*BzzPeer) bool) (closest
 		if !filter(p.BzzPeer) {
 			return true
 		}
+		// skip peers that advertise that they would not store the chunk
+		if info, ok := p.StorageInfo(); ok && (!info.WithinRadius(po) || info.Full()) {
+			return true
+		}
 		if po != myPo {
 			closest = po < myPo
 			return false
@@ -1261,6 +1359,15 @@ func (k *Kademlia) connectedNeighbours(peers [][]byte) (got bool, n int, missing
 
 //Calculates the expected min size of a given bin (minBinSize)
 func (k *Kademlia) expectedMinBinSize(proximityOrder int) int {
+	if len(k.BinTargets) > 0 {
+		return k.binTarget(proximityOrder).Min
+	}
+	return k.defaultMinBinSize(proximityOrder)
+}
+
+// defaultMinBinSize calculates the min size of a bin from MinBinSize
+// growing towards shallower bins relative to the neighbourhood depth
+func (k *Kademlia) defaultMinBinSize(proximityOrder int) int {
 	depth := depthForPot(k.defaultIndex.conns, k.NeighbourhoodSize, k.base)
 
 	minBinSize := k.MinBinSize + (depth - proximityOrder - 1)
@@ -1332,3 +1439,112 @@ func (k *Kademlia) GetHealthInfo(pp *PeerPot) *Health {
 func (h *Health) Healthy() bool {
 	return h.KnowNN && h.ConnectNN && h.CountKnowNN > 0 && h.Saturated
 }
+
+// BinTarget returns the connection counts of the bin with the proximity order po
+func (k *Kademlia) BinTarget(po int) BinTarget {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return k.binTarget(po)
+}
+
+func (k *Kademlia) binTarget(po int) BinTarget {
+	n := len(k.BinTargets)
+	if n == 0 {
+		min := k.defaultMinBinSize(po)
+		return BinTarget{Min: min, Target: min, Max: k.MaxBinSize}
+	}
+	t := k.BinTargets[n-1]
+	if po < n {
+		t = k.BinTargets[po]
+	}
+	// correct inconsistent configuration so that Min <= Target <= Max
+	if t.Target < t.Min {
+		t.Target = t.Min
+	}
+	if t.Max < t.Target {
+		t.Max = t.Target
+	}
+	return t
+}
+
+// SuggestPeers returns at most n callable peers to connect to in order
+// to meet the connection targets of the bins, together with the saturation
+// depth and whether it decreased. Peers from bins under their minimal
+// connection count are suggested first, starting with the bins with the
+// fewest connections. All known peers within the neighbourhood depth
+// are suggested as the neighbourhood must be fully connected.
+func (k *Kademlia) SuggestPeers(n int) (peers []*BzzAddr, saturationDepth int, changed bool) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	metrics.GetOrRegisterCounter("kad/suggestpeers", nil).Inc(1)
+
+	depth := depthForPot(k.defaultIndex.conns, k.NeighbourhoodSize, k.base)
+	conns := make(map[int]int)
+	k.defaultIndex.conns.EachBin(k.base, Pof, 0, func(bin *pot.Bin) bool {
+		conns[bin.ProximityOrder] = bin.Size
+		return true
+	}, true)
+
+	// bins wanting more connections
+	type binWant struct {
+		po          int
+		conns       int
+		need        int
+		unsaturated bool
+		entries     []*entry // known peers not connected
+	}
+	var wants []*binWant
+	k.defaultIndex.addrs.EachBin(k.base, Pof, 0, func(bin *pot.Bin) bool {
+		po := bin.ProximityOrder
+		w := &binWant{
+			po:    po,
+			conns: conns[po],
+		}
+		if po >= depth {
+			w.need = bin.Size - w.conns
+			w.unsaturated = true
+		} else {
+			t := k.binTarget(po)
+			w.need = t.Target - w.conns
+			w.unsaturated = w.conns < t.Min
+		}
+		if w.need <= 0 {
+			return true
+		}
+		bin.ValIterator(func(val pot.Val) bool {
+			if e := val.(*entry); e.conn == nil {
+				w.entries = append(w.entries, e)
+			}
+			return true
+		})
+		wants = append(wants, w)
+		return true
+	}, true)
+
+	// bins are already in ascending order of proximity order
+	sort.SliceStable(wants, func(i, j int) bool {
+		if wants[i].unsaturated != wants[j].unsaturated {
+			return wants[i].unsaturated
+		}
+		return wants[i].conns < wants[j].conns
+	})
+
+	for _, w := range wants {
+		for _, e := range w.entries {
+			if len(peers) >= n || w.need == 0 {
+				break
+			}
+			if k.callable(e) {
+				peers = append(peers, e.BzzAddr)
+				w.need--
+			}
+		}
+	}
+
+	saturationDepth = k.saturation()
+	if uint8(saturationDepth) < k.saturationDepth {
+		changed = k.setSaturationDepth(uint8(saturationDepth))
+	}
+	return peers, saturationDepth, changed
+}
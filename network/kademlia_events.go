@@ -0,0 +1,130 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"fmt"
+	"sync"
+)
+
+// KademliaEventType is the type of a change in the kademlia table
+type KademliaEventType int
+
+const (
+	// PeerAdded is emitted when a peer is connected
+	PeerAdded KademliaEventType = iota
+	// PeerRemoved is emitted when a peer is disconnected
+	PeerRemoved
+	// DepthChanged is emitted when the neighbourhood depth changes
+	DepthChanged
+	// SaturationChanged is emitted when the saturation depth changes
+	SaturationChanged
+)
+
+// String returns the name of the event type
+func (t KademliaEventType) String() string {
+	switch t {
+	case PeerAdded:
+		return "peer added"
+	case PeerRemoved:
+		return "peer removed"
+	case DepthChanged:
+		return "depth changed"
+	case SaturationChanged:
+		return "saturation changed"
+	}
+	return fmt.Sprintf("unknown (%d)", int(t))
+}
+
+// KademliaEvent is a change in the kademlia table
+type KademliaEvent struct {
+	Type KademliaEventType
+	// Peer is the added or removed peer and PO its proximity order
+	Peer *Peer
+	PO   int
+	// Depth is the new neighbourhood depth for DepthChanged
+	// and the new saturation depth for SaturationChanged events
+	Depth int
+}
+
+// String pretty prints the event
+func (e KademliaEvent) String() string {
+	switch e.Type {
+	case PeerAdded, PeerRemoved:
+		return fmt.Sprintf("%v: %s po %d", e.Type, e.Peer.Label(), e.PO)
+	}
+	return fmt.Sprintf("%v: %d", e.Type, e.Depth)
+}
+
+// SubscribeEvents returns the channel that receives the events of changes
+// in the kademlia table in the order they happened. Events are buffered, but
+// the subscriber is expected to receive them promptly as changes to the table
+// block when the buffer is full. Returned function unsubscribes the channel,
+// closes it and releases the resources. Returned function is safe to be
+// called multiple times.
+func (k *Kademlia) SubscribeEvents() (c <-chan KademliaEvent, unsubscribe func()) {
+	sub := k.eventsPubSub.Subscribe()
+	events := make(chan KademliaEvent)
+	quit := make(chan struct{})
+	go func() {
+		defer close(events)
+		for {
+			select {
+			case msg, ok := <-sub.ReceiveChannel():
+				if !ok {
+					return
+				}
+				e, ok := msg.(KademliaEvent)
+				if !ok {
+					continue
+				}
+				select {
+				case events <- e:
+				case <-quit:
+					return
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	unsubscribe = func() {
+		once.Do(func() {
+			close(quit)
+			sub.Unsubscribe()
+		})
+	}
+	return events, unsubscribe
+}
+
+// publishEvent notifies event subscribers about a change in the table
+func (k *Kademlia) publishEvent(e KademliaEvent) {
+	k.eventsPubSub.Publish(e)
+}
+
+// setSaturationDepth sets the saturation depth and emits an event
+// if it changed
+func (k *Kademlia) setSaturationDepth(depth uint8) (changed bool) {
+	if depth == k.saturationDepth {
+		return false
+	}
+	k.saturationDepth = depth
+	k.publishEvent(KademliaEvent{Type: SaturationChanged, Depth: int(depth)})
+	return true
+}
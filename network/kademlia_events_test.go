@@ -0,0 +1,95 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// TestSubscribeEvents tests that kademlia emits events about added and
+// removed peers and changes of depth in the order they happened
+func TestSubscribeEvents(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+
+	events, unsubscribe := tk.SubscribeEvents()
+	defer unsubscribe()
+
+	next := func() KademliaEvent {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for kademlia event")
+		}
+		return KademliaEvent{}
+	}
+	// expect skips the events of other types until the one of the given type
+	expect := func(typ KademliaEventType) KademliaEvent {
+		t.Helper()
+		for {
+			if e := next(); e.Type == typ {
+				return e
+			}
+		}
+	}
+
+	tk.On("10000000")
+	e := next()
+	if e.Type != PeerAdded {
+		t.Fatalf("got event %v, want %v", e, PeerAdded)
+	}
+	if !bytes.Equal(e.Peer.Address(), testKadPeerAddr("10000000").Address()) || e.PO != 0 {
+		t.Fatalf("got event %v, want peer 10000000 in po 0", e)
+	}
+
+	tk.On("01000000", "00100000", "00010000")
+	for _, po := range []int{1, 2, 3} {
+		if e := expect(PeerAdded); e.PO != po {
+			t.Fatalf("got event %v, want po %d", e, po)
+		}
+	}
+	if depth := tk.NeighbourhoodDepth(); depth == 0 {
+		t.Fatal("expected non zero depth")
+	}
+
+	tk.Off("00010000")
+	e = expect(PeerRemoved)
+	if e.PO != 3 {
+		t.Fatalf("got event %v, want po 3", e)
+	}
+	e = expect(DepthChanged)
+	if e.Depth != tk.NeighbourhoodDepth() {
+		t.Fatalf("got event %v, want depth %d", e, tk.NeighbourhoodDepth())
+	}
+
+	unsubscribe()
+	// the channel is closed once unsubscribed
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("events channel not closed")
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ethersphere/swarm/pot"
+)
+
+// KademliaStatus is a structured report of the kademlia connectivity
+// for monitoring systems to alert on its degradation
+type KademliaStatus struct {
+	Self              string            `json:"self"`
+	Depth             int               `json:"depth"`              // neighbourhood depth
+	Saturation        int               `json:"saturation"`         // smallest po with less than expected connections
+	Saturated         bool              `json:"saturated"`          // all bins shallower than depth have expected connections
+	NeighbourhoodSize int               `json:"neighbourhood_size"` // number of peers expected in the neighbourhood
+	MinBinSize        int               `json:"min_bin_size"`
+	MaxBinSize        int               `json:"max_bin_size"`
+	TotalConnections  int               `json:"total_connections"`
+	TotalKnown        int               `json:"total_known"`
+	Bins              []BinStatus       `json:"bins"`
+	SuggestPeer       SuggestPeerStatus `json:"suggest_peer"`
+}
+
+// BinStatus reports the connectivity of a single kademlia bin
+// Peers further than MaxProxDisplay are reported in the last bin
+type BinStatus struct {
+	ProximityOrder  int  `json:"po"`
+	Connections     int  `json:"connections"`
+	Known           int  `json:"known"`
+	ExpectedMinSize int  `json:"expected_min_size"` // connections expected in the bin for it to be saturated
+	TargetSize      int  `json:"target_size"`       // connections dialed for in the bin
+	MaxSize         int  `json:"max_size"`          // connections over which surplus peers are disconnected
+	Saturated       bool `json:"saturated"`         // bin has expected connections or is in the neighbourhood
+}
+
+// SuggestPeerStatus reports the state of peer suggestion for new connections
+type SuggestPeerStatus struct {
+	SaturationDepth int `json:"saturation_depth"` // last saturation depth reported by SuggestPeer
+	Candidates      int `json:"candidates"`       // known peers not connected and not exceeding retries
+	Exhausted       int `json:"exhausted"`        // known peers not connected that exceeded retries
+}
+
+// KademliaStatus returns the structured status of the kademlia table
+func (k *Kademlia) KademliaStatus() KademliaStatus {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return k.kademliaStatus()
+}
+
+func (k *Kademlia) kademliaStatus() (ks KademliaStatus) {
+	ks.Self = hex.EncodeToString(k.BaseAddr())
+	ks.Depth = depthForPot(k.defaultIndex.conns, k.NeighbourhoodSize, k.base)
+	ks.Saturation = k.saturation()
+	ks.NeighbourhoodSize = k.NeighbourhoodSize
+	ks.MinBinSize = k.MinBinSize
+	ks.MaxBinSize = k.MaxBinSize
+	ks.TotalConnections = k.defaultIndex.conns.Size()
+	ks.TotalKnown = k.defaultIndex.addrs.Size()
+	ks.SuggestPeer.SaturationDepth = int(k.saturationDepth)
+
+	ks.Bins = make([]BinStatus, k.MaxProxDisplay)
+	for po := range ks.Bins {
+		ks.Bins[po].ProximityOrder = po
+	}
+	binPo := func(po int) int {
+		if po >= k.MaxProxDisplay {
+			return k.MaxProxDisplay - 1
+		}
+		return po
+	}
+
+	k.defaultIndex.conns.EachBin(k.base, Pof, 0, func(bin *pot.Bin) bool {
+		ks.Bins[binPo(bin.ProximityOrder)].Connections += bin.Size
+		return true
+	}, true)
+
+	k.defaultIndex.addrs.EachBin(k.base, Pof, 0, func(bin *pot.Bin) bool {
+		ks.Bins[binPo(bin.ProximityOrder)].Known += bin.Size
+		bin.ValIterator(func(val pot.Val) bool {
+			e := val.(*entry)
+			if e.conn != nil {
+				return true
+			}
+			if e.retries > k.MaxRetries {
+				ks.SuggestPeer.Exhausted++
+			} else {
+				ks.SuggestPeer.Candidates++
+			}
+			return true
+		})
+		return true
+	}, true)
+
+	ks.Saturated = true
+	for po := range ks.Bins {
+		b := &ks.Bins[po]
+		t := k.binTarget(po)
+		b.ExpectedMinSize = t.Min
+		b.TargetSize = t.Target
+		b.MaxSize = t.Max
+		// all peers in the neighbourhood are connected, so it can not
+		// be saturated with more connections
+		b.Saturated = po >= ks.Depth || b.Connections >= b.ExpectedMinSize
+		if !b.Saturated {
+			ks.Saturated = false
+		}
+	}
+	return ks
+}
+
+// KademliaStatusHandler returns a http handler that serves
+// the kademlia status as JSON
+func (k *Kademlia) KademliaStatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(k.KademliaStatus()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
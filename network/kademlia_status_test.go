@@ -0,0 +1,105 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// TestKademliaStatus checks the connectivity status reported for the
+// bins of the kademlia table and its saturation
+func TestKademliaStatus(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	tk.On("10000000", "11000000", "10100000") // po 0
+	tk.On("01000000")                         // po 1
+	tk.On("00100000", "00110000")             // po 2, neighbourhood
+	tk.Register("00010000")                   // known, not connected
+
+	s := tk.KademliaStatus()
+	if s.Depth != 2 {
+		t.Fatalf("got depth %v, want 2", s.Depth)
+	}
+	if s.TotalConnections != 6 {
+		t.Fatalf("got %v connections, want 6", s.TotalConnections)
+	}
+	if s.TotalKnown != 7 {
+		t.Fatalf("got %v known peers, want 7", s.TotalKnown)
+	}
+	if s.SuggestPeer.Candidates != 1 {
+		t.Fatalf("got %v suggest peer candidates, want 1", s.SuggestPeer.Candidates)
+	}
+	for po, want := range []BinStatus{
+		{ProximityOrder: 0, Connections: 3, Known: 3, ExpectedMinSize: 3, TargetSize: 3, MaxSize: 16, Saturated: true},
+		{ProximityOrder: 1, Connections: 1, Known: 1, ExpectedMinSize: 2, TargetSize: 2, MaxSize: 16, Saturated: false},
+		{ProximityOrder: 2, Connections: 2, Known: 2, ExpectedMinSize: 2, TargetSize: 2, MaxSize: 16, Saturated: true},
+		{ProximityOrder: 3, Connections: 0, Known: 1, ExpectedMinSize: 2, TargetSize: 2, MaxSize: 16, Saturated: true},
+	} {
+		if got := s.Bins[po]; got != want {
+			t.Fatalf("got bin %v status %+v, want %+v", po, got, want)
+		}
+	}
+	if s.Saturated {
+		t.Fatal("expected kademlia not to be saturated")
+	}
+	if s.Saturation != 1 {
+		t.Fatalf("got saturation %v, want 1", s.Saturation)
+	}
+
+	tk.On("01100000")
+	s = tk.KademliaStatus()
+	if !s.Saturated {
+		t.Fatal("expected kademlia to be saturated")
+	}
+}
+
+// TestKademliaStatusHandler validates the kademlia status served as JSON
+func TestKademliaStatusHandler(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	tk.On("10000000", "01000000")
+
+	srv := httptest.NewServer(tk.KademliaStatusHandler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+	var got KademliaStatus
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if want := tk.KademliaStatus(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got status %+v, want %+v", got, want)
+	}
+
+	resp, err = http.Post(srv.URL, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("got status %v, want %v", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
@@ -17,6 +17,7 @@
 package network
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"testing"
@@ -1031,6 +1032,157 @@ func testCapabilityIndexRemove(t *testing.T) {
 	}
 }
 
+// TestBinTargets validates the connection counts of the bins derived
+// from the kademlia parameters and configured explicitly
+func TestBinTargets(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	if got, want := tk.BinTarget(0), (BinTarget{Min: 2, Target: 2, Max: 16}); got != want {
+		t.Fatalf("got default bin target %+v, want %+v", got, want)
+	}
+
+	tk.BinTargets = []BinTarget{
+		{Min: 3, Target: 5, Max: 8},
+		{Min: 2, Target: 1, Max: 0},
+	}
+	for po, want := range map[int]BinTarget{
+		0: {Min: 3, Target: 5, Max: 8},
+		1: {Min: 2, Target: 2, Max: 2},
+		7: {Min: 2, Target: 2, Max: 2},
+	} {
+		if got := tk.BinTarget(po); got != want {
+			t.Fatalf("got bin %v target %+v, want %+v", po, got, want)
+		}
+	}
+	if got := tk.expectedMinBinSize(0); got != 3 {
+		t.Fatalf("got expected min bin size %v, want 3", got)
+	}
+}
+
+// TestSuggestPeersBinTargets checks that peers are suggested to meet the bin targets,
+// first in the neighbourhood and the unsaturated bins, and that peers are
+// not suggested again before their retry interval
+func TestSuggestPeersBinTargets(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	tk.BinTargets = []BinTarget{{Min: 1, Target: 2, Max: 4}}
+
+	tk.On("10000000")             // po 0
+	tk.On("00100000", "00110000") // po 2
+	tk.Register("11000000", "11100000", "11110000")
+	tk.Register("01000000", "01100000")
+	if d := tk.NeighbourhoodDepth(); d != 1 {
+		t.Fatalf("got depth %v, want 1", d)
+	}
+
+	peers, _, _ := tk.SuggestPeers(1)
+	if len(peers) != 1 {
+		t.Fatalf("got %v suggested peers, want 1", len(peers))
+	}
+	suggested := []*BzzAddr{peers[0]}
+
+	peers, _, _ = tk.SuggestPeers(10)
+	if len(peers) != 2 {
+		t.Fatalf("got %v suggested peers, want 2", len(peers))
+	}
+	suggested = append(suggested, peers...)
+
+	// the neighbourhood bin is connected fully, then the shallow bin up to its target
+	for i, want := range []int{1, 1, 0} {
+		if po, _ := Pof(tk.BaseAddr(), suggested[i].Address(), 0); po != want {
+			t.Fatalf("got suggested peer %v in bin %v, want %v", i, po, want)
+		}
+	}
+	if bytes.Equal(suggested[0].Address(), suggested[1].Address()) {
+		t.Fatal("peer suggested twice")
+	}
+
+	// peers already suggested are not suggested again before their retry interval
+	peers, _, _ = tk.SuggestPeers(10)
+	for _, p := range peers {
+		for _, s := range suggested {
+			if bytes.Equal(p.Address(), s.Address()) {
+				t.Fatalf("peer %v suggested again", p)
+			}
+		}
+	}
+}
+
+// TestCapabilitySubsetIndex tests that the default capability indices match
+// peers by the capability bits they advertise, so that peers that can not
+// serve a class of requests are skipped when iterating over the index
+func TestCapabilitySubsetIndex(t *testing.T) {
+	k := NewKademlia(RandomBzzAddr().OAddr, NewKadParams())
+
+	// storer node that does not relay requests of other peers
+	storerCap := newCapabilityBits(capabilitiesRetrieve, capabilitiesPush, capabilitiesStorer)
+
+	peers := make(map[string]*Peer)
+	for name, c := range map[string]*capability.Capability{
+		"full":   newFullCapability(),
+		"light":  newLightCapability(),
+		"storer": storerCap,
+	} {
+		a := RandomBzzAddr()
+		a.Capabilities.Add(c)
+		peers[name] = NewPeer(&BzzPeer{BzzAddr: a}, k)
+		k.On(peers[name])
+	}
+
+	for _, tc := range []struct {
+		capKey string
+		want   []string
+	}{
+		{CapabilityIndexFull, []string{"full"}},
+		{CapabilityIndexLight, []string{"light"}},
+		{CapabilityIndexStorer, []string{"full", "storer"}},
+		{CapabilityIndexRelayRetrieve, []string{"full"}},
+		{CapabilityIndexRelayPush, []string{"full"}},
+		{CapabilityIndexRelayDelivery, []string{"full"}},
+	} {
+		got := make(map[string]bool)
+		err := k.EachConnFiltered(k.BaseAddr(), tc.capKey, 255, func(p *Peer, _ int) bool {
+			for name, peer := range peers {
+				if bytes.Equal(p.Address(), peer.Address()) {
+					got[name] = true
+				}
+			}
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("index %q: got %d peers, want %d", tc.capKey, len(got), len(tc.want))
+		}
+		for _, name := range tc.want {
+			if !got[name] {
+				t.Errorf("index %q: peer %q not found", tc.capKey, name)
+			}
+			ok, err := k.HasCapability(tc.capKey, peers[name].BzzAddr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				t.Errorf("index %q: peer %q has no capability", tc.capKey, name)
+			}
+		}
+	}
+
+	if _, err := k.HasCapability("unknown", peers["full"].BzzAddr); err == nil {
+		t.Fatal("expected error for unregistered capability index")
+	}
+
+	// peers are removed from the subset indices on disconnect
+	k.Off(peers["full"])
+	c := 0
+	k.EachConnFiltered(k.BaseAddr(), CapabilityIndexStorer, 255, func(_ *Peer, _ int) bool {
+		c++
+		return true
+	})
+	if c != 1 {
+		t.Fatalf("index %q: got %d peers after disconnect, want 1", CapabilityIndexStorer, c)
+	}
+}
+
 // TestCapabilityNeighbourhoodDepth tests that depth calculations filtered by capability is correct
 func TestCapabilityNeighbourhoodDepth(t *testing.T) {
 	baseAddressBytes := RandomBzzAddr().OAddr
@@ -1159,3 +1311,31 @@ func (tk *testKademlia) getAddressBin(po int) *pot.Bin {
 func bzzAddrToBinary(bzzAddress *BzzAddr) string {
 	return byteToBitString(bzzAddress.OAddr[0])
 }
+
+// TestIsClosestToStorageInfo checks that peers that advertise storage info
+// excluding the address are not considered closer than self
+func TestIsClosestToStorageInfo(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	p := tk.newTestKadPeer("11000000")
+	tk.Kademlia.On(p)
+
+	addr := pot.NewAddressFromString("11100000")
+	all := func(*BzzPeer) bool { return true }
+	if tk.IsClosestTo(addr, all) {
+		t.Fatal("expected peer without storage info to be closer")
+	}
+
+	for _, tc := range []struct {
+		info    StorageInfo
+		closest bool
+	}{
+		{StorageInfo{Radius: 2, FreeCapacity: 10}, false},
+		{StorageInfo{Radius: 3, FreeCapacity: 10}, true},
+		{StorageInfo{Radius: 2, FreeCapacity: 0}, true},
+	} {
+		p.setStorageInfo(tc.info)
+		if closest := tk.IsClosestTo(addr, all); closest != tc.closest {
+			t.Errorf("storage info %v: got closest %v, want %v", tc.info, closest, tc.closest)
+		}
+	}
+}
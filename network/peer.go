@@ -19,7 +19,9 @@ package network
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -35,6 +37,14 @@ type Peer struct {
 	peers     map[string]bool // tracks node records sent to the peer
 	depth     uint8           // the proximity order advertised by remote as depth of saturation
 	key       string          // peer key. Hex form of Address()
+
+	rttMu     sync.Mutex    // protects round trip time measurement
+	rtt       time.Duration // smoothed round trip time, zero if not measured yet
+	pingNonce uint64        // nonce of the last ping sent
+	pingSent  time.Time     // time the last ping was sent, zero if it was answered
+
+	storageMu sync.RWMutex // protects storage
+	storage   *StorageInfo // storage info advertised by the remote, nil if none
 }
 
 // NewPeer constructs a discovery peer
@@ -44,6 +54,7 @@ func NewPeer(p *BzzPeer, kad *Kademlia) *Peer {
 		BzzPeer: p,
 		peers:   make(map[string]bool),
 		key:     hexutil.Encode(p.Address()),
+		storage: p.storage,
 	}
 	// record remote as seen so we never send a peer its own record
 	d.seen(p.BzzAddr)
@@ -135,6 +146,62 @@ type subPeersMsg struct {
 	Depth uint8
 }
 
+// pingMsg is sent to a peer to measure the round trip time to it
+type pingMsg struct {
+	Nonce uint64
+}
+
+// String returns the pretty printer
+func (msg pingMsg) String() string {
+	return fmt.Sprintf("%T: %d", msg, msg.Nonce)
+}
+
+// pongMsg is the response to pingMsg with the same nonce
+type pongMsg struct {
+	Nonce uint64
+}
+
+// String returns the pretty printer
+func (msg pongMsg) String() string {
+	return fmt.Sprintf("%T: %d", msg, msg.Nonce)
+}
+
+// Ping sends a ping message to the remote node to measure the round trip time
+// A previous ping that was not answered yet is discarded
+func (d *Peer) Ping(ctx context.Context) error {
+	nonce := rand.Uint64()
+	d.rttMu.Lock()
+	d.pingNonce = nonce
+	d.pingSent = time.Now()
+	d.rttMu.Unlock()
+	return d.Send(ctx, &pingMsg{Nonce: nonce})
+}
+
+// RTT returns the smoothed round trip time to the peer
+// or zero if it is not measured yet
+func (d *Peer) RTT() time.Duration {
+	d.rttMu.Lock()
+	defer d.rttMu.Unlock()
+	return d.rtt
+}
+
+// handlePong updates the round trip time if the pong answers the last ping
+func (d *Peer) handlePong(msg *pongMsg) {
+	d.rttMu.Lock()
+	defer d.rttMu.Unlock()
+	if d.pingSent.IsZero() || msg.Nonce != d.pingNonce {
+		return
+	}
+	sample := time.Since(d.pingSent)
+	d.pingSent = time.Time{}
+	if d.rtt == 0 {
+		d.rtt = sample
+		return
+	}
+	// exponentially weighted moving average as for tcp smoothed rtt
+	d.rtt = (7*d.rtt + sample) / 8
+}
+
 // String returns the pretty printer
 func (msg subPeersMsg) String() string {
 	return fmt.Sprintf("%T: request peers > PO%02d. ", msg, msg.Depth)
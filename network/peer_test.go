@@ -18,6 +18,7 @@ package network
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"encoding/binary"
 	"fmt"
@@ -235,6 +236,46 @@ func testSortPeers(peers []*BzzAddr) []*BzzAddr {
 // as we are not creating a real node via the protocol,
 // we need to create the discovery peer objects for the additional kademlia
 // nodes manually
+// TestPeerRTT validates the round trip time measured from pong messages
+func TestPeerRTT(t *testing.T) {
+	p := newDiscPeer(pot.RandomAddress())
+	if rtt := p.RTT(); rtt != 0 {
+		t.Fatalf("got rtt %v before ping, want 0", rtt)
+	}
+	if err := p.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// pong with another nonce is ignored
+	p.handlePong(&pongMsg{Nonce: p.pingNonce + 1})
+	if rtt := p.RTT(); rtt != 0 {
+		t.Fatalf("got rtt %v for unknown nonce, want 0", rtt)
+	}
+
+	p.pingSent = time.Now().Add(-100 * time.Millisecond)
+	p.handlePong(&pongMsg{Nonce: p.pingNonce})
+	rtt := p.RTT()
+	if rtt < 100*time.Millisecond {
+		t.Fatalf("got rtt %v, want at least 100ms", rtt)
+	}
+
+	// the same pong is not counted twice
+	p.handlePong(&pongMsg{Nonce: p.pingNonce})
+	if got := p.RTT(); got != rtt {
+		t.Fatalf("got rtt %v for repeated pong, want %v", got, rtt)
+	}
+
+	// further measurements are smoothed
+	if err := p.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	p.pingSent = time.Now().Add(-900 * time.Millisecond)
+	p.handlePong(&pongMsg{Nonce: p.pingNonce})
+	if got := p.RTT(); got < 200*time.Millisecond || got > 300*time.Millisecond {
+		t.Fatalf("got smoothed rtt %v, want between 200ms and 300ms", got)
+	}
+}
+
 func newDiscPeer(addr pot.Address) *Peer {
 	// deterministically create enode id
 	// Input to the non-random input buffer is 2xaddress since it munches 256 bits
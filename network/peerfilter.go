@@ -0,0 +1,213 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/state"
+)
+
+var (
+	// ErrPeerBlocked is returned for peers matching an entry in the blocklist
+	ErrPeerBlocked = errors.New("peer is blocked")
+	// ErrPeerNotAllowed is returned for peers not matching any entry
+	// in a non-empty allowlist
+	ErrPeerNotAllowed = errors.New("peer is not allowed")
+	// ErrInvalidPeerFilterEntry is returned for entries that do not
+	// specify exactly one of the enode ID or the overlay address prefix
+	ErrInvalidPeerFilterEntry = errors.New("invalid peer filter entry")
+	// ErrPeerFilterNotSet is returned when the lists of a nil
+	// PeerFilter are changed
+	ErrPeerFilterNotSet = errors.New("peer filter not set")
+)
+
+// peerFilterKey is the state store key of the persisted peer filter lists
+const peerFilterKey = "peer_filter"
+
+// PeerFilterEntry identifies peers either by their enode ID or
+// by the prefix of their overlay address
+type PeerFilterEntry struct {
+	ID     *enode.ID     `json:"id,omitempty"`
+	Prefix hexutil.Bytes `json:"prefix,omitempty"`
+}
+
+func (e PeerFilterEntry) validate() error {
+	if (e.ID == nil) == (len(e.Prefix) == 0) {
+		return ErrInvalidPeerFilterEntry
+	}
+	return nil
+}
+
+func (e PeerFilterEntry) equals(o PeerFilterEntry) bool {
+	if e.ID != nil || o.ID != nil {
+		return e.ID != nil && o.ID != nil && *e.ID == *o.ID
+	}
+	return bytes.Equal(e.Prefix, o.Prefix)
+}
+
+// matches returns true if the peer with the provided enode ID and
+// overlay address is identified by the entry
+func (e PeerFilterEntry) matches(id enode.ID, overlay []byte) bool {
+	if e.ID != nil {
+		return *e.ID == id
+	}
+	return bytes.HasPrefix(overlay, e.Prefix)
+}
+
+// PeerFilterLists holds the entries of the blocklist and the allowlist
+type PeerFilterLists struct {
+	Blocked []PeerFilterEntry `json:"blocked"`
+	Allowed []PeerFilterEntry `json:"allowed"`
+}
+
+// PeerFilter decides which peers are allowed to connect based on a
+// blocklist and an allowlist that can be changed at runtime. Peers
+// matching a blocklist entry are rejected. If the allowlist is not empty,
+// only peers matching one of its entries are accepted. A nil PeerFilter
+// accepts all peers.
+type PeerFilter struct {
+	mu    sync.RWMutex
+	store state.Store // persists the lists, nil for no persistence
+	lists PeerFilterLists
+}
+
+// NewPeerFilter returns a new PeerFilter with the lists loaded
+// from the provided store. If the store is nil, the lists are not
+// persisted.
+func NewPeerFilter(store state.Store) (*PeerFilter, error) {
+	f := &PeerFilter{
+		store: store,
+	}
+	if store != nil {
+		if err := store.Get(peerFilterKey, &f.lists); err != nil && err != state.ErrNotFound {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Check returns ErrPeerBlocked or ErrPeerNotAllowed if the peer
+// with the provided enode ID and overlay address is not accepted
+func (f *PeerFilter) Check(id enode.ID, overlay []byte) error {
+	if f == nil {
+		return nil
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, e := range f.lists.Blocked {
+		if e.matches(id, overlay) {
+			return ErrPeerBlocked
+		}
+	}
+	if len(f.lists.Allowed) == 0 {
+		return nil
+	}
+	for _, e := range f.lists.Allowed {
+		if e.matches(id, overlay) {
+			return nil
+		}
+	}
+	return ErrPeerNotAllowed
+}
+
+// checkAddr checks the peer with the provided address
+func (f *PeerFilter) checkAddr(a *BzzAddr) error {
+	if f == nil {
+		return nil
+	}
+	return f.Check(a.ID(), a.Over())
+}
+
+// Block adds an entry to the blocklist
+func (f *PeerFilter) Block(e PeerFilterEntry) error {
+	if f == nil {
+		return ErrPeerFilterNotSet
+	}
+	return f.update(e, &f.lists.Blocked, true)
+}
+
+// Unblock removes an entry from the blocklist
+func (f *PeerFilter) Unblock(e PeerFilterEntry) error {
+	if f == nil {
+		return ErrPeerFilterNotSet
+	}
+	return f.update(e, &f.lists.Blocked, false)
+}
+
+// Allow adds an entry to the allowlist
+func (f *PeerFilter) Allow(e PeerFilterEntry) error {
+	if f == nil {
+		return ErrPeerFilterNotSet
+	}
+	return f.update(e, &f.lists.Allowed, true)
+}
+
+// Disallow removes an entry from the allowlist
+func (f *PeerFilter) Disallow(e PeerFilterEntry) error {
+	if f == nil {
+		return ErrPeerFilterNotSet
+	}
+	return f.update(e, &f.lists.Allowed, false)
+}
+
+// Lists returns a copy of the blocklist and the allowlist entries
+func (f *PeerFilter) Lists() (l PeerFilterLists) {
+	if f == nil {
+		return l
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	l.Blocked = append([]PeerFilterEntry{}, f.lists.Blocked...)
+	l.Allowed = append([]PeerFilterEntry{}, f.lists.Allowed...)
+	return l
+}
+
+// update adds or removes the entry from the list and persists the lists
+func (f *PeerFilter) update(e PeerFilterEntry, list *[]PeerFilterEntry, add bool) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	prev := *list
+	var entries []PeerFilterEntry
+	for _, v := range *list {
+		if !v.equals(e) {
+			entries = append(entries, v)
+		}
+	}
+	if add {
+		entries = append(entries, e)
+	}
+	*list = entries
+	if f.store == nil {
+		return nil
+	}
+	if err := f.store.Put(peerFilterKey, f.lists); err != nil {
+		*list = prev
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/state"
+)
+
+// TestPeerFilter validates accepting peers by the blocklist
+// and the allowlist entries
+func TestPeerFilter(t *testing.T) {
+	f, err := NewPeerFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := enode.HexID("3431c3939e1ee2a6345e976a8234f9870152d64879f30bc272a074f6859e75e8")
+	otherID := enode.HexID("8a608cd324678469291c18e2d3feb82e74b181adb6b44439a6fd1daa48993001")
+	overlay := []byte{0xab, 0xcd, 0xef}
+	otherOverlay := []byte{0x12, 0x34, 0x56}
+
+	check := func(id enode.ID, overlay []byte, want error) {
+		t.Helper()
+		if err := f.Check(id, overlay); err != want {
+			t.Fatalf("got error %v, want %v", err, want)
+		}
+	}
+
+	// all peers are accepted with empty lists
+	check(id, overlay, nil)
+
+	for _, e := range []PeerFilterEntry{
+		{},
+		{ID: &id, Prefix: []byte{0xab}},
+	} {
+		if err := f.Block(e); err != ErrInvalidPeerFilterEntry {
+			t.Fatalf("got error %v, want %v", err, ErrInvalidPeerFilterEntry)
+		}
+	}
+
+	// block by enode ID
+	if err := f.Block(PeerFilterEntry{ID: &id}); err != nil {
+		t.Fatal(err)
+	}
+	check(id, otherOverlay, ErrPeerBlocked)
+	check(otherID, overlay, nil)
+
+	// block by overlay address prefix
+	if err := f.Block(PeerFilterEntry{Prefix: []byte{0xab}}); err != nil {
+		t.Fatal(err)
+	}
+	check(otherID, overlay, ErrPeerBlocked)
+	check(otherID, otherOverlay, nil)
+
+	// adding the same entry again does not duplicate it
+	if err := f.Block(PeerFilterEntry{ID: &id}); err != nil {
+		t.Fatal(err)
+	}
+	if l := f.Lists(); len(l.Blocked) != 2 {
+		t.Fatalf("got %v blocked entries, want 2", len(l.Blocked))
+	}
+
+	// the blocklist takes precedence over the allowlist
+	if err := f.Allow(PeerFilterEntry{Prefix: []byte{0xab, 0xcd}}); err != nil {
+		t.Fatal(err)
+	}
+	check(otherID, overlay, ErrPeerBlocked)
+	check(otherID, otherOverlay, ErrPeerNotAllowed)
+
+	if err := f.Unblock(PeerFilterEntry{Prefix: []byte{0xab}}); err != nil {
+		t.Fatal(err)
+	}
+	check(otherID, overlay, nil)
+	check(id, overlay, ErrPeerBlocked)
+
+	if err := f.Disallow(PeerFilterEntry{Prefix: []byte{0xab, 0xcd}}); err != nil {
+		t.Fatal(err)
+	}
+	check(otherID, otherOverlay, nil)
+
+	// a nil filter accepts all peers
+	f = nil
+	check(id, overlay, nil)
+}
+
+// TestPeerFilterPersistence checks that the peer filter lists
+// are loaded from the state store
+func TestPeerFilterPersistence(t *testing.T) {
+	store := state.NewInmemoryStore()
+	defer store.Close()
+
+	f, err := NewPeerFilter(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := enode.HexID("3431c3939e1ee2a6345e976a8234f9870152d64879f30bc272a074f6859e75e8")
+	if err := f.Block(PeerFilterEntry{ID: &id}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Allow(PeerFilterEntry{Prefix: []byte{0xab}}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = NewPeerFilter(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := f.Lists()
+	if len(l.Blocked) != 1 || l.Blocked[0].ID == nil || *l.Blocked[0].ID != id {
+		t.Fatalf("got blocked entries %v", l.Blocked)
+	}
+	if len(l.Allowed) != 1 || string(l.Allowed[0].Prefix) != string([]byte{0xab}) {
+		t.Fatalf("got allowed entries %v", l.Allowed)
+	}
+}
+
+// TestKademliaPeerFilter checks that filtered peers are neither
+// registered nor connected in the kademlia table
+func TestKademliaPeerFilter(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	f, err := NewPeerFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk.PeerFilter = f
+	blocked := testKadPeerAddr("10000000")
+	if err := f.Block(PeerFilterEntry{Prefix: blocked.Over()[:1]}); err != nil {
+		t.Fatal(err)
+	}
+
+	tk.Register("10000000", "01000000")
+	tk.On("10000000", "00100000")
+
+	if n := tk.defaultIndex.addrs.Size(); n != 2 {
+		t.Fatalf("got %v known peers, want 2", n)
+	}
+	if n := tk.defaultIndex.conns.Size(); n != 1 {
+		t.Fatalf("got %v connected peers, want 1", n)
+	}
+	tk.EachAddr(nil, 255, func(a *BzzAddr, _ int) bool {
+		if a.Over()[0] == blocked.Over()[0] {
+			t.Fatalf("blocked peer %v found", a)
+		}
+		return true
+	})
+}
+
+// TestNilPeerFilter checks that a nil filter accepts all peers
+// and that its lists cannot be changed
+func TestNilPeerFilter(t *testing.T) {
+	var f *PeerFilter
+	if err := f.Check(enode.ID{}, testKadPeerAddr("10000000").Over()); err != nil {
+		t.Fatal(err)
+	}
+	e := PeerFilterEntry{Prefix: []byte{0x80}}
+	for name, update := range map[string]func(PeerFilterEntry) error{
+		"block":    f.Block,
+		"unblock":  f.Unblock,
+		"allow":    f.Allow,
+		"disallow": f.Disallow,
+	} {
+		if err := update(e); err != ErrPeerFilterNotSet {
+			t.Errorf("%s: got error %v, want %v", name, err, ErrPeerFilterNotSet)
+		}
+	}
+	if l := f.Lists(); len(l.Blocked) != 0 || len(l.Allowed) != 0 {
+		t.Fatalf("got lists %+v, want empty", l)
+	}
+
+	kad := NewKademlia(make([]byte, 32), NewKadParams())
+	NewHive(NewHiveParams(), kad, nil)
+	if kad.PeerFilter != nil {
+		t.Fatal("hive set the kademlia peer filter")
+	}
+}
@@ -37,6 +37,8 @@ var (
 	CapabilityID              = capability.CapabilityID(0)
 	capabilitiesRetrieve      = 0
 	capabilitiesPush          = 1
+	capabilitiesSync          = 2
+	capabilitiesRelayDelivery = 3
 	capabilitiesRelayRetrieve = 4
 	capabilitiesRelayPush     = 5
 	capabilitiesStorer        = 15
@@ -57,7 +59,7 @@ var DefaultTestNetworkID = rand.Uint64()
 // BzzSpec is the spec of the generic swarm handshake
 var BzzSpec = &protocols.Spec{
 	Name:       "bzz",
-	Version:    14,
+	Version:    17,
 	MaxMsgSize: 10 * 1024 * 1024,
 	Messages: []interface{}{
 		HandshakeMsg{},
@@ -67,11 +69,14 @@ var BzzSpec = &protocols.Spec{
 // DiscoverySpec is the spec for the bzz discovery subprotocols
 var DiscoverySpec = &protocols.Spec{
 	Name:       "hive",
-	Version:    11,
+	Version:    13,
 	MaxMsgSize: 10 * 1024 * 1024,
 	Messages: []interface{}{
 		peersMsg{},
 		subPeersMsg{},
+		pingMsg{},
+		pongMsg{},
+		storageMsg{},
 	},
 }
 
@@ -81,15 +86,36 @@ func init() {
 	lightCapability = newLightCapability()
 }
 
+// LightNodePolicy declares the services that a light node
+// does not provide to its peers
+type LightNodePolicy struct {
+	NoRetrieve bool // do not serve retrieve requests
+	NoSync     bool // do not serve sync streams
+}
+
 // temporary convenience functions for legacy "LightNode"
 func newLightCapability() *capability.Capability {
+	return newLightCapabilityWithPolicy(LightNodePolicy{})
+}
+
+// newLightCapabilityWithPolicy returns the light node capability
+// without the bits of the services that the policy excludes
+func newLightCapabilityWithPolicy(policy LightNodePolicy) *capability.Capability {
 	c := capability.NewCapability(CapabilityID, 16)
-	c.Set(capabilitiesRetrieve)
+	if !policy.NoRetrieve {
+		c.Set(capabilitiesRetrieve)
+	}
 	c.Set(capabilitiesPush)
+	if !policy.NoSync {
+		c.Set(capabilitiesSync)
+	}
 	return c
 }
+
+// isLightCapability returns true for the light node capability
+// with any of the service policies
 func isLightCapability(c *capability.Capability) bool {
-	return lightCapability.IsSameAs(c)
+	return lightCapability.Match(c) && c.Cap[capabilitiesPush]
 }
 
 // temporary convenience functions for legacy "full node"
@@ -97,6 +123,8 @@ func newFullCapability() *capability.Capability {
 	c := capability.NewCapability(CapabilityID, 16)
 	c.Set(capabilitiesRetrieve)
 	c.Set(capabilitiesPush)
+	c.Set(capabilitiesSync)
+	c.Set(capabilitiesRelayDelivery)
 	c.Set(capabilitiesRelayRetrieve)
 	c.Set(capabilitiesRelayPush)
 	c.Set(capabilitiesStorer)
@@ -107,14 +135,64 @@ func isFullCapability(c *capability.Capability) bool {
 	return fullCapability.IsSameAs(c)
 }
 
+// ServesRetrieve returns false if the peer with the provided address
+// declares that it does not serve retrieve requests.
+// Peers that do not advertise the bzz capability are assumed to serve them.
+func ServesRetrieve(a *BzzAddr) bool {
+	return servesCapability(a, capabilitiesRetrieve)
+}
+
+// ServesSync returns false if the peer with the provided address
+// declares that it does not serve sync streams.
+// Peers that do not advertise the bzz capability are assumed to serve them.
+func ServesSync(a *BzzAddr) bool {
+	return servesCapability(a, capabilitiesSync)
+}
+
+// RelaysDelivery returns true if the peer with the provided address
+// advertises that it relays chunk deliveries between peers that can not
+// connect to each other, such as nodes behind NAT.
+// Unlike with the other services, peers must declare it explicitly.
+func RelaysDelivery(a *BzzAddr) bool {
+	if a.Capabilities == nil {
+		return false
+	}
+	c := a.Capabilities.Get(CapabilityID)
+	return c != nil && capabilitiesRelayDelivery < len(c.Cap) && c.Cap[capabilitiesRelayDelivery]
+}
+
+func servesCapability(a *BzzAddr, bit int) bool {
+	if a.Capabilities == nil {
+		return true
+	}
+	c := a.Capabilities.Get(CapabilityID)
+	if c == nil || bit >= len(c.Cap) {
+		return true
+	}
+	return c.Cap[bit]
+}
+
+// newCapabilityBits returns a capability with only the provided bits set,
+// used for indexing peers by a subset of their capabilities
+func newCapabilityBits(bits ...int) *capability.Capability {
+	c := capability.NewCapability(CapabilityID, 16)
+	for _, b := range bits {
+		c.Set(b)
+	}
+	return c
+}
+
 // BzzConfig captures the config params used by the hive
+
 type BzzConfig struct {
 	Address      *BzzAddr
 	HiveParams   *HiveParams
 	NetworkID    uint64
-	LightNode    bool // temporarily kept as we still only define light/full on operational level
+	LightNode    bool            // temporarily kept as we still only define light/full on operational level
+	LightPolicy  LightNodePolicy // services not provided to peers if LightNode is set
 	BootnodeMode bool
 	SyncEnabled  bool
+	StorageInfo  func() StorageInfo // returns the storage info advertised to peers, if nil it is not advertised
 }
 
 // Bzz is the swarm protocol bundle
@@ -159,10 +237,12 @@ func NewBzz(config *BzzConfig, kad *Kademlia, store state.Store, streamerSpec, r
 		bzz.streamerSpec = nil
 	}
 
+	bzz.Hive.storageInfo = config.StorageInfo
+
 	bzz.localAddr.Capabilities = kad.Capabilities
 	// temporary soon-to-be-legacy light/full, as above
 	if config.LightNode {
-		bzz.localAddr.Capabilities.Add(newLightCapability())
+		bzz.localAddr.Capabilities.Add(newLightCapabilityWithPolicy(config.LightPolicy))
 	} else {
 		bzz.localAddr.Capabilities.Add(newFullCapability())
 	}
@@ -278,6 +358,7 @@ func (b *Bzz) RunProtocol(spec *protocols.Spec, run func(*BzzPeer) error) func(*
 			Peer:       protocols.NewPeer(p, rw, spec),
 			BzzAddr:    handshake.peerAddr,
 			lastActive: time.Now(),
+			storage:    handshake.peerStorage,
 		}
 
 		log.Debug("peer created", "addr", handshake.peerAddr.String())
@@ -300,6 +381,12 @@ func (b *Bzz) performHandshake(p *protocols.Peer, handshake *HandshakeMsg) error
 		return err
 	}
 	handshake.peerAddr = rsh.(*HandshakeMsg).Addr
+	handshake.peerStorage = rsh.(*HandshakeMsg).Storage
+	// reject peers that are not allowed to connect
+	if err := b.PeerFilter.Check(p.ID(), handshake.peerAddr.Over()); err != nil {
+		handshake.err = err
+		return err
+	}
 	return nil
 }
 
@@ -331,9 +418,10 @@ func (b *Bzz) runBzz(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 // BzzPeer is the bzz protocol view of a protocols.Peer (itself an extension of p2p.Peer)
 // implements the Peer interface and all interfaces Peer implements: Addr, OverlayPeer
 type BzzPeer struct {
-	*protocols.Peer           // represents the connection for online peers
-	*BzzAddr                  // remote address -> implements Addr interface = protocols.Peer
-	lastActive      time.Time // time is updated whenever mutexes are releasing
+	*protocols.Peer              // represents the connection for online peers
+	*BzzAddr                     // remote address -> implements Addr interface = protocols.Peer
+	lastActive      time.Time    // time is updated whenever mutexes are releasing
+	storage         *StorageInfo // storage info advertised in the handshake, nil if none
 }
 
 func NewBzzPeer(p *protocols.Peer) *BzzPeer {
@@ -356,14 +444,18 @@ func (p *BzzPeer) ID() enode.ID {
 * NetworkID: 8 byte integer network identifier
 * Addr: the address advertised by the node including underlay and overlay connecctions
 * Capabilities: the capabilities bitvector
+* Storage: the storage radius and free capacity of the node, if advertised
 */
 type HandshakeMsg struct {
 	Version   uint64
 	NetworkID uint64
 	Addr      *BzzAddr
+	Storage   *StorageInfo `rlp:"nil"`
 
 	// peerAddr is the address received in the peer handshake
 	peerAddr *BzzAddr
+	// peerStorage is the storage info received in the peer handshake
+	peerStorage *StorageInfo
 
 	init chan bool
 	done chan struct{}
@@ -372,7 +464,7 @@ type HandshakeMsg struct {
 
 // String pretty prints the handshake
 func (bh *HandshakeMsg) String() string {
-	return fmt.Sprintf("Handshake: Version: %v, NetworkID: %v, Addr: %v, peerAddr: %v", bh.Version, bh.NetworkID, bh.Addr, bh.peerAddr)
+	return fmt.Sprintf("Handshake: Version: %v, NetworkID: %v, Addr: %v, Storage: %v, peerAddr: %v", bh.Version, bh.NetworkID, bh.Addr, bh.Storage, bh.peerAddr)
 }
 
 // Perform initiates the handshake and validates the remote handshake message
@@ -409,6 +501,7 @@ func (b *Bzz) GetOrCreateHandshake(peerID enode.ID) (*HandshakeMsg, bool) {
 			Version:   uint64(BzzSpec.Version),
 			NetworkID: b.NetworkID,
 			Addr:      b.localAddr,
+			Storage:   b.localStorageInfo(),
 			init:      make(chan bool, 1),
 			done:      make(chan struct{}),
 		}
@@ -37,7 +37,7 @@ import (
 )
 
 const (
-	TestProtocolVersion = 14
+	TestProtocolVersion = 17
 )
 
 var TestProtocolNetworkID = DefaultTestNetworkID
@@ -174,7 +174,9 @@ func newBzz(addr *BzzAddr, lightNode bool) *Bzz {
 		NetworkID:  DefaultTestNetworkID,
 		LightNode:  lightNode,
 	}
-	kad := NewKademlia(addr.OAddr, NewKadParams())
+	kp := NewKadParams()
+	kp.PeerFilter, _ = NewPeerFilter(nil)
+	kad := NewKademlia(addr.OAddr, kp)
 	bzz := NewBzz(config, kad, nil, nil, nil, nil, nil)
 	return bzz
 }
@@ -242,6 +244,7 @@ func TestBzzHandshakeRLPSerialization(t *testing.T) {
 		Version:   42,
 		NetworkID: 666,
 		Addr:      addr,
+		Storage:   &StorageInfo{Radius: 3, FreeCapacity: 1000},
 	}
 	b, err := rlp.EncodeToBytes(msg)
 	if err != nil {
@@ -261,6 +264,24 @@ func TestBzzHandshakeRLPSerialization(t *testing.T) {
 	if !msg.Addr.Match(msgRecovered.Addr) {
 		t.Fatalf("bzzaddr mismatch, expected %v, got %v", msg.Addr, msgRecovered.Addr)
 	}
+	if msgRecovered.Storage == nil || *msg.Storage != *msgRecovered.Storage {
+		t.Fatalf("storage mismatch, expected %v, got %v", msg.Storage, msgRecovered.Storage)
+	}
+
+	// storage info is optional
+	msg.Storage = nil
+	b, err = rlp.EncodeToBytes(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgRecovered = HandshakeMsg{}
+	err = rlp.DecodeBytes(b, &msgRecovered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgRecovered.Storage != nil {
+		t.Fatalf("storage mismatch, expected nil, got %v", msgRecovered.Storage)
+	}
 }
 
 func TestBzzHandshakeNetworkIDMismatch(t *testing.T) {
@@ -361,6 +382,149 @@ func TestBzzHandshakeSuccess(t *testing.T) {
 	}
 }
 
+// TestBzzHandshakePeerBlocked checks that peers in the blocklist
+// are disconnected on handshake
+func TestBzzHandshakePeerBlocked(t *testing.T) {
+	lightNode := false
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newBzzHandshakeTester(1, prvkey, lightNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	node := s.Nodes[0]
+
+	id := node.ID()
+	if err := s.bzz.BlockPeer(PeerFilterEntry{ID: &id}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.testHandshake(
+		correctBzzHandshake(s.addr, lightNode),
+		newBzzHandshakeMsg(TestProtocolVersion, TestProtocolNetworkID, NewBzzAddrFromEnode(node), false),
+		&p2ptest.Disconnect{Peer: node.ID(), Error: ErrPeerBlocked},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestBzzHandshakeStorageInfo checks that the storage info
+// advertised in the remote handshake is recorded
+func TestBzzHandshakeStorageInfo(t *testing.T) {
+	lightNode := false
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newBzzHandshakeTester(1, prvkey, lightNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	node := s.Nodes[0]
+
+	rhs := newBzzHandshakeMsg(TestProtocolVersion, TestProtocolNetworkID, NewBzzAddrFromEnode(node), false)
+	rhs.Storage = &StorageInfo{Radius: 4, FreeCapacity: 100}
+	err = s.testHandshake(correctBzzHandshake(s.addr, lightNode), rhs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handshake, _ := s.bzz.GetOrCreateHandshake(node.ID())
+	select {
+	case <-handshake.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("test timeout")
+	}
+	if handshake.peerStorage == nil || *handshake.peerStorage != *rhs.Storage {
+		t.Fatalf("got peer storage %v, want %v", handshake.peerStorage, rhs.Storage)
+	}
+}
+
+// TestLightNodePolicy checks that the services a light node declares it
+// does not provide are advertised in its capabilities and recognised by peers
+func TestLightNodePolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy   LightNodePolicy
+		retrieve bool
+		sync     bool
+	}{
+		{LightNodePolicy{}, true, true},
+		{LightNodePolicy{NoRetrieve: true}, false, true},
+		{LightNodePolicy{NoSync: true}, true, false},
+		{LightNodePolicy{NoRetrieve: true, NoSync: true}, false, false},
+	} {
+		c := newLightCapabilityWithPolicy(tc.policy)
+		if !isLightCapability(c) {
+			t.Errorf("policy %+v: capability %v is not a light capability", tc.policy, c)
+		}
+		if isFullCapability(c) {
+			t.Errorf("policy %+v: capability %v is a full capability", tc.policy, c)
+		}
+
+		caps := capability.NewCapabilities()
+		caps.Add(c)
+		addr := RandomBzzAddr().WithCapabilities(caps)
+		if got := ServesRetrieve(addr); got != tc.retrieve {
+			t.Errorf("policy %+v: got serves retrieve %v, want %v", tc.policy, got, tc.retrieve)
+		}
+		if got := ServesSync(addr); got != tc.sync {
+			t.Errorf("policy %+v: got serves sync %v, want %v", tc.policy, got, tc.sync)
+		}
+
+		k := NewKademlia(RandomBzzAddr().Over(), NewKadParams())
+		for key, want := range map[string]bool{
+			CapabilityIndexLight:    true,
+			CapabilityIndexFull:     false,
+			CapabilityIndexRetrieve: tc.retrieve,
+			CapabilityIndexSync:     tc.sync,
+		} {
+			got, err := k.HasCapability(key, addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("policy %+v: got %q index match %v, want %v", tc.policy, key, got, want)
+			}
+		}
+	}
+
+	// peers without the bzz capability are assumed to serve
+	addr := RandomBzzAddr()
+	if !ServesRetrieve(addr) || !ServesSync(addr) {
+		t.Error("peer without capabilities is assumed not to serve")
+	}
+}
+
+// TestRelaysDelivery checks that only full nodes are recognised as
+// relaying chunk deliveries between peers
+func TestRelaysDelivery(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cap  *capability.Capability
+		want bool
+	}{
+		{"full", newFullCapability(), true},
+		{"light", newLightCapability(), false},
+		{"none", nil, false},
+	} {
+		addr := RandomBzzAddr()
+		if tc.cap != nil {
+			caps := capability.NewCapabilities()
+			caps.Add(tc.cap)
+			addr = addr.WithCapabilities(caps)
+		}
+		if got := RelaysDelivery(addr); got != tc.want {
+			t.Errorf("%s: got relays delivery %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
 func TestBzzHandshakeLightNode(t *testing.T) {
 	var lightNodeTests = []struct {
 		name      string
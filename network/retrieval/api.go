@@ -0,0 +1,35 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+// API exposes the retrieval protocol state over RPC
+type API struct {
+	r *Retrieval
+}
+
+// NewAPI creates a new API for the provided Retrieval
+func NewAPI(r *Retrieval) *API {
+	return &API{
+		r: r,
+	}
+}
+
+// PeerScores returns retrieval statistics of connected peers
+// ordered from the best to the worst scored peer
+func (a *API) PeerScores() []PeerScore {
+	return a.r.PeerScores()
+}
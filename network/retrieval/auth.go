@@ -0,0 +1,128 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// ErrUnauthenticatedRequest is returned when a retrieve request is not
+// authenticated by the requesting peer
+var ErrUnauthenticatedRequest = errors.New("unauthenticated retrieve request")
+
+// Authenticator authenticates retrieve requests between peers of
+// permissioned swarms. Nodes with an Authenticator do not serve
+// requests that can not be verified.
+type Authenticator interface {
+	// Sign returns the authentication of the request sent to the peer
+	// with the provided overlay address
+	Sign(msg *RetrieveRequest, to []byte) ([]byte, error)
+	// Verify returns ErrUnauthenticatedRequest if the request from the peer
+	// with the node ID is not authenticated for the peer with the provided
+	// overlay address
+	Verify(msg *RetrieveRequest, from enode.ID, to []byte) error
+}
+
+// authPayload returns the content of the retrieve request that is
+// authenticated for the peer with the provided overlay address
+func authPayload(msg *RetrieveRequest, to []byte) []byte {
+	b := make([]byte, 9, 9+len(msg.Addr)+len(to))
+	binary.BigEndian.PutUint64(b, uint64(msg.Ruid))
+	b[8] = msg.TTL
+	b = append(b, msg.Addr...)
+	return append(b, to...)
+}
+
+// HMACAuthenticator authenticates retrieve requests with HMAC-SHA256
+// keyed by a secret shared by all nodes of the swarm
+type HMACAuthenticator struct {
+	secret []byte
+}
+
+// NewHMACAuthenticator returns an Authenticator with the shared secret
+func NewHMACAuthenticator(secret []byte) *HMACAuthenticator {
+	return &HMACAuthenticator{
+		secret: secret,
+	}
+}
+
+// Sign implements Authenticator
+func (a *HMACAuthenticator) Sign(msg *RetrieveRequest, to []byte) ([]byte, error) {
+	return a.mac(msg, to), nil
+}
+
+// Verify implements Authenticator
+func (a *HMACAuthenticator) Verify(msg *RetrieveRequest, _ enode.ID, to []byte) error {
+	if !hmac.Equal(msg.Auth, a.mac(msg, to)) {
+		return ErrUnauthenticatedRequest
+	}
+	return nil
+}
+
+func (a *HMACAuthenticator) mac(msg *RetrieveRequest, to []byte) []byte {
+	h := hmac.New(sha256.New, a.secret)
+	h.Write(authPayload(msg, to))
+	return h.Sum(nil)
+}
+
+// KeyAuthenticator authenticates retrieve requests with signatures by the
+// node keys of the requesting peers, which must be in the allowlist
+type KeyAuthenticator struct {
+	key     *ecdsa.PrivateKey
+	allowed map[enode.ID]bool
+}
+
+// NewKeyAuthenticator returns an Authenticator signing requests with the
+// node private key and accepting requests signed by the allowlisted keys
+func NewKeyAuthenticator(key *ecdsa.PrivateKey, allowed ...*ecdsa.PublicKey) *KeyAuthenticator {
+	a := &KeyAuthenticator{
+		key:     key,
+		allowed: make(map[enode.ID]bool, len(allowed)),
+	}
+	for _, pub := range allowed {
+		a.allowed[enode.PubkeyToIDV4(pub)] = true
+	}
+	return a
+}
+
+// Sign implements Authenticator
+func (a *KeyAuthenticator) Sign(msg *RetrieveRequest, to []byte) ([]byte, error) {
+	return crypto.Sign(crypto.Keccak256(authPayload(msg, to)), a.key)
+}
+
+// Verify implements Authenticator. The request must be signed by the
+// requesting peer.
+func (a *KeyAuthenticator) Verify(msg *RetrieveRequest, from enode.ID, to []byte) error {
+	if len(msg.Auth) != receiptSignatureLength {
+		return ErrUnauthenticatedRequest
+	}
+	pub, err := crypto.SigToPub(crypto.Keccak256(authPayload(msg, to)), msg.Auth)
+	if err != nil {
+		return ErrUnauthenticatedRequest
+	}
+	if id := enode.PubkeyToIDV4(pub); id != from || !a.allowed[id] {
+		return ErrUnauthenticatedRequest
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
+	chunktesting "github.com/ethersphere/swarm/chunk/testing"
+	"github.com/ethersphere/swarm/network"
+	p2ptest "github.com/ethersphere/swarm/p2p/testing"
+)
+
+// TestAuthenticators validates signing and verification of retrieve
+// requests by the provided authenticators
+func TestAuthenticators(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := enode.PubkeyToIDV4(&key.PublicKey)
+	to := network.RandomBzzAddr().Over()
+
+	for _, tc := range []struct {
+		name   string
+		signer Authenticator
+		// verifier for which the request is authenticated
+		verifier Authenticator
+		// verifier for which the request is not authenticated
+		refuser Authenticator
+	}{
+		{
+			name:     "hmac",
+			signer:   NewHMACAuthenticator([]byte("secret")),
+			verifier: NewHMACAuthenticator([]byte("secret")),
+			refuser:  NewHMACAuthenticator([]byte("other secret")),
+		},
+		{
+			name:     "key",
+			signer:   NewKeyAuthenticator(key),
+			verifier: NewKeyAuthenticator(other, &key.PublicKey),
+			refuser:  NewKeyAuthenticator(key, &other.PublicKey),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := &RetrieveRequest{
+				Ruid: 1,
+				Addr: hash0[:],
+				TTL:  DefaultMaxHops,
+			}
+			auth, err := tc.signer.Sign(msg, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg.Auth = auth
+
+			if err := tc.verifier.Verify(msg, id, to); err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.refuser.Verify(msg, id, to); err != ErrUnauthenticatedRequest {
+				t.Fatalf("got error %v, want %v", err, ErrUnauthenticatedRequest)
+			}
+			// authentication is bound to the receiving peer
+			if err := tc.verifier.Verify(msg, id, network.RandomBzzAddr().Over()); err != ErrUnauthenticatedRequest {
+				t.Fatalf("got error %v, want %v", err, ErrUnauthenticatedRequest)
+			}
+			// and to the request content
+			msg.TTL--
+			if err := tc.verifier.Verify(msg, id, to); err != ErrUnauthenticatedRequest {
+				t.Fatalf("got error %v, want %v", err, ErrUnauthenticatedRequest)
+			}
+			msg.TTL++
+			msg.Auth = nil
+			if err := tc.verifier.Verify(msg, id, to); err != ErrUnauthenticatedRequest {
+				t.Fatalf("got error %v, want %v", err, ErrUnauthenticatedRequest)
+			}
+		})
+	}
+
+	// signature by a key that is allowed, but not of the requesting peer
+	msg := &RetrieveRequest{Ruid: 1, Addr: hash0[:]}
+	msg.Auth, err = NewKeyAuthenticator(key).Sign(msg, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewKeyAuthenticator(other, &key.PublicKey).Verify(msg, enode.PubkeyToIDV4(&other.PublicKey), to); err != ErrUnauthenticatedRequest {
+		t.Fatalf("got error %v, want %v", err, ErrUnauthenticatedRequest)
+	}
+}
+
+// TestRetrieveRequestAuthentication tests that a node with an Authenticator
+// serves authenticated requests and disconnects peers sending
+// unauthenticated ones
+func TestRetrieveRequestAuthentication(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	bzzAddr := network.PrivateKeyToBzzKey(pk)
+
+	kad := network.NewKademlia(bzzAddr, network.NewKadParams())
+
+	auth := NewHMACAuthenticator([]byte("secret"))
+	tester, _, teardown, err := newRetrievalTesterWithOptions(t, pk, ns, kad, &Options{Authenticator: auth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	node := tester.Nodes[0]
+
+	ch := chunktesting.GenerateTestRandomChunk()
+	if _, err := ns.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &RetrieveRequest{
+		Ruid: 1,
+		Addr: ch.Address(),
+		TTL:  DefaultMaxHops,
+	}
+	req.Auth, err = auth.Sign(req, bzzAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tester.TestExchanges(
+		p2ptest.Exchange{
+			Label: "Authenticated retrieve request",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 1,
+					Msg:  req,
+					Peer: node.ID(),
+				},
+			},
+			Expects: []p2ptest.Expect{
+				{
+					Code: 0,
+					Msg: &ChunkDelivery{
+						Ruid:  1,
+						Addr:  ch.Address(),
+						SData: ch.Data(),
+					},
+					Peer: node.ID(),
+				},
+			},
+		},
+		p2ptest.Exchange{
+			Label: "Unauthenticated retrieve request",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 1,
+					Msg: &RetrieveRequest{
+						Ruid: 2,
+						Addr: ch.Address(),
+						TTL:  DefaultMaxHops,
+						Auth: []byte("invalid"),
+					},
+					Peer: node.ID(),
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tester.TestDisconnected(&p2ptest.Disconnect{Peer: node.ID(), Error: errors.New("subprotocol error")})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// handshakeTimeout is the time to wait for the handshake of a peer
+const handshakeTimeout = 10 * time.Second
+
+// Features is a set of optional protocol features. Features are negotiated
+// with each peer in the handshake, so that new message types can be rolled
+// out while peers that do not support them are still served.
+type Features uint64
+
+const (
+	// FeatureCancel is cancelling retrieve requests that are no longer
+	// needed with CancelRetrieveRequest messages
+	FeatureCancel Features = 1 << iota
+	// FeatureReceipts is acknowledging chunk deliveries with Receipt messages
+	FeatureReceipts
+	// FeatureThrottle is rejecting retrieve requests over the peer limits
+	// with Throttle messages
+	FeatureThrottle
+	// FeatureRelay is requesting chunks from peers that can not be connected
+	// to through a relaying peer with RelayRequest messages
+	FeatureRelay
+
+	// AllFeatures is the set of all features supported by this implementation
+	AllFeatures = FeatureCancel | FeatureReceipts | FeatureThrottle | FeatureRelay
+)
+
+// Has returns true if the set contains all provided features
+func (f Features) Has(features Features) bool {
+	return f&features == features
+}
+
+// handshake exchanges Handshake messages with the peer and sets the
+// features supported by both sides as the peer features
+func (r *Retrieval) handshake(p *Peer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+
+	hs, err := p.Handshake(ctx, &Handshake{
+		Version:  spec.Version,
+		Features: r.features,
+	}, verifyHandshake)
+	if err != nil {
+		return fmt.Errorf("retrieval handshake: %w", err)
+	}
+	rhs := hs.(*Handshake)
+	p.features = r.features & rhs.Features
+	p.logger.Debug("retrieval.handshake", "version", rhs.Version, "features", fmt.Sprintf("%b", p.features))
+	return nil
+}
+
+// verifyHandshake validates the handshake message of a peer
+func verifyHandshake(msg interface{}) error {
+	hs, ok := msg.(*Handshake)
+	if !ok {
+		return fmt.Errorf("unexpected handshake message type %T", msg)
+	}
+	if hs.Version == 0 {
+		return errors.New("invalid protocol version")
+	}
+	return nil
+}
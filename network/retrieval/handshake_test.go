@@ -0,0 +1,170 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/network"
+	p2ptest "github.com/ethersphere/swarm/p2p/testing"
+)
+
+// handshakeExchange exchanges handshakes with the peer, the peer sending
+// the peerFeatures and the node under test expected to send the features
+func handshakeExchange(tester *p2ptest.ProtocolTester, peerID enode.ID, peerFeatures, features Features) error {
+	return tester.TestExchanges(
+		p2ptest.Exchange{
+			Label: "Handshake",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 5,
+					Msg: &Handshake{
+						Version:  spec.Version,
+						Features: peerFeatures,
+					},
+					Peer: peerID,
+				},
+			},
+			Expects: []p2ptest.Expect{
+				{
+					Code: 5,
+					Msg: &Handshake{
+						Version:  spec.Version,
+						Features: features,
+					},
+					Peer: peerID,
+				},
+			},
+		})
+}
+
+// TestFeatures tests the features set
+func TestFeatures(t *testing.T) {
+	f := FeatureCancel | FeatureThrottle
+	if !f.Has(FeatureCancel) || !f.Has(FeatureCancel|FeatureThrottle) {
+		t.Error("feature not in set")
+	}
+	if f.Has(FeatureReceipts) || f.Has(FeatureCancel|FeatureReceipts) {
+		t.Error("feature in set")
+	}
+	if !f.Has(0) {
+		t.Error("empty set not in set")
+	}
+}
+
+// TestHandshakeFeatures tests that the features used with a peer are the
+// ones supported by both sides and that messages of other features are
+// not sent to the peer nor accepted from it
+func TestHandshakeFeatures(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	kad := network.NewKademlia(network.PrivateKeyToBzzKey(pk), network.NewKadParams())
+
+	r := NewWithOptions(kad, ns, network.NewBzzAddr(kad.BaseAddr(), nil), nil, &Options{
+		Features:            FeatureCancel | FeatureThrottle,
+		MaxInFlightRequests: 1,
+	})
+	tester := p2ptest.NewProtocolTester(pk, 1, r.runProtocol)
+	defer tester.Stop()
+	node := tester.Nodes[0]
+
+	// the peer does not support throttling
+	if err := handshakeExchange(tester, node.ID(), FeatureCancel|FeatureReceipts, FeatureCancel|FeatureThrottle); err != nil {
+		t.Fatal(err)
+	}
+
+	var p *Peer
+	for i := 0; p == nil; i++ {
+		if i == 100 {
+			t.Fatal("peer not added")
+		}
+		time.Sleep(10 * time.Millisecond)
+		p = r.getPeer(node.ID())
+	}
+	if p.features != FeatureCancel {
+		t.Fatalf("got features %b, want %b", p.features, FeatureCancel)
+	}
+
+	// receipts were not agreed on
+	err := tester.TestExchanges(p2ptest.Exchange{
+		Label: "Receipt",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 3,
+				Msg: &Receipt{
+					Ruid: 1,
+					Addr: hash0[:],
+				},
+				Peer: node.ID(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tester.TestDisconnected(&p2ptest.Disconnect{Peer: node.ID(), Error: errors.New("subprotocol error")}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestHandshakeInvalid tests that a peer with an invalid handshake
+// is disconnected
+func TestHandshakeInvalid(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	kad := network.NewKademlia(network.PrivateKeyToBzzKey(pk), network.NewKadParams())
+
+	r := New(kad, ns, network.NewBzzAddr(kad.BaseAddr(), nil), nil)
+	tester := p2ptest.NewProtocolTester(pk, 1, r.runProtocol)
+	defer tester.Stop()
+	node := tester.Nodes[0]
+
+	err := tester.TestExchanges(p2ptest.Exchange{
+		Label: "Handshake",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 5,
+				Msg: &Handshake{
+					Features: AllFeatures,
+				},
+				Peer: node.ID(),
+			},
+		},
+		Expects: []p2ptest.Expect{
+			{
+				Code: 5,
+				Msg: &Handshake{
+					Version:  spec.Version,
+					Features: AllFeatures,
+				},
+				Peer: node.ID(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tester.TestDisconnected(&p2ptest.Disconnect{Peer: node.ID(), Error: errors.New("retrieval handshake: message handler: (msg code 5): invalid protocol version")}); err != nil {
+		t.Fatal(err)
+	}
+	if r.getPeer(node.ID()) != nil {
+		t.Fatal("peer with invalid handshake added")
+	}
+}
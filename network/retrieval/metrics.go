@@ -0,0 +1,77 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+)
+
+var (
+	requestsIssued    = metrics.NewRegisteredCounter("network/retrieve/requests_issued", nil)
+	requestsForwarded = metrics.NewRegisteredCounter("network/retrieve/requests_forwarded", nil)
+	requestsServed    = metrics.NewRegisteredCounter("network/retrieve/requests_served", nil)
+	bytesDelivered    = metrics.NewRegisteredCounter("network/retrieve/bytes_delivered", nil)
+	bytesServed       = metrics.NewRegisteredCounter("network/retrieve/bytes_served", nil)
+)
+
+// binMetric returns the name of the metric for the proximity bin
+// of the peer that a request was sent to, relative to the chunk
+func binMetric(name string, peer, addr []byte) string {
+	return fmt.Sprintf("network/retrieve/%s/bin/%d", name, chunk.Proximity(peer, addr))
+}
+
+// newLatencyHistogram returns a histogram for delivery latencies in nanoseconds
+func newLatencyHistogram() metrics.Histogram {
+	return metrics.NewHistogram(metrics.NewExpDecaySample(1028, 0.015))
+}
+
+// requestSent updates the metrics of a retrieve request sent to the peer,
+// either on behalf of this node or forwarded on behalf of another one
+func requestSent(p *Peer, addr chunk.Address, forwarded bool) {
+	if forwarded {
+		requestsForwarded.Inc(1)
+	} else {
+		requestsIssued.Inc(1)
+	}
+	metrics.GetOrRegisterCounter(binMetric("requests_sent", p.BzzAddr.Over(), addr), nil).Inc(1)
+}
+
+// requestDelivered updates the metrics of a chunk delivered by the peer
+// for a retrieve request
+func requestDelivered(p *Peer, addr chunk.Address, size int, latency time.Duration) {
+	bytesDelivered.Inc(int64(size))
+	metrics.GetOrRegisterCounter(binMetric("requests_delivered", p.BzzAddr.Over(), addr), nil).Inc(1)
+	metrics.GetOrRegister("network/retrieve/delivery_latency", newLatencyHistogram).(metrics.Histogram).Update(int64(latency))
+	metrics.GetOrRegister(binMetric("delivery_latency", p.BzzAddr.Over(), addr), newLatencyHistogram).(metrics.Histogram).Update(int64(latency))
+}
+
+// requestFailed updates the metrics of a retrieve request the peer did
+// not deliver a valid chunk for
+func requestFailed(p *Peer, addr chunk.Address) {
+	metrics.GetOrRegisterCounter(binMetric("requests_failed", p.BzzAddr.Over(), addr), nil).Inc(1)
+}
+
+// requestServed updates the metrics of a chunk delivered to a peer
+// for its retrieve request
+func requestServed(size int) {
+	requestsServed.Inc(1)
+	bytesServed.Inc(int64(size))
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
+)
+
+// TestRetrievalMetrics validates that the metrics of retrieve requests are
+// updated for the proximity bin of the peer relative to the requested chunk.
+func TestRetrievalMetrics(t *testing.T) {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	defer func() { metrics.Enabled = enabled }()
+
+	addr := chunk.Address(hash0[:])
+	overlay := make([]byte, len(addr))
+	copy(overlay, addr)
+	overlay[1] ^= 0x01 // proximity 15 to the chunk
+	p := NewPeer(&network.BzzPeer{BzzAddr: network.NewBzzAddr(overlay, nil)}, network.RandomBzzAddr())
+
+	counter := func(name string) int64 {
+		c, ok := metrics.DefaultRegistry.Get(name).(metrics.Counter)
+		if !ok {
+			return 0
+		}
+		return c.Count()
+	}
+	sent := counter("network/retrieve/requests_sent/bin/15")
+	delivered := counter("network/retrieve/requests_delivered/bin/15")
+	failed := counter("network/retrieve/requests_failed/bin/15")
+
+	requestSent(p, addr, false)
+	requestSent(p, addr, true)
+	requestDelivered(p, addr, chunk.DefaultSize, 100*time.Millisecond)
+	requestFailed(p, addr)
+
+	if got := counter("network/retrieve/requests_sent/bin/15") - sent; got != 2 {
+		t.Fatalf("got %v sent requests, want 2", got)
+	}
+	if got := counter("network/retrieve/requests_delivered/bin/15") - delivered; got != 1 {
+		t.Fatalf("got %v delivered requests, want 1", got)
+	}
+	if got := counter("network/retrieve/requests_failed/bin/15") - failed; got != 1 {
+		t.Fatalf("got %v failed requests, want 1", got)
+	}
+	h, ok := metrics.DefaultRegistry.Get("network/retrieve/delivery_latency/bin/15").(metrics.Histogram)
+	if !ok {
+		t.Fatal("delivery latency histogram not registered")
+	}
+	if h.Count() == 0 || h.Max() != int64(100*time.Millisecond) {
+		t.Fatalf("got latency histogram count %v max %v, want max %v", h.Count(), h.Max(), 100*time.Millisecond)
+	}
+}
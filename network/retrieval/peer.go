@@ -18,22 +18,61 @@ package retrieval
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/timeouts"
 	"github.com/ethersphere/swarm/storage"
+	opentracing "github.com/opentracing/opentracing-go"
+	olog "github.com/opentracing/opentracing-go/log"
+	"golang.org/x/time/rate"
 )
 
+// errRetrievalCancelled is returned by checkRequest for deliveries of
+// retrievals that were cancelled because another peer delivered first
+var errRetrievalCancelled = errors.New("retrieval cancelled")
+
 // Peer wraps BzzPeer with a contextual logger and tracks open
 // retrievals for that peer
 type Peer struct {
 	*network.BzzPeer
-	logger     log.Logger             // logger with base and peer address
-	mtx        sync.Mutex             // synchronize retrievals
-	retrievals map[uint]chunk.Address // current ongoing retrievals
+	logger     log.Logger                  // logger with base and peer address
+	mtx        sync.Mutex                  // synchronize retrievals
+	retrievals map[uint]retrieval          // current ongoing retrievals
+	cancelled  map[uint]time.Time          // cancelled retrievals that may still be delivered
+	requests   map[uint]context.CancelFunc // incoming retrieve requests being served
+	deliveries map[uint]delivery           // chunks delivered to the peer that can be acknowledged by a receipt
+	limiter    *rate.Limiter               // limits the rate of incoming retrieve requests
+	throttled  time.Time                   // time until which the peer does not accept requests
+	features   Features                    // optional features negotiated with the peer
+}
+
+// retrieval is a retrieve request sent to the peer
+type retrieval struct {
+	addr      chunk.Address    // requested chunk address
+	sent      time.Time        // time when the request was sent
+	span      opentracing.Span // span of waiting for the delivery, may be nil
+	forwarded bool             // request is forwarded on behalf of another peer
+}
+
+// finish finishes the span of the retrieval with its result
+func (rt retrieval) finish(result string) {
+	if rt.span == nil {
+		return
+	}
+	rt.span.LogFields(olog.String("result", result))
+	rt.span.Finish()
+}
+
+// delivery is a chunk delivered to the peer
+type delivery struct {
+	addr chunk.Address // delivered chunk address
+	sent time.Time     // time when the chunk was sent
 }
 
 // NewPeer is the constructor for Peer
@@ -41,38 +80,220 @@ func NewPeer(peer *network.BzzPeer, baseKey *network.BzzAddr) *Peer {
 	return &Peer{
 		BzzPeer:    peer,
 		logger:     log.NewBaseAddressLogger(baseKey.ShortString(), "peer", peer.BzzAddr.ShortString()),
-		retrievals: make(map[uint]chunk.Address),
+		retrievals: make(map[uint]retrieval),
+		cancelled:  make(map[uint]time.Time),
+		requests:   make(map[uint]context.CancelFunc),
+		deliveries: make(map[uint]delivery),
 	}
 }
 
 // chunkRequested adds a new retrieval to the retrievals map
 // this is in order to identify unsolicited chunk deliveries
-func (p *Peer) addRetrieval(ruid uint, addr storage.Address) {
+// the span is finished when the retrieval is delivered or removed
+func (p *Peer) addRetrieval(ruid uint, addr storage.Address, span opentracing.Span, forwarded bool) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
-	p.retrievals[ruid] = addr
+	p.retrievals[ruid] = retrieval{
+		addr:      addr,
+		sent:      time.Now(),
+		span:      span,
+		forwarded: forwarded,
+	}
 }
 
 func (p *Peer) expireRetrieval(ruid uint) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
+	if rt, ok := p.retrievals[ruid]; ok {
+		rt.finish("expired")
+		delete(p.retrievals, ruid)
+	}
+}
+
+// hasRetrieval returns true if the retrieval with the provided ruid
+// is neither delivered, expired nor cancelled
+func (p *Peer) hasRetrieval(ruid uint) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	_, ok := p.retrievals[ruid]
+	return ok
+}
+
+// cancelRetrieval removes a retrieval that is no longer needed, but
+// remembers it for a while so that a late delivery is not considered
+// unsolicited. It returns the time elapsed since the request was sent,
+// or false if the retrieval was already delivered or expired.
+func (p *Peer) cancelRetrieval(ruid uint) (time.Duration, bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	rt, ok := p.retrievals[ruid]
+	if !ok {
+		return 0, false
+	}
 	delete(p.retrievals, ruid)
+	rt.finish("cancelled")
+	now := time.Now()
+	for r, t := range p.cancelled {
+		if now.Sub(t) > timeouts.FetcherGlobalTimeout {
+			delete(p.cancelled, r)
+		}
+	}
+	p.cancelled[ruid] = now
+	return now.Sub(rt.sent), true
+}
+
+// allowRequest takes a token from the peer request rate limiter and returns
+// false with the time to wait for the next token if there is none
+func (p *Peer) allowRequest(limit rate.Limit, burst int) (time.Duration, bool) {
+	if limit == rate.Inf {
+		return 0, true
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.limiter == nil {
+		p.limiter = rate.NewLimiter(limit, burst)
+	}
+	res := p.limiter.Reserve()
+	if delay := res.Delay(); delay > 0 {
+		res.Cancel()
+		return delay, false
+	}
+	return 0, true
+}
+
+// addRequest registers the cancel function of an incoming retrieve request
+// that is being served, so that the peer can cancel it. It returns false if
+// maxInFlight requests are already served for the peer, zero means no limit.
+func (p *Peer) addRequest(ruid uint, cancel context.CancelFunc, maxInFlight int) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if maxInFlight > 0 && len(p.requests) >= maxInFlight {
+		return false
+	}
+	p.requests[ruid] = cancel
+	return true
+}
+
+// removeRequest removes an incoming retrieve request that is served
+func (p *Peer) removeRequest(ruid uint) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	delete(p.requests, ruid)
+}
+
+// cancelRequest cancels an incoming retrieve request that is being served
+// and returns false if there is no such request
+func (p *Peer) cancelRequest(ruid uint) bool {
+	p.mtx.Lock()
+	cancel, ok := p.requests[ruid]
+	delete(p.requests, ruid)
+	p.mtx.Unlock()
+
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// throttle removes a retrieval rejected by the peer and marks the peer as
+// not accepting requests for the delay. It returns false if there is no
+// such retrieval.
+func (p *Peer) throttle(ruid uint, delay time.Duration) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	rt, ok := p.retrievals[ruid]
+	if !ok {
+		return false
+	}
+	delete(p.retrievals, ruid)
+	rt.finish("throttled")
+	if until := time.Now().Add(delay); until.After(p.throttled) {
+		p.throttled = until
+	}
+	return true
+}
+
+// pendingRetrievals returns the number of retrievals sent to the peer
+// that are waiting for delivery
+func (p *Peer) pendingRetrievals() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return len(p.retrievals)
+}
+
+// isThrottled returns true if the peer asked not to be sent requests
+func (p *Peer) isThrottled() bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return time.Now().Before(p.throttled)
+}
+
+// addDelivery records a chunk delivered to the peer, so that a receipt
+// for it can be accepted
+func (p *Peer) addDelivery(ruid uint, addr chunk.Address) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	now := time.Now()
+	for r, d := range p.deliveries {
+		if now.Sub(d.sent) > timeouts.FetcherGlobalTimeout {
+			delete(p.deliveries, r)
+		}
+	}
+	p.deliveries[ruid] = delivery{
+		addr: addr,
+		sent: now,
+	}
+}
+
+// checkDelivery returns an error if the chunk with the provided address
+// was not delivered to the peer for the retrieve request with the ruid,
+// or if its receipt was already received
+func (p *Peer) checkDelivery(ruid uint, addr chunk.Address) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	d, ok := p.deliveries[ruid]
+	if !ok {
+		return errors.New("cannot find delivery")
+	}
+	delete(p.deliveries, ruid)
+	if !bytes.Equal(d.addr, addr) {
+		return errors.New("delivery found but address does not match")
+	}
+	return nil
 }
 
 // chunkReceived is called upon ChunkDelivery message reception
 // it is meant to idenfify unsolicited chunk deliveries
-func (p *Peer) checkRequest(ruid uint, addr storage.Address) error {
+// returns the time elapsed since the request was sent and whether
+// the request was forwarded on behalf of another peer
+func (p *Peer) checkRequest(ruid uint, addr storage.Address) (latency time.Duration, forwarded bool, err error) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 	v, ok := p.retrievals[ruid]
 	if !ok {
-		return errors.New("cannot find ruid")
+		if _, ok := p.cancelled[ruid]; ok {
+			delete(p.cancelled, ruid)
+			return 0, false, errRetrievalCancelled
+		}
+		return 0, false, errors.New("cannot find ruid")
 	}
 	delete(p.retrievals, ruid) // since we got the delivery we wanted - it is safe to delete the retrieve request
-	if !bytes.Equal(v, addr) {
-		return errors.New("retrieve request found but address does not match")
+	if !bytes.Equal(v.addr, addr) {
+		v.finish("invalid")
+		return 0, false, errors.New("retrieve request found but address does not match")
 	}
+	v.finish("delivered")
 
-	return nil
+	return time.Since(v.sent), v.forwarded, nil
 }
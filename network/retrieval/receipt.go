@@ -0,0 +1,110 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/state"
+)
+
+// receiptSignatureLength is the length of a recoverable secp256k1 signature
+const receiptSignatureLength = 65
+
+// ErrInvalidReceiptSignature is returned when a receipt is not signed
+// by the peer that requested the chunk
+var ErrInvalidReceiptSignature = errors.New("invalid receipt signature")
+
+// newReceipt returns a receipt for the chunk delivered by the peer with the
+// provided overlay address, signed with the requesting node private key
+func newReceipt(key *ecdsa.PrivateKey, ruid uint, addr chunk.Address, overlay []byte, timestamp uint64) (*Receipt, error) {
+	r := &Receipt{
+		Ruid:      ruid,
+		Addr:      addr,
+		Timestamp: timestamp,
+	}
+	sig, err := crypto.Sign(r.sigHash(overlay), key)
+	if err != nil {
+		return nil, err
+	}
+	r.Signature = sig
+	return r, nil
+}
+
+// sigHash returns the hash of the receipt content that is signed
+// for the delivering peer with the provided overlay address
+func (r *Receipt) sigHash(overlay []byte) []byte {
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, r.Timestamp)
+	return crypto.Keccak256(r.Addr, overlay, ts)
+}
+
+// Signer returns the node ID of the peer that signed the receipt
+// for the delivering peer with the provided overlay address
+func (r *Receipt) Signer(overlay []byte) (enode.ID, error) {
+	if len(r.Signature) != receiptSignatureLength {
+		return enode.ID{}, fmt.Errorf("receipt signature has invalid length: %d", len(r.Signature))
+	}
+	pub, err := crypto.SigToPub(r.sigHash(overlay), r.Signature)
+	if err != nil {
+		return enode.ID{}, err
+	}
+	return enode.PubkeyToIDV4(pub), nil
+}
+
+// ReceiptStore stores receipts that peers signed for chunks delivered to them
+type ReceiptStore interface {
+	Put(peer enode.ID, r *Receipt) error
+	Get(peer enode.ID, addr chunk.Address) (*Receipt, error)
+}
+
+// StateReceiptStore is a ReceiptStore backed by a state.Store
+type StateReceiptStore struct {
+	store state.Store
+}
+
+// NewStateReceiptStore returns a ReceiptStore that persists receipts in the provided state.Store
+func NewStateReceiptStore(store state.Store) *StateReceiptStore {
+	return &StateReceiptStore{
+		store: store,
+	}
+}
+
+// Put stores a receipt signed by the peer
+func (s *StateReceiptStore) Put(peer enode.ID, r *Receipt) error {
+	return s.store.Put(receiptKey(peer, r.Addr), r)
+}
+
+// Get returns the receipt signed by the peer for the chunk address.
+// It returns state.ErrNotFound if there is no such receipt.
+func (s *StateReceiptStore) Get(peer enode.ID, addr chunk.Address) (*Receipt, error) {
+	r := new(Receipt)
+	if err := s.store.Get(receiptKey(peer, addr), r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func receiptKey(peer enode.ID, addr chunk.Address) string {
+	return fmt.Sprintf("receipt_%s_%s", peer.String(), addr.Hex())
+}
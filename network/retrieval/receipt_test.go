@@ -0,0 +1,200 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	chunktesting "github.com/ethersphere/swarm/chunk/testing"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/state"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// TestReceiptSigner tests that the signer of a receipt is recovered only
+// for the delivering peer overlay address that it was signed for.
+func TestReceiptSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay := network.RandomBzzAddr().Over()
+
+	r, err := newReceipt(key, 1, storage.Address(hash0[:]), overlay, uint64(time.Now().UnixNano()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := r.Signer(overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := enode.PubkeyToIDV4(&key.PublicKey); signer != want {
+		t.Errorf("got signer %v, want %v", signer, want)
+	}
+
+	signer, err = r.Signer(network.RandomBzzAddr().Over())
+	if err == nil && signer == enode.PubkeyToIDV4(&key.PublicKey) {
+		t.Error("signer recovered for a different overlay address")
+	}
+
+	r.Signature = r.Signature[1:]
+	if _, err := r.Signer(overlay); err == nil {
+		t.Error("expected error for a signature with invalid length")
+	}
+}
+
+// TestStateReceiptStore tests storing and retrieving receipts.
+func TestStateReceiptStore(t *testing.T) {
+	s := NewStateReceiptStore(state.NewInmemoryStore())
+
+	peer := enode.HexID("3431c3939e1ee2a6345e976a8234f9870152d64879f30bc272a074f6859e75e8")
+	addr := storage.Address(hash0[:])
+
+	if _, err := s.Get(peer, addr); err != state.ErrNotFound {
+		t.Fatalf("got error %v, want %v", err, state.ErrNotFound)
+	}
+
+	want := &Receipt{
+		Ruid:      1,
+		Addr:      addr,
+		Timestamp: 42,
+		Signature: []byte{1, 2, 3},
+	}
+	if err := s.Put(peer, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(peer, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Ruid != want.Ruid || !bytes.Equal(got.Addr, want.Addr) || got.Timestamp != want.Timestamp || !bytes.Equal(got.Signature, want.Signature) {
+		t.Errorf("got receipt %+v, want %+v", got, want)
+	}
+}
+
+// TestHandleReceipt tests that only receipts signed by the peer for chunks
+// delivered to it are stored.
+func TestHandleReceipt(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+	store := NewStateReceiptStore(state.NewInmemoryStore())
+	r := NewWithOptions(to, nil, addr, nil, &Options{ReceiptStore: store})
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newTestRetrievalPeer(t, r, to, key, network.RandomBzzAddr().Over(), nil)
+
+	ref := storage.Address(hash0[:])
+	receipt, err := newReceipt(key, 1, ref, addr.Over(), uint64(time.Now().UnixNano()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// receipt for a chunk that was not delivered
+	if err := r.handleReceipt(context.Background(), p, receipt); err == nil {
+		t.Fatal("expected error for an unsolicited receipt")
+	}
+
+	// receipt signed by a different key
+	otherKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherReceipt, err := newReceipt(otherKey, 2, ref, addr.Over(), uint64(time.Now().UnixNano()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.addDelivery(2, ref)
+	if err := r.handleReceipt(context.Background(), p, otherReceipt); err == nil {
+		t.Fatal("expected error for a receipt signed by another key")
+	}
+	if _, err := store.Get(p.ID(), ref); err != state.ErrNotFound {
+		t.Fatalf("got error %v, want %v", err, state.ErrNotFound)
+	}
+
+	p.addDelivery(1, ref)
+	if err := r.handleReceipt(context.Background(), p, receipt); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Get(p.ID(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Signature, receipt.Signature) {
+		t.Errorf("got receipt signature %x, want %x", got.Signature, receipt.Signature)
+	}
+
+	// the same delivery can not be acknowledged twice
+	if err := r.handleReceipt(context.Background(), p, receipt); err == nil {
+		t.Fatal("expected error for a repeated receipt")
+	}
+}
+
+// TestChunkDeliveryReceipt tests that a receipt is sent to the peer
+// that delivered a requested chunk.
+func TestChunkDeliveryReceipt(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	bzzAddr := network.NewBzzAddr(network.PrivateKeyToBzzKey(pk), nil)
+
+	to := network.NewKademlia(bzzAddr.Over(), network.NewKadParams())
+	r := NewWithOptions(to, ns, bzzAddr, nil, &Options{PrivateKey: pk})
+
+	rw, remoteRW := p2p.MsgPipe()
+	defer remoteRW.Close()
+	p := newTestRetrievalPeer(t, r, to, nil, network.RandomBzzAddr().Over(), rw)
+
+	codes := make(chan uint64, 1)
+	go func() {
+		msg, err := remoteRW.ReadMsg()
+		if err != nil {
+			return
+		}
+		msg.Discard()
+		codes <- msg.Code
+	}()
+
+	ch := chunktesting.GenerateTestRandomChunk()
+	p.addRetrieval(1, ch.Address(), nil, false)
+	err := r.handleChunkDelivery(context.Background(), p, &ChunkDelivery{
+		Ruid:  1,
+		Addr:  ch.Address(),
+		SData: ch.Data(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantCode, _ := spec.GetCode(&Receipt{})
+	select {
+	case code := <-codes:
+		if code != wantCode {
+			t.Fatalf("got message code %v, want %v", code, wantCode)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("receipt not received")
+	}
+}
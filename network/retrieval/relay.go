@@ -0,0 +1,276 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+
+	olog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/timeouts"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	"github.com/ethersphere/swarm/spancontext"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// handleRelayRequest handles a RelayRequest message from a peer that can not
+// connect to the target peer of the request, by requesting the chunk from the
+// target on its behalf and delivering the chunk back to the requesting peer
+func (r *Retrieval) handleRelayRequest(ctx context.Context, p *Peer, msg *RelayRequest) error {
+	p.logger.Debug("retrieval.handleRelayRequest", "ref", msg.Addr, "ruid", msg.Ruid)
+
+	if r.noRelay {
+		return protocols.Break(fmt.Errorf("relay request from peer, ruid %d, addr %s: %w", msg.Ruid, msg.Addr, ErrRelayDisabled))
+	}
+
+	if r.auth != nil {
+		if err := r.auth.Verify(msg.retrieveRequest(), p.ID(), r.baseAddress.Over()); err != nil {
+			unauthenticatedRequest.Inc(1)
+			return protocols.Break(fmt.Errorf("relay request from peer, ruid %d, addr %s: %w", msg.Ruid, msg.Addr, err))
+		}
+	}
+
+	if delay, ok := p.allowRequest(r.requestRate, r.requestBurst); !ok {
+		return r.sendThrottle(ctx, p, msg.Ruid, msg.Addr, delay)
+	}
+
+	ctx, osp := spancontext.StartSpan(
+		ctx,
+		"handle.relay.request")
+
+	osp.LogFields(
+		olog.String("ref", msg.Addr.String()),
+		olog.Uint64("ruid", uint64(msg.Ruid)),
+		olog.String("peer", p.ID().String()),
+	)
+
+	defer osp.Finish()
+
+	ctx, cancel := context.WithTimeout(ctx, timeouts.FetcherGlobalTimeout)
+	defer cancel()
+
+	// the requesting peer may cancel the request if it is no longer needed
+	if !p.addRequest(msg.Ruid, cancel, r.maxInFlight) {
+		return r.sendThrottle(ctx, p, msg.Ruid, msg.Addr, 0)
+	}
+	defer p.removeRequest(msg.Ruid)
+
+	ch, err := r.relay(ctx, p, msg)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			p.logger.Trace("retrieval.handleRelayRequest - cancelled", "ref", msg.Addr, "ruid", msg.Ruid)
+			return nil
+		}
+		retrieveChunkFail.Inc(1)
+		return fmt.Errorf("relaying request for ref %s: %w", msg.Addr, err)
+	}
+	relayedRequest.Inc(1)
+
+	p.logger.Trace("retrieval.handleRelayRequest - delivery", "ref", msg.Addr)
+
+	deliveryMsg := &ChunkDelivery{
+		Ruid:  msg.Ruid,
+		Addr:  ch.Address(),
+		SData: ch.Data(),
+	}
+
+	if r.receiptStore != nil && p.features.Has(FeatureReceipts) {
+		// the receipt may arrive before the send returns
+		p.addDelivery(msg.Ruid, ch.Address())
+	}
+
+	err = p.Send(ctx, deliveryMsg)
+	if err != nil {
+		return fmt.Errorf("retrieval.handleRelayRequest - peer delivery for ref %s: %w", msg.Addr, err)
+	}
+	requestServed(len(deliveryMsg.SData))
+	osp.LogFields(olog.Bool("delivered", true))
+
+	return nil
+}
+
+// relay sends a retrieve request for the chunk to the target peer of the
+// relay request and waits for the chunk to be delivered. As forwarded
+// retrieve requests, relay requests from the peer are served only from
+// the local store if the forwarding policy does not allow forwarding them
+// or if their TTL is expired.
+func (r *Retrieval) relay(ctx context.Context, p *Peer, msg *RelayRequest) (chunk.Chunk, error) {
+	// the chunk may already be stored by this node
+	ch, err := r.netStore.Store.Get(ctx, chunk.ModeGetRequest, msg.Addr)
+	if err == nil {
+		return ch, nil
+	}
+
+	if !r.forwards(p) {
+		notForwardedRetrieveRequest.Inc(1)
+		return nil, err
+	}
+
+	// the request to the target is one more hop
+	if msg.TTL == 0 {
+		p.logger.Trace("retrieval.relay - ttl expired", "ref", msg.Addr, "ruid", msg.Ruid)
+		metrics.GetOrRegisterCounter("network/retrieve/ttl_expired", nil).Inc(1)
+		return nil, ErrTTLExpired
+	}
+
+	target := r.getPeerByOverlay(msg.Target)
+	if target == nil {
+		relayTargetNotFound.Inc(1)
+		return nil, ErrRelayTargetNotFound
+	}
+
+	// the delivery of the target peer is put to the netstore,
+	// which closes the fetcher of the chunk
+	fi, _, _ := r.netStore.GetOrCreateFetcher(ctx, msg.Addr, "relay")
+
+	req := &RetrieveRequest{
+		Ruid: uint(rand.Uint32()),
+		Addr: msg.Addr,
+		TTL:  msg.TTL - 1,
+	}
+	if r.auth != nil {
+		auth, err := r.auth.Sign(req, target.BzzAddr.Over())
+		if err != nil {
+			return nil, err
+		}
+		req.Auth = auth
+	}
+
+	target.logger.Trace("sending relayed retrieve request", "ref", req.Addr, "ruid", req.Ruid, "ttl", req.TTL)
+	target.addRetrieval(req.Ruid, req.Addr, nil, true)
+	if err := target.Send(ctx, req); err != nil {
+		target.expireRetrieval(req.Ruid)
+		return nil, err
+	}
+	requestSent(target, req.Addr, true)
+
+	select {
+	case <-fi.Delivered:
+		return fi.Chunk, nil
+	case <-ctx.Done():
+		if _, ok := target.cancelRetrieval(req.Ruid); ok && target.features.Has(FeatureCancel) {
+			go r.sendCancel(target, req.Ruid)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// sendRelayRequest sends a relay request for the chunk through a connected
+// peer if the closest known peer to the chunk can not be requested directly.
+// It returns the relaying peer and the ruid of the request, or false if the
+// request is not relayed.
+func (r *Retrieval) sendRelayRequest(ctx context.Context, req *storage.Request, ttl uint8, priority Priority) (*Peer, uint, bool) {
+	relay, target := r.findRelay(req)
+	if relay == nil {
+		return nil, 0, false
+	}
+
+	msg := &RelayRequest{
+		Ruid:   uint(rand.Uint32()),
+		Addr:   req.Addr,
+		Target: target.Over(),
+		TTL:    ttl,
+	}
+	if r.auth != nil {
+		auth, err := r.auth.Sign(msg.retrieveRequest(), relay.BzzAddr.Over())
+		if err != nil {
+			return nil, 0, false
+		}
+		msg.Auth = auth
+	}
+
+	relay.logger.Trace("sending relay request", "ref", msg.Addr, "target", target, "ruid", msg.Ruid, "ttl", msg.TTL)
+	relay.addRetrieval(msg.Ruid, msg.Addr, nil, priority == PriorityForwarded)
+	if err := relay.Send(ctx, msg); err != nil {
+		relay.logger.Trace("error sending relay request to peer", "ruid", msg.Ruid, "err", err)
+		relay.expireRetrieval(msg.Ruid)
+		return nil, 0, false
+	}
+	relayRequestSent.Inc(1)
+	requestSent(relay, req.Addr, priority == PriorityForwarded)
+	return relay, msg.Ruid, true
+}
+
+// findRelay returns the closest known peer to the chunk that serves retrieve
+// requests, if it is not connected but closer to the chunk than this node and
+// all connected peers, as nodes behind NAT can not be connected to. It is
+// returned together with the connected peer closest to it that relays chunk
+// deliveries, which is most likely to be connected to it.
+func (r *Retrieval) findRelay(req *storage.Request) (*Peer, *network.BzzAddr) {
+	var target *network.BzzAddr
+	r.kad.EachAddr(req.Addr, 255, func(a *network.BzzAddr, _ int) bool {
+		if !network.ServesRetrieve(a) {
+			return true
+		}
+		target = a
+		return false
+	})
+	if target == nil || r.getPeer(target.ID()) != nil {
+		return nil, nil
+	}
+
+	po := chunk.Proximity(target.Over(), req.Addr)
+	if po <= chunk.Proximity(r.kad.BaseAddr(), req.Addr) {
+		return nil, nil
+	}
+	closer := true
+	r.kad.EachConn(req.Addr, 255, func(p *network.Peer, _ int) bool {
+		closer = po > chunk.Proximity(p.Over(), req.Addr)
+		return false
+	})
+	if !closer {
+		return nil, nil
+	}
+
+	var relay *Peer
+	_ = r.kad.EachConnFiltered(target.Over(), network.CapabilityIndexRelayDelivery, 255, func(p *network.Peer, _ int) bool {
+		// do not send the request back to the peer who asked us
+		if p.ID() == req.Origin || req.SkipPeer(p.ID().String()) {
+			return true
+		}
+		protoPeer := r.getPeer(p.ID())
+		if protoPeer == nil || !protoPeer.features.Has(FeatureRelay) {
+			return true
+		}
+		relay = protoPeer
+		return false
+	})
+	if relay == nil {
+		return nil, nil
+	}
+	return relay, target
+}
+
+// getPeerByOverlay returns the connected peer with the overlay address,
+// or nil if it is not connected
+func (r *Retrieval) getPeerByOverlay(overlay []byte) *Peer {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	for _, p := range r.peers {
+		if bytes.Equal(p.BzzAddr.Over(), overlay) {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
+	chunktesting "github.com/ethersphere/swarm/chunk/testing"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/capability"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// bits of the bzz capability advertising that a peer serves
+// retrieve requests and relays chunk deliveries
+const (
+	capabilityRetrieve      = 0
+	capabilityRelayDelivery = 3
+)
+
+// readMsgs sends the messages with the code read from the pipe to the
+// returned channel, discarding the other ones. The payload is read before
+// sending, as writing to the pipe blocks until the message is consumed.
+func readMsgs(rw p2p.MsgReadWriter, code uint64) <-chan p2p.Msg {
+	msgs := make(chan p2p.Msg, 10)
+	go func() {
+		for {
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return
+			}
+			if msg.Code != code {
+				msg.Discard()
+				continue
+			}
+			payload, err := ioutil.ReadAll(msg.Payload)
+			if err != nil {
+				return
+			}
+			msg.Payload = bytes.NewReader(payload)
+			msgs <- msg
+		}
+	}()
+	return msgs
+}
+
+// TestRelayRequest tests that a relay request is served by requesting
+// the chunk from the target peer and delivering it to the requesting peer
+// with the ruid of the relay request
+func TestRelayRequest(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	bzzAddr := network.PrivateKeyToBzzKey(pk)
+
+	kad := network.NewKademlia(bzzAddr, network.NewKadParams())
+	r := NewWithOptions(kad, ns, network.NewBzzAddr(bzzAddr, nil), nil, nil)
+
+	requesterRW, requesterRemote := p2p.MsgPipe()
+	defer requesterRemote.Close()
+	requester := newTestRetrievalPeer(t, r, kad, nil, network.RandomBzzAddr().Over(), requesterRW)
+
+	targetRW, targetRemote := p2p.MsgPipe()
+	defer targetRemote.Close()
+	targetOverlay := network.RandomBzzAddr().Over()
+	target := newTestRetrievalPeer(t, r, kad, nil, targetOverlay, targetRW)
+
+	deliveries := readMsgs(requesterRemote, 0)
+	requests := readMsgs(targetRemote, 1)
+
+	ch := chunktesting.GenerateTestRandomChunk()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- r.handleRelayRequest(context.Background(), requester, &RelayRequest{
+			Ruid:   1234,
+			Addr:   ch.Address(),
+			Target: targetOverlay,
+			TTL:    5,
+		})
+	}()
+
+	var req RetrieveRequest
+	select {
+	case msg := <-requests:
+		if err := msg.Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("relayed retrieve request not received")
+	}
+	if !bytes.Equal(req.Addr, ch.Address()) {
+		t.Fatalf("got relayed request for %s, want %s", req.Addr, ch.Address())
+	}
+	if req.TTL != 4 {
+		t.Fatalf("got relayed request ttl %v, want 4", req.TTL)
+	}
+
+	err := r.handleChunkDelivery(context.Background(), target, &ChunkDelivery{
+		Ruid:  req.Ruid,
+		Addr:  ch.Address(),
+		SData: ch.Data(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var delivery ChunkDelivery
+	select {
+	case msg := <-deliveries:
+		if err := msg.Decode(&delivery); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("relayed chunk not delivered")
+	}
+	if delivery.Ruid != 1234 {
+		t.Errorf("got delivery ruid %v, want 1234", delivery.Ruid)
+	}
+	if !bytes.Equal(delivery.SData, ch.Data()) {
+		t.Error("delivered chunk data does not match")
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRelayRequestRefused tests that relay requests for peers that are
+// not connected fail, that requests are not relayed if the forwarding
+// policy does not allow it or their TTL is expired, and that nodes that
+// do not relay deliveries disconnect the requesting peers
+func TestRelayRequestRefused(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	bzzAddr := network.PrivateKeyToBzzKey(pk)
+
+	for _, tc := range []struct {
+		name         string
+		disableRelay bool
+		forwarding   ForwardingPolicy
+		ttl          uint8
+		want         error
+	}{
+		{name: "target not connected", ttl: 5, want: ErrRelayTargetNotFound},
+		{name: "relay disabled", disableRelay: true, ttl: 5, want: ErrRelayDisabled},
+		{name: "forwarding disabled", forwarding: ForwardNone, ttl: 5, want: chunk.ErrChunkNotFound},
+		{name: "ttl expired", ttl: 0, want: ErrTTLExpired},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			kad := network.NewKademlia(bzzAddr, network.NewKadParams())
+			r := NewWithOptions(kad, ns, network.NewBzzAddr(bzzAddr, nil), nil, &Options{
+				DisableRelay:     tc.disableRelay,
+				ForwardingPolicy: tc.forwarding,
+			})
+			requester := newTestRetrievalPeer(t, r, kad, nil, network.RandomBzzAddr().Over(), nil)
+
+			err := r.handleRelayRequest(context.Background(), requester, &RelayRequest{
+				Ruid:   1234,
+				Addr:   hash0[:],
+				Target: network.RandomBzzAddr().Over(),
+				TTL:    tc.ttl,
+			})
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("got error %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+// TestRequestFromPeersRelay tests that a request for a chunk whose closest
+// known peer is not connected is sent through a connected relaying peer
+// only if relaying requests is enabled
+func TestRequestFromPeersRelay(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		relaying bool
+		wantCode uint64
+	}{
+		{name: "relayed", relaying: true, wantCode: 6},
+		{name: "not relayed", relaying: false, wantCode: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := network.RandomBzzAddr()
+			// the base address is far from the chunk
+			addr.OAddr = append([]byte{^hash0[0]}, hash0[1:]...)
+			to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+			r := NewWithOptions(to, nil, addr, nil, &Options{RelayRequests: tc.relaying})
+
+			// the closest peer to the chunk is known, but can not be connected to
+			target := network.RandomBzzAddr()
+			target.OAddr = append(append([]byte{}, hash0[:31]...), ^hash0[31])
+			if err := to.Register(target); err != nil {
+				t.Fatal(err)
+			}
+
+			c := capability.NewCapability(network.CapabilityID, 16)
+			c.Set(capabilityRetrieve)
+			c.Set(capabilityRelayDelivery)
+			caps := capability.NewCapabilities()
+			caps.Add(c)
+			relayAddr := network.RandomBzzAddr()
+			relayAddr.OAddr = append([]byte{hash0[0] ^ 0x01}, hash0[1:]...)
+			relayAddr = relayAddr.WithCapabilities(caps)
+
+			rw, remoteRW := p2p.MsgPipe()
+			defer remoteRW.Close()
+			newTestRetrievalPeerWithAddr(t, r, to, nil, relayAddr, rw)
+			msgs := readMsgs(remoteRW, tc.wantCode)
+
+			_, cleanup, err := r.RequestFromPeers(context.Background(), storage.NewRequest(storage.Address(hash0[:])), enode.ID{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// cleanup may send a cancel message
+			defer func() { go cleanup() }()
+
+			select {
+			case msg := <-msgs:
+				if !tc.relaying {
+					return
+				}
+				var req RelayRequest
+				if err := msg.Decode(&req); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(req.Target, target.Over()) {
+					t.Errorf("got relay target %x, want %x", req.Target, target.Over())
+				}
+				if req.TTL != DefaultMaxHops {
+					t.Errorf("got ttl %v, want %v", req.TTL, DefaultMaxHops)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("message with code %d not received", tc.wantCode)
+			}
+		})
+	}
+}
+
+// TestRelayRequestDeliveredFromStore tests that a relay request for a chunk
+// stored by the relaying node is served without contacting the target peer
+func TestRelayRequestDeliveredFromStore(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	bzzAddr := network.PrivateKeyToBzzKey(pk)
+
+	kad := network.NewKademlia(bzzAddr, network.NewKadParams())
+	r := NewWithOptions(kad, ns, network.NewBzzAddr(bzzAddr, nil), nil, nil)
+
+	requester := newTestRetrievalPeer(t, r, kad, nil, network.RandomBzzAddr().Over(), nil)
+
+	ch := chunktesting.GenerateTestRandomChunk()
+	if _, err := ns.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.relay(context.Background(), requester, &RelayRequest{
+		Ruid:   1234,
+		Addr:   ch.Address(),
+		Target: network.RandomBzzAddr().Over(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Data(), ch.Data()) {
+		t.Error("relayed chunk data does not match")
+	}
+}
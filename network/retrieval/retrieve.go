@@ -19,10 +19,12 @@ package retrieval
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
 	"errors"
 	"fmt"
 	"math/rand"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -42,6 +44,7 @@ import (
 	"github.com/ethersphere/swarm/spancontext"
 	"github.com/ethersphere/swarm/storage"
 	"github.com/ethersphere/swarm/swap"
+	"golang.org/x/time/rate"
 )
 
 var (
@@ -53,20 +56,84 @@ var (
 	handleRetrieveRequestMsgCount = metrics.NewRegisteredCounter("network/retrieve/handle_retrieve_request_msg", nil)
 	retrieveChunkFail             = metrics.NewRegisteredCounter("network/retrieve/retrieve_chunks_fail", nil)
 	unsolicitedChunkDelivery      = metrics.NewRegisteredCounter("network/retrieve/unsolicited_delivery", nil)
+	cancelledChunkDelivery        = metrics.NewRegisteredCounter("network/retrieve/cancelled_delivery", nil)
+	cancelledRetrieveRequest      = metrics.NewRegisteredCounter("network/retrieve/cancelled_request", nil)
+	receiptsStored                = metrics.NewRegisteredCounter("network/retrieve/receipts_stored", nil)
+	notForwardedRetrieveRequest   = metrics.NewRegisteredCounter("network/retrieve/not_forwarded_request", nil)
+	throttledRetrieveRequest      = metrics.NewRegisteredCounter("network/retrieve/throttled_request", nil)
+	throttleReceived              = metrics.NewRegisteredCounter("network/retrieve/throttle_received", nil)
+	saturatedPeerSkipped          = metrics.NewRegisteredCounter("network/retrieve/saturated_peer_skipped", nil)
+	notCachedChunkDelivery        = metrics.NewRegisteredCounter("network/retrieve/not_cached_delivery", nil)
+	unauthenticatedRequest        = metrics.NewRegisteredCounter("network/retrieve/unauthenticated_request", nil)
+	relayRequestSent              = metrics.NewRegisteredCounter("network/retrieve/relay_request_sent", nil)
+	relayedRequest                = metrics.NewRegisteredCounter("network/retrieve/relayed_request", nil)
+	relayTargetNotFound           = metrics.NewRegisteredCounter("network/retrieve/relay_target_not_found", nil)
 
 	retrievalPeers = metrics.GetOrRegisterGauge("network/retrieve/peers", nil)
 
 	spec = &protocols.Spec{
 		Name:       "bzz-retrieve",
-		Version:    2,
+		Version:    8,
 		MaxMsgSize: 10 * 1024 * 1024,
 		Messages: []interface{}{
 			ChunkDelivery{},
 			RetrieveRequest{},
+			CancelRetrieveRequest{},
+			Receipt{},
+			Throttle{},
+			Handshake{},
+			RelayRequest{},
 		},
 	}
 
 	ErrNoPeerFound = errors.New("no peer found")
+
+	// ErrTTLExpired is returned when a retrieve request from a peer can not
+	// be forwarded further as it reached the maximum number of hops
+	ErrTTLExpired = errors.New("retrieve request ttl expired")
+
+	// ErrServingDisabled is returned when a retrieve request is received
+	// by a node that declared it does not serve them
+	ErrServingDisabled = errors.New("retrieve requests are not served")
+
+	// ErrRelayDisabled is returned when a relay request is received
+	// by a node that does not relay chunk deliveries
+	ErrRelayDisabled = errors.New("relay requests are not served")
+
+	// ErrRelayTargetNotFound is returned when the peer a relay request
+	// is for is not connected to the relaying node
+	ErrRelayTargetNotFound = errors.New("relay target not connected")
+)
+
+const (
+	// DefaultMaxRetries is the default number of times a retrieve request
+	// is retried against the next best peer if it could not be sent or
+	// was not delivered in time
+	DefaultMaxRetries = 3
+	// DefaultRetryBackoff is the default time to wait before the first retry,
+	// doubled on every subsequent one
+	DefaultRetryBackoff = 50 * time.Millisecond
+	// DefaultRetryTimeout is the default time to wait for a delivery
+	// before a retrieve request is retried against the next best peer
+	DefaultRetryTimeout = 500 * time.Millisecond
+	// DefaultMaxHops is the default maximum number of hops
+	// a retrieve request is forwarded over
+	DefaultMaxHops = 20
+	// DefaultRequestRate is the default number of retrieve requests
+	// per second accepted from a single peer
+	DefaultRequestRate = 500
+	// DefaultRequestBurst is the default number of retrieve requests
+	// a peer can send at once over the request rate
+	DefaultRequestBurst = 1000
+	// DefaultMaxInFlightRequests is the default number of retrieve requests
+	// from a single peer that are served concurrently
+	DefaultMaxInFlightRequests = 500
+	// DefaultMaxActiveRequests is the default number of retrieve requests
+	// sent to peers that can wait for delivery at the same time
+	DefaultMaxActiveRequests = 1000
+	// DefaultMaxPeerRequests is the default number of retrieve requests
+	// sent to a single peer that can wait for delivery at the same time
+	DefaultMaxPeerRequests = DefaultMaxInFlightRequests
 )
 
 // Price is the method through which a message type marks itself
@@ -93,28 +160,267 @@ func (cd *ChunkDelivery) Price() *protocols.Price {
 
 // Retrieval holds state and handles protocol messages for the `bzz-retrieve` protocol
 type Retrieval struct {
-	netStore    *storage.NetStore
-	baseAddress *network.BzzAddr
-	kad         *network.Kademlia
-	kademliaLB  *network.KademliaLoadBalancer
-	mtx         sync.RWMutex       // protect peer map
-	peers       map[enode.ID]*Peer // compatible peers
-	spec        *protocols.Spec    // protocol spec
-	logger      log.Logger         // custom logger to append a basekey
-	quit        chan struct{}      // shutdown channel
+	netStore     *storage.NetStore
+	baseAddress  *network.BzzAddr
+	kad          *network.Kademlia
+	kademliaLB   *network.KademliaLoadBalancer
+	mtx          sync.RWMutex       // protect peer map
+	peers        map[enode.ID]*Peer // compatible peers
+	spec         *protocols.Spec    // protocol spec
+	logger       log.Logger         // custom logger to append a basekey
+	quit         chan struct{}      // shutdown channel
+	fanOut       int                // number of peers a single request is sent to
+	maxRetries   int                // number of retries on failed requests
+	retryBackoff time.Duration      // initial backoff between retries
+	retryTimeout time.Duration      // time to wait for a delivery before retrying
+	scores       *peerScores        // retrieval statistics of peers
+	privateKey   *ecdsa.PrivateKey  // key to sign receipts for received chunks
+	receiptStore ReceiptStore       // store for receipts of delivered chunks
+	maxHops      uint8              // maximum number of hops a request is forwarded over
+	forwarding   ForwardingPolicy   // policy for forwarding requests that can not be served locally
+	forwardMinPo int                // minimal peer proximity for ForwardProximity policy
+	requestRate  rate.Limit         // retrieve requests per second accepted from a peer
+	requestBurst int                // retrieve requests a peer can send at once over the rate
+	maxInFlight  int                // retrieve requests from a peer served concurrently, zero for no limit
+	scheduler    *scheduler         // schedules sending of requests by priority, nil for no limit
+	features     Features           // optional protocol features supported by this node
+	maxPeerReqs  int                // requests sent to a peer waiting for delivery, zero for no limit
+	selector     PeerSelector       // selects the peer a request is sent to
+	caching      CachePolicy        // policy for caching chunks delivered for forwarded requests
+	auth         Authenticator      // authenticates retrieve requests, nil for no authentication
+	capIndex     string             // kademlia capability index of peers requests are sent to, empty for all peers
+	noServe      bool               // refuse retrieve requests from peers
+	noRelay      bool               // refuse relay requests from peers
+	relaying     bool               // send requests for chunks of unconnected peers through relays
+}
+
+// ForwardingPolicy defines how retrieve requests from peers for chunks
+// that are not in the local store are handled
+type ForwardingPolicy int
+
+const (
+	// ForwardAll forwards all requests to closer peers
+	ForwardAll ForwardingPolicy = iota
+	// ForwardNone serves requests only from the local store
+	ForwardNone
+	// ForwardProximity forwards requests only from peers with proximity
+	// to this node of at least Options.ForwardMinProximity
+	ForwardProximity
+)
+
+// CachePolicy defines whether chunks delivered for requests forwarded on
+// behalf of other peers are stored in the local store. Chunks within the
+// neighbourhood of this node are always stored.
+type CachePolicy int
+
+const (
+	// CacheAlways stores all forwarded chunks
+	CacheAlways CachePolicy = iota
+	// CacheNever only passes forwarded chunks to the requesting peers
+	CacheNever
+	// CacheProximity stores forwarded chunks with probability growing with
+	// the chunk proximity to this node, (po+1)/(depth+1), so that chunks
+	// closer to the neighbourhood are more likely to be requested again
+	CacheProximity
+)
+
+// Options holds optional parameters for the retrieval protocol handler
+type Options struct {
+	// FanOut is the number of closest peers a retrieve request is sent to
+	// concurrently. The first delivered chunk is used and the requests to
+	// the other peers are cancelled. Values smaller than 1 default to 1.
+	FanOut int
+	// MaxRetries is the number of times a request that could not be sent,
+	// or was not delivered within RetryTimeout, is retried against the
+	// next best peer. Failed peers are skipped for
+	// the request until their entry in the request skip list decays.
+	// If zero, DefaultMaxRetries is used, negative value disables retries.
+	MaxRetries int
+	// RetryBackoff is the time to wait before the first retry, doubled on
+	// every subsequent one. If zero, DefaultRetryBackoff is used.
+	RetryBackoff time.Duration
+	// RetryTimeout is the time to wait for a delivery before the request
+	// is retried against the next best peer, counted against MaxRetries.
+	// Peers that did not deliver in time may still deliver. If zero,
+	// DefaultRetryTimeout is used, negative value disables these retries.
+	RetryTimeout time.Duration
+	// PrivateKey is the node key used to sign receipts for chunks
+	// delivered by peers. If nil, receipts are not sent.
+	PrivateKey *ecdsa.PrivateKey
+	// ReceiptStore stores receipts that peers sign for chunks delivered
+	// to them. If nil, receipts are not requested to be stored.
+	ReceiptStore ReceiptStore
+	// MaxHops is the maximum number of hops a retrieve request is
+	// forwarded over. It is set as the TTL of requests originating from
+	// this node and caps the TTL of forwarded requests. If zero,
+	// DefaultMaxHops is used.
+	MaxHops uint8
+	// ForwardingPolicy controls whether requests from peers that can not
+	// be served from the local store are forwarded. Light and bandwidth
+	// capped nodes can use it not to act as relays.
+	ForwardingPolicy ForwardingPolicy
+	// ForwardMinProximity is the minimal proximity of a requesting peer
+	// to this node for its requests to be forwarded with the
+	// ForwardProximity policy.
+	ForwardMinProximity int
+	// RequestRate is the number of retrieve requests per second accepted
+	// from a single peer. Requests over the rate are rejected with a
+	// Throttle message. If zero, DefaultRequestRate is used, negative
+	// value disables the limit.
+	RequestRate float64
+	// RequestBurst is the number of retrieve requests a peer can send at
+	// once over the request rate. If zero, DefaultRequestBurst is used.
+	RequestBurst int
+	// MaxInFlightRequests is the number of retrieve requests from a single
+	// peer that are served concurrently. Requests over the limit are
+	// rejected with a Throttle message. If zero, DefaultMaxInFlightRequests
+	// is used, negative value disables the limit.
+	MaxInFlightRequests int
+	// MaxActiveRequests is the number of retrieve requests sent to peers
+	// that can wait for delivery at the same time. Requests over the limit
+	// are queued by priority class and scheduled by weighted round robin,
+	// so that local requests are preferred over forwarded and background
+	// ones. If zero, DefaultMaxActiveRequests is used, negative value
+	// disables the limit.
+	MaxActiveRequests int
+	// Features is the set of optional protocol features supported by this
+	// node. The features used with a peer are the ones supported by both,
+	// as agreed in the handshake. If zero, AllFeatures is used.
+	Features Features
+	// MaxPeerRequests is the number of retrieve requests sent to a single
+	// peer that can wait for delivery at the same time. Saturated peers are
+	// not selected for requests until some of their requests are delivered
+	// or cancelled. If zero, DefaultMaxPeerRequests is used, negative value
+	// disables the limit.
+	MaxPeerRequests int
+	// PeerSelector selects the peer a retrieve request is sent to from
+	// the peers allowed by the forwarding rules. If nil, ScoreSelector is
+	// used, selecting the best scored peer from the closest ones.
+	PeerSelector PeerSelector
+	// CachePolicy controls whether chunks delivered for requests forwarded
+	// on behalf of other peers are cached in the local store, trading disk
+	// churn against latency of future requests for the same chunks.
+	CachePolicy CachePolicy
+	// Authenticator authenticates retrieve requests in permissioned swarms.
+	// Requests sent to peers are signed with it and requests from peers
+	// that can not be verified are refused by disconnecting the peer.
+	// If nil, requests are not authenticated.
+	Authenticator Authenticator
+	// DisableServing refuses retrieve requests from peers by disconnecting
+	// them, for light nodes that declare they do not serve them. Requests
+	// of this node are still sent to peers.
+	DisableServing bool
+	// CapabilityIndex is the key of the kademlia capability index peers
+	// are selected from for retrieve requests, such as
+	// network.CapabilityIndexRelayRetrieve, skipping peers that do not
+	// advertise the capability to serve them. If empty, all connected
+	// peers are considered.
+	CapabilityIndex string
+	// DisableRelay refuses relay requests from peers by disconnecting
+	// them, for nodes that do not advertise the capability to relay chunk
+	// deliveries between peers that can not connect to each other.
+	DisableRelay bool
+	// RelayRequests sends requests for chunks whose closest known peer is
+	// not connected, but closer to the chunk than this node and all
+	// connected peers, through a connected peer that relays deliveries,
+	// so that chunks stored by nodes behind NAT can be retrieved.
+	RelayRequests bool
 }
 
 // New returns a new instance of the retrieval protocol handler
 func New(kad *network.Kademlia, ns *storage.NetStore, baseKey *network.BzzAddr, balance protocols.Balance) *Retrieval {
+	return NewWithOptions(kad, ns, baseKey, balance, nil)
+}
+
+// NewWithOptions returns a new instance of the retrieval protocol handler
+// configured with the provided options
+func NewWithOptions(kad *network.Kademlia, ns *storage.NetStore, baseKey *network.BzzAddr, balance protocols.Balance, o *Options) *Retrieval {
+	if o == nil {
+		o = new(Options)
+	}
 	r := &Retrieval{
-		netStore:    ns,
-		baseAddress: baseKey,
-		kad:         kad,
-		kademliaLB:  network.NewKademliaLoadBalancer(kad, false),
-		peers:       make(map[enode.ID]*Peer),
-		spec:        spec,
-		logger:      log.NewBaseAddressLogger(baseKey.ShortString()),
-		quit:        make(chan struct{}),
+		netStore:     ns,
+		baseAddress:  baseKey,
+		kad:          kad,
+		kademliaLB:   network.NewKademliaLoadBalancer(kad, false),
+		peers:        make(map[enode.ID]*Peer),
+		spec:         spec,
+		logger:       log.NewBaseAddressLogger(baseKey.ShortString()),
+		quit:         make(chan struct{}),
+		fanOut:       o.FanOut,
+		maxRetries:   o.MaxRetries,
+		retryBackoff: o.RetryBackoff,
+		retryTimeout: o.RetryTimeout,
+		scores:       newPeerScores(),
+		privateKey:   o.PrivateKey,
+		receiptStore: o.ReceiptStore,
+		maxHops:      o.MaxHops,
+		forwarding:   o.ForwardingPolicy,
+		noServe:      o.DisableServing,
+		noRelay:      o.DisableRelay,
+		relaying:     o.RelayRequests,
+		forwardMinPo: o.ForwardMinProximity,
+		requestRate:  rate.Limit(o.RequestRate),
+		requestBurst: o.RequestBurst,
+		maxInFlight:  o.MaxInFlightRequests,
+		features:     o.Features,
+		maxPeerReqs:  o.MaxPeerRequests,
+		selector:     o.PeerSelector,
+		caching:      o.CachePolicy,
+		auth:         o.Authenticator,
+		capIndex:     o.CapabilityIndex,
+	}
+	if r.fanOut < 1 {
+		r.fanOut = 1
+	}
+	if r.maxRetries == 0 {
+		r.maxRetries = DefaultMaxRetries
+	}
+	if r.maxRetries < 0 {
+		r.maxRetries = 0
+	}
+	if r.retryBackoff == 0 {
+		r.retryBackoff = DefaultRetryBackoff
+	}
+	if r.retryTimeout == 0 {
+		r.retryTimeout = DefaultRetryTimeout
+	}
+	if r.maxHops == 0 {
+		r.maxHops = DefaultMaxHops
+	}
+	if r.requestRate == 0 {
+		r.requestRate = DefaultRequestRate
+	}
+	if r.requestRate < 0 {
+		r.requestRate = rate.Inf
+	}
+	if r.requestBurst == 0 {
+		r.requestBurst = DefaultRequestBurst
+	}
+	if r.maxInFlight == 0 {
+		r.maxInFlight = DefaultMaxInFlightRequests
+	}
+	if r.maxInFlight < 0 {
+		r.maxInFlight = 0
+	}
+	maxActive := o.MaxActiveRequests
+	if maxActive == 0 {
+		maxActive = DefaultMaxActiveRequests
+	}
+	if maxActive > 0 {
+		r.scheduler = newScheduler(maxActive)
+	}
+	if r.maxPeerReqs == 0 {
+		r.maxPeerReqs = DefaultMaxPeerRequests
+	}
+	if r.maxPeerReqs < 0 {
+		r.maxPeerReqs = 0
+	}
+	if r.selector == nil {
+		r.selector = ScoreSelector{}
+	}
+	if r.features == 0 {
+		r.features = AllFeatures
 	}
 	if balance != nil && !reflect.ValueOf(balance).IsNil() {
 		// swap is enabled, so setup the hook
@@ -134,6 +440,7 @@ func (r *Retrieval) removePeer(p *Peer) {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 	delete(r.peers, p.ID())
+	r.scores.remove(p.ID())
 	retrievalPeers.Update(int64(len(r.peers)))
 }
 
@@ -147,6 +454,9 @@ func (r *Retrieval) getPeer(id enode.ID) *Peer {
 // Run is being dispatched when 2 nodes connect
 func (r *Retrieval) Run(bp *network.BzzPeer) error {
 	sp := NewPeer(bp, r.baseAddress)
+	if err := r.handshake(sp); err != nil {
+		return err
+	}
 	r.addPeer(sp)
 	defer r.removePeer(sp)
 
@@ -155,16 +465,43 @@ func (r *Retrieval) Run(bp *network.BzzPeer) error {
 
 func (r *Retrieval) handleMsg(p *Peer) func(context.Context, interface{}) error {
 	return func(ctx context.Context, msg interface{}) error {
+		if f := msgFeature(msg); !p.features.Has(f) {
+			return protocols.Break(fmt.Errorf("message %T of feature not agreed in handshake", msg))
+		}
 		switch msg := msg.(type) {
 		case *RetrieveRequest:
 			return r.handleRetrieveRequest(ctx, p, msg)
 		case *ChunkDelivery:
 			return r.handleChunkDelivery(ctx, p, msg)
+		case *CancelRetrieveRequest:
+			return r.handleCancelRetrieveRequest(ctx, p, msg)
+		case *Receipt:
+			return r.handleReceipt(ctx, p, msg)
+		case *Throttle:
+			return r.handleThrottle(ctx, p, msg)
+		case *RelayRequest:
+			return r.handleRelayRequest(ctx, p, msg)
 		}
 		return nil
 	}
 }
 
+// msgFeature returns the optional feature a message belongs to,
+// or zero if the message is not optional
+func msgFeature(msg interface{}) Features {
+	switch msg.(type) {
+	case *CancelRetrieveRequest:
+		return FeatureCancel
+	case *Receipt:
+		return FeatureReceipts
+	case *Throttle:
+		return FeatureThrottle
+	case *RelayRequest:
+		return FeatureRelay
+	}
+	return 0
+}
+
 // getOriginPo returns the originPo if the incoming Request has an Origin
 // if our node is the first node that requests this chunk, then we don't have an Origin,
 // and return -1
@@ -215,22 +552,44 @@ func (r *Retrieval) findPeerLB(ctx context.Context, req *storage.Request) (retPe
 		return nil, errors.New("not forwarding request, origin node is closer to chunk than this node")
 	}
 
-	r.kademliaLB.EachBinDesc(req.Addr, func(bin network.LBBin) bool {
-		for _, lbPeer := range bin.LBPeers {
-			id := lbPeer.Peer.ID()
+	skipPeer := func(lbPeer network.LBPeer) bool {
+		id := lbPeer.Peer.ID()
 
-			// skip peer that does not support retrieval
-			if !lbPeer.Peer.HasCap(r.spec.Name) {
-				continue
-			}
+		// skip peer that does not support retrieval
+		if !lbPeer.Peer.HasCap(r.spec.Name) {
+			return true
+		}
 
-			// do not send request back to peer who asked us. maybe merge with SkipPeer at some point
-			if bytes.Equal(req.Origin.Bytes(), id.Bytes()) {
-				continue
+		// skip light peers that declare they do not serve retrieve requests
+		if !network.ServesRetrieve(lbPeer.Peer.BzzAddr) {
+			return true
+		}
+
+		// do not send request back to peer who asked us. maybe merge with SkipPeer at some point
+		if bytes.Equal(req.Origin.Bytes(), id.Bytes()) {
+			return true
+		}
+
+		if p := r.getPeer(id); p != nil {
+			// skip peers that rejected requests over their limits
+			if p.isThrottled() {
+				return true
+			}
+			// skip peers that have too many requests waiting for delivery
+			if r.maxPeerReqs > 0 && p.pendingRetrievals() >= r.maxPeerReqs {
+				saturatedPeerSkipped.Inc(1)
+				return true
 			}
+		}
+
+		// skip peers that we have already tried
+		return req.SkipPeer(id.String())
+	}
 
-			// skip peers that we have already tried
-			if req.SkipPeer(id.String()) {
+	var candidates []Candidate
+	consumeBin := func(bin network.LBBin) bool {
+		for _, lbPeer := range bin.LBPeers {
+			if skipPeer(lbPeer) {
 				continue
 			}
 
@@ -247,34 +606,60 @@ func (r *Retrieval) findPeerLB(ctx context.Context, req *storage.Request) (retPe
 			}
 
 			// if selected peer is not in the depth (2nd condition; if depth <= po, then peer is in nearest neighbourhood)
-			// and they have a lower po than ours, return error
+			// and they have a lower po than ours, return error unless closer peers were found
 			if bin.ProximityOrder < myPo && depth > bin.ProximityOrder {
-				err = fmt.Errorf("not asking peers further away from origin; ref=%s originpo=%v po=%v depth=%v myPo=%v", req.Addr.String(), originPo, bin.ProximityOrder, depth, myPo)
+				if len(candidates) == 0 {
+					err = fmt.Errorf("not asking peers further away from origin; ref=%s originpo=%v po=%v depth=%v myPo=%v", req.Addr.String(), originPo, bin.ProximityOrder, depth, myPo)
+				}
 				return false
 			}
 
 			// if chunk falls in our nearest neighbourhood (1st condition), but suggested peer is not in
 			// the nearest neighbourhood (2nd condition), don't forward the request to suggested peer
 			if depth <= myPo && depth > bin.ProximityOrder {
-				err = fmt.Errorf("not going outside of depth; ref=%s originpo=%v po=%v depth=%v myPo=%v", req.Addr.String(), originPo, bin.ProximityOrder, depth, myPo)
+				if len(candidates) == 0 {
+					err = fmt.Errorf("not going outside of depth; ref=%s originpo=%v po=%v depth=%v myPo=%v", req.Addr.String(), originPo, bin.ProximityOrder, depth, myPo)
+				}
 				return false
 			}
 
-			retPeer = lbPeer.Peer
-
-			// sp could be nil, if we encountered a peer that is not registered for delivery, i.e. doesn't support the `stream` protocol
-			// if sp is not nil, then we have selected the next peer and we stop iterating
-			// if sp is nil, we continue iterating
-			if retPeer != nil {
-				selectedPeerPo = bin.ProximityOrder
-				lbPeer.AddUseCount()
-
-				return false
+			// lbPeer.Peer could be nil, if we encountered a peer that is not registered for delivery, i.e. doesn't support the `stream` protocol
+			if lbPeer.Peer == nil {
+				continue
 			}
+			info, ok := lbPeer.Peer.StorageInfo()
+			candidates = append(candidates, Candidate{
+				Peer:      lbPeer.Peer,
+				Proximity: bin.ProximityOrder,
+				Score:     r.scores.score(lbPeer.Peer.ID()),
+				Storer:    ok && info.WithinRadius(bin.ProximityOrder),
+				lbPeer:    lbPeer,
+			})
 		}
 
 		return true
-	})
+	}
+	if r.capIndex == "" {
+		r.kademliaLB.EachBinDesc(req.Addr, consumeBin)
+	} else if e := r.kademliaLB.EachBinFiltered(req.Addr, r.capIndex, consumeBin); e != nil {
+		return nil, e
+	}
+
+	if len(candidates) > 0 {
+		// prefer peers that are expected to store the chunk over others in the same bin
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if candidates[i].Proximity != candidates[j].Proximity {
+				return candidates[i].Proximity > candidates[j].Proximity
+			}
+			return candidates[i].Storer && !candidates[j].Storer
+		})
+		if i := r.selector.SelectPeer(req, candidates); i >= 0 && i < len(candidates) {
+			c := candidates[i]
+			retPeer = c.Peer
+			selectedPeerPo = c.Proximity
+			c.lbPeer.AddUseCount()
+		}
+	}
 
 	if osp != nil {
 		osp.LogFields(olog.Int("selectedPeerPo", selectedPeerPo))
@@ -298,23 +683,69 @@ func (r *Retrieval) handleRetrieveRequest(ctx context.Context, p *Peer, msg *Ret
 	p.logger.Debug("retrieval.handleRetrieveRequest", "ref", msg.Addr)
 	handleRetrieveRequestMsgCount.Inc(1)
 
+	if r.noServe {
+		return protocols.Break(fmt.Errorf("retrieve request from peer, ruid %d, addr %s: %w", msg.Ruid, msg.Addr, ErrServingDisabled))
+	}
+
+	if r.auth != nil {
+		if err := r.auth.Verify(msg, p.ID(), r.baseAddress.Over()); err != nil {
+			unauthenticatedRequest.Inc(1)
+			return protocols.Break(fmt.Errorf("retrieve request from peer, ruid %d, addr %s: %w", msg.Ruid, msg.Addr, err))
+		}
+	}
+
+	if delay, ok := p.allowRequest(r.requestRate, r.requestBurst); !ok {
+		return r.sendThrottle(ctx, p, msg.Ruid, msg.Addr, delay)
+	}
+
 	ctx, osp := spancontext.StartSpan(
 		ctx,
 		"handle.retrieve.request")
 
-	osp.LogFields(olog.String("ref", msg.Addr.String()))
+	osp.LogFields(
+		olog.String("ref", msg.Addr.String()),
+		olog.Uint64("ruid", uint64(msg.Ruid)),
+		olog.String("peer", p.ID().String()),
+	)
 
 	defer osp.Finish()
 
 	ctx, cancel := context.WithTimeout(ctx, timeouts.FetcherGlobalTimeout)
 	defer cancel()
 
+	// the requesting peer may cancel the request if it is no longer needed
+	if !p.addRequest(msg.Ruid, cancel, r.maxInFlight) {
+		return r.sendThrottle(ctx, p, msg.Ruid, msg.Addr, 0)
+	}
+	defer p.removeRequest(msg.Ruid)
+
 	req := &storage.Request{
 		Addr:   msg.Addr,
 		Origin: p.ID(),
 	}
-	chunk, err := r.netStore.Get(ctx, chunk.ModeGetRequest, req)
+	// the request took one hop to get here
+	if msg.TTL > 0 {
+		req.TTL = msg.TTL - 1
+	}
+	osp.LogFields(olog.Int("ttl", int(req.TTL)))
+
+	var ch chunk.Chunk
+	var err error
+	forward := r.forwards(p)
+	if forward {
+		ch, err = r.netStore.Get(ctx, chunk.ModeGetRequest, req)
+	} else {
+		// serve only from the local store
+		ch, err = r.netStore.Store.Get(ctx, chunk.ModeGetRequest, msg.Addr)
+	}
 	if err != nil {
+		if ctx.Err() == context.Canceled {
+			p.logger.Trace("retrieval.handleRetrieveRequest - cancelled", "ref", msg.Addr, "ruid", msg.Ruid)
+			return nil
+		}
+		if !forward {
+			notForwardedRetrieveRequest.Inc(1)
+		}
 		retrieveChunkFail.Inc(1)
 		return fmt.Errorf("netstore.Get can not retrieve chunk for ref %s: %w", msg.Addr, err)
 	}
@@ -323,25 +754,114 @@ func (r *Retrieval) handleRetrieveRequest(ctx context.Context, p *Peer, msg *Ret
 
 	deliveryMsg := &ChunkDelivery{
 		Ruid:  msg.Ruid,
-		Addr:  chunk.Address(),
-		SData: chunk.Data(),
+		Addr:  ch.Address(),
+		SData: ch.Data(),
+	}
+
+	if r.receiptStore != nil && p.features.Has(FeatureReceipts) {
+		// the receipt may arrive before the send returns
+		p.addDelivery(msg.Ruid, ch.Address())
 	}
 
 	err = p.Send(ctx, deliveryMsg)
 	if err != nil {
 		return fmt.Errorf("retrieval.handleRetrieveRequest - peer delivery for ref %s: %w", msg.Addr, err)
 	}
+	requestServed(len(deliveryMsg.SData))
 	osp.LogFields(olog.Bool("delivered", true))
 
 	return nil
 }
 
+// sendThrottle rejects a request of a peer that is over its limits,
+// so that the peer sends its requests to other peers for the delay
+func (r *Retrieval) sendThrottle(ctx context.Context, p *Peer, ruid uint, addr storage.Address, delay time.Duration) error {
+	p.logger.Trace("retrieval.sendThrottle", "ref", addr, "ruid", ruid, "delay", delay)
+	throttledRetrieveRequest.Inc(1)
+
+	// peers that do not support throttling are left to time out
+	if !p.features.Has(FeatureThrottle) {
+		return nil
+	}
+	err := p.Send(ctx, &Throttle{
+		Ruid:  ruid,
+		Delay: uint64(delay),
+	})
+	if err != nil {
+		return fmt.Errorf("retrieval.sendThrottle - throttle for ref %s: %w", addr, err)
+	}
+	return nil
+}
+
+// forwards returns true if requests from the peer that can not be served
+// from the local store should be forwarded according to the forwarding policy
+func (r *Retrieval) forwards(p *Peer) bool {
+	switch r.forwarding {
+	case ForwardNone:
+		return false
+	case ForwardProximity:
+		return chunk.Proximity(r.baseAddress.Over(), p.BzzAddr.Over()) >= r.forwardMinPo
+	default:
+		return true
+	}
+}
+
+// caches returns true if a chunk with the proximity to this node, delivered
+// for a request forwarded on behalf of another peer, should be stored in
+// the local store according to the cache policy
+func (r *Retrieval) caches(po, depth int) bool {
+	switch r.caching {
+	case CacheNever:
+		return false
+	case CacheProximity:
+		return po >= depth || rand.Intn(depth+1) <= po
+	default:
+		return true
+	}
+}
+
+// handleCancelRetrieveRequest handles a CancelRetrieveRequest message from a
+// certain peer by cancelling the retrieve request from that peer that is being
+// served, together with the requests forwarded on its behalf
+func (r *Retrieval) handleCancelRetrieveRequest(ctx context.Context, p *Peer, msg *CancelRetrieveRequest) error {
+	p.logger.Debug("retrieval.handleCancelRetrieveRequest", "ruid", msg.Ruid)
+	if p.cancelRequest(msg.Ruid) {
+		cancelledRetrieveRequest.Inc(1)
+	}
+	return nil
+}
+
+// handleThrottle handles a rejection of a retrieve request by a peer that is
+// over its limits. The peer is not sent requests for the delay, at least for
+// the retry backoff, and the request is retried against other peers when it
+// times out.
+func (r *Retrieval) handleThrottle(ctx context.Context, p *Peer, msg *Throttle) error {
+	delay := time.Duration(msg.Delay)
+	if delay < r.retryBackoff {
+		delay = r.retryBackoff
+	}
+	if !p.throttle(msg.Ruid, delay) {
+		// the retrieval may have been delivered by other peer or cancelled
+		p.logger.Trace("retrieval.handleThrottle - no retrieval", "ruid", msg.Ruid)
+		return nil
+	}
+	p.logger.Debug("retrieval.handleThrottle", "ruid", msg.Ruid, "delay", delay)
+	throttleReceived.Inc(1)
+	return nil
+}
+
 // handleChunkDelivery handles a ChunkDelivery message from a certain peer
 // if the chunk proximity order in relation to our base address is within depth
 // we treat the chunk as a chunk received in syncing
 func (r *Retrieval) handleChunkDelivery(ctx context.Context, p *Peer, msg *ChunkDelivery) error {
 	p.logger.Debug("retrieval.handleChunkDelivery", "ref", msg.Addr)
-	err := p.checkRequest(msg.Ruid, msg.Addr)
+	latency, forwarded, err := p.checkRequest(msg.Ruid, msg.Addr)
+	if err == errRetrievalCancelled {
+		// a late delivery for a request that was fanned out to multiple
+		// peers and already satisfied by another one
+		cancelledChunkDelivery.Inc(1)
+		return nil
+	}
 	if err != nil {
 		unsolicitedChunkDelivery.Inc(1)
 		return protocols.Break(fmt.Errorf("unsolicited chunk delivery from peer, ruid %d, addr %s: %w", msg.Ruid, msg.Addr, err))
@@ -350,6 +870,11 @@ func (r *Retrieval) handleChunkDelivery(ctx context.Context, p *Peer, msg *Chunk
 	ctx, osp = spancontext.StartSpan(
 		ctx,
 		"handle.chunk.delivery")
+	osp.LogFields(
+		olog.String("ref", msg.Addr.String()),
+		olog.Uint64("ruid", uint64(msg.Ruid)),
+		olog.String("peer", p.ID().String()),
+	)
 
 	processReceivedChunksCount.Inc(1)
 
@@ -371,67 +896,393 @@ func (r *Retrieval) handleChunkDelivery(ctx context.Context, p *Peer, msg *Chunk
 	}
 	defer osp.Finish()
 
-	_, err = r.netStore.Put(ctx, mode, storage.NewChunk(msg.Addr, msg.SData))
+	sctx, ssp := spancontext.StartSpan(
+		ctx,
+		"store.chunk")
+	if mode == chunk.ModePutRequest && forwarded && !r.caches(po, depth) {
+		// pass the chunk to the requesting peers without storing it
+		ssp.LogFields(olog.Bool("cached", false))
+		notCachedChunkDelivery.Inc(1)
+		err = r.netStore.Deliver(sctx, storage.NewChunk(msg.Addr, msg.SData))
+	} else {
+		_, err = r.netStore.Put(sctx, mode, storage.NewChunk(msg.Addr, msg.SData))
+	}
+	ssp.Finish()
 	if err != nil {
-		if err == storage.ErrChunkInvalid {
+		if err == chunk.ErrChunkInvalid {
+			r.scores.failed(p.ID())
+			requestFailed(p, msg.Addr)
 			return protocols.Break(fmt.Errorf("netstore putting chunk to localstore: %w", err))
 		}
 
 		return fmt.Errorf("netstore putting chunk to localstore: %w", err)
 	}
+	r.scores.delivered(p.ID(), latency)
+	requestDelivered(p, msg.Addr, len(msg.SData), latency)
+
+	if r.privateKey != nil && p.features.Has(FeatureReceipts) {
+		receipt, err := newReceipt(r.privateKey, msg.Ruid, msg.Addr, p.BzzAddr.Over(), uint64(time.Now().UnixNano()))
+		if err != nil {
+			return fmt.Errorf("signing receipt for ref %s: %w", msg.Addr, err)
+		}
+		if err := p.Send(ctx, receipt); err != nil {
+			return fmt.Errorf("sending receipt for ref %s: %w", msg.Addr, err)
+		}
+	}
 
 	return nil
 }
 
-// RequestFromPeers sends a chunk retrieve request to the next found peer.
-// returns the next peer to try, a cleanup function to expire retrievals that were never delivered
+// handleReceipt handles a Receipt message from a certain peer by validating
+// that it is signed by the peer for a chunk that was delivered to it and
+// storing it in the receipt store
+func (r *Retrieval) handleReceipt(ctx context.Context, p *Peer, msg *Receipt) error {
+	p.logger.Debug("retrieval.handleReceipt", "ref", msg.Addr)
+	if r.receiptStore == nil {
+		return nil
+	}
+	if err := p.checkDelivery(msg.Ruid, msg.Addr); err != nil {
+		return protocols.Break(fmt.Errorf("unsolicited receipt from peer, ruid %d, addr %s: %w", msg.Ruid, msg.Addr, err))
+	}
+	signer, err := msg.Signer(r.baseAddress.Over())
+	if err != nil {
+		return protocols.Break(fmt.Errorf("receipt signature from peer, ruid %d, addr %s: %w", msg.Ruid, msg.Addr, err))
+	}
+	if signer != p.ID() {
+		return protocols.Break(fmt.Errorf("receipt from peer, ruid %d, addr %s: %w", msg.Ruid, msg.Addr, ErrInvalidReceiptSignature))
+	}
+	if err := r.receiptStore.Put(p.ID(), msg); err != nil {
+		return fmt.Errorf("storing receipt for ref %s: %w", msg.Addr, err)
+	}
+	receiptsStored.Inc(1)
+	return nil
+}
+
+// RequestFromPeers sends a chunk retrieve request to the next found peers.
+// The request is sent to up to fanOut peers concurrently, the first delivery
+// wins and the requests to the remaining peers are cancelled on cleanup.
+// If a request can not be sent, it is retried against the next best peer
+// with exponential backoff. If no peer delivers within the retry timeout,
+// the request is also sent to the next best peer, while the previous ones
+// are recorded in the request skip list and may still deliver. If the number of active requests is limited,
+// the request waits for its turn according to its priority class.
+// returns the first peer tried, a cleanup function to cancel retrievals that were never delivered
 func (r *Retrieval) RequestFromPeers(ctx context.Context, req *storage.Request, localID enode.ID) (*enode.ID, func(), error) {
 	r.logger.Debug("retrieval.requestFromPeers", "req.Addr", req.Addr, "localID", localID)
 	metrics.GetOrRegisterCounter("network/retrieve/request_from_peers", nil).Inc(1)
 
-	const maxFindPeerRetries = 5
-	retries := 0
+	ctx, osp := spancontext.StartSpan(
+		ctx,
+		"request.from.peers")
+	defer osp.Finish()
 
-FINDPEER:
-	sp, err := r.findPeerLB(ctx, req)
-	if err != nil {
-		r.logger.Trace(err.Error())
-		return nil, func() {}, err
+	priority := r.priority(req, localID)
+	osp.LogFields(
+		olog.String("ref", req.Addr.String()),
+		olog.String("priority", priority.String()),
+	)
+	ttl := r.maxHops
+	if priority == PriorityForwarded {
+		// request is forwarded on behalf of a peer
+		if req.TTL == 0 {
+			r.logger.Trace("retrieval.requestFromPeers - ttl expired", "req.Addr", req.Addr, "origin", req.Origin)
+			metrics.GetOrRegisterCounter("network/retrieve/ttl_expired", nil).Inc(1)
+			return nil, func() {}, ErrTTLExpired
+		}
+		if req.TTL < ttl {
+			ttl = req.TTL
+		}
 	}
 
-	protoPeer := r.getPeer(sp.ID())
-	if protoPeer == nil {
-		r.logger.Trace("findPeer returned a peer to skip", "peer", sp.String(), "retry", retries, "ref", req.Addr)
-		req.PeersToSkip.Store(sp.ID().String(), time.Now())
-		retries++
-		if retries == maxFindPeerRetries {
-			r.logger.Trace("max find peer retries reached", "max retries", maxFindPeerRetries, "ref", req.Addr)
-			return nil, func() {}, ErrNoPeerFound
+	release := func() {}
+	if r.scheduler != nil {
+		metrics.GetOrRegisterCounter(fmt.Sprintf("network/retrieve/request_from_peers/%s", priority), nil).Inc(1)
+		if err := r.scheduler.acquire(ctx, priority); err != nil {
+			return nil, func() {}, err
 		}
-
-		goto FINDPEER
+		release = r.scheduler.release
+		osp.LogFields(olog.String("event", "scheduled"))
 	}
 
-	ret := &RetrieveRequest{
-		Ruid: uint(rand.Uint32()),
-		Addr: req.Addr,
+	type sentRequest struct {
+		peer *Peer
+		ruid uint
 	}
-	protoPeer.logger.Trace("sending retrieve request", "ref", ret.Addr, "origin", localID, "ruid", ret.Ruid)
-	protoPeer.addRetrieval(ret.Ruid, ret.Addr)
+	var (
+		retrievals []sentRequest
+		mu         sync.Mutex // protects retrievals from the retry goroutine
+		done       = make(chan struct{})
+		once       sync.Once
+	)
+
 	cleanup := func() {
-		protoPeer.expireRetrieval(ret.Ruid)
+		once.Do(func() {
+			defer release()
+			close(done)
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, rt := range retrievals {
+				// peers may still deliver, so do not treat their deliveries
+				// as unsolicited, but let them know that they can stop
+				// serving the request
+				elapsed, ok := rt.peer.cancelRetrieval(rt.ruid)
+				if !ok {
+					continue
+				}
+				// peers cancelled before the search timeout are not penalized,
+				// as another peer was just faster to deliver
+				if elapsed >= timeouts.SearchTimeout {
+					r.scores.failed(rt.peer.ID())
+					requestFailed(rt.peer, req.Addr)
+				}
+				if rt.peer.features.Has(FeatureCancel) {
+					go r.sendCancel(rt.peer, rt.ruid)
+				}
+			}
+		})
 	}
-	err = protoPeer.Send(ctx, ret)
-	if err != nil {
-		protoPeer.logger.Trace("error sending retrieve request to peer", "ruid", ret.Ruid, "err", err)
-		cleanup()
+
+	if r.relaying {
+		if relay, ruid, ok := r.sendRelayRequest(ctx, req, ttl, priority); ok {
+			retrievals = append(retrievals, sentRequest{peer: relay, ruid: ruid})
+			if r.fanOut > 1 {
+				req.PeersToSkip.Store(relay.ID().String(), time.Now())
+			}
+		}
+	}
+
+	// send sends the request to the next found peer, retrying against the
+	// next best one if it can not be sent
+	retries := 0
+	send := func() (*Peer, uint, error) {
+		for {
+			fctx, fsp := spancontext.StartSpan(
+				ctx,
+				"find.peer")
+			protoPeer, err := r.findProtoPeer(fctx, req)
+			if err != nil {
+				fsp.LogFields(olog.Error(err))
+				fsp.Finish()
+				return nil, 0, err
+			}
+			fsp.LogFields(olog.String("peer", protoPeer.ID().String()))
+			fsp.Finish()
+
+			ret := &RetrieveRequest{
+				Ruid: uint(rand.Uint32()),
+				Addr: req.Addr,
+				TTL:  ttl,
+			}
+			if r.auth != nil {
+				ret.Auth, err = r.auth.Sign(ret, protoPeer.BzzAddr.Over())
+				if err != nil {
+					return nil, 0, err
+				}
+			}
+			protoPeer.logger.Trace("sending retrieve request", "ref", ret.Addr, "origin", localID, "ruid", ret.Ruid, "ttl", ret.TTL)
+
+			// the span of the wait for delivery is finished by the peer,
+			// the span of the send is the parent of the spans on the next hop
+			_, wsp := spancontext.StartSpan(
+				ctx,
+				"wait.chunk.delivery")
+			wsp.LogFields(
+				olog.Uint64("ruid", uint64(ret.Ruid)),
+				olog.String("peer", protoPeer.ID().String()),
+			)
+			protoPeer.addRetrieval(ret.Ruid, ret.Addr, wsp, priority == PriorityForwarded)
+			sctx, ssp := spancontext.StartSpan(
+				ctx,
+				"send.retrieve.request")
+			ssp.LogFields(
+				olog.Uint64("ruid", uint64(ret.Ruid)),
+				olog.String("peer", protoPeer.ID().String()),
+				olog.Int("ttl", int(ret.TTL)),
+			)
+			err = protoPeer.Send(sctx, ret)
+			if err != nil {
+				ssp.LogFields(olog.Error(err))
+			}
+			ssp.Finish()
+			if err == nil {
+				requestSent(protoPeer, req.Addr, priority == PriorityForwarded)
+				return protoPeer, ret.Ruid, nil
+			}
+			protoPeer.logger.Trace("error sending retrieve request to peer", "ruid", ret.Ruid, "err", err)
+			protoPeer.expireRetrieval(ret.Ruid)
+			r.scores.failed(protoPeer.ID())
+			requestFailed(protoPeer, req.Addr)
+			// do not try this peer again until its skip entry decays
+			req.PeersToSkip.Store(protoPeer.ID().String(), time.Now())
+			if retries >= r.maxRetries {
+				return nil, 0, err
+			}
+			if err := r.retryWait(ctx, retries); err != nil {
+				return nil, 0, err
+			}
+			retries++
+			metrics.GetOrRegisterCounter("network/retrieve/request_retries", nil).Inc(1)
+		}
+	}
+
+	var err error
+	for len(retrievals) < r.fanOut {
+		var protoPeer *Peer
+		var ruid uint
+		protoPeer, ruid, err = send()
+		if err != nil {
+			break
+		}
+		retrievals = append(retrievals, sentRequest{peer: protoPeer, ruid: ruid})
+		if r.fanOut > 1 {
+			// make sure the next found peer is a different one
+			req.PeersToSkip.Store(protoPeer.ID().String(), time.Now())
+		}
+	}
+	if len(retrievals) == 0 {
+		release()
 		return nil, func() {}, err
 	}
+	if len(retrievals) > 1 {
+		metrics.GetOrRegisterCounter("network/retrieve/fanout_requests", nil).Inc(int64(len(retrievals) - 1))
+	}
+
+	spID := retrievals[0].peer.ID()
+	if r.retryTimeout > 0 && retries < r.maxRetries {
+		// retry the request against the next best peer if none of the
+		// peers delivers in time, until it is delivered or cleaned up
+		pending := append([]sentRequest(nil), retrievals...)
+		go func() {
+			for retries < r.maxRetries {
+				t := time.NewTimer(r.retryTimeout)
+				select {
+				case <-t.C:
+				case <-done:
+					t.Stop()
+					return
+				case <-ctx.Done():
+					t.Stop()
+					return
+				case <-r.quit:
+					t.Stop()
+					return
+				}
+				for _, rt := range pending {
+					if !rt.peer.hasRetrieval(rt.ruid) {
+						// delivered or cancelled
+						return
+					}
+					req.PeersToSkip.Store(rt.peer.ID().String(), time.Now())
+				}
+				retries++
+				protoPeer, ruid, err := send()
+				if err != nil {
+					return
+				}
+				metrics.GetOrRegisterCounter("network/retrieve/request_retries/timeout", nil).Inc(1)
+				rt := sentRequest{peer: protoPeer, ruid: ruid}
+				mu.Lock()
+				select {
+				case <-done:
+					mu.Unlock()
+					// cleaned up while sending
+					if _, ok := protoPeer.cancelRetrieval(ruid); ok && protoPeer.features.Has(FeatureCancel) {
+						go r.sendCancel(protoPeer, ruid)
+					}
+					return
+				default:
+				}
+				retrievals = append(retrievals, rt)
+				mu.Unlock()
+				pending = append(pending, rt)
+			}
+		}()
+	}
 
-	spID := protoPeer.ID()
 	return &spID, cleanup, nil
 }
 
+// sendCancel notifies the peer that the retrieve request with the provided
+// ruid is no longer needed
+func (r *Retrieval) sendCancel(p *Peer, ruid uint) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.SearchTimeout)
+	defer cancel()
+
+	p.logger.Trace("sending cancel retrieve request", "ruid", ruid)
+	if err := p.Send(ctx, &CancelRetrieveRequest{Ruid: ruid}); err != nil {
+		p.logger.Trace("error sending cancel retrieve request to peer", "ruid", ruid, "err", err)
+	}
+}
+
+// priority returns the priority class of a request, requests made on behalf
+// of this node are local regardless of whether the origin is set
+func (r *Retrieval) priority(req *storage.Request, localID enode.ID) Priority {
+	switch {
+	case req.Background:
+		return PriorityBackground
+	case req.Origin == (enode.ID{}) || req.Origin == localID:
+		return PriorityLocal
+	}
+	return PriorityForwarded
+}
+
+// retryWait blocks for the exponential backoff duration of the retry
+// with the provided sequence number, until a new peer is added to kademlia
+// or until the context is done
+func (r *Retrieval) retryWait(ctx context.Context, retry int) error {
+	t := time.NewTimer(r.retryBackoff << uint(retry))
+	defer t.Stop()
+
+	events, unsubscribe := r.kad.SubscribeEvents()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-t.C:
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if e.Type == network.PeerAdded {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.quit:
+			return errors.New("retrieval stopped")
+		}
+	}
+}
+
+// findProtoPeer finds the next peer to send a request to, that is connected
+// over the retrieve protocol
+func (r *Retrieval) findProtoPeer(ctx context.Context, req *storage.Request) (*Peer, error) {
+	const maxFindPeerRetries = 5
+
+	for retries := 0; ; {
+		sp, err := r.findPeerLB(ctx, req)
+		if err != nil {
+			r.logger.Trace(err.Error())
+			return nil, err
+		}
+
+		protoPeer := r.getPeer(sp.ID())
+		if protoPeer != nil {
+			return protoPeer, nil
+		}
+		r.logger.Trace("findPeer returned a peer to skip", "peer", sp.String(), "retry", retries, "ref", req.Addr)
+		req.PeersToSkip.Store(sp.ID().String(), time.Now())
+		retries++
+		if retries == maxFindPeerRetries {
+			r.logger.Trace("max find peer retries reached", "max retries", maxFindPeerRetries, "ref", req.Addr)
+			return nil, ErrNoPeerFound
+		}
+	}
+}
+
 func (r *Retrieval) Start(server *p2p.Server) error {
 	r.logger.Info("starting bzz-retrieve")
 	return nil
@@ -463,7 +1314,20 @@ func (r *Retrieval) runProtocol(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 }
 
 func (r *Retrieval) APIs() []rpc.API {
-	return nil
+	return []rpc.API{
+		{
+			Namespace: "retrieve",
+			Version:   "1.0",
+			Service:   NewAPI(r),
+			Public:    false,
+		},
+	}
+}
+
+// PeerScores returns retrieval statistics of connected peers
+// ordered from the best to the worst scored peer
+func (r *Retrieval) PeerScores() []PeerScore {
+	return r.scores.all()
 }
 
 func (r *Retrieval) Spec() *protocols.Spec {
@@ -44,14 +44,20 @@ import (
 	chunktesting "github.com/ethersphere/swarm/chunk/testing"
 	"github.com/ethersphere/swarm/network"
 	"github.com/ethersphere/swarm/network/simulation"
+	"github.com/ethersphere/swarm/network/timeouts"
 	"github.com/ethersphere/swarm/p2p/protocols"
 	p2ptest "github.com/ethersphere/swarm/p2p/testing"
+	"github.com/ethersphere/swarm/spancontext"
 	"github.com/ethersphere/swarm/state"
 	"github.com/ethersphere/swarm/storage"
 	"github.com/ethersphere/swarm/storage/localstore"
 	"github.com/ethersphere/swarm/storage/mock"
 	"github.com/ethersphere/swarm/testutil"
+	"github.com/ethersphere/swarm/tracing"
+	opentracing "github.com/opentracing/opentracing-go"
+	jaeger "github.com/uber/jaeger-client-go"
 	"golang.org/x/crypto/sha3"
+	"golang.org/x/time/rate"
 )
 
 var (
@@ -59,6 +65,7 @@ var (
 	bucketKeyNetstore  = simulation.BucketKey("netstore")
 
 	hash0 = sha3.Sum256([]byte{0})
+	hash1 = sha3.Sum256([]byte{1})
 )
 
 func init() {
@@ -278,7 +285,7 @@ func TestUnsolicitedChunkDeliveryFaultyAddr(t *testing.T) {
 		time.Sleep(1 * time.Millisecond)
 	}
 	// inject a supposed retrieve request that was sent to that peer
-	r.getPeer(node.ID()).addRetrieval(1234, []byte{0, 1, 2, 3})
+	r.getPeer(node.ID()).addRetrieval(1234, []byte{0, 1, 2, 3}, nil, false)
 
 	// respond with a chunk delivery with the same Ruid but with a different chunk address
 	err = tester.TestExchanges(
@@ -352,7 +359,7 @@ func TestUnsolicitedChunkDeliveryDouble(t *testing.T) {
 		time.Sleep(1 * time.Millisecond)
 	}
 	// inject a supposed retrieve request that was sent to that peer
-	r.getPeer(node.ID()).addRetrieval(1234, []byte{0, 1, 2, 3})
+	r.getPeer(node.ID()).addRetrieval(1234, []byte{0, 1, 2, 3}, nil, false)
 
 	// respond with a chunk delivery with the same Ruid and the matching chunk address
 	err = tester.TestExchanges(
@@ -519,6 +526,1103 @@ func TestRequestFromPeers(t *testing.T) {
 	}
 }
 
+// TestRequestFromPeersFanOut brings up three nodes, stores chunks on the uploader
+// and retrieves them through a fetching node connected both to the uploader and to
+// a node that does not have the chunks. With a fan out of two, requests are sent to
+// both peers and no retrieval should wait for the search timeout of the empty peer.
+func TestRequestFromPeersFanOut(t *testing.T) {
+	chunkCount := 10
+
+	sim := simulation.NewBzzInProc(map[string]simulation.ServiceFunc{
+		"bzz-retrieve": newBzzRetrieveWithLocalstoreOptions(&Options{FanOut: 2}),
+	}, true)
+	defer sim.Close()
+
+	nodeIDs, err := sim.AddNodes(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploader, empty, fetching := nodeIDs[0], nodeIDs[1], nodeIDs[2]
+
+	uploaderStore := sim.MustNodeItem(uploader, bucketKeyNetstore).(*storage.NetStore)
+	chunks := make([]chunk.Chunk, chunkCount)
+	for i := range chunks {
+		chunks[i] = chunktesting.GenerateTestRandomChunk()
+		if _, err := uploaderStore.Put(context.Background(), chunk.ModePutUpload, chunks[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range []enode.ID{uploader, empty} {
+		if err := sim.Net.Connect(fetching, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := sim.Service("bzz-retrieve", fetching).(*Retrieval)
+	kad := sim.MustNodeItem(fetching, simulation.BucketKeyKademlia).(*network.Kademlia)
+	for i := 0; ; i++ {
+		r.mtx.RLock()
+		n := len(r.peers)
+		r.mtx.RUnlock()
+		if n == 2 && kad.KademliaInfo().TotalConnections == 2 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("timed out waiting for peers, got %v", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	fetcherNetstore := sim.MustNodeItem(fetching, bucketKeyNetstore).(*storage.NetStore)
+	for _, ch := range chunks {
+		start := time.Now()
+		got, err := fetcherNetstore.Get(context.Background(), chunk.ModeGetRequest, storage.NewRequest(ch.Address()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Data(), ch.Data()) {
+			t.Fatalf("got chunk data %x, want %x", got.Data(), ch.Data())
+		}
+		if d := time.Since(start); d >= timeouts.SearchTimeout {
+			t.Errorf("chunk %s retrieved in %v, not faster than the search timeout", ch.Address(), d)
+		}
+	}
+
+	r.mtx.RLock()
+	n := len(r.peers)
+	r.mtx.RUnlock()
+	if n != 2 {
+		t.Errorf("got %v peers after retrievals, want 2", n)
+	}
+}
+
+// TestPeerCancelRetrieval tests that a delivery of a cancelled retrieval
+// is distinguished from an unsolicited one
+func TestPeerCancelRetrieval(t *testing.T) {
+	p := NewPeer(&network.BzzPeer{BzzAddr: network.RandomBzzAddr()}, network.RandomBzzAddr())
+	addr := storage.Address(hash0[:])
+
+	p.addRetrieval(1, addr, nil, false)
+	p.cancelRetrieval(1)
+	if _, _, err := p.checkRequest(1, addr); err != errRetrievalCancelled {
+		t.Fatalf("got error %v, want %v", err, errRetrievalCancelled)
+	}
+	if _, _, err := p.checkRequest(1, addr); err == nil || err == errRetrievalCancelled {
+		t.Fatalf("got error %v for a second delivery, want unsolicited delivery error", err)
+	}
+
+	// cancelling an already delivered retrieval does not allow another delivery
+	p.addRetrieval(2, addr, nil, false)
+	if _, _, err := p.checkRequest(2, addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.cancelRetrieval(2); ok {
+		t.Fatal("delivered retrieval cancelled")
+	}
+	if _, _, err := p.checkRequest(2, addr); err == nil || err == errRetrievalCancelled {
+		t.Fatalf("got error %v, want unsolicited delivery error", err)
+	}
+}
+
+// TestRequestFromPeersRetry tests that a retrieve request that can not be sent to
+// the closest peer is retried against the next best one and that the failed peer
+// is recorded in the request skip list
+func TestRequestFromPeersRetry(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+	r := NewWithOptions(to, nil, addr, nil, &Options{RetryBackoff: time.Millisecond})
+
+	ref := storage.Address(hash0[:])
+
+	// add a peer that is close to the chunk, but fails to send requests
+	failingOverlay := make([]byte, len(ref))
+	copy(failingOverlay, ref)
+	failingOverlay[len(failingOverlay)-1] ^= 1
+	failingRW, failingRemoteRW := p2p.MsgPipe()
+	failingRemoteRW.Close()
+	failingPeer := newTestRetrievalPeer(t, r, to, nil, failingOverlay, failingRW)
+
+	// add a peer that is further from the chunk and receives requests
+	overlay := make([]byte, len(ref))
+	copy(overlay, ref)
+	overlay[0] ^= 0x80
+	rw, remoteRW := p2p.MsgPipe()
+	defer remoteRW.Close()
+	peer := newTestRetrievalPeer(t, r, to, nil, overlay, rw)
+
+	received := make(chan p2p.Msg, 1)
+	go func() {
+		msg, err := remoteRW.ReadMsg()
+		if err != nil {
+			return
+		}
+		msg.Discard()
+		received <- msg
+	}()
+
+	req := storage.NewRequest(ref)
+	id, cleanup, err := r.RequestFromPeers(context.Background(), req, enode.ID{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	if *id != peer.ID() {
+		t.Fatalf("got peer %v, want %v (failing %v)", id, peer.ID(), failingPeer.ID())
+	}
+	if !req.SkipPeer(failingPeer.ID().String()) {
+		t.Error("failing peer is not in the request skip list")
+	}
+	if scores := r.PeerScores(); len(scores) != 1 || scores[0].Peer != failingPeer.ID() || scores[0].Failures != 1 {
+		t.Errorf("got peer scores %+v, want one failure of the failing peer", scores)
+	}
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("retrieve request not received")
+	}
+
+	// with retries disabled, the send error is returned
+	r = NewWithOptions(to, nil, addr, nil, &Options{MaxRetries: -1})
+	r.addPeer(failingPeer)
+	if _, _, err := r.RequestFromPeers(context.Background(), storage.NewRequest(ref), enode.ID{}); err == nil {
+		t.Fatal("expected error, got none")
+	}
+}
+
+// TestRequestFromPeersRetryTimeout tests that a retrieve request that is not
+// delivered within the retry timeout is sent to the next best peer, and that
+// it is not retried once it is delivered
+func TestRequestFromPeersRetryTimeout(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+	r := NewWithOptions(to, nil, addr, nil, &Options{RetryTimeout: 50 * time.Millisecond})
+
+	ref := storage.Address(hash0[:])
+	retrieveRequestCode, _ := spec.GetCode(&RetrieveRequest{})
+
+	var pipes []*p2p.MsgPipeRW
+	defer func() {
+		for _, rw := range pipes {
+			rw.Close()
+		}
+	}()
+	newPeer := func(overlay []byte) (*Peer, chan struct{}) {
+		rw, remoteRW := p2p.MsgPipe()
+		pipes = append(pipes, remoteRW)
+		peer := newTestRetrievalPeer(t, r, to, nil, overlay, rw)
+		received := make(chan struct{}, 10)
+		go func() {
+			for {
+				msg, err := remoteRW.ReadMsg()
+				if err != nil {
+					return
+				}
+				msg.Discard()
+				if msg.Code == retrieveRequestCode {
+					received <- struct{}{}
+				}
+			}
+		}()
+		return peer, received
+	}
+
+	// a peer that is close to the chunk, but does not deliver
+	closeOverlay := make([]byte, len(ref))
+	copy(closeOverlay, ref)
+	closeOverlay[len(closeOverlay)-1] ^= 1
+	closePeer, closeReceived := newPeer(closeOverlay)
+
+	// a peer that is further from the chunk
+	farOverlay := make([]byte, len(ref))
+	copy(farOverlay, ref)
+	farOverlay[0] ^= 0x80
+	farPeer, farReceived := newPeer(farOverlay)
+
+	req := storage.NewRequest(ref)
+	id, cleanup, err := r.RequestFromPeers(context.Background(), req, enode.ID{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *id != closePeer.ID() {
+		t.Fatalf("got peer %v, want %v", id, closePeer.ID())
+	}
+	select {
+	case <-closeReceived:
+	case <-time.After(time.Second):
+		t.Fatal("retrieve request not received by the closest peer")
+	}
+	select {
+	case <-farReceived:
+	case <-time.After(time.Second):
+		t.Fatal("retrieve request not retried after timeout")
+	}
+	if !req.SkipPeer(closePeer.ID().String()) {
+		t.Error("timed out peer is not in the request skip list")
+	}
+	// the timed out peer may still deliver
+	if closePeer.pendingRetrievals() != 1 || farPeer.pendingRetrievals() != 1 {
+		t.Errorf("got %v and %v pending retrievals, want 1 and 1", closePeer.pendingRetrievals(), farPeer.pendingRetrievals())
+	}
+	cleanup()
+	// the retried retrieval may be cancelled after the cleanup returns
+	deadline := time.Now().Add(time.Second)
+	for closePeer.pendingRetrievals() != 0 || farPeer.pendingRetrievals() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %v and %v pending retrievals after cleanup, want none", closePeer.pendingRetrievals(), farPeer.pendingRetrievals())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// a delivered request is not retried
+	req = storage.NewRequest(ref)
+	_, cleanup, err = r.RequestFromPeers(context.Background(), req, enode.ID{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	select {
+	case <-closeReceived:
+	case <-time.After(time.Second):
+		t.Fatal("retrieve request not received by the closest peer")
+	}
+	closePeer.mtx.Lock()
+	var ruid uint
+	for ruid = range closePeer.retrievals {
+	}
+	closePeer.mtx.Unlock()
+	if _, _, err := closePeer.checkRequest(ruid, ref); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-farReceived:
+		t.Fatal("delivered request retried")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// TestRequestFromPeersCancel tests that the cleanup function of a retrieve
+// request that was not delivered sends a cancel message to the peer
+func TestRequestFromPeersCancel(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+	r := New(to, nil, addr, nil)
+
+	rw, remoteRW := p2p.MsgPipe()
+	defer remoteRW.Close()
+	peer := newTestRetrievalPeer(t, r, to, nil, network.RandomBzzAddr().Over(), rw)
+
+	codes := make(chan uint64)
+	go func() {
+		for {
+			msg, err := remoteRW.ReadMsg()
+			if err != nil {
+				return
+			}
+			msg.Discard()
+			codes <- msg.Code
+		}
+	}()
+	receiveCode := func(want interface{}) {
+		t.Helper()
+		wantCode, _ := spec.GetCode(want)
+		select {
+		case code := <-codes:
+			if code != wantCode {
+				t.Fatalf("got message code %v, want %v", code, wantCode)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("message %T not received", want)
+		}
+	}
+
+	ref := storage.Address(hash0[:])
+	_, cleanup, err := r.RequestFromPeers(context.Background(), storage.NewRequest(ref), enode.ID{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiveCode(&RetrieveRequest{})
+
+	peer.mtx.Lock()
+	var ruid uint
+	for ruid = range peer.retrievals {
+	}
+	peer.mtx.Unlock()
+
+	cleanup()
+	receiveCode(&CancelRetrieveRequest{})
+
+	// a late delivery of the cancelled request is not unsolicited
+	if _, _, err := peer.checkRequest(ruid, ref); err != errRetrievalCancelled {
+		t.Fatalf("got error %v, want %v", err, errRetrievalCancelled)
+	}
+}
+
+// TestCancelRetrieveRequest tests that a retrieve request that is being served
+// is cancelled, together with the request forwarded on its behalf, when the
+// requesting peer sends a cancel message
+func TestCancelRetrieveRequest(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	bzzAddr := network.PrivateKeyToBzzKey(pk)
+
+	kad := network.NewKademlia(bzzAddr, network.NewKadParams())
+
+	tester, _, teardown, err := newRetrievalTester(t, pk, ns, kad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	forwarded := make(chan struct{})
+	forwardedCleanup := make(chan struct{})
+	ns.RemoteGet = func(ctx context.Context, req *storage.Request, localID enode.ID) (*enode.ID, func(), error) {
+		close(forwarded)
+		return &enode.ID{}, func() { close(forwardedCleanup) }, nil
+	}
+	node := tester.Nodes[0]
+
+	err = tester.TestExchanges(
+		p2ptest.Exchange{
+			Label: "Retrieve request for a chunk that is not stored",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 1,
+					Msg: &RetrieveRequest{
+						Ruid: 1234,
+						Addr: hash0[:],
+					},
+					Peer: node.ID(),
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-forwarded:
+	case <-time.After(time.Second):
+		t.Fatal("retrieve request not forwarded")
+	}
+
+	err = tester.TestExchanges(
+		p2ptest.Exchange{
+			Label: "Cancel retrieve request",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 2,
+					Msg: &CancelRetrieveRequest{
+						Ruid: 1234,
+					},
+					Peer: node.ID(),
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// forwarded request would otherwise be cleaned up only after the global fetcher timeout
+	select {
+	case <-forwardedCleanup:
+	case <-time.After(time.Second):
+		t.Fatal("forwarded request not cancelled")
+	}
+}
+
+// TestRequestFromPeersTTL tests that requests originating from the node are sent
+// with the maximal TTL, that forwarded requests keep the decremented TTL capped by
+// the maximal one and that requests with expired TTL are not forwarded
+func TestRequestFromPeersTTL(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+	r := NewWithOptions(to, nil, addr, nil, &Options{MaxHops: 5})
+
+	rw, remoteRW := p2p.MsgPipe()
+	defer remoteRW.Close()
+	newTestRetrievalPeer(t, r, to, nil, network.RandomBzzAddr().Over(), rw)
+
+	requests := make(chan *RetrieveRequest)
+	go func() {
+		for {
+			msg, err := remoteRW.ReadMsg()
+			if err != nil {
+				return
+			}
+			var req RetrieveRequest
+			if err := msg.Decode(&req); err != nil {
+				msg.Discard()
+				continue
+			}
+			requests <- &req
+		}
+	}()
+
+	origin := enode.HexID("3431c3939e1ee2a6345e976a8234f9870152d64879f30bc272a074f6859e75e8")
+	for _, tc := range []struct {
+		name    string
+		origin  enode.ID
+		ttl     uint8
+		wantTTL uint8
+	}{
+		{name: "local", wantTTL: 5},
+		{name: "forwarded", origin: origin, ttl: 3, wantTTL: 3},
+		{name: "forwarded capped", origin: origin, ttl: 10, wantTTL: 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := storage.NewRequest(storage.Address(hash0[:]))
+			req.Origin = tc.origin
+			req.TTL = tc.ttl
+			_, cleanup, err := r.RequestFromPeers(context.Background(), req, enode.ID{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case got := <-requests:
+				if got.TTL != tc.wantTTL {
+					t.Errorf("got ttl %v, want %v", got.TTL, tc.wantTTL)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("retrieve request not received")
+			}
+			// cleanup may send a cancel message
+			go cleanup()
+		})
+	}
+
+	req := storage.NewRequest(storage.Address(hash0[:]))
+	req.Origin = origin
+	if _, _, err := r.RequestFromPeers(context.Background(), req, enode.ID{}); err != ErrTTLExpired {
+		t.Fatalf("got error %v, want %v", err, ErrTTLExpired)
+	}
+}
+
+// TestRetrieveRequestTTL tests that the TTL of an incoming retrieve request
+// is decremented for the forwarded request
+func TestRetrieveRequestTTL(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	bzzAddr := network.PrivateKeyToBzzKey(pk)
+
+	kad := network.NewKademlia(bzzAddr, network.NewKadParams())
+
+	tester, _, teardown, err := newRetrievalTester(t, pk, ns, kad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	ttlC := make(chan uint8, 1)
+	ns.RemoteGet = func(ctx context.Context, req *storage.Request, localID enode.ID) (*enode.ID, func(), error) {
+		ttlC <- req.TTL
+		return nil, func() {}, ErrTTLExpired
+	}
+	node := tester.Nodes[0]
+
+	err = tester.TestExchanges(
+		p2ptest.Exchange{
+			Label: "Retrieve request with ttl",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 1,
+					Msg: &RetrieveRequest{
+						Ruid: 1234,
+						Addr: hash0[:],
+						TTL:  3,
+					},
+					Peer: node.ID(),
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ttl := <-ttlC:
+		if ttl != 2 {
+			t.Errorf("got forwarded request ttl %v, want 2", ttl)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("retrieve request not forwarded")
+	}
+}
+
+// TestForwardingPolicy tests which peers requests are forwarded for
+// with different forwarding policies
+func TestForwardingPolicy(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+
+	// peer with proximity 2 to the node
+	overlay := make([]byte, len(addr.Over()))
+	copy(overlay, addr.Over())
+	overlay[0] ^= 0x20
+	po := chunk.Proximity(addr.Over(), overlay)
+
+	for _, tc := range []struct {
+		name  string
+		o     *Options
+		wants bool
+	}{
+		{name: "default", o: nil, wants: true},
+		{name: "all", o: &Options{ForwardingPolicy: ForwardAll}, wants: true},
+		{name: "none", o: &Options{ForwardingPolicy: ForwardNone}, wants: false},
+		{name: "proximity below", o: &Options{ForwardingPolicy: ForwardProximity, ForwardMinProximity: po + 1}, wants: false},
+		{name: "proximity", o: &Options{ForwardingPolicy: ForwardProximity, ForwardMinProximity: po}, wants: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewWithOptions(to, nil, addr, nil, tc.o)
+			p := newTestRetrievalPeer(t, r, to, nil, overlay, nil)
+			if got := r.forwards(p); got != tc.wants {
+				t.Errorf("got forwards %v, want %v", got, tc.wants)
+			}
+		})
+	}
+}
+
+// TestCachePolicy tests which forwarded chunks are cached
+// with different cache policies
+func TestCachePolicy(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+
+	for _, tc := range []struct {
+		name  string
+		o     *Options
+		po    int
+		depth int
+		wants int // number of cached chunks out of 1000
+	}{
+		{name: "default", o: nil, po: 0, depth: 4, wants: 1000},
+		{name: "always", o: &Options{CachePolicy: CacheAlways}, po: 0, depth: 4, wants: 1000},
+		{name: "never", o: &Options{CachePolicy: CacheNever}, po: 0, depth: 4, wants: 0},
+		{name: "proximity depth", o: &Options{CachePolicy: CacheProximity}, po: 4, depth: 4, wants: 1000},
+		{name: "proximity", o: &Options{CachePolicy: CacheProximity}, po: 1, depth: 3, wants: 500},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewWithOptions(to, nil, addr, nil, tc.o)
+			var cached int
+			for i := 0; i < 1000; i++ {
+				if r.caches(tc.po, tc.depth) {
+					cached++
+				}
+			}
+			// allow for randomness of the proximity policy
+			if cached < tc.wants-100 || cached > tc.wants+100 {
+				t.Errorf("got %v cached chunks, want %v", cached, tc.wants)
+			}
+		})
+	}
+}
+
+// TestChunkDeliveryCachePolicy tests that chunks delivered for forwarded
+// requests are passed to the waiting fetchers, but stored in the local
+// store only according to the cache policy
+func TestChunkDeliveryCachePolicy(t *testing.T) {
+	ch := chunktesting.GenerateTestRandomChunk()
+
+	// the chunk is outside the neighbourhood of the node
+	base := make([]byte, len(ch.Address()))
+	copy(base, ch.Address())
+	base[0] ^= 0x80
+
+	for _, tc := range []struct {
+		name      string
+		policy    CachePolicy
+		forwarded bool
+		stored    bool
+	}{
+		{name: "always", policy: CacheAlways, forwarded: true, stored: true},
+		{name: "never", policy: CacheNever, forwarded: true, stored: false},
+		{name: "never local", policy: CacheNever, forwarded: false, stored: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ns, cleanup := newTestNetstore(t)
+			defer cleanup()
+
+			to := network.NewKademlia(base, network.NewKadParams())
+			r := NewWithOptions(to, ns, network.NewBzzAddr(base, nil), nil, &Options{CachePolicy: tc.policy})
+
+			// peers close to the node, together with the delivering peer in
+			// the shallowest bin, make the neighbourhood depth bigger than zero
+			for i := 0; i < 3; i++ {
+				overlay := make([]byte, len(base))
+				copy(overlay, base)
+				overlay[1] ^= 0x80 >> uint(i)
+				newTestRetrievalPeer(t, r, to, nil, overlay, nil)
+			}
+			p := newTestRetrievalPeer(t, r, to, nil, ch.Address(), nil)
+			if depth := to.NeighbourhoodDepth(); depth == 0 {
+				t.Fatal("neighbourhood depth is zero")
+			}
+			p.addRetrieval(1, ch.Address(), nil, tc.forwarded)
+
+			fi, _, ok := ns.GetOrCreateFetcher(context.Background(), ch.Address(), "request")
+			if !ok {
+				t.Fatal("fetcher not created")
+			}
+
+			err := r.handleChunkDelivery(context.Background(), p, &ChunkDelivery{
+				Ruid:  1,
+				Addr:  ch.Address(),
+				SData: ch.Data(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case <-fi.Delivered:
+			default:
+				t.Fatal("chunk not delivered to the fetcher")
+			}
+
+			stored, err := ns.Store.Has(context.Background(), ch.Address())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored != tc.stored {
+				t.Fatalf("got chunk stored %v, want %v", stored, tc.stored)
+			}
+		})
+	}
+}
+
+// TestRetrieveRequestNotForwarded tests that a node with ForwardNone policy
+// serves chunks from its local store, but does not forward requests
+func TestRetrieveRequestNotForwarded(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	bzzAddr := network.PrivateKeyToBzzKey(pk)
+
+	kad := network.NewKademlia(bzzAddr, network.NewKadParams())
+
+	tester, _, teardown, err := newRetrievalTesterWithOptions(t, pk, ns, kad, &Options{ForwardingPolicy: ForwardNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	forwarded := make(chan struct{}, 1)
+	ns.RemoteGet = func(ctx context.Context, req *storage.Request, localID enode.ID) (*enode.ID, func(), error) {
+		forwarded <- struct{}{}
+		return nil, func() {}, ErrNoPeerFound
+	}
+	node := tester.Nodes[0]
+
+	ch := chunktesting.GenerateTestRandomChunk()
+	if _, err := ns.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	err = tester.TestExchanges(
+		p2ptest.Exchange{
+			Label: "Retrieve request for a stored chunk",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 1,
+					Msg: &RetrieveRequest{
+						Ruid: 1,
+						Addr: ch.Address(),
+						TTL:  DefaultMaxHops,
+					},
+					Peer: node.ID(),
+				},
+			},
+			Expects: []p2ptest.Expect{
+				{
+					Code: 0,
+					Msg: &ChunkDelivery{
+						Ruid:  1,
+						Addr:  ch.Address(),
+						SData: ch.Data(),
+					},
+					Peer: node.ID(),
+				},
+			},
+		},
+		p2ptest.Exchange{
+			Label: "Retrieve request for a chunk that is not stored",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 1,
+					Msg: &RetrieveRequest{
+						Ruid: 2,
+						Addr: hash0[:],
+						TTL:  DefaultMaxHops,
+					},
+					Peer: node.ID(),
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-forwarded:
+		t.Fatal("retrieve request forwarded")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestPeerAllowRequest tests the per peer retrieve request rate limit
+func TestPeerAllowRequest(t *testing.T) {
+	p := NewPeer(&network.BzzPeer{BzzAddr: network.RandomBzzAddr()}, network.RandomBzzAddr())
+
+	limit := rate.Limit(10)
+	for i := 0; i < 2; i++ {
+		if _, ok := p.allowRequest(limit, 2); !ok {
+			t.Fatalf("request %v over the burst not allowed", i)
+		}
+	}
+	delay, ok := p.allowRequest(limit, 2)
+	if ok {
+		t.Fatal("request over the rate allowed")
+	}
+	if delay <= 0 || delay > 100*time.Millisecond {
+		t.Fatalf("got delay %v, want up to 100ms", delay)
+	}
+
+	for i := 0; i < 100; i++ {
+		if _, ok := p.allowRequest(rate.Inf, 0); !ok {
+			t.Fatal("request without rate limit not allowed")
+		}
+	}
+}
+
+// TestRetrieveRequestThrottle tests that a retrieve request over the
+// in-flight limit of a peer is rejected with a Throttle message
+func TestRetrieveRequestThrottle(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	bzzAddr := network.PrivateKeyToBzzKey(pk)
+
+	kad := network.NewKademlia(bzzAddr, network.NewKadParams())
+
+	tester, r, teardown, err := newRetrievalTesterWithOptions(t, pk, ns, kad, &Options{MaxInFlightRequests: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	// keep the first request in flight until the end of the test
+	release := make(chan struct{})
+	defer close(release)
+	ns.RemoteGet = func(ctx context.Context, req *storage.Request, localID enode.ID) (*enode.ID, func(), error) {
+		<-release
+		return nil, func() {}, ErrNoPeerFound
+	}
+	node := tester.Nodes[0]
+
+	err = tester.TestExchanges(
+		p2ptest.Exchange{
+			Label: "Retrieve request in flight",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 1,
+					Msg: &RetrieveRequest{
+						Ruid: 1,
+						Addr: hash0[:],
+						TTL:  DefaultMaxHops,
+					},
+					Peer: node.ID(),
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inFlight := func() int {
+		p := r.getPeer(node.ID())
+		if p == nil {
+			return 0
+		}
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+		return len(p.requests)
+	}
+	for i := 0; inFlight() != 1; i++ {
+		if i == 100 {
+			t.Fatal("retrieve request not in flight")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	err = tester.TestExchanges(
+		p2ptest.Exchange{
+			Label: "Retrieve request over the in-flight limit",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 1,
+					Msg: &RetrieveRequest{
+						Ruid: 2,
+						Addr: hash1[:],
+						TTL:  DefaultMaxHops,
+					},
+					Peer: node.ID(),
+				},
+			},
+			Expects: []p2ptest.Expect{
+				{
+					Code: 4,
+					Msg: &Throttle{
+						Ruid:  2,
+						Delay: 0,
+					},
+					Peer: node.ID(),
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestHandleThrottle tests that a peer that rejected a retrieve request
+// is not selected for requests until the throttle delay passes
+func TestHandleThrottle(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+	r := New(to, nil, addr, nil)
+
+	ref := storage.Address(hash0[:])
+
+	// both peers are in the same bin in relation to the chunk
+	newPeer := func(b byte) *Peer {
+		overlay := make([]byte, len(ref))
+		copy(overlay, ref)
+		overlay[0] ^= 0x80
+		overlay[len(overlay)-1] ^= b
+		return newTestRetrievalPeer(t, r, to, nil, overlay, nil)
+	}
+	throttled := newPeer(1)
+	other := newPeer(2)
+
+	// throttle for unknown retrieval is ignored
+	if err := r.handleThrottle(context.Background(), throttled, &Throttle{Ruid: 1, Delay: uint64(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if throttled.isThrottled() {
+		t.Fatal("peer throttled without retrieval")
+	}
+
+	throttled.addRetrieval(1, ref, nil, false)
+	if err := r.handleThrottle(context.Background(), throttled, &Throttle{Ruid: 1, Delay: uint64(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if !throttled.isThrottled() {
+		t.Fatal("peer not throttled")
+	}
+	if _, _, err := throttled.checkRequest(1, ref); err == nil {
+		t.Fatal("throttled retrieval not removed")
+	}
+
+	for i := 0; i < 4; i++ {
+		p, err := r.findPeerLB(context.Background(), storage.NewRequest(ref))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.ID() != other.ID() {
+			t.Fatalf("got peer %v, want %v", p.ID(), other.ID())
+		}
+	}
+}
+
+// TestFindPeerLBSaturated tests that peers with the maximal number of
+// requests waiting for delivery are not selected
+func TestFindPeerLBSaturated(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+	r := NewWithOptions(to, nil, addr, nil, &Options{MaxPeerRequests: 2})
+
+	ref := storage.Address(hash0[:])
+
+	// both peers are in the same bin in relation to the chunk
+	newPeer := func(b byte) *Peer {
+		overlay := make([]byte, len(ref))
+		copy(overlay, ref)
+		overlay[0] ^= 0x80
+		overlay[len(overlay)-1] ^= b
+		return newTestRetrievalPeer(t, r, to, nil, overlay, nil)
+	}
+	saturated := newPeer(1)
+	other := newPeer(2)
+
+	saturated.addRetrieval(1, ref, nil, false)
+	saturated.addRetrieval(2, ref, nil, false)
+	for i := 0; i < 4; i++ {
+		p, err := r.findPeerLB(context.Background(), storage.NewRequest(ref))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.ID() != other.ID() {
+			t.Fatalf("got peer %v, want %v", p.ID(), other.ID())
+		}
+	}
+
+	other.addRetrieval(3, ref, nil, false)
+	other.addRetrieval(4, ref, nil, false)
+	if _, err := r.findPeerLB(context.Background(), storage.NewRequest(ref)); err != ErrNoPeerFound {
+		t.Fatalf("got error %v, want %v", err, ErrNoPeerFound)
+	}
+
+	// the peer can be selected again when a request is delivered
+	if _, _, err := saturated.checkRequest(1, ref); err != nil {
+		t.Fatal(err)
+	}
+	p, err := r.findPeerLB(context.Background(), storage.NewRequest(ref))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID() != saturated.ID() {
+		t.Fatalf("got peer %v, want %v", p.ID(), saturated.ID())
+	}
+}
+
+// TestRetrievalTracing tests the spans of a retrieve request lifecycle
+// and that the span context is carried to the next hop
+func TestRetrievalTracing(t *testing.T) {
+	reporter := jaeger.NewInMemoryReporter()
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), reporter)
+	defer closer.Close()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	tracing.Enabled = true
+	defer func() { tracing.Enabled = false }()
+
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	addr := network.NewBzzAddr(network.PrivateKeyToBzzKey(pk), nil)
+	to := network.NewKademlia(addr.Over(), network.NewKadParams())
+	r := New(to, ns, addr, nil)
+
+	ch := chunktesting.GenerateTestRandomChunk()
+
+	rw, remoteRW := p2p.MsgPipe()
+	defer rw.Close()
+	p := newTestRetrievalPeer(t, r, to, nil, network.RandomBzzAddr().Over(), rw)
+
+	// the next hop starts its span from the context of the received request
+	type request struct {
+		msg  *RetrieveRequest
+		span opentracing.Span
+	}
+	requests := make(chan request, 1)
+	remote := protocols.NewPeer(p2p.NewPeer(enode.ID{}, "remote", nil), remoteRW, spec)
+	go remote.Run(func(ctx context.Context, msg interface{}) error {
+		if msg, ok := msg.(*RetrieveRequest); ok {
+			_, sp := spancontext.StartSpan(ctx, "remote.handle.retrieve.request")
+			sp.Finish()
+			requests <- request{msg: msg, span: sp}
+		}
+		return nil
+	})
+
+	_, _, err := r.RequestFromPeers(context.Background(), storage.NewRequest(ch.Address()), enode.ID{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req request
+	select {
+	case req = <-requests:
+	case <-time.After(time.Second):
+		t.Fatal("retrieve request not received")
+	}
+
+	spans := func() map[string]jaeger.SpanContext {
+		m := make(map[string]jaeger.SpanContext)
+		for _, sp := range reporter.GetSpans() {
+			m[sp.(*jaeger.Span).OperationName()] = sp.Context().(jaeger.SpanContext)
+		}
+		return m
+	}
+	got := spans()
+	if _, ok := got["wait.chunk.delivery"]; ok {
+		t.Fatal("wait span finished before delivery")
+	}
+
+	err = r.handleChunkDelivery(context.Background(), p, &ChunkDelivery{
+		Ruid:  req.msg.Ruid,
+		Addr:  ch.Address(),
+		SData: ch.Data(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got = spans()
+	for _, tc := range []struct {
+		name, parent string
+	}{
+		{name: "find.peer", parent: "request.from.peers"},
+		{name: "send.retrieve.request", parent: "request.from.peers"},
+		{name: "wait.chunk.delivery", parent: "request.from.peers"},
+		{name: "remote.handle.retrieve.request", parent: "send.retrieve.request"},
+		{name: "store.chunk", parent: "handle.chunk.delivery"},
+	} {
+		sp, ok := got[tc.name]
+		if !ok {
+			t.Errorf("no %s span", tc.name)
+			continue
+		}
+		parent, ok := got[tc.parent]
+		if !ok {
+			t.Errorf("no %s span", tc.parent)
+			continue
+		}
+		if sp.ParentID() != parent.SpanID() {
+			t.Errorf("%s span is not a child of %s span", tc.name, tc.parent)
+		}
+		if sp.TraceID() != parent.TraceID() {
+			t.Errorf("%s span is not in the trace of %s span", tc.name, tc.parent)
+		}
+	}
+}
+
+// newTestRetrievalPeer creates a retrieval protocol Peer with provided overlay address,
+// that communicates over the provided message read writer, and adds it to kademlia
+// and retrieval r. If key is nil, a new node key is generated.
+func newTestRetrievalPeer(t *testing.T, r *Retrieval, kad *network.Kademlia, key *ecdsa.PrivateKey, overlay []byte, rw p2p.MsgReadWriter) *Peer {
+	t.Helper()
+
+	return newTestRetrievalPeerWithAddr(t, r, kad, key, network.NewBzzAddr(overlay, nil), rw)
+}
+
+func newTestRetrievalPeerWithAddr(t *testing.T, r *Retrieval, kad *network.Kademlia, key *ecdsa.PrivateKey, addr *network.BzzAddr, rw p2p.MsgReadWriter) *Peer {
+	t.Helper()
+
+	if key == nil {
+		var err error
+		key, err = crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	id := enode.PubkeyToIDV4(&key.PublicKey)
+	protocolsPeer := protocols.NewPeer(p2p.NewPeer(id, "test", []p2p.Cap{{Name: spec.Name, Version: spec.Version}}), rw, spec)
+	bzzPeer := &network.BzzPeer{
+		BzzAddr: addr,
+		Peer:    protocolsPeer,
+	}
+	kad.On(network.NewPeer(bzzPeer, kad))
+	p := NewPeer(bzzPeer, r.baseAddress)
+	// as if the handshake was done
+	p.features = r.features
+	r.addPeer(p)
+	return p
+}
+
 //TestHasPriceImplementation is to check that Retrieval provides priced messages
 func TestHasPriceImplementation(t *testing.T) {
 	price := (&ChunkDelivery{}).Price()
@@ -533,6 +1637,18 @@ func TestHasPriceImplementation(t *testing.T) {
 }
 
 func newBzzRetrieveWithLocalstore(ctx *adapters.ServiceContext, bucket *sync.Map) (s node.Service, cleanup func(), err error) {
+	return newBzzRetrieveWithLocalstoreOptions(nil)(ctx, bucket)
+}
+
+// newBzzRetrieveWithLocalstoreOptions returns a service function that
+// constructs the retrieval protocol handler with provided options
+func newBzzRetrieveWithLocalstoreOptions(o *Options) simulation.ServiceFunc {
+	return func(ctx *adapters.ServiceContext, bucket *sync.Map) (s node.Service, cleanup func(), err error) {
+		return newBzzRetrieveWithLocalstoreAndOptions(ctx, bucket, o)
+	}
+}
+
+func newBzzRetrieveWithLocalstoreAndOptions(ctx *adapters.ServiceContext, bucket *sync.Map, o *Options) (s node.Service, cleanup func(), err error) {
 	n := ctx.Config.Node()
 	addr := network.NewBzzAddrFromEnode(n)
 
@@ -559,7 +1675,7 @@ func newBzzRetrieveWithLocalstore(ctx *adapters.ServiceContext, bucket *sync.Map
 		return nil, nil, err
 	}
 
-	r := New(kad, netStore, addr, nil)
+	r := NewWithOptions(kad, netStore, addr, nil, o)
 	netStore.RemoteGet = r.RequestFromPeers
 	bucket.Store(bucketKeyFileStore, fileStore)
 	bucket.Store(bucketKeyNetstore, netStore)
@@ -600,19 +1716,8 @@ func newTestLocalStore(id enode.ID, addr *network.BzzAddr, globalStore mock.Glob
 }
 
 func getAllRefs(testData []byte) (storage.AddressCollection, error) {
-	datadir, err := ioutil.TempDir("", "chunk-debug")
-	if err != nil {
-		return nil, err
-	}
-	defer os.RemoveAll(datadir)
-	fileStore, cleanup, err := storage.NewLocalFileStore(datadir, make([]byte, 32), chunk.NewTags())
-	if err != nil {
-		return nil, err
-	}
-	defer cleanup()
-
-	reader := bytes.NewReader(testData)
-	return fileStore.GetAllReferences(context.Background(), reader)
+	fileStore := storage.NewFileStore(&storage.FakeChunkStore{}, &storage.FakeChunkStore{}, storage.NewFileStoreParams(), chunk.NewTags())
+	return fileStore.References(context.Background(), bytes.NewReader(testData))
 }
 
 func getChunks(store chunk.Store) (chunks map[string]struct{}, err error) {
@@ -670,6 +1775,12 @@ func nodeConfigAtPo(t *testing.T, baseaddr []byte, po int) *adapters.NodeConfig
 func newRetrievalTester(t *testing.T, prvkey *ecdsa.PrivateKey, netStore *storage.NetStore, kad *network.Kademlia) (*p2ptest.ProtocolTester, *Retrieval, func(), error) {
 	t.Helper()
 
+	return newRetrievalTesterWithOptions(t, prvkey, netStore, kad, nil)
+}
+
+func newRetrievalTesterWithOptions(t *testing.T, prvkey *ecdsa.PrivateKey, netStore *storage.NetStore, kad *network.Kademlia, o *Options) (*p2ptest.ProtocolTester, *Retrieval, func(), error) {
+	t.Helper()
+
 	if prvkey == nil {
 		key, err := crypto.GenerateKey()
 		if err != nil {
@@ -678,9 +1789,16 @@ func newRetrievalTester(t *testing.T, prvkey *ecdsa.PrivateKey, netStore *storag
 		prvkey = key
 	}
 
-	r := New(kad, netStore, network.NewBzzAddr(kad.BaseAddr(), nil), nil)
+	r := NewWithOptions(kad, netStore, network.NewBzzAddr(kad.BaseAddr(), nil), nil, o)
 	protocolTester := p2ptest.NewProtocolTester(prvkey, 1, r.runProtocol)
 
+	for _, node := range protocolTester.Nodes {
+		if err := handshakeExchange(protocolTester, node.ID(), r.features, r.features); err != nil {
+			protocolTester.Stop()
+			return nil, nil, nil, err
+		}
+	}
+
 	return protocolTester, r, protocolTester.Stop, nil
 }
 
@@ -717,3 +1835,79 @@ func newTestNetstore(t *testing.T) (prvkey *ecdsa.PrivateKey, netStore *storage.
 	}
 	return prvkey, netStore, cleanup
 }
+
+// TestRetryWaitPeerAdded tests that waiting for a retry ends early
+// when a new peer is added to kademlia
+func TestRetryWaitPeerAdded(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+	r := NewWithOptions(to, nil, addr, nil, &Options{RetryBackoff: time.Hour})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- r.retryWait(context.Background(), 0)
+	}()
+
+	// peers are added until the wait ends, as the subscription to kademlia
+	// events may not be made yet when the first one is added
+	timeout := time.After(5 * time.Second)
+	for {
+		newTestRetrievalPeer(t, r, to, nil, network.RandomBzzAddr().OAddr, nil)
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("retry wait did not end when a peer was added")
+		}
+	}
+}
+
+// TestRetrieveRequestServingDisabled tests that a node that declared
+// it does not serve retrieve requests disconnects peers that send them
+func TestRetrieveRequestServingDisabled(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	bzzAddr := network.PrivateKeyToBzzKey(pk)
+
+	kad := network.NewKademlia(bzzAddr, network.NewKadParams())
+
+	tester, _, teardown, err := newRetrievalTesterWithOptions(t, pk, ns, kad, &Options{DisableServing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	node := tester.Nodes[0]
+
+	ch := chunktesting.GenerateTestRandomChunk()
+	if _, err := ns.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "Retrieve request",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 1,
+				Msg: &RetrieveRequest{
+					Ruid: 1,
+					Addr: ch.Address(),
+					TTL:  DefaultMaxHops,
+				},
+				Peer: node.ID(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tester.TestDisconnected(&p2ptest.Disconnect{Peer: node.ID(), Error: errors.New("subprotocol error")})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"context"
+	"sync"
+)
+
+// Priority is the class of a retrieve request that determines the order in
+// which requests are sent when the number of active requests is limited
+type Priority int
+
+const (
+	// PriorityLocal is the class of requests originating from this node
+	PriorityLocal Priority = iota
+	// PriorityForwarded is the class of requests forwarded on behalf of peers
+	PriorityForwarded
+	// PriorityBackground is the class of requests made by background
+	// processes, such as repair of missing chunks
+	PriorityBackground
+
+	priorityCount = iota
+)
+
+// String returns the name of the priority class
+func (p Priority) String() string {
+	switch p {
+	case PriorityLocal:
+		return "local"
+	case PriorityForwarded:
+		return "forwarded"
+	case PriorityBackground:
+		return "background"
+	}
+	return "unknown"
+}
+
+// priorityWeights are the numbers of queued requests of each priority class
+// that are scheduled in one round, so that higher classes get a larger share
+// of the active requests while lower classes are not starved
+var priorityWeights = [priorityCount]int{4, 2, 1}
+
+// scheduler limits the number of active retrieve requests and schedules
+// queued requests from per class queues by weighted round robin
+type scheduler struct {
+	mtx     sync.Mutex
+	slots   int                            // number of free slots for active requests
+	queues  [priorityCount][]chan struct{} // requests waiting for a slot, by class
+	credits [priorityCount]int             // requests each class may still be scheduled in the current round
+}
+
+// newScheduler returns a scheduler for up to max active requests
+func newScheduler(max int) *scheduler {
+	s := &scheduler{
+		slots: max,
+	}
+	s.credits = priorityWeights
+	return s
+}
+
+// acquire waits for a free slot for a request of the priority class
+// or until the context is done
+func (s *scheduler) acquire(ctx context.Context, p Priority) error {
+	s.mtx.Lock()
+	if s.slots > 0 && s.queued() == 0 {
+		s.slots--
+		s.mtx.Unlock()
+		return nil
+	}
+	c := make(chan struct{})
+	s.queues[p] = append(s.queues[p], c)
+	s.mtx.Unlock()
+
+	select {
+	case <-c:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for i, q := range s.queues[p] {
+		if q == c {
+			s.queues[p] = append(s.queues[p][:i], s.queues[p][i+1:]...)
+			return ctx.Err()
+		}
+	}
+	// the slot was granted while the context was done, pass it on
+	s.releaseLocked()
+	return ctx.Err()
+}
+
+// release frees the slot of a finished request, handing it over to the
+// next scheduled queued request
+func (s *scheduler) release() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.releaseLocked()
+}
+
+func (s *scheduler) releaseLocked() {
+	if c := s.next(); c != nil {
+		close(c)
+		return
+	}
+	s.slots++
+}
+
+// next removes and returns the next queued request, taking it from the
+// highest class that has not used up its credits in the current round.
+// A new round starts when no class with credits has queued requests.
+func (s *scheduler) next() chan struct{} {
+	if s.queued() == 0 {
+		return nil
+	}
+	for {
+		for p := range s.queues {
+			if s.credits[p] > 0 && len(s.queues[p]) > 0 {
+				s.credits[p]--
+				c := s.queues[p][0]
+				s.queues[p] = s.queues[p][1:]
+				return c
+			}
+		}
+		s.credits = priorityWeights
+	}
+}
+
+// queued returns the number of requests waiting for a slot
+func (s *scheduler) queued() (n int) {
+	for _, q := range s.queues {
+		n += len(q)
+	}
+	return n
+}
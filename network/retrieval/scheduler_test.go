@@ -0,0 +1,145 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// TestSchedulerLimit tests that requests over the limit wait for
+// a released slot or until their context is done
+func TestSchedulerLimit(t *testing.T) {
+	s := newScheduler(1)
+
+	if err := s.acquire(context.Background(), PriorityLocal); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.acquire(ctx, PriorityLocal); err != context.DeadlineExceeded {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := s.queued(); n != 0 {
+		t.Fatalf("got %v queued requests after timeout, want 0", n)
+	}
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- s.acquire(context.Background(), PriorityForwarded)
+	}()
+	for i := 0; ; i++ {
+		s.mtx.Lock()
+		n := s.queued()
+		s.mtx.Unlock()
+		if n == 1 {
+			break
+		}
+		if i == 100 {
+			t.Fatal("request not queued")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.release()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued request not scheduled on release")
+	}
+
+	s.release()
+	if s.slots != 1 {
+		t.Fatalf("got %v free slots, want 1", s.slots)
+	}
+}
+
+// TestSchedulerPriority tests the order in which queued requests
+// of different priority classes are scheduled
+func TestSchedulerPriority(t *testing.T) {
+	s := newScheduler(0)
+
+	names := map[Priority]string{
+		PriorityLocal:      "L",
+		PriorityForwarded:  "F",
+		PriorityBackground: "B",
+	}
+	classes := make(map[chan struct{}]Priority)
+	for _, p := range []Priority{PriorityBackground, PriorityForwarded, PriorityLocal} {
+		for i := 0; i < 7; i++ {
+			c := make(chan struct{})
+			classes[c] = p
+			s.queues[p] = append(s.queues[p], c)
+		}
+	}
+
+	var got []string
+	for c := s.next(); c != nil; c = s.next() {
+		got = append(got, names[classes[c]])
+	}
+
+	// weighted rounds of 4 local, 2 forwarded and 1 background request
+	want := "LLLLFFB" + "LLLFFB" + "FFB" + "FB" + "B" + "B" + "B"
+	if strings.Join(got, "") != want {
+		t.Fatalf("got schedule %s, want %s", strings.Join(got, ""), want)
+	}
+}
+
+// TestRetrievalPriority tests the priority classes of requests
+func TestRetrievalPriority(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+	r := New(to, nil, addr, nil)
+
+	localID := enode.HexID("3431c3939e1ee2a6345e976a8234f9870152d64879f30bc272a074f6859e75e8")
+	peerID := enode.HexID("8a608cd324678469291c18e2d3feb82e74b181adb6b44439a6fd1daa48993001")
+
+	for _, tc := range []struct {
+		name string
+		req  *storage.Request
+		want Priority
+	}{
+		{name: "local", req: &storage.Request{Addr: hash0[:]}, want: PriorityLocal},
+		{name: "local origin", req: &storage.Request{Addr: hash0[:], Origin: localID}, want: PriorityLocal},
+		{name: "forwarded", req: &storage.Request{Addr: hash0[:], Origin: peerID}, want: PriorityForwarded},
+		{name: "background", req: &storage.Request{Addr: hash0[:], Background: true}, want: PriorityBackground},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := r.priority(tc.req, localID); got != tc.want {
+				t.Errorf("got priority %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// requests with this node as origin are not subject to ttl
+	_, _, err := r.RequestFromPeers(context.Background(), &storage.Request{Addr: hash0[:], Origin: localID}, localID)
+	if err != ErrNoPeerFound {
+		t.Fatalf("got error %v, want %v", err, ErrNoPeerFound)
+	}
+	if r.scheduler.slots != DefaultMaxActiveRequests {
+		t.Fatalf("got %v free slots, want %v", r.scheduler.slots, DefaultMaxActiveRequests)
+	}
+}
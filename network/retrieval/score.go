@@ -0,0 +1,143 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/network/timeouts"
+)
+
+// scoreEWMAWeight is the weight of a new sample in the exponentially
+// weighted moving averages of peer retrieval statistics
+const scoreEWMAWeight = 0.2
+
+// PeerScore holds retrieval statistics of a peer. Latency and FailureRate
+// are exponentially weighted moving averages of delivery latency and of the
+// ratio of requests that were not delivered within the search timeout.
+type PeerScore struct {
+	Peer        enode.ID      `json:"peer"`
+	Latency     time.Duration `json:"latency"`
+	FailureRate float64       `json:"failureRate"`
+	Deliveries  uint64        `json:"deliveries"`
+	Failures    uint64        `json:"failures"`
+	// Score is the expected time in seconds to retrieve a chunk from
+	// the peer, accounting a search timeout for every failure. Peers
+	// with lower scores are preferred.
+	Score float64 `json:"score"`
+}
+
+// peerScores keeps retrieval statistics of connected peers
+type peerScores struct {
+	scores map[enode.ID]*PeerScore
+	mu     sync.RWMutex
+}
+
+func newPeerScores() *peerScores {
+	return &peerScores{
+		scores: make(map[enode.ID]*PeerScore),
+	}
+}
+
+// delivered records a successful delivery from the peer with its latency
+func (s *peerScores) delivered(id enode.ID, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ps := s.get(id)
+	if ps.Deliveries == 0 {
+		ps.Latency = latency
+	} else {
+		ps.Latency = time.Duration(ewma(float64(ps.Latency), float64(latency)))
+	}
+	ps.FailureRate = ewma(ps.FailureRate, 0)
+	ps.Deliveries++
+	ps.Score = score(ps)
+}
+
+// failed records a request that the peer failed to deliver
+func (s *peerScores) failed(id enode.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ps := s.get(id)
+	if ps.Deliveries+ps.Failures == 0 {
+		ps.FailureRate = 1
+	} else {
+		ps.FailureRate = ewma(ps.FailureRate, 1)
+	}
+	ps.Failures++
+	ps.Score = score(ps)
+}
+
+// get returns the score of the peer, creating it if it does not exist.
+// It must be called with the lock held.
+func (s *peerScores) get(id enode.ID) *PeerScore {
+	ps, ok := s.scores[id]
+	if !ok {
+		ps = &PeerScore{Peer: id}
+		s.scores[id] = ps
+	}
+	return ps
+}
+
+// score returns the score of the peer, or zero for peers without statistics,
+// so that they are tried before the ones that are known to be slow
+func (s *peerScores) score(id enode.ID) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if ps, ok := s.scores[id]; ok {
+		return ps.Score
+	}
+	return 0
+}
+
+// remove removes statistics of the peer
+func (s *peerScores) remove(id enode.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.scores, id)
+}
+
+// all returns scores of all peers ordered from the best to the worst
+func (s *peerScores) all() (scores []PeerScore) {
+	s.mu.RLock()
+	for _, ps := range s.scores {
+		scores = append(scores, *ps)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].Score < scores[j].Score
+	})
+	return scores
+}
+
+// score returns the expected time in seconds to retrieve a chunk from a peer
+func score(ps *PeerScore) float64 {
+	return ps.Latency.Seconds() + ps.FailureRate*timeouts.SearchTimeout.Seconds()
+}
+
+// ewma returns the exponentially weighted moving average with a new sample
+func ewma(avg, sample float64) float64 {
+	return scoreEWMAWeight*sample + (1-scoreEWMAWeight)*avg
+}
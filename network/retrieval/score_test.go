@@ -0,0 +1,138 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/timeouts"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// TestPeerScores validates exponentially weighted moving averages
+// of peer statistics and ordering of peers by their scores.
+func TestPeerScores(t *testing.T) {
+	s := newPeerScores()
+
+	fast := enode.HexID("3431c3939e1ee2a6345e976a8234f9870152d64879f30bc272a074f6859e75e8")
+	slow := enode.HexID("8a608cd324678469291c18e2d3feb82e74b181adb6b44439a6fd1daa48993001")
+	failing := enode.HexID("bacf09fb92eaed1934b60831669c1715f7e9c7658ff58b575c0cb2a53f0b7fd4")
+
+	if got := s.score(fast); got != 0 {
+		t.Errorf("got score %v for a peer without statistics, want 0", got)
+	}
+
+	s.delivered(fast, 100*time.Millisecond)
+	s.delivered(fast, 200*time.Millisecond)
+	s.delivered(slow, time.Second)
+	s.failed(failing)
+	s.delivered(failing, 100*time.Millisecond)
+
+	scores := s.all()
+	if len(scores) != 3 {
+		t.Fatalf("got %v scores, want 3", len(scores))
+	}
+	for i, want := range []enode.ID{fast, slow, failing} {
+		if scores[i].Peer != want {
+			t.Errorf("got peer %v at position %v, want %v", scores[i].Peer, i, want)
+		}
+	}
+
+	// 0.2*200ms + 0.8*100ms
+	if got, want := scores[0].Latency, 120*time.Millisecond; got != want {
+		t.Errorf("got latency %v, want %v", got, want)
+	}
+	if got := scores[0].Deliveries; got != 2 {
+		t.Errorf("got %v deliveries, want 2", got)
+	}
+
+	// 0.2*0 + 0.8*1
+	if got, want := scores[2].FailureRate, 0.8; math.Abs(got-want) > 1e-9 {
+		t.Errorf("got failure rate %v, want %v", got, want)
+	}
+	if got := scores[2].Failures; got != 1 {
+		t.Errorf("got %v failures, want 1", got)
+	}
+	want := (100 * time.Millisecond).Seconds() + 0.8*timeouts.SearchTimeout.Seconds()
+	if got := scores[2].Score; math.Abs(got-want) > 1e-9 {
+		t.Errorf("got score %v, want %v", got, want)
+	}
+
+	s.remove(slow)
+	if got := len(s.all()); got != 2 {
+		t.Errorf("got %v scores after removal, want 2", got)
+	}
+}
+
+// TestFindPeerLBScore tests that a peer with a worse score is not selected
+// over a peer in the same kademlia bin with a better score.
+func TestFindPeerLBScore(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+	r := New(to, nil, addr, nil)
+
+	ref := storage.Address(hash0[:])
+
+	// both peers are in the same bin in relation to the chunk
+	newPeer := func(id enode.ID, b byte) *network.Peer {
+		overlay := make([]byte, len(ref))
+		copy(overlay, ref)
+		overlay[0] ^= 0x80
+		overlay[len(overlay)-1] ^= b
+		protocolsPeer := protocols.NewPeer(p2p.NewPeer(id, "test", []p2p.Cap{{Name: spec.Name, Version: spec.Version}}), nil, nil)
+		peer := network.NewPeer(&network.BzzPeer{
+			BzzAddr: network.NewBzzAddr(overlay, nil),
+			Peer:    protocolsPeer,
+		}, to)
+		to.On(peer)
+		return peer
+	}
+	good := newPeer(enode.HexID("3431c3939e1ee2a6345e976a8234f9870152d64879f30bc272a074f6859e75e8"), 1)
+	bad := newPeer(enode.HexID("8a608cd324678469291c18e2d3feb82e74b181adb6b44439a6fd1daa48993001"), 2)
+
+	r.scores.delivered(good.ID(), 100*time.Millisecond)
+	r.scores.failed(bad.ID())
+
+	// without scores, the load balancer would alternate between peers
+	for i := 0; i < 4; i++ {
+		p, err := r.findPeerLB(context.Background(), storage.NewRequest(ref))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.ID() != good.ID() {
+			t.Fatalf("got peer %v, want %v", p.ID(), good.ID())
+		}
+	}
+
+	// the worse scored peer is still selected if the better one is skipped
+	req := storage.NewRequest(ref)
+	req.PeersToSkip.Store(good.ID().String(), time.Now())
+	p, err := r.findPeerLB(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID() != bad.ID() {
+		t.Fatalf("got peer %v, want %v", p.ID(), bad.ID())
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"math/rand"
+
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// Candidate is a peer that a retrieve request can be sent to
+type Candidate struct {
+	Peer      *network.Peer
+	Proximity int     // proximity order of the peer to the requested chunk
+	Score     float64 // retrieval score of the peer, lower is better, zero if unknown
+	Storer    bool    // peer advertises a storage radius that includes the requested chunk
+	lbPeer    network.LBPeer
+}
+
+// PeerSelector selects the peer a retrieve request is sent to from the
+// candidates that are allowed by the forwarding rules. Candidates are
+// ordered by proximity to the requested chunk, closest first, then storers
+// of the chunk first and by the load balancer order within the same proximity.
+type PeerSelector interface {
+	// SelectPeer returns the index of the selected candidate, or -1 if
+	// the request should not be sent to any of them. There is at least
+	// one candidate.
+	SelectPeer(req *storage.Request, candidates []Candidate) int
+}
+
+// PeerSelectorFunc is a function that implements PeerSelector
+type PeerSelectorFunc func(req *storage.Request, candidates []Candidate) int
+
+// SelectPeer calls the function
+func (f PeerSelectorFunc) SelectPeer(req *storage.Request, candidates []Candidate) int {
+	return f(req, candidates)
+}
+
+// ClosestSelector selects the closest peer to the chunk in the load
+// balancer order, regardless of retrieval scores
+type ClosestSelector struct{}
+
+// SelectPeer implements PeerSelector
+func (ClosestSelector) SelectPeer(_ *storage.Request, _ []Candidate) int {
+	return 0
+}
+
+// RandomSelector selects a random peer from the N closest peers
+// to the chunk, spreading requests over more peers
+type RandomSelector struct {
+	N int // number of closest candidates to select from, at least one
+}
+
+// SelectPeer implements PeerSelector
+func (s RandomSelector) SelectPeer(_ *storage.Request, candidates []Candidate) int {
+	n := s.N
+	if n < 1 {
+		n = 1
+	}
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	return rand.Intn(n)
+}
+
+// ScoreSelector selects the peer with the best retrieval score from the
+// closest peers to the chunk with the same proximity, keeping the load
+// balancer order for equally scored peers. It is the default selector.
+type ScoreSelector struct{}
+
+// SelectPeer implements PeerSelector
+func (ScoreSelector) SelectPeer(_ *storage.Request, candidates []Candidate) int {
+	best := 0
+	for i, c := range candidates {
+		if c.Proximity != candidates[0].Proximity {
+			break
+		}
+		if c.Score < candidates[best].Score {
+			best = i
+		}
+	}
+	return best
+}
+
+// PriceSelector selects the cheapest peer from the closest peers to the
+// chunk with the same proximity, preferring peers with better retrieval
+// scores for the same price
+type PriceSelector struct {
+	Price func(p *network.Peer) uint64 // price of a chunk retrieval from the peer
+}
+
+// SelectPeer implements PeerSelector
+func (s PriceSelector) SelectPeer(_ *storage.Request, candidates []Candidate) int {
+	best := 0
+	bestPrice := s.Price(candidates[0].Peer)
+	for i, c := range candidates[1:] {
+		if c.Proximity != candidates[0].Proximity {
+			break
+		}
+		price := s.Price(c.Peer)
+		if price < bestPrice || price == bestPrice && c.Score < candidates[best].Score {
+			best = i + 1
+			bestPrice = price
+		}
+	}
+	return best
+}
@@ -0,0 +1,223 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/capability"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// TestPeerSelectors validates the peer selection of the provided selectors
+func TestPeerSelectors(t *testing.T) {
+	newPeer := func(b byte) *network.Peer {
+		var id enode.ID
+		id[0] = b
+		return network.NewPeer(&network.BzzPeer{
+			BzzAddr: network.RandomBzzAddr(),
+			Peer:    protocols.NewPeer(p2p.NewPeer(id, "test", nil), nil, nil),
+		}, nil)
+	}
+	candidates := []Candidate{
+		{Peer: newPeer(1), Proximity: 5, Score: 0.3},
+		{Peer: newPeer(2), Proximity: 5, Score: 0.1},
+		{Peer: newPeer(3), Proximity: 5, Score: 0.1},
+		{Peer: newPeer(4), Proximity: 2, Score: 0},
+	}
+	prices := map[enode.ID]uint64{
+		candidates[0].Peer.ID(): 10,
+		candidates[1].Peer.ID(): 20,
+		candidates[2].Peer.ID(): 10,
+		candidates[3].Peer.ID(): 1,
+	}
+	req := storage.NewRequest(storage.Address(hash0[:]))
+
+	for _, tc := range []struct {
+		name     string
+		selector PeerSelector
+		want     int
+	}{
+		{
+			name:     "closest",
+			selector: ClosestSelector{},
+			want:     0,
+		},
+		{
+			name:     "score",
+			selector: ScoreSelector{},
+			want:     1,
+		},
+		{
+			name: "price",
+			selector: PriceSelector{Price: func(p *network.Peer) uint64 {
+				return prices[p.ID()]
+			}},
+			want: 2,
+		},
+		{
+			name:     "random single",
+			selector: RandomSelector{N: 1},
+			want:     0,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.selector.SelectPeer(req, candidates); got != tc.want {
+				t.Fatalf("got candidate %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// random selection is limited to the first N candidates
+	selected := make(map[int]bool)
+	for i := 0; i < 1000; i++ {
+		got := RandomSelector{N: 2}.SelectPeer(req, candidates)
+		if got < 0 || got > 1 {
+			t.Fatalf("got candidate %v, want one of the first 2", got)
+		}
+		selected[got] = true
+	}
+	if len(selected) != 2 {
+		t.Fatalf("got %v selected candidates, want 2", len(selected))
+	}
+	if got := (RandomSelector{N: 10}).SelectPeer(req, candidates); got < 0 || got >= len(candidates) {
+		t.Fatalf("got candidate %v, want one of %v", got, len(candidates))
+	}
+}
+
+// TestFindPeerLBSelector validates that the configured peer selector
+// is given candidates from all eligible bins, closest first, and that
+// its selection is used.
+func TestFindPeerLBSelector(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+
+	var candidates []Candidate
+	selectPeer := -1
+	r := NewWithOptions(to, nil, addr, nil, &Options{
+		PeerSelector: PeerSelectorFunc(func(_ *storage.Request, c []Candidate) int {
+			candidates = c
+			return selectPeer
+		}),
+	})
+
+	ref := storage.Address(hash0[:])
+
+	newPeer := func(id enode.ID, po int) *network.Peer {
+		overlay := make([]byte, len(ref))
+		copy(overlay, ref)
+		overlay[po/8] ^= 0x80 >> uint(po%8)
+		protocolsPeer := protocols.NewPeer(p2p.NewPeer(id, "test", []p2p.Cap{{Name: spec.Name, Version: spec.Version}}), nil, nil)
+		peer := network.NewPeer(&network.BzzPeer{
+			BzzAddr: network.NewBzzAddr(overlay, nil),
+			Peer:    protocolsPeer,
+		}, to)
+		to.On(peer)
+		return peer
+	}
+	far := newPeer(enode.HexID("3431c3939e1ee2a6345e976a8234f9870152d64879f30bc272a074f6859e75e8"), 0)
+	near := newPeer(enode.HexID("8a608cd324678469291c18e2d3feb82e74b181adb6b44439a6fd1daa48993001"), 7)
+
+	// no peer is selected
+	if _, err := r.findPeerLB(context.Background(), storage.NewRequest(ref)); err != ErrNoPeerFound {
+		t.Fatalf("got error %v, want %v", err, ErrNoPeerFound)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("got %v candidates, want 2", len(candidates))
+	}
+	for i, want := range []struct {
+		peer *network.Peer
+		po   int
+	}{
+		{near, 7},
+		{far, 0},
+	} {
+		if candidates[i].Peer.ID() != want.peer.ID() {
+			t.Fatalf("got candidate %v peer %v, want %v", i, candidates[i].Peer.ID(), want.peer.ID())
+		}
+		if candidates[i].Proximity != want.po {
+			t.Fatalf("got candidate %v proximity %v, want %v", i, candidates[i].Proximity, want.po)
+		}
+	}
+
+	// the further peer is selected
+	selectPeer = 1
+	p, err := r.findPeerLB(context.Background(), storage.NewRequest(ref))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID() != far.ID() {
+		t.Fatalf("got peer %v, want %v", p.ID(), far.ID())
+	}
+}
+
+// TestFindPeerLBCapabilityIndex checks that only peers in the configured
+// capability index are selected for requests, skipping light nodes
+func TestFindPeerLBCapabilityIndex(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
+
+	var candidates []Candidate
+	r := NewWithOptions(to, nil, addr, nil, &Options{
+		CapabilityIndex: network.CapabilityIndexRelayRetrieve,
+		PeerSelector: PeerSelectorFunc(func(_ *storage.Request, c []Candidate) int {
+			candidates = c
+			return 0
+		}),
+	})
+
+	ref := storage.Address(hash0[:])
+
+	newPeer := func(id enode.ID, po int, bits ...int) *network.Peer {
+		overlay := make([]byte, len(ref))
+		copy(overlay, ref)
+		overlay[po/8] ^= 0x80 >> uint(po%8)
+		c := capability.NewCapability(network.CapabilityID, 16)
+		for _, b := range bits {
+			c.Set(b)
+		}
+		bzzAddr := network.NewBzzAddr(overlay, nil)
+		bzzAddr.Capabilities.Add(c)
+		protocolsPeer := protocols.NewPeer(p2p.NewPeer(id, "test", []p2p.Cap{{Name: spec.Name, Version: spec.Version}}), nil, nil)
+		peer := network.NewPeer(&network.BzzPeer{
+			BzzAddr: bzzAddr,
+			Peer:    protocolsPeer,
+		}, to)
+		to.On(peer)
+		return peer
+	}
+	// the light node is closer to the chunk, but does not serve requests
+	// of other peers
+	full := newPeer(enode.HexID("3431c3939e1ee2a6345e976a8234f9870152d64879f30bc272a074f6859e75e8"), 0, 0, 1, 4, 5, 15)
+	newPeer(enode.HexID("8a608cd324678469291c18e2d3feb82e74b181adb6b44439a6fd1daa48993001"), 7, 0, 1)
+
+	p, err := r.findPeerLB(context.Background(), storage.NewRequest(ref))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 {
+		t.Fatalf("got %v candidates, want 1", len(candidates))
+	}
+	if p.ID() != full.ID() {
+		t.Fatalf("got peer %v, want %v", p.ID(), full.ID())
+	}
+}
@@ -22,6 +22,8 @@ import "github.com/ethersphere/swarm/storage"
 type RetrieveRequest struct {
 	Ruid uint
 	Addr storage.Address
+	TTL  uint8  // number of hops the request may be forwarded over, decremented on each forward
+	Auth []byte // authentication of the request in permissioned swarms, see Authenticator
 }
 
 // ChunkDelivery is the protocol msg for delivering a solicited chunk to a peer
@@ -30,3 +32,57 @@ type ChunkDelivery struct {
 	Addr  storage.Address
 	SData []byte
 }
+
+// CancelRetrieveRequest is the protocol msg for cancelling a retrieve
+// request that is no longer needed by the requesting peer
+type CancelRetrieveRequest struct {
+	Ruid uint
+}
+
+// Receipt is the protocol msg for acknowledging a chunk delivery. It is signed
+// by the requesting peer and can be stored by the delivering peer as a statement
+// of custody, for incentive accounting or audits
+type Receipt struct {
+	Ruid      uint
+	Addr      storage.Address
+	Timestamp uint64 // unix time in nanoseconds when the chunk was received
+	Signature []byte // requesting peer signature over the chunk address, delivering peer overlay address and timestamp
+}
+
+// Throttle is the protocol msg for rejecting a retrieve request of a peer that
+// is over its request limits. The requesting peer should not send further
+// requests before the delay passes.
+type Throttle struct {
+	Ruid  uint
+	Delay uint64 // time in nanoseconds after which requests are accepted again
+}
+
+// Handshake is the protocol msg exchanged by peers when the protocol starts,
+// to agree on the optional features used on the connection
+type Handshake struct {
+	Version  uint     // protocol version of the sending peer
+	Features Features // optional features supported by the sending peer
+}
+
+// RelayRequest is the protocol msg for requesting a chunk from a peer that
+// the requesting peer can not connect to, such as a node behind NAT. The
+// relaying peer sends a retrieve request to the target peer and delivers the
+// chunk back with a ChunkDelivery message with the ruid of the relay request.
+type RelayRequest struct {
+	Ruid   uint
+	Addr   storage.Address
+	Target []byte // overlay address of the peer the request is relayed to
+	TTL    uint8  // number of hops the relayed request may be forwarded over
+	Auth   []byte // authentication of the request in permissioned swarms, see Authenticator
+}
+
+// retrieveRequest returns the retrieve request with the ruid, address and
+// ttl of the relay request, which is authenticated in its place
+func (rr *RelayRequest) retrieveRequest() *RetrieveRequest {
+	return &RetrieveRequest{
+		Ruid: rr.Ruid,
+		Addr: rr.Addr,
+		TTL:  rr.TTL,
+		Auth: rr.Auth,
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethersphere/swarm/log"
+)
+
+// StorageInfo is the state of the storage that a node advertises to its
+// peers in the handshake and whenever it changes
+type StorageInfo struct {
+	Radius       uint8  // proximity order from the node's address within which chunks are stored
+	FreeCapacity uint64 // number of chunks that can be stored before the capacity is reached
+}
+
+// WithinRadius returns true if a chunk with the provided proximity order
+// to the node's address falls within its storage radius
+func (i StorageInfo) WithinRadius(po int) bool {
+	return po >= int(i.Radius)
+}
+
+// Full returns true if the node has no free capacity
+// and can store new chunks only by evicting others
+func (i StorageInfo) Full() bool {
+	return i.FreeCapacity == 0
+}
+
+// String returns the pretty printer
+func (i StorageInfo) String() string {
+	return fmt.Sprintf("radius: %d, free capacity: %d", i.Radius, i.FreeCapacity)
+}
+
+// storageMsg advertises a change of the storage info to a peer
+type storageMsg struct {
+	Radius       uint8
+	FreeCapacity uint64
+}
+
+// String returns the pretty printer
+func (msg storageMsg) String() string {
+	return fmt.Sprintf("%T: radius: %d, free capacity: %d", msg, msg.Radius, msg.FreeCapacity)
+}
+
+// StorageInfo returns the storage info last advertised by the peer
+// and false if the peer did not advertise any
+func (d *Peer) StorageInfo() (info StorageInfo, ok bool) {
+	d.storageMu.RLock()
+	defer d.storageMu.RUnlock()
+	if d.storage == nil {
+		return StorageInfo{}, false
+	}
+	return *d.storage, true
+}
+
+// setStorageInfo sets the storage info advertised by the peer
+func (d *Peer) setStorageInfo(info StorageInfo) {
+	d.storageMu.Lock()
+	defer d.storageMu.Unlock()
+	d.storage = &info
+}
+
+// localStorageInfo returns the storage info of this node
+// or nil if it is not advertised
+func (h *Hive) localStorageInfo() *StorageInfo {
+	if h.storageInfo == nil {
+		return nil
+	}
+	info := h.storageInfo()
+	return &info
+}
+
+// advertiseStorage sends the storage info of this node to all connected
+// peers if it changed since it was last advertised
+func (h *Hive) advertiseStorage() {
+	info := h.localStorageInfo()
+	if info == nil || (h.advertisedStorage != nil && *info == *h.advertisedStorage) {
+		return
+	}
+	h.advertisedStorage = info
+	msg := &storageMsg{Radius: info.Radius, FreeCapacity: info.FreeCapacity}
+	h.EachConn(nil, 255, func(p *Peer, _ int) bool {
+		go func() {
+			if err := p.Send(context.TODO(), msg); err != nil {
+				log.Debug("hive storage info advertisement failed", "peer", p.ID(), "err", err)
+			}
+		}()
+		return true
+	})
+}
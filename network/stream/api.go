@@ -0,0 +1,35 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+// API exposes the stream protocol state over RPC
+type API struct {
+	r *Registry
+}
+
+// NewAPI creates a new API for the provided Registry
+func NewAPI(r *Registry) *API {
+	return &API{
+		r: r,
+	}
+}
+
+// SyncProgress returns the progress of pull syncing the bins of the
+// connected peers, to tell if the node is done syncing its neighbourhood
+func (a *API) SyncProgress() (*SyncProgress, error) {
+	return a.r.SyncProgress()
+}
@@ -561,7 +561,7 @@ func TestCorrectCursorsExchangeRace(t *testing.T) {
 CHECKSTREAMS:
 	pivotDepth := pivotKad.NeighbourhoodDepth()
 	po := chunk.Proximity(otherBase, pivotKad.BaseAddr())
-	sub, qui := syncSubscriptionsDiff(po, -1, pivotDepth, pivotKad.MaxProxDisplay, false) //s.syncBinsOnlyWithinDepth)
+	sub, qui := syncSubscriptionsDiff(po, -1, pivotDepth, pivotKad.MaxProxDisplay, syncBinsAll) //s.syncBins)
 	log.Debug("got desired pivot cursor state", "depth", pivotDepth, "subs", sub, "quits", qui)
 
 	streamInfoMtx.Lock()
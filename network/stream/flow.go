@@ -0,0 +1,139 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/network/timeouts"
+)
+
+// maxInFlightBatches is the upper bound of the history batches a peer can
+// request on a stream at a time, the server refuses more
+const maxInFlightBatches = 8
+
+var (
+	batchWindowGrowCount   = metrics.GetOrRegisterCounter("network/stream/batch_window/grow", nil)
+	batchWindowShrinkCount = metrics.GetOrRegisterCounter("network/stream/batch_window/shrink", nil)
+)
+
+// Options holds the flow-control parameters of the stream protocol
+type Options struct {
+	// MinBatchSize and MaxBatchSize bound the number of hashes requested
+	// in a history batch from a peer, which is adapted between them to the peer's
+	// responsiveness, starting from BatchSize. MaxBatchSize is also the
+	// largest batch served to peers. Zero values default to BatchSize/4 and
+	// BatchSize*4, equal values fix the batch size.
+	MinBatchSize uint
+	MaxBatchSize uint
+	// MaxInFlightBatches is the number of history batches requested from a
+	// peer on a stream at a time, at most 8. Zero defaults to 1.
+	MaxInFlightBatches int
+}
+
+// withDefaults returns the options with zero values set to their defaults
+// and out of range values bounded
+func (o Options) withDefaults() Options {
+	if o.MinBatchSize == 0 {
+		o.MinBatchSize = BatchSize / 4
+	}
+	if o.MaxBatchSize == 0 {
+		o.MaxBatchSize = BatchSize * 4
+	}
+	if o.MaxBatchSize < o.MinBatchSize {
+		o.MaxBatchSize = o.MinBatchSize
+	}
+	if o.MaxInFlightBatches <= 0 {
+		o.MaxInFlightBatches = 1
+	}
+	if o.MaxInFlightBatches > maxInFlightBatches {
+		o.MaxInFlightBatches = maxInFlightBatches
+	}
+	return o
+}
+
+// batchWindow adapts the batch size requested from a peer on a stream to
+// the peer's responsiveness: it is doubled when a full batch is delivered
+// fast and halved when the delivery is slow or times out
+type batchWindow struct {
+	mu       sync.Mutex
+	size     uint
+	min, max uint
+}
+
+// newBatchWindow returns a batchWindow starting from BatchSize within the bounds
+func newBatchWindow(min, max uint) *batchWindow {
+	return &batchWindow{
+		size: boundBatchSize(BatchSize, min, max),
+		min:  min,
+		max:  max,
+	}
+}
+
+// get returns the batch size to request
+func (w *batchWindow) get() uint {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size
+}
+
+// delivered adapts the batch size to the duration of the delivery of a batch,
+// full indicates if the peer offered as many hashes as requested
+func (w *batchWindow) delivered(full bool, d time.Duration) {
+	switch {
+	case d > timeouts.SyncBatchTimeout/2:
+		w.shrink()
+	case full && d < timeouts.SyncBatchTimeout/10:
+		w.grow()
+	}
+}
+
+// timeout halves the batch size after a batch delivery timed out
+func (w *batchWindow) timeout() {
+	w.shrink()
+}
+
+func (w *batchWindow) grow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if size := boundBatchSize(w.size*2, w.min, w.max); size != w.size {
+		w.size = size
+		batchWindowGrowCount.Inc(1)
+	}
+}
+
+func (w *batchWindow) shrink() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if size := boundBatchSize(w.size/2, w.min, w.max); size != w.size {
+		w.size = size
+		batchWindowShrinkCount.Inc(1)
+	}
+}
+
+// boundBatchSize returns the batch size bounded by min and max
+func boundBatchSize(size, min, max uint) uint {
+	if size < min {
+		return min
+	}
+	if size > max {
+		return max
+	}
+	return size
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"testing"
+
+	"github.com/ethersphere/swarm/network/timeouts"
+)
+
+// TestOptionsDefaults tests that zero flow-control options get their
+// defaults and out of range values are bounded
+func TestOptionsDefaults(t *testing.T) {
+	o := Options{}.withDefaults()
+	if o.MinBatchSize != BatchSize/4 || o.MaxBatchSize != BatchSize*4 || o.MaxInFlightBatches != 1 {
+		t.Fatalf("got defaults %+v", o)
+	}
+	o = Options{MinBatchSize: 100, MaxBatchSize: 10, MaxInFlightBatches: 100}.withDefaults()
+	if o.MinBatchSize != 100 || o.MaxBatchSize != 100 || o.MaxInFlightBatches != maxInFlightBatches {
+		t.Fatalf("got bounded options %+v", o)
+	}
+}
+
+// TestBatchWindow tests that the batch size grows when full batches are
+// delivered fast, shrinks when they are slow or time out and stays
+// within its bounds
+func TestBatchWindow(t *testing.T) {
+	fast := timeouts.SyncBatchTimeout / 20
+	slow := timeouts.SyncBatchTimeout
+
+	w := newBatchWindow(16, 256)
+	if got := w.get(); got != BatchSize {
+		t.Fatalf("got initial batch size %v, want %v", got, BatchSize)
+	}
+	// batches not full do not tell about the capacity of the peer
+	w.delivered(false, fast)
+	if got := w.get(); got != BatchSize {
+		t.Fatalf("got batch size %v after partial batch, want %v", got, BatchSize)
+	}
+	for _, want := range []uint{128, 256, 256} {
+		w.delivered(true, fast)
+		if got := w.get(); got != want {
+			t.Fatalf("got batch size %v after fast batch, want %v", got, want)
+		}
+	}
+	w.delivered(true, slow)
+	if got := w.get(); got != 128 {
+		t.Fatalf("got batch size %v after slow batch, want 128", got)
+	}
+	// moderately fast deliveries keep the batch size
+	w.delivered(true, timeouts.SyncBatchTimeout/4)
+	if got := w.get(); got != 128 {
+		t.Fatalf("got batch size %v, want 128", got)
+	}
+	for _, want := range []uint{64, 32, 16, 16} {
+		w.timeout()
+		if got := w.get(); got != want {
+			t.Fatalf("got batch size %v after timeout, want %v", got, want)
+		}
+	}
+
+	// equal bounds fix the batch size
+	w = newBatchWindow(32, 32)
+	w.delivered(true, fast)
+	w.timeout()
+	if got := w.get(); got != 32 {
+		t.Fatalf("got fixed batch size %v, want 32", got)
+	}
+}
@@ -45,6 +45,12 @@ type Peer struct {
 	clientOpenGetRange map[string]uint   // maintain open GetRange requests to eliminate overlapping requests on the client side
 	serverOpenGetRange map[string]uint   // maintain open GetRange requests to eliminate overlapping requests on the server side
 
+	batchWindowsMu sync.Mutex
+	batchWindows   map[string]*batchWindow // key: Stream ID string representation, value: adaptive batch size requested on the stream
+
+	deliveryRatesMu sync.Mutex
+	deliveryRates   map[string]*deliveryRate // key: Stream ID string representation, value: rate of chunks delivered on the stream
+
 	quit chan struct{} // closed when peer is going offline
 }
 
@@ -59,6 +65,8 @@ func newPeer(peer *network.BzzPeer, baseAddress *network.BzzAddr, i state.Store,
 		openOffers:         make(map[uint]offer),
 		clientOpenGetRange: make(map[string]uint),
 		serverOpenGetRange: make(map[string]uint),
+		batchWindows:       make(map[string]*batchWindow),
+		deliveryRates:      make(map[string]*deliveryRate),
 		quit:               make(chan struct{}),
 		logger:             log.NewBaseAddressLogger(baseAddress.ShortString(), "peer", peer.BzzAddr.ShortString()),
 	}
@@ -104,6 +112,31 @@ func (p *Peer) deleteCursor(stream ID) {
 	delete(p.streamCursors, stream.String())
 }
 
+// batchWindow returns the batch window of the stream, creating it
+// with the batch size bounds of the options if it does not exist
+func (p *Peer) batchWindow(stream ID, o Options) *batchWindow {
+	p.batchWindowsMu.Lock()
+	defer p.batchWindowsMu.Unlock()
+
+	w, ok := p.batchWindows[stream.String()]
+	if !ok {
+		w = newBatchWindow(o.MinBatchSize, o.MaxBatchSize)
+		p.batchWindows[stream.String()] = w
+	}
+	return w
+}
+
+// historyInFlight returns the number of open wants for history batches of the stream
+// p.mtx must be held by the caller
+func (p *Peer) historyInFlight(stream ID) (n int) {
+	for _, w := range p.openWants {
+		if !w.head && w.stream == stream {
+			n++
+		}
+	}
+	return n
+}
+
 // InitProviders initializes a provider for a certain peer
 func (p *Peer) InitProviders() {
 	p.logger.Debug("peer.InitProviders")
@@ -130,6 +163,8 @@ type want struct {
 	from      uint64              // want from index
 	to        *uint64             // want to index, nil signifies top of range not yet known
 	head      bool                // is this the head of the stream? (bound versus tip of the stream; true is tip)
+	limit     uint64              // upper bound of the requested range, unlike to it is not updated by the offer
+	batchSize uint                // number of hashes requested in the batch
 	stream    ID                  // the stream id
 	hashes    map[string]struct{} // key: chunk address, value: wanted yes/no, used to prevent unsolicited chunks
 	requested time.Time           // requested at time
@@ -184,6 +219,12 @@ func (p *Peer) nextInterval(stream ID, ceil uint64) (start, end uint64, empty bo
 	if err != nil {
 		return 0, 0, false, err
 	}
+	// ranges of history batches in flight are not requested again
+	for _, w := range p.openWants {
+		if !w.head && w.stream == stream {
+			i.Add(w.from, w.limit)
+		}
+	}
 
 	start, end, empty = i.Next(ceil)
 	return start, end, empty, nil
@@ -224,11 +265,50 @@ func (p *Peer) getOrCreateInterval(key string) (*intervals.Intervals, error) {
 	return i, nil
 }
 
+// resetInterval clears the synced intervals of the stream
+func (p *Peer) resetInterval(stream ID) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.intervalsStore.Put(p.peerStreamIntervalKey(stream), intervals.NewIntervals(1))
+}
+
+// resumeStream checks the stream cursor received from the peer against the
+// one persisted in a previous session and persists the new one.
+// If the cursor regressed, the peer's stream restarted (e.g. its localstore was
+// reset) and the synced intervals no longer refer to the same chunks, so they
+// are cleared. Otherwise syncing resumes from the persisted intervals.
+func (p *Peer) resumeStream(stream ID, cursor uint64) error {
+	key := p.peerStreamCursorKey(stream)
+	var prev uint64
+	switch err := p.intervalsStore.Get(key, &prev); err {
+	case nil:
+		if cursor < prev {
+			p.logger.Debug("peer stream cursor regressed, resetting intervals", "stream", stream, "cursor", cursor, "persisted", prev)
+			if err := p.resetInterval(stream); err != nil {
+				return err
+			}
+		}
+	case state.ErrNotFound:
+	default:
+		return err
+	}
+	return p.intervalsStore.Put(key, cursor)
+}
+
 func (p *Peer) peerStreamIntervalKey(stream ID) string {
 	k := fmt.Sprintf("%s|%s", hex.EncodeToString(p.BzzAddr.OAddr), stream.String())
 	return k
 }
 
+// cursorKeyPrefix prefixes the keys of persisted stream cursors
+// to distinguish them from intervals in the same store
+const cursorKeyPrefix = "cursor|"
+
+func (p *Peer) peerStreamCursorKey(stream ID) string {
+	return cursorKeyPrefix + p.peerStreamIntervalKey(stream)
+}
+
 func (p *Peer) getRangeKey(id ID, head bool) string {
 	return fmt.Sprintf("%s_%t", id.String(), head)
 }
@@ -0,0 +1,114 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"testing"
+
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/state"
+)
+
+// TestPeerResumeStream tests that the stream cursors are persisted across
+// sessions with the peer, that synced intervals are kept if the cursor
+// advanced and reset if it regressed
+func TestPeerResumeStream(t *testing.T) {
+	store := state.NewInmemoryStore()
+	defer store.Close()
+
+	addr := network.RandomBzzAddr()
+	base := network.RandomBzzAddr()
+	stream := NewID(syncStreamName, encodeSyncKey(1))
+
+	// first session syncs [1, 100] and [201, 300] of the peer's stream
+	p := newPeer(&network.BzzPeer{BzzAddr: addr}, base, store, nil)
+	if _, err := p.getOrCreateInterval(p.peerStreamIntervalKey(stream)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.resumeStream(stream, 300); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.addInterval(stream, 1, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.addInterval(stream, 201, 300); err != nil {
+		t.Fatal(err)
+	}
+
+	// reconnect with the peer's cursor advanced
+	p = newPeer(&network.BzzPeer{BzzAddr: addr}, base, store, nil)
+	if err := p.resumeStream(stream, 400); err != nil {
+		t.Fatal(err)
+	}
+	// only the gap is requested, not the ranges synced in the first session
+	from, to, empty, err := p.nextInterval(stream, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 101 || to != 200 || empty {
+		t.Fatalf("got next interval [%v, %v] (empty %v), want [101, 200]", from, to, empty)
+	}
+	var cursor uint64
+	if err := store.Get(p.peerStreamCursorKey(stream), &cursor); err != nil {
+		t.Fatal(err)
+	}
+	if cursor != 400 {
+		t.Fatalf("got persisted cursor %v, want 400", cursor)
+	}
+
+	// reconnect with the peer's cursor regressed, e.g. after its store was reset
+	p = newPeer(&network.BzzPeer{BzzAddr: addr}, base, store, nil)
+	if err := p.resumeStream(stream, 50); err != nil {
+		t.Fatal(err)
+	}
+	from, to, empty, err = p.nextInterval(stream, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 1 || to != 50 || empty {
+		t.Fatalf("got next interval [%v, %v] (empty %v), want [1, 50]", from, to, empty)
+	}
+}
+
+// TestPeerNextIntervalInFlight tests that ranges of history batches in
+// flight are not requested again, while ranges of head batches are
+func TestPeerNextIntervalInFlight(t *testing.T) {
+	store := state.NewInmemoryStore()
+	defer store.Close()
+
+	stream := NewID(syncStreamName, encodeSyncKey(1))
+	p := newPeer(&network.BzzPeer{BzzAddr: network.RandomBzzAddr()}, network.RandomBzzAddr(), store, nil)
+	if _, err := p.getOrCreateInterval(p.peerStreamIntervalKey(stream)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.addInterval(stream, 1, 100); err != nil {
+		t.Fatal(err)
+	}
+	p.openWants[1] = &want{ruid: 1, stream: stream, from: 101, limit: 150}
+	p.openWants[2] = &want{ruid: 2, stream: stream, from: 151, head: true}
+
+	from, to, empty, err := p.nextInterval(stream, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 151 || to != 200 || empty {
+		t.Fatalf("got next interval [%v, %v] (empty %v), want [151, 200]", from, to, empty)
+	}
+	if n := p.historyInFlight(stream); n != 1 {
+		t.Fatalf("got %v history batches in flight, want 1", n)
+	}
+}
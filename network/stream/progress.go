@@ -0,0 +1,200 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network/stream/intervals"
+	"github.com/ethersphere/swarm/state"
+)
+
+// deliveryRateWindow is the period over which the rate of chunks
+// delivered on a stream is measured
+const deliveryRateWindow = 10 * time.Second
+
+// SyncProgress holds the pull sync progress of the node with its peers
+type SyncProgress struct {
+	Base  string             `json:"base"`  // our node's base address
+	Peers []PeerSyncProgress `json:"peers"` // progress with the connected peers ordered by address
+	Done  bool               `json:"done"`  // true if the history of all synced bins of all peers is synced
+}
+
+// PeerSyncProgress holds the pull sync progress with a connected peer
+type PeerSyncProgress struct {
+	Peer string            `json:"peer"` // the peer address
+	Bins []BinSyncProgress `json:"bins"` // progress of the bins synced from the peer ordered by bin
+	Done bool              `json:"done"` // true if the history of all bins synced from the peer is synced
+}
+
+// BinSyncProgress holds the pull sync progress of a bin synced from a peer
+type BinSyncProgress struct {
+	Bin             uint8   `json:"bin"`
+	Cursor          uint64  `json:"cursor"`          // the peer's top bin ID when syncing of the bin started, the end of the history
+	Synced          uint64  `json:"synced"`          // bin ID up to which the history is synced without gaps
+	Top             uint64  `json:"top"`             // highest bin ID synced from the peer, including the live stream
+	ChunksPerSecond float64 `json:"chunksPerSecond"` // chunks delivered by the peer per second in the last measured period
+	Done            bool    `json:"done"`            // true if the history of the bin is synced
+}
+
+// SyncProgress returns the progress of pull syncing the bins of the connected peers
+func (r *Registry) SyncProgress() (*SyncProgress, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	progress := &SyncProgress{
+		Base:  hex.EncodeToString(r.address.Over()),
+		Peers: make([]PeerSyncProgress, 0, len(r.peers)),
+		Done:  len(r.peers) > 0,
+	}
+	provider := r.providers[syncStreamName]
+	if provider == nil {
+		return progress, nil
+	}
+	now := time.Now()
+	for _, p := range r.peers {
+		p := p
+		pp, err := p.syncProgress(func(stream ID) bool {
+			return provider.WantStream(p, stream)
+		}, now)
+		if err != nil {
+			return nil, err
+		}
+		progress.Peers = append(progress.Peers, *pp)
+		progress.Done = progress.Done && pp.Done
+	}
+	sort.Slice(progress.Peers, func(i, j int) bool {
+		return progress.Peers[i].Peer < progress.Peers[j].Peer
+	})
+	return progress, nil
+}
+
+// syncProgress returns the progress of pull syncing the bins of the peer for
+// which a stream cursor is set, bins that are wanted but without a cursor
+// are reported as not synced
+func (p *Peer) syncProgress(want func(ID) bool, now time.Time) (*PeerSyncProgress, error) {
+	progress := &PeerSyncProgress{
+		Peer: hex.EncodeToString(p.OAddr),
+		Bins: make([]BinSyncProgress, 0),
+		Done: true,
+	}
+	for bin := uint8(0); bin <= chunk.MaxPO; bin++ {
+		stream := NewID(syncStreamName, encodeSyncKey(bin))
+		cursor, ok := p.getCursor(stream)
+		if !ok {
+			if want(stream) {
+				progress.Bins = append(progress.Bins, BinSyncProgress{Bin: bin})
+				progress.Done = false
+			}
+			continue
+		}
+		bp, err := p.binSyncProgress(stream, cursor, now)
+		if err != nil {
+			return nil, err
+		}
+		bp.Bin = bin
+		progress.Bins = append(progress.Bins, *bp)
+		progress.Done = progress.Done && bp.Done
+	}
+	return progress, nil
+}
+
+// binSyncProgress returns the progress of syncing the stream against the
+// peer's cursor from the intervals synced from the peer
+func (p *Peer) binSyncProgress(stream ID, cursor uint64, now time.Time) (*BinSyncProgress, error) {
+	progress := &BinSyncProgress{
+		Cursor:          cursor,
+		ChunksPerSecond: p.deliveryRate(stream).perSecond(now),
+	}
+	i := &intervals.Intervals{}
+	switch err := p.intervalsStore.Get(p.peerStreamIntervalKey(stream), i); err {
+	case nil:
+	case state.ErrNotFound:
+		// bin IDs start from 1, nothing is synced
+		i = intervals.NewIntervals(1)
+	default:
+		return nil, err
+	}
+	progress.Top = i.Last()
+	// the history is synced up to the start of the first gap
+	start, _, empty := i.Next(cursor)
+	if empty || start > cursor {
+		progress.Synced = cursor
+		progress.Done = true
+	} else if start > 0 {
+		progress.Synced = start - 1
+	}
+	if progress.Top < progress.Synced {
+		progress.Top = progress.Synced
+	}
+	return progress, nil
+}
+
+// deliveryRate returns the delivery rate of chunks on the stream,
+// creating it if it does not exist
+func (p *Peer) deliveryRate(stream ID) *deliveryRate {
+	p.deliveryRatesMu.Lock()
+	defer p.deliveryRatesMu.Unlock()
+
+	d, ok := p.deliveryRates[stream.String()]
+	if !ok {
+		d = new(deliveryRate)
+		p.deliveryRates[stream.String()] = d
+	}
+	return d
+}
+
+// deliveryRate measures the number of chunks delivered on a stream
+// per deliveryRateWindow
+type deliveryRate struct {
+	mu    sync.Mutex
+	start time.Time // start of the current window
+	count uint64    // chunks delivered in the current window
+	last  uint64    // chunks delivered in the previous window
+}
+
+// add records n chunks delivered at the time now
+func (d *deliveryRate) add(now time.Time, n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.rotate(now)
+	d.count += uint64(n)
+}
+
+// perSecond returns the rate of chunks delivered in the last complete window
+func (d *deliveryRate) perSecond(now time.Time) float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.rotate(now)
+	return float64(d.last) / deliveryRateWindow.Seconds()
+}
+
+// rotate moves the current window forward to contain the time now
+func (d *deliveryRate) rotate(now time.Time) {
+	switch elapsed := now.Sub(d.start); {
+	case elapsed >= 2*deliveryRateWindow:
+		d.start, d.count, d.last = now, 0, 0
+	case elapsed >= deliveryRateWindow:
+		d.start, d.count, d.last = d.start.Add(deliveryRateWindow), 0, d.count
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/simulation"
+	"github.com/ethersphere/swarm/state"
+)
+
+// TestDeliveryRate tests that the rate of delivered chunks
+// is measured over the last complete window
+func TestDeliveryRate(t *testing.T) {
+	now := time.Now()
+	d := new(deliveryRate)
+
+	d.add(now, 10)
+	d.add(now.Add(time.Second), 10)
+	if r := d.perSecond(now.Add(2 * time.Second)); r != 0 {
+		t.Fatalf("got rate %v in the first window, want 0", r)
+	}
+	if r := d.perSecond(now.Add(deliveryRateWindow)); r != 2 {
+		t.Fatalf("got rate %v, want 2", r)
+	}
+	d.add(now.Add(deliveryRateWindow+time.Second), 50)
+	if r := d.perSecond(now.Add(2*deliveryRateWindow + time.Second)); r != 5 {
+		t.Fatalf("got rate %v, want 5", r)
+	}
+	if r := d.perSecond(now.Add(4 * deliveryRateWindow)); r != 0 {
+		t.Fatalf("got rate %v after no deliveries, want 0", r)
+	}
+}
+
+// TestPeerSyncProgress tests the progress of syncing the bins of a peer
+// against the peer's cursors and that wanted bins without cursors are not synced
+func TestPeerSyncProgress(t *testing.T) {
+	store := state.NewInmemoryStore()
+	defer store.Close()
+
+	p := newPeer(&network.BzzPeer{BzzAddr: network.RandomBzzAddr()}, network.RandomBzzAddr(), store, nil)
+	for _, tc := range []struct {
+		bin       uint8
+		cursor    uint64
+		intervals [][2]uint64
+	}{
+		{bin: 1, cursor: 100, intervals: [][2]uint64{{1, 100}, {101, 130}}},
+		{bin: 2, cursor: 100, intervals: [][2]uint64{{1, 50}, {80, 120}}},
+		{bin: 3, cursor: 100},
+		{bin: 4, cursor: 0},
+	} {
+		stream := NewID(syncStreamName, encodeSyncKey(tc.bin))
+		p.setCursor(stream, tc.cursor)
+		if tc.intervals == nil {
+			continue
+		}
+		if _, err := p.getOrCreateInterval(p.peerStreamIntervalKey(stream)); err != nil {
+			t.Fatal(err)
+		}
+		for _, i := range tc.intervals {
+			if err := p.addInterval(stream, i[0], i[1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	progress, err := p.syncProgress(func(stream ID) bool {
+		return stream.Key == encodeSyncKey(5)
+	}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Done {
+		t.Fatal("got peer synced, want not synced")
+	}
+	want := []BinSyncProgress{
+		{Bin: 1, Cursor: 100, Synced: 100, Top: 130, Done: true},
+		{Bin: 2, Cursor: 100, Synced: 50, Top: 120},
+		{Bin: 3, Cursor: 100},
+		{Bin: 4, Done: true},
+		{Bin: 5},
+	}
+	if len(progress.Bins) != len(want) {
+		t.Fatalf("got %v bins, want %v", len(progress.Bins), len(want))
+	}
+	for i, b := range progress.Bins {
+		if b != want[i] {
+			t.Errorf("got bin progress %+v, want %+v", b, want[i])
+		}
+	}
+}
+
+// TestSyncProgressDone tests that a node reports over RPC that it is done
+// syncing after it synced all chunks from its peer
+func TestSyncProgressDone(t *testing.T) {
+	sim := simulation.NewBzzInProc(map[string]simulation.ServiceFunc{
+		"bzz-sync": newSyncSimServiceFunc(&SyncSimServiceOptions{Autostart: true}),
+	}, false)
+	defer sim.Close()
+
+	uploaderID, err := sim.AddNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustUploadChunks(context.Background(), t, nodeFileStore(sim, uploaderID), 500)
+
+	syncerID, err := sim.AddNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := sim.Net.GetNode(syncerID).Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress := new(SyncProgress)
+	if err := client.Call(progress, "stream_syncProgress"); err != nil {
+		t.Fatal(err)
+	}
+	if progress.Done {
+		t.Fatal("got synced without peers, want not synced")
+	}
+
+	if err := sim.Net.Connect(syncerID, uploaderID); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for {
+		progress = new(SyncProgress)
+		if err := client.Call(progress, "stream_syncProgress"); err != nil {
+			t.Fatal(err)
+		}
+		if progress.Done {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("sync not done: %+v", progress)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	if len(progress.Peers) != 1 || len(progress.Peers[0].Bins) == 0 {
+		t.Fatalf("got progress %+v, want one peer with synced bins", progress)
+	}
+	if err := waitChunks(nodeFileStore(sim, syncerID), 500, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
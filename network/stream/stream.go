@@ -81,7 +81,7 @@ var (
 	// Protocol spec
 	Spec = &protocols.Spec{
 		Name:       "bzz-stream",
-		Version:    8,
+		Version:    9,
 		MaxMsgSize: 10 * 1024 * 1024,
 		Messages: []interface{}{
 			StreamInfoReq{},
@@ -103,6 +103,7 @@ var (
 type Registry struct {
 	mtx                     sync.RWMutex
 	intervalsStore          state.Store               // store intervals for all peers
+	opts                    Options                   // flow-control parameters
 	peers                   map[enode.ID]*Peer        // peers
 	address                 *network.BzzAddr          // this node's base address
 	providers               map[string]StreamProvider // stream providers by name of stream
@@ -115,8 +116,18 @@ type Registry struct {
 
 // New creates a new stream protocol handler
 func New(intervalsStore state.Store, address *network.BzzAddr, providers ...StreamProvider) *Registry {
+	return NewWithOptions(intervalsStore, address, nil, providers...)
+}
+
+// NewWithOptions creates a new stream protocol handler
+// configured with the provided flow-control options
+func NewWithOptions(intervalsStore state.Store, address *network.BzzAddr, o *Options, providers ...StreamProvider) *Registry {
+	if o == nil {
+		o = new(Options)
+	}
 	r := &Registry{
 		intervalsStore: intervalsStore,
+		opts:           o.withDefaults(),
 		peers:          make(map[enode.ID]*Peer),
 		providers:      make(map[string]StreamProvider),
 		quit:           make(chan struct{}),
@@ -244,6 +255,11 @@ func (r *Registry) clientHandleStreamInfoRes(ctx context.Context, p *Peer, msg *
 			continue
 		}
 
+		// persist the cursor and check it against the previous session with the peer
+		if err := p.resumeStream(s.Stream, s.Cursor); err != nil {
+			return protocols.Break(fmt.Errorf("resuming stream %s: %w", s.Stream, err))
+		}
+
 		p.logger.Debug("setting stream cursor", "stream", s.Stream, "cursor", s.Cursor)
 		p.setCursor(s.Stream, s.Cursor)
 
@@ -289,52 +305,90 @@ func (r *Registry) clientRequestStreamHead(ctx context.Context, p *Peer, stream
 }
 
 // clientRequestStreamRange sends a GetRange message to the server requesting
-// a bound interval of chunks starting from the current stored interval in the
-// interval store and ending at most in the supplied cursor position
+// a bound interval of chunks filling the first gap in the interval store
+// and ending at most in the supplied cursor position, so that ranges synced
+// in previous sessions with the peer are not offered again
 func (r *Registry) clientRequestStreamRange(ctx context.Context, p *Peer, provider StreamProvider, stream ID, cursor uint64) error {
 	p.logger.Debug("clientRequestStreamRange", "stream", stream, "cursor", cursor)
 
-	// get the next interval from the intervals store
-	from, _, empty, err := p.nextInterval(stream, 0)
-	if err != nil {
-		return protocols.Break(err)
-	}
+	// request batches until the number of batches in flight on the stream is reached
+	for {
+		p.mtx.RLock()
+		n := p.historyInFlight(stream)
+		p.mtx.RUnlock()
+		if n >= r.opts.MaxInFlightBatches {
+			return nil
+		}
 
-	// nothing to do - the next interval is bigger than the cursor or theinterval is empty
-	if from > cursor || empty {
-		p.logger.Debug("peer.requestStreamRange stream finished", "stream", stream, "cursor", cursor)
-		return nil
+		// get the next interval from the intervals store
+		from, to, empty, err := p.nextInterval(stream, cursor)
+		if err != nil {
+			return protocols.Break(err)
+		}
+
+		// nothing to do - the next interval is bigger than the cursor or theinterval is empty
+		if from > cursor || empty {
+			p.logger.Debug("peer.requestStreamRange stream finished", "stream", stream, "cursor", cursor)
+			return nil
+		}
+		// with more batches in flight, each covers at most the batch size of
+		// the range, so that they do not overlap
+		if r.opts.MaxInFlightBatches > 1 {
+			if size := uint64(p.batchWindow(stream, r.opts).get()); to-from >= size {
+				to = from + size - 1
+			}
+		}
+		if err := r.clientCreateSendWant(ctx, p, stream, from, &to, false); err != nil {
+			return err
+		}
 	}
-	return r.clientCreateSendWant(ctx, p, stream, from, &cursor, false)
 }
 
 func (r *Registry) clientCreateSendWant(ctx context.Context, p *Peer, stream ID, from uint64, to *uint64, head bool) error {
+	// the batch size of history batches is adapted to the peer's responsiveness,
+	// head batches are offered as soon as they fill up and keep the default
+	batchSize := uint(BatchSize)
+	if !head {
+		batchSize = p.batchWindow(stream, r.opts).get()
+	}
 	g := GetRange{
 		Ruid:      uint(rand.Uint32()),
 		Stream:    stream,
 		From:      from,
 		To:        to,
-		BatchSize: BatchSize,
+		BatchSize: batchSize,
 	}
 
 	p.mtx.Lock()
 	s := p.getRangeKey(stream, head)
-	if v, ok := p.clientOpenGetRange[s]; ok {
-		p.logger.Warn("batch already requested, skipping", "stream", stream, "head", head, "from", from, "to", to, "existing ruid", v)
+	if head {
+		if v, ok := p.clientOpenGetRange[s]; ok {
+			p.logger.Warn("batch already requested, skipping", "stream", stream, "head", head, "from", from, "to", to, "existing ruid", v)
+			p.mtx.Unlock()
+			return nil
+		}
+		p.clientOpenGetRange[s] = g.Ruid
+	} else if n := p.historyInFlight(stream); n >= r.opts.MaxInFlightBatches {
+		p.logger.Debug("history batches in flight, skipping", "stream", stream, "from", from, "to", to, "in flight", n)
 		p.mtx.Unlock()
 		return nil
 	}
-	p.clientOpenGetRange[s] = g.Ruid
 
+	var limit uint64
+	if to != nil {
+		limit = *to
+	}
 	p.openWants[g.Ruid] = &want{
-		ruid:   g.Ruid,
-		stream: g.Stream,
-		from:   g.From,
-		to:     to,
-		head:   head,
-		hashes: make(map[string]struct{}),
-		chunks: make(chan chunk.Address),
-		closeC: make(chan error),
+		ruid:      g.Ruid,
+		stream:    g.Stream,
+		from:      g.From,
+		to:        to,
+		head:      head,
+		limit:     limit,
+		batchSize: g.BatchSize,
+		hashes:    make(map[string]struct{}),
+		chunks:    make(chan chunk.Address),
+		closeC:    make(chan error),
 
 		requested: time.Now(),
 	}
@@ -357,10 +411,25 @@ func (r *Registry) serverHandleGetRange(ctx context.Context, p *Peer, msg *GetRa
 	p.logger.Debug("serverHandleGetRange", "ruid", msg.Ruid, "head?", msg.To == nil)
 	p.mtx.Lock()
 	s := p.getRangeKey(msg.Stream, msg.To == nil)
-	if ruid, exists := p.serverOpenGetRange[s]; exists {
-		p.logger.Debug("stream request already ongoing, skipping", "ruid in flight", ruid)
-		p.mtx.Unlock()
-		return nil
+	if msg.To == nil {
+		if ruid, exists := p.serverOpenGetRange[s]; exists {
+			p.logger.Debug("stream request already ongoing, skipping", "ruid in flight", ruid)
+			p.mtx.Unlock()
+			return nil
+		}
+	} else {
+		// peers can have more history batches in flight on a stream
+		var n int
+		for k := range p.serverOpenGetRange {
+			if strings.HasPrefix(k, s+"_") {
+				n++
+			}
+		}
+		if n >= maxInFlightBatches {
+			p.mtx.Unlock()
+			return protocols.Break(fmt.Errorf("too many history batches in flight on stream %s: %d", msg.Stream, n))
+		}
+		s = fmt.Sprintf("%s_%d", s, msg.Ruid)
 	}
 	p.serverOpenGetRange[s] = msg.Ruid
 	p.mtx.Unlock()
@@ -384,7 +453,12 @@ func (r *Registry) serverHandleGetRange(ctx context.Context, p *Peer, msg *GetRa
 	if msg.To != nil {
 		to = *msg.To
 	}
-	h, _, t, e, err := r.serverCollectBatch(ctx, p, provider, key, msg.From, to)
+	// serve the requested batch size within the bounds, peers not specifying it get the default
+	batchSize := BatchSize
+	if msg.BatchSize > 0 {
+		batchSize = int(boundBatchSize(msg.BatchSize, 1, r.opts.MaxBatchSize))
+	}
+	h, _, t, e, err := r.serverCollectBatch(ctx, p, provider, key, msg.From, to, batchSize)
 	if err != nil {
 		return protocols.Break(fmt.Errorf("getting live batch for stream %s: %w", msg.Stream, err))
 	}
@@ -577,8 +651,15 @@ func (r *Registry) clientHandleOfferedHashes(ctx context.Context, p *Peer, msg *
 		if err := p.sealWant(w); err != nil {
 			return protocols.Break(fmt.Errorf("persisting interval from %d, to %d: %w", w.from, w.to, err))
 		}
+		// adapt the batch size to how fast the peer delivered the history batch
+		if !w.head {
+			p.batchWindow(w.stream, r.opts).delivered(uint(lenHashes/HashSize) >= w.batchSize, time.Since(start))
+		}
 	case <-time.After(timeouts.SyncBatchTimeout):
 		p.logger.Error("batch has timed out", "ruid", w.ruid)
+		if !w.head {
+			p.batchWindow(w.stream, r.opts).timeout()
+		}
 		close(w.closeC) // signal the polling goroutine to terminate
 		p.mtx.Lock()
 		delete(p.openWants, msg.Ruid)
@@ -770,6 +851,7 @@ func (r *Registry) clientHandleChunkDelivery(ctx context.Context, p *Peer, msg *
 	}
 
 	providerPutTimer.UpdateSince(startPut)
+	p.deliveryRate(w.stream).add(time.Now(), len(chunks))
 
 	// increment seen chunk delivery metric. duplicate delivery is possible when the same chunk is asked from multiple peers, we currently do not limit this
 	for _, v := range seen {
@@ -848,7 +930,7 @@ func (r *Registry) clientSealBatch(ctx context.Context, p *Peer, provider Stream
 
 // serverCollectBatch collects a batch of hashes in response for a GetRange message
 // it will block until at least one hash is received from the provider
-func (r *Registry) serverCollectBatch(ctx context.Context, p *Peer, provider StreamProvider, key interface{}, from, to uint64) (hashes []byte, f, t uint64, empty bool, err error) {
+func (r *Registry) serverCollectBatch(ctx context.Context, p *Peer, provider StreamProvider, key interface{}, from, to uint64, maxBatchSize int) (hashes []byte, f, t uint64, empty bool, err error) {
 	p.logger.Debug("serverCollectBatch", "from", from, "to", to)
 
 	var (
@@ -889,7 +971,7 @@ func (r *Registry) serverCollectBatch(ctx context.Context, p *Peer, provider Str
 				batchStartID = &d.BinID
 			}
 			batchEndID = d.BinID
-			if batchSize >= BatchSize {
+			if batchSize >= maxBatchSize {
 				iterate = false
 				metrics.GetOrRegisterCounter("network/stream/server_collect_batch/full-batch", nil).Inc(1)
 			}
@@ -1023,6 +1105,9 @@ func (r *Registry) PeerInfo() (*PeerInfo, error) {
 	}
 	info.Intervals = make(map[string]string)
 	if err := r.intervalsStore.Iterate("", func(key, value []byte) (stop bool, err error) {
+		if strings.HasPrefix(string(key), cursorKeyPrefix) {
+			return false, nil
+		}
 		i := new(intervals.Intervals)
 		if err := i.UnmarshalBinary(value); err != nil {
 			return true, err
@@ -1074,7 +1159,14 @@ func (r *Registry) runProtocol(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 }
 
 func (r *Registry) APIs() []rpc.API {
-	return nil
+	return []rpc.API{
+		{
+			Namespace: "stream",
+			Version:   "1.0",
+			Service:   NewAPI(r),
+			Public:    false,
+		},
+	}
 }
 
 func (r *Registry) Start(server *p2p.Server) error {
@@ -44,20 +44,44 @@ const (
 var (
 	setCacheMissCount = metrics.GetOrRegisterCounter("network/stream/sync_provider/set/cachemiss", nil)
 	setCacheHitCount  = metrics.GetOrRegisterCounter("network/stream/sync_provider/set/cachehit", nil)
+	// number of bins currently subscribed to over all peers, incremented and decremented as subscriptions change
+	subscribedBinsCount = metrics.GetOrRegisterCounter("network/stream/sync_provider/subscribed_bins", nil)
 )
 
+// syncBinsMode defines which bins syncing subscriptions are requested for with peers
+type syncBinsMode int
+
+const (
+	// syncBinsAll establishes streams on all bins as they did traditionally with Pull Sync
+	syncBinsAll syncBinsMode = iota
+	// syncBinsWithinDepth establishes streams only with peers within depth ( >=depth )
+	syncBinsWithinDepth
+	// syncBinsWithinRadius establishes streams only on bins that hold chunks within
+	// the storage radius (depth), so that chunks garbage collected right after
+	// they are synced are not requested
+	syncBinsWithinRadius
+)
+
+// syncBinsModeFor returns the syncBinsMode for the syncOnlyWithinDepth toggle
+func syncBinsModeFor(syncOnlyWithinDepth bool) syncBinsMode {
+	if syncOnlyWithinDepth {
+		return syncBinsWithinDepth
+	}
+	return syncBinsAll
+}
+
 type syncProvider struct {
-	netStore                *storage.NetStore // netstore
-	kad                     *network.Kademlia // kademlia
-	name                    string            // name of the stream we are responsible for
-	syncBinsOnlyWithinDepth bool              // true means streams are established only within depth, false means outside of depth too
-	autostart               bool              // start fetching streams automatically when cursors arrive from peer
-	quit                    chan struct{}     // shutdown
-	cacheMtx                sync.RWMutex      // synchronization primitive to protect cache
-	cache                   *lru.Cache        // cache to minimize load on netstore
-	setCacheMtx             sync.RWMutex      // set cache mutex
-	setCache                *lru.Cache        // cache to reduce load on localstore to not set the same chunk as synced
-	logger                  log.Logger        // logger that appends the base address to loglines
+	netStore    *storage.NetStore // netstore
+	kad         *network.Kademlia // kademlia
+	name        string            // name of the stream we are responsible for
+	syncBins    syncBinsMode      // which bins streams are established on
+	autostart   bool              // start fetching streams automatically when cursors arrive from peer
+	quit        chan struct{}     // shutdown
+	cacheMtx    sync.RWMutex      // synchronization primitive to protect cache
+	cache       *lru.Cache        // cache to minimize load on netstore
+	setCacheMtx sync.RWMutex      // set cache mutex
+	setCache    *lru.Cache        // cache to reduce load on localstore to not set the same chunk as synced
+	logger      log.Logger        // logger that appends the base address to loglines
 }
 
 // NewSyncProvider creates a new sync provider that is used by the stream protocol to sink data and control its behaviour
@@ -65,6 +89,19 @@ type syncProvider struct {
 // established only within depth ( >=depth ). This is needed for Push Sync. When set to false, the streams are
 // established on all bins as they did traditionally with Pull Sync.
 func NewSyncProvider(ns *storage.NetStore, kad *network.Kademlia, baseAddr *network.BzzAddr, autostart bool, syncOnlyWithinDepth bool) StreamProvider {
+	return newSyncProvider(ns, kad, baseAddr, autostart, syncBinsModeFor(syncOnlyWithinDepth))
+}
+
+// NewSyncProviderWithinRadius creates a new sync provider that establishes streams only
+// on bins holding chunks within the node's storage radius, the kademlia depth.
+// From peers within depth, bins from depth are synced, from peers outside of depth
+// only their bin at depth-1, as all of its chunks are within depth from the node.
+// Subscriptions are adjusted as depth changes.
+func NewSyncProviderWithinRadius(ns *storage.NetStore, kad *network.Kademlia, baseAddr *network.BzzAddr, autostart bool) StreamProvider {
+	return newSyncProvider(ns, kad, baseAddr, autostart, syncBinsWithinRadius)
+}
+
+func newSyncProvider(ns *storage.NetStore, kad *network.Kademlia, baseAddr *network.BzzAddr, autostart bool, mode syncBinsMode) *syncProvider {
 	c, err := lru.New(cacheCapacity)
 	if err != nil {
 		panic(err)
@@ -75,15 +112,15 @@ func NewSyncProvider(ns *storage.NetStore, kad *network.Kademlia, baseAddr *netw
 	}
 
 	return &syncProvider{
-		netStore:                ns,
-		kad:                     kad,
-		syncBinsOnlyWithinDepth: syncOnlyWithinDepth,
-		autostart:               autostart,
-		name:                    syncStreamName,
-		quit:                    make(chan struct{}),
-		cache:                   c,
-		setCache:                sc,
-		logger:                  log.NewBaseAddressLogger(baseAddr.ShortString()),
+		netStore:  ns,
+		kad:       kad,
+		syncBins:  mode,
+		autostart: autostart,
+		name:      syncStreamName,
+		quit:      make(chan struct{}),
+		cache:     c,
+		setCache:  sc,
+		logger:    log.NewBaseAddressLogger(baseAddr.ShortString()),
 	}
 }
 
@@ -123,7 +160,7 @@ func (s *syncProvider) NeedData(ctx context.Context, addrs ...chunk.Address) ([]
 	s.cacheMtx.RUnlock()
 
 	// check localstore for the remaining chunks
-	has, err := s.netStore.Store.HasMulti(ctx, check...)
+	has, err := s.netStore.HasMulti(ctx, check...)
 	if err != nil {
 		return nil, err
 	}
@@ -282,11 +319,14 @@ func (s *syncProvider) Cursor(k string) (cursor uint64, err error) {
 // WantStream checks if we are interested in a given stream for a peer
 func (s *syncProvider) WantStream(p *Peer, streamID ID) bool {
 	p.logger.Debug("syncProvider.WantStream", "stream", streamID)
+	if !network.ServesSync(p.BzzAddr) {
+		return false
+	}
 	po := chunk.Proximity(p.BzzAddr.Over(), s.kad.BaseAddr())
 	depth := s.kad.NeighbourhoodDepth()
 
 	// check all subscriptions that should exist for this peer
-	subBins, _ := syncSubscriptionsDiff(po, -1, depth, s.kad.MaxProxDisplay, s.syncBinsOnlyWithinDepth)
+	subBins, _ := syncSubscriptionsDiff(po, -1, depth, s.kad.MaxProxDisplay, s.syncBins)
 	v, err := parseSyncKey(streamID.Key)
 	if err != nil {
 		return false
@@ -307,6 +347,11 @@ var (
 // peer connects and disconnects quickly
 func (s *syncProvider) InitPeer(p *Peer) {
 	p.logger.Debug("syncProvider.InitPeer")
+	if !network.ServesSync(p.BzzAddr) {
+		// light nodes can declare that they do not serve sync streams
+		p.logger.Debug("syncProvider.InitPeer: skipping peer that does not serve sync")
+		return
+	}
 	timer := time.NewTimer(SyncInitBackoff)
 	defer timer.Stop()
 
@@ -321,9 +366,15 @@ func (s *syncProvider) InitPeer(p *Peer) {
 
 	p.logger.Debug("update syncing subscriptions: initial", "po", po, "depth", depth)
 
-	subBins, quitBins := syncSubscriptionsDiff(po, -1, depth, s.kad.MaxProxDisplay, s.syncBinsOnlyWithinDepth)
+	subBins, quitBins := syncSubscriptionsDiff(po, -1, depth, s.kad.MaxProxDisplay, s.syncBins)
 	s.updateSyncSubscriptions(p, subBins, quitBins)
 
+	subscribed := syncBinsCount(po, depth, s.kad.MaxProxDisplay, s.syncBins)
+	subscribedBinsCount.Inc(int64(subscribed))
+	defer func() {
+		subscribedBinsCount.Dec(int64(subscribed))
+	}()
+
 	depthChangeSignal, unsubscribeDepthChangeSignal := s.kad.SubscribeToNeighbourhoodDepthChange()
 	defer unsubscribeDepthChangeSignal()
 
@@ -336,10 +387,14 @@ func (s *syncProvider) InitPeer(p *Peer) {
 
 			// update subscriptions for this peer when depth changes
 			ndepth := s.kad.NeighbourhoodDepth()
-			subs, quits := syncSubscriptionsDiff(po, depth, ndepth, s.kad.MaxProxDisplay, s.syncBinsOnlyWithinDepth)
+			subs, quits := syncSubscriptionsDiff(po, depth, ndepth, s.kad.MaxProxDisplay, s.syncBins)
 			p.logger.Debug("update syncing subscriptions", "po", po, "depth", depth, "sub", subs, "quit", quits)
 			s.updateSyncSubscriptions(p, subs, quits)
 			depth = ndepth
+
+			n := syncBinsCount(po, depth, s.kad.MaxProxDisplay, s.syncBins)
+			subscribedBinsCount.Inc(int64(n - subscribed))
+			subscribed = n
 		case <-s.quit:
 			return
 		case <-p.quit:
@@ -389,10 +444,10 @@ func (s *syncProvider) updateSyncSubscriptions(p *Peer, subBins, quitBins []int)
 // be requested and the second one which subscriptions need to be quit. Argument
 // prevDepth with value less then 0 represents no previous depth, used for
 // initial syncing subscriptions.
-// syncBinsOnlyWithinDepth toggles between having requested streams only within depth(true)
-// or rather with the old stream establishing logic (false)
-func syncSubscriptionsDiff(peerPO, prevDepth, newDepth, max int, syncBinsOnlyWithinDepth bool) (subBins, quitBins []int) {
-	newStart, newEnd := syncBins(peerPO, newDepth, max, syncBinsOnlyWithinDepth)
+// mode toggles between having requested streams only within depth, only on bins
+// within the storage radius or rather with the old stream establishing logic
+func syncSubscriptionsDiff(peerPO, prevDepth, newDepth, max int, mode syncBinsMode) (subBins, quitBins []int) {
+	newStart, newEnd := syncBins(peerPO, newDepth, max, mode)
 	if prevDepth < 0 {
 		if newStart == -1 && newEnd == -1 {
 			return nil, nil
@@ -402,7 +457,15 @@ func syncSubscriptionsDiff(peerPO, prevDepth, newDepth, max int, syncBinsOnlyWit
 		return intRange(newStart, newEnd), nil
 	}
 
-	prevStart, prevEnd := syncBins(peerPO, prevDepth, max, syncBinsOnlyWithinDepth)
+	prevStart, prevEnd := syncBins(peerPO, prevDepth, max, mode)
+	if prevStart == -1 && prevEnd == -1 {
+		// the peer moved into depth, no streams were established
+		// on the previous depth, request the complete range
+		if newStart == -1 && newEnd == -1 {
+			return nil, nil
+		}
+		return intRange(newStart, newEnd), nil
+	}
 	if newStart == -1 && newEnd == -1 {
 		// this means that we should not have any streams on any bins with this peer
 		// get rid of what was established on the previous depth
@@ -433,13 +496,18 @@ func syncSubscriptionsDiff(peerPO, prevDepth, newDepth, max int, syncBinsOnlyWit
 // subscriptions need to be requested, based on peer proximity and
 // kademlia neighbourhood depth. Returned range is [start,end), inclusive for
 // start and exclusive for end.
-// syncBinsOnlyWithinDepth toggles between having requested streams only within depth(true)
-// or rather with the old stream establishing logic (false)
-func syncBins(peerPO, depth, max int, syncBinsOnlyWithinDepth bool) (start, end int) {
-	if syncBinsOnlyWithinDepth && peerPO < depth {
+// mode toggles between having requested streams only within depth, only on bins
+// within the storage radius or rather with the old stream establishing logic
+func syncBins(peerPO, depth, max int, mode syncBinsMode) (start, end int) {
+	if mode == syncBinsWithinDepth && peerPO < depth {
 		// we don't want to request anything from peers outside depth
 		return -1, -1
 	}
+	if mode == syncBinsWithinRadius && peerPO < depth-1 {
+		// chunks in bin peerPO of the peer are at least peerPO+1 from us,
+		// only bin depth-1 of peers outside depth is entirely within the radius
+		return -1, -1
+	}
 	if peerPO < depth {
 		// subscribe only to peerPO bin if it is not
 		// in the nearest neighbourhood
@@ -450,6 +518,13 @@ func syncBins(peerPO, depth, max int, syncBinsOnlyWithinDepth bool) (start, end
 	return depth, max + 1
 }
 
+// syncBinsCount returns the number of bins syncing subscriptions are
+// requested for with a peer, see syncBins
+func syncBinsCount(peerPO, depth, max int, mode syncBinsMode) int {
+	start, end := syncBins(peerPO, depth, max, mode)
+	return end - start
+}
+
 // intRange returns the slice of integers [start,end). The start
 // is inclusive and the end is not.
 func intRange(start, end int) (r []int) {
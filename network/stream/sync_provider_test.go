@@ -178,8 +178,18 @@ func TestSyncSubscriptionsDiff(t *testing.T) {
 			quitBins:                []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
 			syncBinsOnlyWithinDepth: true,
 		},
+		{
+			po: 5, prevDepth: 8, newDepth: 5, // [] -> 5-16
+			subBins:                 []int{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			syncBinsOnlyWithinDepth: true,
+		},
+		{
+			po: 5, prevDepth: 8, newDepth: 3, // [] -> 3-16
+			subBins:                 []int{3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			syncBinsOnlyWithinDepth: true,
+		},
 	} {
-		subBins, quitBins := syncSubscriptionsDiff(tc.po, tc.prevDepth, tc.newDepth, max, tc.syncBinsOnlyWithinDepth)
+		subBins, quitBins := syncSubscriptionsDiff(tc.po, tc.prevDepth, tc.newDepth, max, syncBinsModeFor(tc.syncBinsOnlyWithinDepth))
 		if fmt.Sprint(subBins) != fmt.Sprint(tc.subBins) {
 			t.Errorf("po: %v, prevDepth: %v, newDepth: %v, syncBinsOnlyWithinDepth: %t: got subBins %v, want %v", tc.po, tc.prevDepth, tc.newDepth, tc.syncBinsOnlyWithinDepth, subBins, tc.subBins)
 		}
@@ -188,3 +198,72 @@ func TestSyncSubscriptionsDiff(t *testing.T) {
 		}
 	}
 }
+
+// TestSyncSubscriptionsDiffWithinRadius validates the output of syncSubscriptionsDiff
+// function for syncing only bins within the storage radius
+func TestSyncSubscriptionsDiffWithinRadius(t *testing.T) {
+	max := network.NewKadParams().MaxProxDisplay
+	for _, tc := range []struct {
+		po, prevDepth, newDepth int
+		subBins, quitBins       []int
+	}{
+		{
+			po: 3, prevDepth: -1, newDepth: 6, // [] -> []
+		},
+		{
+			po: 5, prevDepth: -1, newDepth: 6, // [] -> 5
+			subBins: []int{5},
+		},
+		{
+			po: 9, prevDepth: -1, newDepth: 6, // [] -> 6-16
+			subBins: []int{6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		},
+		{
+			po: 5, prevDepth: 6, newDepth: 7, // 5 -> []
+			quitBins: []int{5},
+		},
+		{
+			po: 5, prevDepth: 7, newDepth: 6, // [] -> 5
+			subBins: []int{5},
+		},
+		{
+			po: 5, prevDepth: 6, newDepth: 5, // 5 -> 5-16
+			subBins: []int{6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		},
+		{
+			po: 9, prevDepth: 6, newDepth: 10, // 6-16 -> 9
+			quitBins: []int{6, 7, 8, 10, 11, 12, 13, 14, 15, 16},
+		},
+	} {
+		subBins, quitBins := syncSubscriptionsDiff(tc.po, tc.prevDepth, tc.newDepth, max, syncBinsWithinRadius)
+		if fmt.Sprint(subBins) != fmt.Sprint(tc.subBins) {
+			t.Errorf("po: %v, prevDepth: %v, newDepth: %v: got subBins %v, want %v", tc.po, tc.prevDepth, tc.newDepth, subBins, tc.subBins)
+		}
+		if fmt.Sprint(quitBins) != fmt.Sprint(tc.quitBins) {
+			t.Errorf("po: %v, prevDepth: %v, newDepth: %v: got quitBins %v, want %v", tc.po, tc.prevDepth, tc.newDepth, quitBins, tc.quitBins)
+		}
+	}
+}
+
+// TestSyncBinsCount validates the number of bins subscribed to with a peer
+func TestSyncBinsCount(t *testing.T) {
+	max := network.NewKadParams().MaxProxDisplay
+	for _, tc := range []struct {
+		po, depth int
+		mode      syncBinsMode
+		want      int
+	}{
+		{po: 2, depth: 5, mode: syncBinsAll, want: 1},
+		{po: 2, depth: 5, mode: syncBinsWithinDepth, want: 0},
+		{po: 2, depth: 5, mode: syncBinsWithinRadius, want: 0},
+		{po: 4, depth: 5, mode: syncBinsWithinRadius, want: 1},
+		{po: 5, depth: 5, mode: syncBinsAll, want: max - 4},
+		{po: 9, depth: 5, mode: syncBinsWithinDepth, want: max - 4},
+		{po: 9, depth: 5, mode: syncBinsWithinRadius, want: max - 4},
+		{po: 9, depth: 0, mode: syncBinsWithinDepth, want: max + 1},
+	} {
+		if got := syncBinsCount(tc.po, tc.depth, max, tc.mode); got != tc.want {
+			t.Errorf("po: %v, depth: %v, mode: %v: got %v, want %v", tc.po, tc.depth, tc.mode, got, tc.want)
+		}
+	}
+}
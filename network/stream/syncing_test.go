@@ -21,13 +21,12 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
-	"io/ioutil"
-	"os"
 	"runtime"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethersphere/swarm/chunk"
@@ -36,6 +35,7 @@ import (
 	"github.com/ethersphere/swarm/network/simulation"
 	"github.com/ethersphere/swarm/p2p/protocols"
 	"github.com/ethersphere/swarm/pot"
+	"github.com/ethersphere/swarm/state"
 	"github.com/ethersphere/swarm/storage"
 	"github.com/ethersphere/swarm/storage/localstore"
 	"github.com/ethersphere/swarm/testutil"
@@ -260,6 +260,56 @@ func TestThreeNodesUnionHistoricalSync(t *testing.T) {
 	}
 }
 
+// TestThreeNodesUnionHistoricalSyncInFlightBatches checks that nodes sync
+// all chunks with more small history batches in flight on each stream
+func TestThreeNodesUnionHistoricalSyncInFlightBatches(t *testing.T) {
+	nodes := 3
+	chunkCount := 1000
+	sim := simulation.NewBzzInProc(map[string]simulation.ServiceFunc{
+		"bzz-sync": newSyncSimServiceFunc(&SyncSimServiceOptions{
+			Autostart: true,
+			StreamConstructorFunc: func(s state.Store, b *network.BzzAddr, p ...StreamProvider) node.Service {
+				return NewWithOptions(s, b, &Options{
+					MinBatchSize:       8,
+					MaxBatchSize:       16,
+					MaxInFlightBatches: 4,
+				}, p...)
+			},
+		}),
+	}, false)
+	defer sim.Close()
+	union := make(map[string]struct{})
+	nodeIDs := []enode.ID{}
+	for i := 0; i < nodes; i++ {
+		node, err := sim.AddNode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodeIDs = append(nodeIDs, node)
+		nodeStore := sim.MustNodeItem(node, bucketKeyFileStore).(*storage.FileStore)
+		mustUploadChunks(context.Background(), t, nodeStore, uint64(chunkCount))
+
+		uploadedChunks, err := getChunks(nodeStore.ChunkStore)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k := range uploadedChunks {
+			union[k] = struct{}{}
+		}
+	}
+
+	err := sim.Net.ConnectNodesFull(nodeIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range nodeIDs {
+		nodeStore := sim.MustNodeItem(n, bucketKeyFileStore).(*storage.FileStore)
+		if err := waitChunks(nodeStore, uint64(len(union)), 10*time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 // TestFullSync performs a series of subtests where a number of nodes are
 // connected to the single (chunk uploading) node.
 func TestFullSync(t *testing.T) {
@@ -809,17 +859,6 @@ type chunkProxData struct {
 }
 
 func getAllRefs(testData []byte) (storage.AddressCollection, error) {
-	datadir, err := ioutil.TempDir("", "chunk-debug")
-	if err != nil {
-		return nil, fmt.Errorf("unable to create temp dir: %v", err)
-	}
-	defer os.RemoveAll(datadir)
-	fileStore, cleanup, err := storage.NewLocalFileStore(datadir, make([]byte, 32), chunk.NewTags())
-	if err != nil {
-		return nil, err
-	}
-	defer cleanup()
-
-	reader := bytes.NewReader(testData)
-	return fileStore.GetAllReferences(context.Background(), reader)
+	fileStore := storage.NewFileStore(&storage.FakeChunkStore{}, &storage.FakeChunkStore{}, storage.NewFileStoreParams(), chunk.NewTags())
+	return fileStore.References(context.Background(), bytes.NewReader(testData))
 }
@@ -16,6 +16,12 @@ var FetcherSlowChunkDeliveryThreshold = 5 * time.Second
 // SearchTimeout is the max time requests wait for a peer to deliver a chunk, after which another peer is tried
 var SearchTimeout = 1500 * time.Millisecond
 
+// MissingChunkTTL is the time a chunk that was not found on the network is not requested again
+var MissingChunkTTL = 5 * time.Second
+
+// FallbackTimeout is the max time a chunk is retrieved from the network before the NetStore fallback is used
+var FallbackTimeout = 5 * time.Second
+
 // SyncerClientWaitTimeout is the max time a syncer client waits for a chunk to be delivered during syncing
 var SyncerClientWaitTimeout = 20 * time.Second
 
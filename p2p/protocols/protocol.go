@@ -321,11 +321,10 @@ func (p *Peer) Send(ctx context.Context, msg interface{}) error {
 		if err := p.spec.Hook.Apply(p, costToLocalNode, uint32(size)); err != nil {
 			return err
 		}
-	} else {
-		err = p2p.Send(p.rw, code, wmsg)
+		return nil
 	}
 
-	return nil
+	return p2p.Send(p.rw, code, wmsg)
 }
 
 // SetMsgPauser sets message pauser for this peer
@@ -379,6 +379,22 @@ func TestNoHook(t *testing.T) {
 	}
 }
 
+// TestNoHookSendError tests that the error of writing a message
+// is returned if the hook is not defined
+func TestNoHookSendError(t *testing.T) {
+	spec := createTestSpec()
+	id := adapters.RandomNodeConfig().ID
+	p := p2p.NewPeer(id, "testPeer", nil)
+	rw, remoteRW := p2p.MsgPipe()
+	remoteRW.Close()
+	peer := NewPeer(p, rw, spec)
+
+	err := peer.Send(context.TODO(), &perBytesMsgSenderPays{Content: "testBalance"})
+	if err != p2p.ErrPipeClosed {
+		t.Fatalf("got error %v, want %v", err, p2p.ErrPipeClosed)
+	}
+}
+
 func TestPeer_Receive(t *testing.T) {
 	t.Run("OK", func(t *testing.T) {
 		rw := &dummyRW{}
@@ -17,10 +17,14 @@
 package pushsync
 
 import (
+	"bytes"
+	"crypto/ecdsa"
 	"crypto/rand"
+	"errors"
 	"io"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -50,8 +54,10 @@ type chunkMsg struct {
 // it is currently a notification only (contains no proof) sent to the originator
 // Nonce is there to make multiple responses immune to deduplication cache
 type receiptMsg struct {
-	Addr  []byte // chunk address
-	Nonce []byte // nonce to make multiple instances of send immune to deduplication cache
+	Addr      []byte // chunk address
+	Nonce     []byte // nonce to make multiple instances of send immune to deduplication cache
+	Storer    []byte // overlay address of the storer, empty if the storer does not sign receipts
+	Signature []byte // storer signature over the chunk address
 }
 
 func decodeChunkMsg(msg []byte) (*chunkMsg, error) {
@@ -74,6 +80,41 @@ func decodeReceiptMsg(msg []byte) (*receiptMsg, error) {
 
 // newNonce creates a random nonce;
 // even without POC it is important otherwise resending a chunk is deduplicated by pss
+// errInvalidReceiptSignature is returned if the receipt is not signed
+// by the storer it was sent by
+var errInvalidReceiptSignature = errors.New("invalid receipt signature")
+
+// signReceipt sets the storer overlay address and its signature over the
+// chunk address, which is the proof of custody sent in receipts
+func signReceipt(rmsg *receiptMsg, key *ecdsa.PrivateKey) error {
+	sig, err := crypto.Sign(crypto.Keccak256(rmsg.Addr), key)
+	if err != nil {
+		return err
+	}
+	rmsg.Storer = overlayAddr(&key.PublicKey)
+	rmsg.Signature = sig
+	return nil
+}
+
+// verifyReceipt returns errInvalidReceiptSignature if the receipt
+// is not signed by the storer it was sent by
+func verifyReceipt(rmsg *receiptMsg) error {
+	pub, err := crypto.SigToPub(crypto.Keccak256(rmsg.Addr), rmsg.Signature)
+	if err != nil {
+		return errInvalidReceiptSignature
+	}
+	if !bytes.Equal(overlayAddr(pub), rmsg.Storer) {
+		return errInvalidReceiptSignature
+	}
+	return nil
+}
+
+// overlayAddr returns the overlay address of the node with the public key,
+// as derived by network.PrivateKeyToBzzKey
+func overlayAddr(pub *ecdsa.PublicKey) []byte {
+	return crypto.Keccak256(crypto.FromECDSAPub(pub))
+}
+
 func newNonce() []byte {
 	buf := make([]byte, 32)
 	io.ReadFull(rand.Reader, buf)
@@ -17,13 +17,16 @@
 package pushsync
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
 	"encoding/binary"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
 )
@@ -43,6 +46,7 @@ func TestProtocol(t *testing.T) {
 
 	// set up a number of storers
 	storers := make([]*Storer, storerCnt)
+	keys := make([]*ecdsa.PrivateKey, storerCnt)
 	for i := 0; i < storerCnt; i++ {
 		// every chunk is closest to exactly one storer
 		j := i
@@ -51,7 +55,12 @@ func TestProtocol(t *testing.T) {
 			log.Debug("closest node?", "n", n, "n%storerCnt", n%storerCnt, "storer", j)
 			return n%storerCnt == j
 		}
-		storers[j] = NewStorer(&testStore{store}, &testPubSub{lb, isClosestTo})
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[j] = key
+		storers[j] = NewStorer(&testStore{store}, &testPubSub{lb, isClosestTo}, key)
 	}
 
 	tags, tagIDs := setupTags(chunkCnt, tagCnt)
@@ -60,7 +69,7 @@ func TestProtocol(t *testing.T) {
 	// isClosestTo function mocked
 	isClosestTo := func([]byte) bool { return false }
 	// start push syncing in a go routine
-	p := NewPusher(tp, &testPubSub{lb, isClosestTo}, tags)
+	p := NewPusher(tp, &testPubSub{lb, isClosestTo}, tags, nil)
 	defer p.Close()
 
 	synced := make(map[int]int)
@@ -88,12 +97,33 @@ func TestProtocol(t *testing.T) {
 				if cnt := *(v.(*uint32)); cnt < uint32(storerCnt) {
 					t.Fatalf("chunk %v expected to be saved at least %v times, got %v", i, storerCnt, cnt)
 				}
+				checkReceipt(t, i, tagIDs, tags, keys)
 			}
 			return
 		}
 	}
 }
 
+// checkReceipt checks that the receipt of the storer closest to the chunk
+// with the index is recorded on the tag of the chunk
+func checkReceipt(t *testing.T, i uint64, tagIDs []uint32, tags *chunk.Tags, keys []*ecdsa.PrivateKey) {
+	t.Helper()
+	tag, _ := tags.Get(tagIDs[int(i)%len(tagIDs)])
+	if tag == nil {
+		return
+	}
+	addr := make([]byte, 32)
+	binary.BigEndian.PutUint64(addr, i)
+	r := tag.Receipt(addr)
+	if r == nil {
+		t.Fatalf("no receipt for chunk %v", i)
+	}
+	storer := keys[int(i)%len(keys)]
+	if want := crypto.Keccak256(crypto.FromECDSAPub(&storer.PublicKey)); !bytes.Equal(r.Storer, want) {
+		t.Fatalf("chunk %v: got receipt from storer %x, want %x", i, r.Storer, want)
+	}
+}
+
 type testStore struct {
 	store *sync.Map
 }
@@ -111,3 +141,36 @@ func (t *testStore) Put(_ context.Context, _ chunk.ModePut, chs ...chunk.Chunk)
 	}
 	return exists, nil
 }
+
+// TestReceiptSignature tests that signed receipts verify and that receipts
+// with a storer not matching the signature are rejected
+func TestReceiptSignature(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rmsg := &receiptMsg{Addr: make([]byte, 32), Nonce: newNonce()}
+	if err := signReceipt(rmsg, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyReceipt(rmsg); err != nil {
+		t.Fatalf("expected valid receipt, got %v", err)
+	}
+
+	forged := *rmsg
+	forged.Storer = overlayAddr(&other.PublicKey)
+	if err := verifyReceipt(&forged); err != errInvalidReceiptSignature {
+		t.Fatalf("expected %v, got %v", errInvalidReceiptSignature, err)
+	}
+
+	forged = *rmsg
+	forged.Addr = make([]byte, 32)
+	forged.Addr[0] = 1
+	if err := verifyReceipt(&forged); err != errInvalidReceiptSignature {
+		t.Fatalf("expected %v, got %v", errInvalidReceiptSignature, err)
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/spancontext"
+	"github.com/ethersphere/swarm/state"
 	"github.com/ethersphere/swarm/storage"
 	"github.com/opentracing/opentracing-go"
 	olog "github.com/opentracing/opentracing-go/log"
@@ -55,19 +56,22 @@ type Pusher struct {
 	pushedMu       sync.Mutex
 	syncedAddrs    []storage.Address
 	syncedAddrsMu  sync.Mutex
-	receipts       chan []byte // channel to receive receipts
-	ps             PubSub      // PubSub interface to send chunks and receive receipts
-	logger         log.Logger  // custom logger
+	retries        *retryQueue         // chunks not acknowledged in time scheduled for re-push
+	receipts       chan *chunk.Receipt // channel to receive receipts
+	ps             PubSub              // PubSub interface to send chunks and receive receipts
+	logger         log.Logger          // custom logger
 }
 
 // pushedItem captures the info needed for the pusher about a chunk during the
 // push-sync--receipt roundtrip
 type pushedItem struct {
-	tag      *chunk.Tag       // tag for the chunk
-	shortcut bool             // if the chunk receipt was sent by self
-	sentAt   time.Time        // first sent at time
-	synced   bool             // set when chunk got synced
-	span     opentracing.Span // roundtrip span
+	tag        *chunk.Tag       // tag for the chunk
+	shortcut   bool             // if the chunk receipt was sent by self
+	sentAt     time.Time        // first sent at time
+	lastSentAt time.Time        // last sent at time
+	sent       bool             // set when chunk got handed to the network or stored by self as closest
+	synced     bool             // set when chunk got synced
+	span       opentracing.Span // roundtrip span
 }
 
 // NewPusher constructs a Pusher and starts up the push sync protocol
@@ -75,7 +79,12 @@ type pushedItem struct {
 // - a DB interface to subscribe to push sync index to allow iterating over recently stored chunks
 // - a pubsub interface to send chunks and receive statements of custody
 // - tags that hold the tags
-func NewPusher(store DB, ps PubSub, tags *chunk.Tags) *Pusher {
+// - a state store to persist the re-push schedule of chunks across restarts, nil keeps it in memory
+func NewPusher(store DB, ps PubSub, tags *chunk.Tags, stateStore state.Store) *Pusher {
+	retries, err := newRetryQueue(stateStore)
+	if err != nil {
+		log.Error("pushsync: error loading retry queue", "err", err)
+	}
 	p := &Pusher{
 		store:          store,
 		tags:           tags,
@@ -83,7 +92,8 @@ func NewPusher(store DB, ps PubSub, tags *chunk.Tags) *Pusher {
 		closedChunks:   make(chan struct{}),
 		closedReceipts: make(chan struct{}),
 		pushed:         make(map[string]*pushedItem),
-		receipts:       make(chan []byte),
+		retries:        retries,
+		receipts:       make(chan *chunk.Receipt),
 		ps:             ps,
 		logger:         log.New("self", label(ps.BaseAddr())),
 	}
@@ -111,8 +121,8 @@ func (p *Pusher) Close() {
 
 // sync starts a forever loop that pushes chunks to their neighbourhood
 // and receives receipts (statements of custody) for them.
-// chunks that are not acknowledged with a receipt are retried
-// not earlier than retryInterval after they were last pushed (this is NOT done yet, still TODO)
+// chunks that are not acknowledged with a receipt within retryInterval are
+// re-pushed with exponential backoff, see retryQueue
 // the routine also updates counts of states on a tag in order
 // to monitor the proportion of saved, sent and synced chunks of
 // a file or collection
@@ -157,7 +167,13 @@ func (p *Pusher) chunksWorker() {
 			if err := p.sendChunkMsg(ch); err != nil {
 				metrics.GetOrRegisterCounter("pusher/send-chunk-msg/err", nil).Inc(1)
 				p.logger.Error("error sending chunk", "addr", ch.Address().Hex(), "err", err)
+				break
 			}
+			p.pushedMu.Lock()
+			if item, found := p.pushed[ch.Address().Hex()]; found {
+				item.setSent()
+			}
+			p.pushedMu.Unlock()
 
 			// retry interval timer triggers starting from new
 		case <-timer.C:
@@ -184,8 +200,10 @@ func (p *Pusher) chunksWorker() {
 
 				// delete from pushed item
 				p.pushedMu.Lock()
+				hexaddrs := make([]string, len(syncedAddrs))
 				for i := 0; i < len(syncedAddrs); i++ {
 					hexaddr := syncedAddrs[i].Hex()
+					hexaddrs[i] = hexaddr
 					item, found := p.pushed[hexaddr]
 					if found && item.tag != nil && item.tag.Done(chunk.StateSynced) {
 						p.logger.Debug("closing root span for tag", "taguid", item.tag.Uid, "tagname", item.tag.Name)
@@ -194,6 +212,11 @@ func (p *Pusher) chunksWorker() {
 
 					delete(p.pushed, hexaddr)
 				}
+				// synced chunks need no more re-push
+				if err := p.retries.remove(hexaddrs...); err != nil {
+					log.Error("pushsync: error removing chunks from retry queue", "err", err)
+				}
+				metrics.GetOrRegisterGauge("pusher/retry/queue", nil).Update(int64(p.retries.size()))
 				p.pushedMu.Unlock()
 
 				// we don't want to record the first iteration
@@ -234,7 +257,8 @@ func (p *Pusher) receiptsWorker() {
 	for {
 		select {
 		// handle incoming receipts
-		case addr := <-p.receipts:
+		case receipt := <-p.receipts:
+			addr := receipt.Address
 			hexaddr := hex.EncodeToString(addr)
 			p.logger.Trace("got receipt", "addr", hexaddr)
 			metrics.GetOrRegisterCounter("pusher/receipts/all", nil).Inc(1)
@@ -253,9 +277,21 @@ func (p *Pusher) receiptsWorker() {
 				break
 			}
 
+			// the receipt acknowledges that the chunk reached its neighbourhood
+			// even if sending it was not recorded
+			p.pushedMu.Lock()
+			item.setSent()
+			p.pushedMu.Unlock()
+
 			if item.tag != nil {
 				// finish span for pushsync roundtrip, only have this span if we have a tag
 				item.span.Finish()
+				// record the proof of custody of the storer against the upload tag
+				if receipt.Signature != nil {
+					item.tag.AddReceipt(receipt)
+				}
+				// increment SYNCED count on tag, once per chunk acknowledged by a receipt
+				item.tag.Inc(chunk.StateSynced)
 			}
 
 			totalDuration := time.Since(item.sentAt)
@@ -275,22 +311,36 @@ func (p *Pusher) receiptsWorker() {
 }
 
 // handleReceiptMsg is a handler for pssReceiptTopic that
-// - deserialises receiptMsg and
-// - sends the receipted address on a channel
+// - deserialises receiptMsg,
+// - verifies the storer signature, if the receipt is signed, and
+// - sends the receipt on a channel
+// receipts with invalid signatures are dropped, so that the chunk is pushed again
 func (p *Pusher) handleReceiptMsg(msg []byte) error {
-	receipt, err := decodeReceiptMsg(msg)
+	rmsg, err := decodeReceiptMsg(msg)
 	if err != nil {
 		return err
 	}
-	p.logger.Trace("handleReceiptMsg", "receipt", hex.EncodeToString(receipt.Addr))
-	go p.pushReceipt(receipt.Addr)
+	p.logger.Trace("handleReceiptMsg", "receipt", hex.EncodeToString(rmsg.Addr))
+	receipt := &chunk.Receipt{
+		Address: rmsg.Addr,
+	}
+	if len(rmsg.Storer) > 0 || len(rmsg.Signature) > 0 {
+		if err := verifyReceipt(rmsg); err != nil {
+			metrics.GetOrRegisterCounter("pusher/receipts/invalid", nil).Inc(1)
+			p.logger.Debug("dropping receipt", "addr", hex.EncodeToString(rmsg.Addr), "storer", label(rmsg.Storer), "err", err)
+			return nil
+		}
+		receipt.Storer = rmsg.Storer
+		receipt.Signature = rmsg.Signature
+	}
+	go p.pushReceipt(receipt)
 	return nil
 }
 
-// pushReceipt just inserts the address into the channel
-func (p *Pusher) pushReceipt(addr []byte) {
+// pushReceipt just inserts the receipt into the channel
+func (p *Pusher) pushReceipt(receipt *chunk.Receipt) {
 	select {
-	case p.receipts <- addr:
+	case p.receipts <- receipt:
 	case <-p.quit:
 	}
 }
@@ -319,15 +369,16 @@ func (p *Pusher) sendChunkMsg(ch chunk.Chunk) error {
 }
 
 // needToSync checks if a chunk needs to be push-synced:
-// * if not sent yet OR
-// * if sent but more than retryInterval ago, so need resend OR
+// * if not sent yet (and not scheduled for later re-push before a restart) OR
+// * if sent but not acknowledged within retryInterval and its re-push is due, so need resend OR
 // * if self is closest node to chunk TODO: and not light node
 //   in this case send receipt to self to trigger synced state on chunk
 func (p *Pusher) needToSync(ch chunk.Chunk) bool {
 	p.pushedMu.Lock()
 	defer p.pushedMu.Unlock()
 
-	item, found := p.pushed[ch.Address().Hex()]
+	hexaddr := ch.Address().Hex()
+	item, found := p.pushed[hexaddr]
 	now := time.Now()
 	// has been pushed already
 	if found {
@@ -335,20 +386,36 @@ func (p *Pusher) needToSync(ch chunk.Chunk) bool {
 		if item.synced {
 			return false
 		}
+		// still waiting for the receipt
+		if now.Sub(item.lastSentAt) < retryInterval {
+			return false
+		}
+	}
+	// re-push not yet due according to the backoff schedule
+	if !p.retries.due(hexaddr, now) {
+		return false
+	}
+	if found {
+		// no receipt within retryInterval, schedule the next attempt
+		retry, err := p.retries.failed(hexaddr, now)
+		if err != nil {
+			log.Error("pushsync: error scheduling chunk re-push", "ref", hexaddr, "err", err)
+		}
+		metrics.GetOrRegisterCounter("pusher/retry", nil).Inc(1)
+		p.logger.Debug("chunk not acknowledged, re-push", "ref", hexaddr, "attempts", retry.Attempts, "next", retry.Next)
+		item.lastSentAt = now
 	} else {
 		// first time encountered
 		addr := ch.Address()
-		hexaddr := addr.Hex()
 		// remember item
 		tag, _ := p.tags.Get(ch.TagID())
 		item = &pushedItem{
-			tag:    tag,
-			sentAt: now,
+			tag:        tag,
+			sentAt:     now,
+			lastSentAt: now,
 		}
 
-		// increment SENT count on tag  if it exists
 		if tag != nil {
-			tag.Inc(chunk.StateSent)
 			// opentracing for chunk roundtrip
 			_, span := spancontext.StartSpan(tag.Context(), "chunk.sent")
 			span.LogFields(olog.String("ref", hexaddr))
@@ -361,10 +428,24 @@ func (p *Pusher) needToSync(ch chunk.Chunk) bool {
 		if p.ps.IsClosestTo(addr) {
 			p.logger.Trace("self is closest to ref: push receipt locally", "ref", hexaddr)
 			item.shortcut = true
-			go p.pushReceipt(addr)
+			item.setSent()
+			go p.pushReceipt(&chunk.Receipt{Address: addr})
 			return false
 		}
 		p.logger.Trace("self is not the closest to ref: send chunk to neighbourhood", "ref", hexaddr)
 	}
 	return true
 }
+
+// setSent increments the SENT count on the tag of the chunk
+// the first time the chunk is sent
+// p.pushedMu must be held by the caller
+func (i *pushedItem) setSent() {
+	if i.sent {
+		return
+	}
+	i.sent = true
+	if i.tag != nil {
+		i.tag.Inc(chunk.StateSent)
+	}
+}
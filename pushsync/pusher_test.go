@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/rand"
 	"sync"
@@ -32,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethersphere/swarm/chunk"
 	chunktesting "github.com/ethersphere/swarm/chunk/testing"
+	"github.com/ethersphere/swarm/state"
 	"github.com/ethersphere/swarm/storage"
 	"github.com/ethersphere/swarm/testutil"
 )
@@ -95,7 +97,7 @@ func TestPusher(t *testing.T) {
 	// construct the mock push sync index iterator
 	tp := newTestPushSyncIndex(chunkCnt, tagIDs, tags, sent)
 	// start push syncing in a go routine
-	p := NewPusher(tp, &testPubSub{lb, func([]byte) bool { return false }}, tags)
+	p := NewPusher(tp, &testPubSub{lb, func([]byte) bool { return false }}, tags, nil)
 	defer p.Close()
 	// collect synced chunks until all chunks synced
 	// wait on errc for errors on any thread
@@ -121,6 +123,140 @@ func TestPusher(t *testing.T) {
 
 }
 
+// TestPusherRepush tests that chunks not acknowledged with a receipt
+// within retryInterval are pushed again and that synced chunks are
+// removed from the persisted retry queue
+func TestPusherRepush(t *testing.T) {
+	defer func(d time.Duration) { retryInterval = d }(retryInterval)
+	retryInterval = 50 * time.Millisecond
+
+	timeout := 10 * time.Second
+	chunkCnt := 16
+	tagCnt := 4
+
+	lb := newLoopBack()
+	var mu sync.Mutex
+	pushes := make(map[int]int)
+	// respond to all but the first push of each chunk
+	respond := func(msg []byte, _ *p2p.Peer) error {
+		chmsg, err := decodeChunkMsg(msg)
+		if err != nil {
+			return err
+		}
+		idx := int(binary.BigEndian.Uint64(chmsg.Addr[:8]))
+		mu.Lock()
+		pushes[idx]++
+		n := pushes[idx]
+		mu.Unlock()
+		if n == 1 {
+			return nil
+		}
+		rmsg, err := rlp.EncodeToBytes(&receiptMsg{Addr: chmsg.Addr})
+		if err != nil {
+			return err
+		}
+		return lb.Send(chmsg.Origin, pssReceiptTopic, rmsg)
+	}
+	lb.Register(pssChunkTopic, false, respond)
+	tags, tagIDs := setupTags(chunkCnt, tagCnt)
+	tp := newTestPushSyncIndex(chunkCnt, tagIDs, tags, &sync.Map{})
+	store := state.NewInmemoryStore()
+	defer store.Close()
+	p := NewPusher(tp, &testPubSub{lb, func([]byte) bool { return false }}, tags, store)
+
+	synced := make(map[int]bool)
+	for len(synced) < chunkCnt {
+		select {
+		case i := <-tp.synced:
+			synced[i] = true
+		case <-time.After(timeout):
+			p.Close()
+			t.Fatalf("timeout waiting for all chunks to be synced, synced %v", len(synced))
+		}
+	}
+	p.Close()
+
+	mu.Lock()
+	for i := 0; i < chunkCnt; i++ {
+		if pushes[i] < 2 {
+			t.Errorf("chunk %v: expected to be pushed at least twice, got %v", i, pushes[i])
+		}
+	}
+	mu.Unlock()
+	q, err := newRetryQueue(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.size() != 0 {
+		t.Fatalf("expected empty retry queue, got %v", q.size())
+	}
+}
+
+// TestPusherTagCounts tests that chunks are counted as sent on their tags only
+// once they are handed to the network and as synced once they are acknowledged
+// with a receipt, however many times they are pushed
+func TestPusherTagCounts(t *testing.T) {
+	defer func(d time.Duration) { retryInterval = d }(retryInterval)
+	retryInterval = 50 * time.Millisecond
+
+	timeout := 10 * time.Second
+	chunkCnt := 16
+	tagCnt := 4
+
+	lb := newLoopBack()
+	var mu sync.Mutex
+	failing := true
+	// fail sending chunks until failing is unset, then respond with receipts
+	respond := func(msg []byte, _ *p2p.Peer) error {
+		mu.Lock()
+		f := failing
+		mu.Unlock()
+		if f {
+			return errors.New("send failed")
+		}
+		chmsg, err := decodeChunkMsg(msg)
+		if err != nil {
+			return err
+		}
+		rmsg, err := rlp.EncodeToBytes(&receiptMsg{Addr: chmsg.Addr})
+		if err != nil {
+			return err
+		}
+		return lb.Send(chmsg.Origin, pssReceiptTopic, rmsg)
+	}
+	lb.Register(pssChunkTopic, false, respond)
+	tags, tagIDs := setupTags(chunkCnt, tagCnt)
+	tp := newTestPushSyncIndex(chunkCnt, tagIDs, tags, &sync.Map{})
+	p := NewPusher(tp, &testPubSub{lb, func([]byte) bool { return false }}, tags, nil)
+	defer p.Close()
+
+	// let the chunks be pushed a few times without success
+	time.Sleep(4 * retryInterval)
+	for _, tagID := range tagIDs[:tagCnt-1] {
+		tag, err := tags.Get(tagID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := tag.Get(chunk.StateSent); n != 0 {
+			t.Fatalf("tag %v: got %v sent chunks before any was sent, want 0", tag.Uid, n)
+		}
+	}
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+
+	synced := make(map[int]bool)
+	for len(synced) < chunkCnt {
+		select {
+		case i := <-tp.synced:
+			synced[i] = true
+		case <-time.After(timeout):
+			t.Fatalf("timeout waiting for all chunks to be synced, synced %v", len(synced))
+		}
+	}
+	checkTags(t, int64(chunkCnt/tagCnt), tagIDs[:tagCnt-1], tags)
+}
+
 type testPubSub struct {
 	*loopBack
 	isClosestTo func([]byte) bool
@@ -251,12 +387,6 @@ func (tp *testPushSyncIndex) Set(ctx context.Context, _ chunk.ModeSet, addrs ...
 	for _, addr := range addrs {
 		idx := int(binary.BigEndian.Uint64(addr[:8]))
 		tp.sent.Delete(idx)
-
-		tagID := tp.tagIDs[idx%len(tp.tagIDs)]
-		if tag, _ := tp.tags.Get(tagID); tag != nil {
-			tag.Inc(chunk.StateSynced)
-		}
-
 		tp.synced <- idx
 		log.Debug("set chunk synced", "idx", idx, "addr", addr)
 	}
@@ -286,8 +416,8 @@ func setupTags(chunkCnt, tagCnt int) (tags *chunk.Tags, tagIDs []uint32) {
 		tags.Create("", int64(chunkCnt/tagCnt), false)
 	}
 	// extract tag ids
-	tags.Range(func(k, _ interface{}) bool {
-		tagIDs = append(tagIDs, k.(uint32))
+	tags.Range(func(t *chunk.Tag) bool {
+		tagIDs = append(tagIDs, t.Uid)
 		return true
 	})
 	// add an extra for which no tag exists
@@ -301,7 +431,7 @@ func checkTags(t *testing.T, expTotal int64, tagIDs []uint32, tags *chunk.Tags)
 		if err != nil {
 			t.Fatal(err)
 		}
-		// the tag is adjusted after the store.Set calls show
+		// the tag is adjusted when the receipts are received
 		err = tag.WaitTillDone(context.Background(), chunk.StateSynced)
 		if err != nil {
 			t.Fatalf("error waiting for syncing on tag %v: %v", tag.Uid, err)
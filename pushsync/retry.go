@@ -0,0 +1,125 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pushsync
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/ethersphere/swarm/state"
+)
+
+// retryKeyPrefix is the state store key prefix of the retry queue entries
+const retryKeyPrefix = "pushsync_retry_"
+
+var maxRetryInterval = 10 * time.Minute // upper bound on the backoff between re-push attempts
+
+// retryItem records the failed push attempts of a chunk
+type retryItem struct {
+	Attempts int       `json:"attempts"` // number of pushes not acknowledged within retryInterval
+	Next     time.Time `json:"next"`     // earliest time of the next push attempt
+}
+
+// retryQueue keeps track of chunks that were pushed but not acknowledged
+// with a receipt within retryInterval and schedules their re-push with
+// exponential backoff. If a state store is given, the queue is persisted
+// so that the schedule survives restarts.
+// It is not safe for concurrent use, the pusher only accesses it from chunksWorker.
+type retryQueue struct {
+	store state.Store           // persistent store, nil if the queue is in memory only
+	items map[string]*retryItem // retry items by hex chunk address
+}
+
+// newRetryQueue constructs a retryQueue and loads the persisted entries from the store
+// the queue is returned even if loading fails, holding the entries loaded until the error
+func newRetryQueue(store state.Store) (*retryQueue, error) {
+	q := &retryQueue{
+		store: store,
+		items: make(map[string]*retryItem),
+	}
+	if store == nil {
+		return q, nil
+	}
+	err := store.Iterate(retryKeyPrefix, func(key, value []byte) (bool, error) {
+		item := new(retryItem)
+		if err := json.Unmarshal(value, item); err != nil {
+			return true, err
+		}
+		q.items[strings.TrimPrefix(string(key), retryKeyPrefix)] = item
+		return false, nil
+	})
+	return q, err
+}
+
+// due returns true if the chunk is not scheduled for re-push or its next attempt is due
+func (q *retryQueue) due(hexaddr string, now time.Time) bool {
+	item, ok := q.items[hexaddr]
+	return !ok || !now.Before(item.Next)
+}
+
+// failed records a push attempt of the chunk that was not acknowledged
+// and schedules the next one after the backoff interval
+func (q *retryQueue) failed(hexaddr string, now time.Time) (*retryItem, error) {
+	item, ok := q.items[hexaddr]
+	if !ok {
+		item = new(retryItem)
+		q.items[hexaddr] = item
+	}
+	item.Attempts++
+	item.Next = now.Add(backoff(item.Attempts))
+	if q.store == nil {
+		return item, nil
+	}
+	return item, q.store.Put(retryKeyPrefix+hexaddr, item)
+}
+
+// remove deletes the chunks from the queue once they are synced
+func (q *retryQueue) remove(hexaddrs ...string) error {
+	batch := new(state.StoreBatch)
+	var n int
+	for _, hexaddr := range hexaddrs {
+		if _, ok := q.items[hexaddr]; !ok {
+			continue
+		}
+		delete(q.items, hexaddr)
+		batch.Delete(retryKeyPrefix + hexaddr)
+		n++
+	}
+	if q.store == nil || n == 0 {
+		return nil
+	}
+	return q.store.WriteBatch(batch)
+}
+
+// size returns the number of chunks scheduled for re-push
+func (q *retryQueue) size() int {
+	return len(q.items)
+}
+
+// backoff returns the interval before the next push attempt after the given
+// number of failed attempts, doubling retryInterval on each attempt up to maxRetryInterval
+func backoff(attempts int) time.Duration {
+	d := retryInterval
+	for i := 0; i < attempts && d < maxRetryInterval; i++ {
+		d *= 2
+	}
+	if d > maxRetryInterval {
+		d = maxRetryInterval
+	}
+	return d
+}
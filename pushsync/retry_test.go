@@ -0,0 +1,107 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pushsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/state"
+)
+
+// TestBackoff tests that the re-push interval doubles with each failed attempt
+// and is capped at maxRetryInterval
+func TestBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, retryInterval},
+		{1, 2 * retryInterval},
+		{2, 4 * retryInterval},
+		{5, 32 * retryInterval},
+		{6, maxRetryInterval},
+		{100, maxRetryInterval},
+	} {
+		if got := backoff(tc.attempts); got != tc.want {
+			t.Errorf("backoff(%v): got %v, want %v", tc.attempts, got, tc.want)
+		}
+	}
+}
+
+// TestRetryQueue tests that failed push attempts are scheduled with backoff,
+// survive reloading the queue from the state store and are removed when synced
+func TestRetryQueue(t *testing.T) {
+	store := state.NewInmemoryStore()
+	defer store.Close()
+
+	q, err := newRetryQueue(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hexaddr := "aa"
+	now := time.Now()
+	if !q.due(hexaddr, now) {
+		t.Fatal("expected unscheduled chunk to be due")
+	}
+	if _, err := q.failed(hexaddr, now); err != nil {
+		t.Fatal(err)
+	}
+	item, err := q.failed(hexaddr, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %v", item.Attempts)
+	}
+	if q.due(hexaddr, now.Add(backoff(2)-time.Millisecond)) {
+		t.Fatal("expected chunk not to be due before backoff")
+	}
+
+	// reload the queue as after a restart
+	q, err = newRetryQueue(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.size() != 1 {
+		t.Fatalf("expected 1 chunk in the queue, got %v", q.size())
+	}
+	if q.due(hexaddr, now.Add(backoff(2)-time.Millisecond)) {
+		t.Fatal("expected chunk not to be due before backoff after reload")
+	}
+	if !q.due(hexaddr, now.Add(backoff(2))) {
+		t.Fatal("expected chunk to be due after backoff after reload")
+	}
+	item, err = q.failed(hexaddr, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Attempts != 3 {
+		t.Fatalf("expected 3 attempts after reload, got %v", item.Attempts)
+	}
+
+	if err := q.remove(hexaddr, "bb"); err != nil {
+		t.Fatal(err)
+	}
+	q, err = newRetryQueue(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.size() != 0 {
+		t.Fatalf("expected empty queue, got %v", q.size())
+	}
+}
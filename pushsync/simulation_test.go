@@ -206,11 +206,11 @@ func newServiceFunc(ctx *adapters.ServiceContext, bucket *sync.Map) (node.Servic
 
 	pubSub := pss.NewPubSub(ps, 1*time.Second)
 	// setup pusher
-	p := NewPusher(lstore, pubSub, tags)
+	p := NewPusher(lstore, pubSub, tags, nil)
 	bucket.Store(bucketKeyPushSyncer, p)
 
 	// setup storer
-	s := NewStorer(netStore, pubSub)
+	s := NewStorer(netStore, pubSub, privKey)
 
 	cleanup := func() {
 		p.Close()
@@ -18,9 +18,11 @@ package pushsync
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"encoding/hex"
 
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethersphere/swarm/chunk"
@@ -37,10 +39,11 @@ type Store interface {
 
 // Storer is the object used by the push-sync server side protocol
 type Storer struct {
-	store      Store      // store to put chunks in, and retrieve them from
-	ps         PubSub     // pubsub interface to receive chunks and send receipts
-	deregister func()     // deregister the registered handler when Storer is closed
-	logger     log.Logger // custom logger
+	store      Store             // store to put chunks in, and retrieve them from
+	ps         PubSub            // pubsub interface to receive chunks and send receipts
+	key        *ecdsa.PrivateKey // key to sign receipts with, nil for unsigned receipts
+	deregister func()            // deregister the registered handler when Storer is closed
+	logger     log.Logger        // custom logger
 }
 
 // NewStorer constructs a Storer
@@ -49,11 +52,15 @@ type Storer struct {
 // The protocol makes sure that
 // - the chunks are stored and synced to their nearest neighbours and
 // - a statement of custody receipt is sent as a response to the originator
+// receipts are signed with the node private key, so that the originator has
+// a proof that the chunk reached its neighbourhood; if key is nil, receipts
+// are not signed
 // it sets a cancel function that deregisters the handler
-func NewStorer(store Store, ps PubSub) *Storer {
+func NewStorer(store Store, ps PubSub, key *ecdsa.PrivateKey) *Storer {
 	s := &Storer{
 		store:  store,
 		ps:     ps,
+		key:    key,
 		logger: log.New("self", label(ps.BaseAddr())),
 	}
 	s.deregister = ps.Register(pssChunkTopic, true, func(msg []byte, _ *p2p.Peer) error {
@@ -95,6 +102,9 @@ func (s *Storer) handleChunkMsg(msg []byte) error {
 func (s *Storer) processChunkMsg(ctx context.Context, chmsg *chunkMsg) error {
 	ch := storage.NewChunk(chmsg.Addr, chmsg.Data)
 	if _, err := s.store.Put(ctx, chunk.ModePutSync, ch); err != nil {
+		if err == chunk.ErrChunkInvalid {
+			metrics.GetOrRegisterCounter("storer/chunks/invalid", nil).Inc(1)
+		}
 		return err
 	}
 
@@ -109,6 +119,7 @@ func (s *Storer) processChunkMsg(ctx context.Context, chmsg *chunkMsg) error {
 // sendReceiptMsg sends a statement of custody receipt message
 // to the originator of a push-synced chunk message.
 // Including a unique nonce makes the receipt immune to deduplication cache
+// The signature of the storer over the chunk address is the proof of custody
 func (s *Storer) sendReceiptMsg(ctx context.Context, chmsg *chunkMsg) error {
 	ctx, osp := spancontext.StartSpan(ctx, "send.receipt")
 	defer osp.Finish()
@@ -121,6 +132,11 @@ func (s *Storer) sendReceiptMsg(ctx context.Context, chmsg *chunkMsg) error {
 		Addr:  chmsg.Addr,
 		Nonce: newNonce(),
 	}
+	if s.key != nil {
+		if err := signReceipt(rmsg, s.key); err != nil {
+			return err
+		}
+	}
 	msg, err := rlp.EncodeToBytes(rmsg)
 	if err != nil {
 		return err
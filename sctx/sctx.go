@@ -6,6 +6,8 @@ type (
 	HTTPRequestIDKey struct{}
 	requestHostKey   struct{}
 	tagKey           struct{}
+	chunkerKey       struct{}
+	encryptionKey    struct{}
 )
 
 // SetHost sets the http request host in the context
@@ -35,3 +37,31 @@ func GetTag(ctx context.Context) uint32 {
 	}
 	return 0
 }
+
+// SetChunker sets the name of the chunker content is split with in the context
+func SetChunker(ctx context.Context, chunker string) context.Context {
+	return context.WithValue(ctx, chunkerKey{}, chunker)
+}
+
+// GetChunker gets the name of the chunker from the context
+func GetChunker(ctx context.Context) string {
+	v, ok := ctx.Value(chunkerKey{}).(string)
+	if ok {
+		return v
+	}
+	return ""
+}
+
+// SetEncryptionKey sets the master key content is encrypted under in the context
+func SetEncryptionKey(ctx context.Context, key []byte) context.Context {
+	return context.WithValue(ctx, encryptionKey{}, key)
+}
+
+// GetEncryptionKey gets the master encryption key from the context
+func GetEncryptionKey(ctx context.Context) []byte {
+	v, ok := ctx.Value(encryptionKey{}).([]byte)
+	if ok {
+		return v
+	}
+	return nil
+}
@@ -45,16 +45,34 @@ const (
 // It provides a schema functionality to store fields and indexes
 // information about naming and types.
 type DB struct {
-	ldb  *leveldb.DB
-	quit chan struct{} // Quit channel to stop the metrics collection before closing the database
+	ldb *leveldb.DB
+	// readOnlySchema holds the schema of a read-only database, so that fields
+	// and indexes that are not in the stored schema are created as empty
+	// without writing the schema
+	readOnlySchema *schema
+	quit           chan struct{} // Quit channel to stop the metrics collection before closing the database
 }
 
 // NewDB constructs a new DB and validates the schema
 // if it exists in database on the given path.
 // metricsPrefix is used for metrics collection for the given DB.
 func NewDB(path string, metricsPrefix string) (db *DB, err error) {
+	return newDB(path, metricsPrefix, false)
+}
+
+// NewReadOnlyDB constructs a new DB from an existing database
+// on the given path that does not allow any writes. Fields and
+// indexes that are not in the stored schema are empty.
+// metricsPrefix is used for metrics collection for the given DB.
+func NewReadOnlyDB(path string, metricsPrefix string) (db *DB, err error) {
+	return newDB(path, metricsPrefix, true)
+}
+
+func newDB(path string, metricsPrefix string, readOnly bool) (db *DB, err error) {
 	ldb, err := leveldb.OpenFile(path, &opt.Options{
 		OpenFilesCacheCapacity: openFileLimit,
+		ReadOnly:               readOnly,
+		ErrorIfMissing:         readOnly,
 	})
 	if err != nil {
 		return nil, err
@@ -63,8 +81,9 @@ func NewDB(path string, metricsPrefix string) (db *DB, err error) {
 		ldb: ldb,
 	}
 
-	if _, err = db.getSchema(); err != nil {
-		if err == leveldb.ErrNotFound {
+	s, err := db.getSchema()
+	if err != nil {
+		if err == leveldb.ErrNotFound && !readOnly {
 			// save schema with initialized default fields
 			if err = db.putSchema(schema{
 				Fields:  make(map[string]fieldSpec),
@@ -73,9 +92,13 @@ func NewDB(path string, metricsPrefix string) (db *DB, err error) {
 				return nil, err
 			}
 		} else {
+			ldb.Close()
 			return nil, err
 		}
 	}
+	if readOnly {
+		db.readOnlySchema = &s
+	}
 
 	// Create a quit channel for the periodic metrics collector and run it
 	db.quit = make(chan struct{})
@@ -90,6 +90,98 @@ func TestDB_persistence(t *testing.T) {
 	}
 }
 
+// TestNewReadOnlyDB validates that a read-only DB can read
+// existing fields, can not write and that new fields and
+// indexes are empty without changing the stored schema.
+func TestNewReadOnlyDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shed-test-read-only")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := NewReadOnlyDB(dir, ""); err == nil {
+		t.Fatal("read-only db opened without existing database")
+	}
+
+	db, err := NewDB(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stringField, err := db.NewStringField("preserve-me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "persistent value"
+	err = stringField.Put(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db2, err := NewReadOnlyDB(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stringField2, err := db2.NewStringField("preserve-me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := stringField2.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got string %q, want %q", got, want)
+	}
+
+	if err := stringField2.Put("new value"); err == nil {
+		t.Error("got no error on put to read-only db")
+	}
+	newField, err := db2.NewStringField("new-field")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := newField.Get(); err != nil || got != "" {
+		t.Errorf("got new field value %q, error %v, want empty value", got, err)
+	}
+	newIndex, err := db2.NewIndex("new-index", retrievalIndexFuncs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := newIndex.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("got %v items in new index, want 0", count)
+	}
+	if err := db2.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the stored schema must not be changed by the read-only db
+	db3, err := NewDB(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db3.Close()
+	s, err := db3.getSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Fields["new-field"]; ok {
+		t.Error("new field stored in schema by read-only db")
+	}
+	if len(s.Indexes) != 0 {
+		t.Errorf("got %v indexes stored in schema by read-only db, want 0", len(s.Indexes))
+	}
+}
+
 // newTestDB is a helper function that constructs a
 // temporary database and returns a cleanup function that must
 // be called to remove the data.
@@ -43,6 +43,7 @@ type Item struct {
 	BinID           uint64
 	PinCounter      uint64 // maintains the no of time a chunk is pinned
 	Tag             uint32
+	AccessCount     uint64 // maintains the no of time a chunk is accessed
 }
 
 // Merge is a helper method to construct a new
@@ -70,6 +71,9 @@ func (i Item) Merge(i2 Item) (new Item) {
 	if i.Tag == 0 {
 		i.Tag = i2.Tag
 	}
+	if i.AccessCount == 0 {
+		i.AccessCount = i2.AccessCount
+	}
 	return i
 }
 
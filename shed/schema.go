@@ -71,6 +71,7 @@ func (db *DB) schemaFieldKey(name, fieldType string) (key []byte, err error) {
 			if f.Type != fieldType {
 				return nil, fmt.Errorf("field %q of type %q stored as %q in db", name, fieldType, f.Type)
 			}
+			found = true
 			break
 		}
 	}
@@ -147,6 +148,9 @@ func (db *DB) schemaIndexPrefix(name string) (id byte, err error) {
 // getSchema retrieves the complete schema from
 // the database.
 func (db *DB) getSchema() (s schema, err error) {
+	if db.readOnlySchema != nil {
+		return *db.readOnlySchema, nil
+	}
 	b, err := db.Get(keySchema)
 	if err != nil {
 		return s, err
@@ -156,8 +160,12 @@ func (db *DB) getSchema() (s schema, err error) {
 }
 
 // putSchema stores the complete schema to
-// the database.
+// the database, or only in memory if it is read-only.
 func (db *DB) putSchema(s schema) (err error) {
+	if db.readOnlySchema != nil {
+		*db.readOnlySchema = s
+		return nil
+	}
 	b, err := json.Marshal(s)
 	if err != nil {
 		return err
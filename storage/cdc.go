@@ -0,0 +1,215 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+
+	"github.com/ethersphere/swarm/chunk"
+	"golang.org/x/crypto/sha3"
+)
+
+/*
+Content defined chunking splits data into leaf chunks at the positions where
+a rolling gear hash of the preceding bytes matches a mask, instead of at fixed
+offsets. An insertion or deletion in the data only changes the chunks around
+it, so that the other chunks of similar content, such as of the versions of a
+file, are the same and stored only once.
+
+Intermediate chunks are closed after a child whose reference matches a mask,
+so that they are also shared by similar content. As the children are of
+variable size, the span of intermediate chunks has spanVariableFlag set and
+their data holds the size of every child after its reference:
+data := span | spanVariableFlag || key_0 || size_0 || key_1 || size_1 ...
+*/
+
+const (
+	cdcMinSize    = 1024                  // minimal data size of leaf chunks, except the last one
+	cdcMask       = (1<<11 - 1) << 53     // rolling hash mask at leaf chunk boundaries
+	cdcBranchMask = 1<<5 - 1              // reference mask at intermediate chunk boundaries
+	cdcSizeLength = 8                     // length of the size of a child
+	cdcBufferSize = 2 * chunk.DefaultSize // size of the buffer data is read into
+)
+
+// cdcGear is the table of random values of bytes added to the rolling hash,
+// derived from their Keccak256 hashes, so that chunk boundaries are the same
+// on all nodes
+var cdcGear [256]uint64
+
+func init() {
+	h := sha3.NewLegacyKeccak256()
+	for i := range cdcGear {
+		h.Reset()
+		h.Write([]byte{byte(i)})
+		cdcGear[i] = binary.LittleEndian.Uint64(h.Sum(nil))
+	}
+}
+
+// CDCSplit splits the data with content defined chunking and stores the
+// chunks with the putter. It returns the address of the root chunk and a
+// function to wait for all chunks to be stored, as PyramidSplit does.
+// The putter must not encrypt chunks, as the length of the data of
+// decrypted intermediate chunks is not derived from their span.
+func CDCSplit(ctx context.Context, data io.Reader, putter Putter, tag *chunk.Tag) (Address, func(context.Context) error, error) {
+	s := &cdcSplitter{
+		putter:     putter,
+		tag:        tag,
+		maxEntries: chunk.DefaultSize / (int(putter.RefSize()) + cdcSizeLength),
+	}
+	defer putter.Close()
+
+	addr, err := s.split(ctx, bufio.NewReaderSize(data, cdcBufferSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	return addr, putter.Wait, nil
+}
+
+// cdcSplitter builds the tree of chunks bottom up, keeping the entries of
+// the children of the intermediate chunk of every level that is not closed
+type cdcSplitter struct {
+	putter     Putter
+	tag        *chunk.Tag
+	maxEntries int // maximal number of children of intermediate chunks
+	levels     []*cdcLevel
+}
+
+// cdcLevel holds the references and sizes of the children
+// of an intermediate chunk that is not closed yet
+type cdcLevel struct {
+	entries []byte
+	count   int
+	size    uint64
+}
+
+func (s *cdcSplitter) split(ctx context.Context, data *bufio.Reader) (Address, error) {
+	for leaves := 0; ; leaves++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		buf, err := data.Peek(chunk.DefaultSize)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, err
+		}
+		// the data of an empty file is stored in an empty leaf chunk
+		if len(buf) == 0 && leaves > 0 {
+			break
+		}
+		n := cdcBoundary(buf)
+		chunkData := make([]byte, 8+n)
+		binary.LittleEndian.PutUint64(chunkData, uint64(n))
+		copy(chunkData[8:], buf[:n])
+		if _, err := data.Discard(n); err != nil {
+			return nil, err
+		}
+		ref, err := s.put(ctx, chunkData)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.add(ctx, 0, ref, uint64(n)); err != nil {
+			return nil, err
+		}
+	}
+	return s.finish(ctx)
+}
+
+// cdcBoundary returns the length of the leaf chunk data at the start of buf
+func cdcBoundary(buf []byte) int {
+	if len(buf) <= cdcMinSize {
+		return len(buf)
+	}
+	var h uint64
+	for i := cdcMinSize; i < len(buf); i++ {
+		h = h<<1 + cdcGear[buf[i]]
+		if h&cdcMask == 0 {
+			return i + 1
+		}
+	}
+	return len(buf)
+}
+
+// add adds the child to the intermediate chunk of the level,
+// closing it if the reference of the child is at a boundary or
+// if the intermediate chunk is full
+func (s *cdcSplitter) add(ctx context.Context, level int, ref Reference, size uint64) error {
+	if level == len(s.levels) {
+		s.levels = append(s.levels, &cdcLevel{})
+	}
+	l := s.levels[level]
+	l.entries = append(l.entries, ref...)
+	l.entries = append(l.entries, make([]byte, cdcSizeLength)...)
+	binary.LittleEndian.PutUint64(l.entries[len(l.entries)-cdcSizeLength:], size)
+	l.count++
+	l.size += size
+	if binary.LittleEndian.Uint16(ref)&cdcBranchMask != 0 && l.count < s.maxEntries {
+		return nil
+	}
+	return s.close(ctx, level)
+}
+
+// close stores the intermediate chunk of the level
+// and adds it to the one of the level above
+func (s *cdcSplitter) close(ctx context.Context, level int) error {
+	l := s.levels[level]
+	chunkData := make([]byte, 8+len(l.entries))
+	binary.LittleEndian.PutUint64(chunkData, l.size|spanVariableFlag)
+	copy(chunkData[8:], l.entries)
+	size := l.size
+	s.levels[level] = &cdcLevel{}
+
+	ref, err := s.put(ctx, chunkData)
+	if err != nil {
+		return err
+	}
+	return s.add(ctx, level+1, ref, size)
+}
+
+// finish closes the intermediate chunks of all levels and returns the root
+// address. The single child of an intermediate chunk is added to the level
+// above instead, as the sizes of children are not bound to their level.
+func (s *cdcSplitter) finish(ctx context.Context) (Address, error) {
+	for level := 0; ; level++ {
+		l := s.levels[level]
+		top := level == len(s.levels)-1
+		switch {
+		case l.count == 1 && top:
+			return Address(l.entries[:len(l.entries)-cdcSizeLength]), nil
+		case l.count == 1:
+			ref := Reference(l.entries[:len(l.entries)-cdcSizeLength])
+			s.levels[level] = &cdcLevel{}
+			if err := s.add(ctx, level+1, ref, l.size); err != nil {
+				return nil, err
+			}
+		case l.count > 1:
+			if err := s.close(ctx, level); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (s *cdcSplitter) put(ctx context.Context, chunkData ChunkData) (Reference, error) {
+	ref, err := s.putter.Put(ctx, chunkData)
+	if err != nil {
+		return nil, err
+	}
+	s.tag.Inc(chunk.StateSplit)
+	return ref, nil
+}
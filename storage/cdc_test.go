@@ -0,0 +1,151 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/sctx"
+	"github.com/ethersphere/swarm/testutil"
+)
+
+// TestCDCSplit tests that content split with content defined chunking
+// is retrieved whole and in ranges.
+func TestCDCSplit(t *testing.T) {
+	ctx := sctx.SetChunker(context.Background(), ChunkerCDC)
+	for _, size := range []int{0, 1, cdcMinSize, chunk.DefaultSize, chunk.DefaultSize + 1, 100000, 2000000} {
+		t.Run(fmt.Sprintf("%d", size), func(t *testing.T) {
+			store := NewMapChunkStore()
+			fileStore := NewFileStore(store, store, NewFileStoreParams(), chunk.NewTags())
+
+			slice := testutil.RandomBytes(1, size)
+			addr, wait, err := fileStore.Store(ctx, bytes.NewReader(slice), int64(size), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := wait(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			reader, _ := fileStore.Retrieve(ctx, addr)
+			got, err := ioutil.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, slice) {
+				t.Fatal("retrieved data differs")
+			}
+
+			for _, r := range [][2]int{{0, 10}, {size / 3, size / 2}, {size - 5000, size}} {
+				start, end := r[0], r[1]
+				if start < 0 {
+					start = 0
+				}
+				if end > size {
+					end = size
+				}
+				if start >= end {
+					continue
+				}
+				b := make([]byte, end-start)
+				n, err := reader.ReadAt(b, int64(start))
+				if err != nil && err != io.EOF {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(b[:n], slice[start:end]) {
+					t.Fatalf("retrieved data of range %d-%d differs", start, end)
+				}
+			}
+		})
+	}
+}
+
+// TestCDCDeduplication tests that most chunks of content split
+// with content defined chunking are shared by a modified version of it.
+func TestCDCDeduplication(t *testing.T) {
+	size := 1000000
+	slice := testutil.RandomBytes(1, size)
+	// insert data in the middle of the content
+	modified := append(append(append([]byte{}, slice[:size/2]...), testutil.RandomBytes(2, 100)...), slice[size/2:]...)
+
+	for _, tc := range []struct {
+		chunker string
+		max     int
+	}{
+		{ChunkerCDC, 10},
+		{ChunkerPyramid, -1},
+	} {
+		t.Run(tc.chunker, func(t *testing.T) {
+			ctx := sctx.SetChunker(context.Background(), tc.chunker)
+			store := NewMapChunkStore()
+			fileStore := NewFileStore(store, store, NewFileStoreParams(), chunk.NewTags())
+
+			put := func(data []byte) int {
+				addr, wait, err := fileStore.Store(ctx, bytes.NewReader(data), int64(len(data)), false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := wait(ctx); err != nil {
+					t.Fatal(err)
+				}
+				reader, _ := fileStore.Retrieve(ctx, addr)
+				got, err := ioutil.ReadAll(reader)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Fatal("retrieved data differs")
+				}
+				store.mu.RLock()
+				defer store.mu.RUnlock()
+				return len(store.chunks)
+			}
+			count := put(slice)
+			added := put(modified) - count
+			if tc.max >= 0 && added > tc.max {
+				t.Fatalf("got %d new chunks of %d, want at most %d", added, count, tc.max)
+			}
+			if tc.max < 0 && added < count/3 {
+				t.Fatalf("got %d new chunks of %d, want more with fixed size chunks", added, count)
+			}
+		})
+	}
+}
+
+// TestCDCUnsupported tests that content defined chunking
+// is not used for encrypted content or unknown chunkers.
+func TestCDCUnsupported(t *testing.T) {
+	store := NewMapChunkStore()
+	fileStore := NewFileStore(store, store, NewFileStoreParams(), chunk.NewTags())
+	slice := testutil.RandomBytes(1, 10000)
+
+	ctx := sctx.SetChunker(context.Background(), ChunkerCDC)
+	if _, _, err := fileStore.Store(ctx, bytes.NewReader(slice), int64(len(slice)), true); err != errCDCUnsupported {
+		t.Fatalf("got error %v, want %v", err, errCDCUnsupported)
+	}
+
+	ctx = sctx.SetChunker(context.Background(), "unknown")
+	if _, _, err := fileStore.Store(ctx, bytes.NewReader(slice), int64(len(slice)), false); err == nil {
+		t.Fatal("got no error for unknown chunker")
+	}
+}
@@ -16,6 +16,7 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"errors"
@@ -28,6 +29,7 @@ import (
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/spancontext"
+	"github.com/ethersphere/swarm/storage/erasure"
 	opentracing "github.com/opentracing/opentracing-go"
 	olog "github.com/opentracing/opentracing-go/log"
 )
@@ -54,6 +56,17 @@ data_{i} := size(subtree_{i}) || key_{j} || key_{j+1} .... || key_{j+n-1}
   branches^l length (except the last one).
   key = hash(int64(size) + key(slice0) + key(slice1) + ...)
 
+6 if the data is erasure coded, the keys of the children of branching nodes
+  are followed by the keys of parity chunks, Reed-Solomon encoded from the
+  data of the children, so that missing children can be reconstructed. The
+  number of parity keys is stored in the most significant byte of the size,
+  and branching nodes have that many less children.
+
+7 if the data is split with content defined chunking, leaf nodes are of
+  variable size and the keys of the children of branching nodes are followed
+  by their sizes, which is flagged in the most significant bit of the size,
+  see CDCSplit.
+
  The underlying hash function is configurable
 */
 
@@ -79,7 +92,9 @@ type SplitterParams struct {
 
 type TreeSplitterParams struct {
 	SplitterParams
-	size int64
+	size     int64
+	parities int64      // number of parity references of branching nodes, 0 disables erasure coding
+	tag      *chunk.Tag // tag counting split chunks, if not nil
 }
 
 type JoinerParams struct {
@@ -102,6 +117,8 @@ type TreeChunker struct {
 	depth       int
 	hashSize    int64        // self.hashFunc.New().Size()
 	chunkSize   int64        // hashSize* branches
+	parities    int64        // number of parity references of branching nodes
+	tag         *chunk.Tag   // tag counting split chunks
 	workerCount int64        // the number of worker routines used
 	workerLock  sync.RWMutex // lock for the worker count
 	jobC        chan *hashJob
@@ -158,6 +175,33 @@ func TreeSplit(ctx context.Context, data io.Reader, size int64, putter Putter) (
 	return NewTreeSplitter(tsp).Split(ctx)
 }
 
+/*
+	ErasureSplit splits the data as TreeSplit does, but the references of the children
+	of branching nodes are followed by the references of the given number of parity chunks,
+	Reed-Solomon encoded from the data of the children. Branching nodes have as many less
+	children, so that the joiner can reconstruct missing children from their siblings and
+	the parity chunks. Split chunks are counted on the tag.
+*/
+func ErasureSplit(ctx context.Context, data io.Reader, size int64, putter Putter, parities int, tag *chunk.Tag) (k Address, wait func(context.Context) error, err error) {
+	if parities <= 0 || int64(parities) >= chunk.DefaultSize/putter.RefSize() {
+		return nil, nil, fmt.Errorf("invalid number of parities: %d", parities)
+	}
+	tsp := &TreeSplitterParams{
+		SplitterParams: SplitterParams{
+			ChunkerParams: ChunkerParams{
+				chunkSize: chunk.DefaultSize,
+				hashSize:  putter.RefSize(),
+			},
+			reader: data,
+			putter: putter,
+		},
+		size:     size,
+		parities: int64(parities),
+		tag:      tag,
+	}
+	return NewTreeSplitter(tsp).Split(ctx)
+}
+
 func NewTreeJoiner(params *JoinerParams) *TreeChunker {
 	tc := &TreeChunker{}
 	tc.hashSize = params.hashSize
@@ -182,7 +226,9 @@ func NewTreeSplitter(params *TreeSplitterParams) *TreeChunker {
 	tc.data = params.reader
 	tc.dataSize = params.size
 	tc.hashSize = params.hashSize
-	tc.branches = params.chunkSize / params.hashSize
+	tc.branches = params.chunkSize/params.hashSize - params.parities
+	tc.parities = params.parities
+	tc.tag = params.tag
 	tc.addr = params.addr
 	tc.chunkSize = params.chunkSize
 	tc.putter = params.putter
@@ -263,7 +309,8 @@ func (tc *TreeChunker) Split(ctx context.Context) (k Address, wait func(context.
 	return key, tc.putter.Wait, nil
 }
 
-func (tc *TreeChunker) split(ctx context.Context, depth int, treeSize int64, addr Address, size int64, parentWg *sync.WaitGroup) {
+// split splits the data of the subtree and returns the data of its root chunk
+func (tc *TreeChunker) split(ctx context.Context, depth int, treeSize int64, addr Address, size int64, parentWg *sync.WaitGroup) []byte {
 
 	//
 
@@ -282,23 +329,24 @@ func (tc *TreeChunker) split(ctx context.Context, depth int, treeSize int64, add
 			readBytes += int64(n)
 			if err != nil && !(err == io.EOF && readBytes == size) {
 				tc.errC <- err
-				return
+				return nil
 			}
 		}
 		select {
 		case tc.jobC <- &hashJob{addr, chunkData, size, parentWg}:
 		case <-tc.quitC:
 		}
-		return
+		return chunkData
 	}
 	// dept > 0
 	// intermediate chunk containing child nodes hashes
 	branchCnt := (size + treeSize - 1) / treeSize
 
-	var chunk = make([]byte, branchCnt*tc.hashSize+8)
+	var chunk = make([]byte, (branchCnt+tc.parities)*tc.hashSize+8)
 	var pos, i int64
 
-	binary.LittleEndian.PutUint64(chunk[0:8], uint64(size))
+	binary.LittleEndian.PutUint64(chunk[0:8], uint64(size)|uint64(tc.parities)<<56)
+	children := make([][]byte, branchCnt)
 
 	childrenWg := &sync.WaitGroup{}
 	var secSize int64
@@ -313,7 +361,7 @@ func (tc *TreeChunker) split(ctx context.Context, depth int, treeSize int64, add
 		subTreeAddress := chunk[8+i*tc.hashSize : 8+(i+1)*tc.hashSize]
 
 		childrenWg.Add(1)
-		tc.split(ctx, depth-1, treeSize/tc.branches, subTreeAddress, secSize, childrenWg)
+		children[i] = tc.split(ctx, depth-1, treeSize/tc.branches, subTreeAddress, secSize, childrenWg)
 
 		i++
 		pos += treeSize
@@ -323,6 +371,13 @@ func (tc *TreeChunker) split(ctx context.Context, depth int, treeSize int64, add
 	// go func() {
 	childrenWg.Wait()
 
+	if tc.parities > 0 {
+		if err := tc.putParities(ctx, chunk[8+branchCnt*tc.hashSize:], children); err != nil {
+			tc.errC <- err
+			return nil
+		}
+	}
+
 	worker := tc.getWorkerCount()
 	if int64(len(tc.jobC)) > worker && worker < ChunkProcessors {
 		tc.runWorker(ctx)
@@ -332,6 +387,36 @@ func (tc *TreeChunker) split(ctx context.Context, depth int, treeSize int64, add
 	case tc.jobC <- &hashJob{addr, chunk, size, parentWg}:
 	case <-tc.quitC:
 	}
+	return chunk
+}
+
+// putParities stores the parity chunks encoded from the data of the
+// children chunks, padded to the maximal chunk data length, and copies
+// their references to refs.
+func (tc *TreeChunker) putParities(ctx context.Context, refs []byte, children [][]byte) error {
+	code, err := erasure.New(len(children), int(tc.parities))
+	if err != nil {
+		return err
+	}
+	shards := make([][]byte, len(children)+int(tc.parities))
+	for i, data := range children {
+		shards[i] = make([]byte, tc.chunkSize+8)
+		copy(shards[i], data)
+	}
+	if err := code.Encode(shards); err != nil {
+		return err
+	}
+	for i, parity := range shards[len(children):] {
+		ref, err := tc.putter.Put(ctx, parity)
+		if err != nil {
+			return err
+		}
+		if tc.tag != nil {
+			tc.tag.Inc(chunk.StateSplit)
+		}
+		copy(refs[int64(i)*tc.hashSize:], ref)
+	}
+	return nil
 }
 
 func (tc *TreeChunker) runWorker(ctx context.Context) {
@@ -351,6 +436,9 @@ func (tc *TreeChunker) runWorker(ctx context.Context) {
 					tc.errC <- err
 					return
 				}
+				if tc.tag != nil {
+					tc.tag.Inc(chunk.StateSplit)
+				}
 				copy(job.key, h)
 				job.parentWg.Done()
 			case <-tc.quitC:
@@ -360,31 +448,52 @@ func (tc *TreeChunker) runWorker(ctx context.Context) {
 	}()
 }
 
+// maxCachedBranches is the maximal number of branching chunks cached
+// by a LazyChunkReader, which is sufficient for the branches of 2GB
+// of content of a single reader with the default chunk size
+const maxCachedBranches = 512
+
 // LazyChunkReader implements LazySectionReader
 type LazyChunkReader struct {
-	ctx       context.Context
-	addr      Address // root address
-	chunkData ChunkData
-	off       int64 // offset
-	chunkSize int64 // inherit from chunker
-	branches  int64 // inherit from chunker
-	hashSize  int64 // inherit from chunker
-	depth     int
-	getter    Getter
+	ctx         context.Context
+	addr        Address // root address
+	chunkData   ChunkData
+	off         int64       // offset
+	chunkSize   int64       // inherit from chunker
+	branches    int64       // inherit from chunker
+	hashSize    int64       // inherit from chunker
+	parities    int64       // number of parity references of branching nodes, from the root chunk
+	hashFunc    SwarmHasher // verifies recovered chunks, the default hash if nil
+	depth       int
+	getter      Getter
+	branchCache map[string]ChunkData // retrieved branching chunks by address
+	branchMu    sync.Mutex           // protects branchCache
+	prefetch    int64                // number of chunks to retrieve after the read ones
+	prefetchOff int64                // start of the prefetched data
+	prefetchEnd int64                // end of the prefetched data
+	prefetchMu  sync.Mutex           // protects prefetchOff and prefetchEnd
 }
 
 func (tc *TreeChunker) Join(ctx context.Context) *LazyChunkReader {
 	return &LazyChunkReader{
-		addr:      tc.addr,
-		chunkSize: tc.chunkSize,
-		branches:  tc.branches,
-		hashSize:  tc.hashSize,
-		depth:     tc.depth,
-		getter:    tc.getter,
-		ctx:       tc.ctx,
+		addr:        tc.addr,
+		chunkSize:   tc.chunkSize,
+		branches:    tc.branches,
+		hashSize:    tc.hashSize,
+		depth:       tc.depth,
+		getter:      tc.getter,
+		ctx:         tc.ctx,
+		branchCache: make(map[string]ChunkData),
 	}
 }
 
+// SetPrefetch sets the number of chunks following the data read by ReadAt
+// that are retrieved in the background, so that they are available for the
+// next sequential reads. Prefetching is disabled if n is 0.
+func (r *LazyChunkReader) SetPrefetch(n int) {
+	r.prefetch = int64(n)
+}
+
 func (r *LazyChunkReader) Context() context.Context {
 	return r.ctx
 }
@@ -409,7 +518,11 @@ func (r *LazyChunkReader) Size(ctx context.Context, quitC chan bool) (n int64, e
 			return 0, err
 		}
 		metrics.GetOrRegisterResettingTimer("lcr/getter/get", nil).UpdateSince(startTime)
+		if err := r.checkRoot(chunkData); err != nil {
+			return 0, err
+		}
 		r.chunkData = chunkData
+		r.parities = int64(chunkData.Parities())
 	}
 
 	s := r.chunkData.Size()
@@ -441,21 +554,42 @@ func (r *LazyChunkReader) ReadAt(b []byte, off int64) (read int, err error) {
 	if len(b) == 0 {
 		return 0, nil
 	}
-	quitC := make(chan bool)
-	size, err := r.Size(cctx, quitC)
+	size, err := r.Size(cctx, nil)
 	if err != nil {
 		log.Debug("lazychunkreader.readat.size", "size", size, "err", err)
 		return 0, err
 	}
+	// no chunks need to be retrieved past the end
+	if off >= size {
+		return 0, io.EOF
+	}
+
+	if err := r.read(cctx, b, off, size); err != nil {
+		return 0, err
+	}
+	if off+int64(len(b)) >= size {
+		log.Debug("lazychunkreader.readat.return at end", "size", size, "off", off)
+		return int(size - off), io.EOF
+	}
+	if r.prefetch > 0 {
+		r.prefetchAfter(off+int64(len(b)), size)
+	}
+	log.Debug("lazychunkreader.readat.errc", "buff", len(b))
+	return len(b), nil
+}
 
+// read retrieves the chunks of the data at the offset, only traversing
+// the branches of the tree that the data spans, and copies it to b
+func (r *LazyChunkReader) read(ctx context.Context, b []byte, off int64, size int64) error {
+	quitC := make(chan bool)
 	errC := make(chan error)
 
-	// }
 	var treeSize int64
 	var depth int
 	// calculate depth and max treeSize
 	treeSize = r.chunkSize
-	for ; treeSize < size; treeSize *= r.branches {
+	branches := r.dataBranches()
+	for ; treeSize < size; treeSize *= branches {
 		depth++
 	}
 	wg := sync.WaitGroup{}
@@ -465,31 +599,89 @@ func (r *LazyChunkReader) ReadAt(b []byte, off int64) (read int, err error) {
 		length *= r.chunkSize
 	}
 	wg.Add(1)
-	go r.join(cctx, b, off, off+length, depth, treeSize/r.branches, r.chunkData, &wg, errC, quitC)
+	go r.join(ctx, b, off, off+length, depth, treeSize/branches, r.chunkData, &wg, errC, quitC)
 	go func() {
 		wg.Wait()
 		close(errC)
 	}()
 
-	err = <-errC
-	if err != nil {
+	if err := <-errC; err != nil {
 		log.Debug("lazychunkreader.readat.errc", "err", err)
 		close(quitC)
-		return 0, err
+		return err
 	}
-	if off+int64(len(b)) >= size {
-		log.Debug("lazychunkreader.readat.return at end", "size", size, "off", off)
-		return int(size - off), io.EOF
+	return nil
+}
+
+// prefetchAfter retrieves the chunks of the data following the offset in the
+// background, unless they are already being prefetched. Errors are ignored,
+// as they are returned when the data is read.
+func (r *LazyChunkReader) prefetchAfter(off, size int64) {
+	end := off + r.prefetch*r.chunkSize
+	if end > size {
+		end = size
+	}
+	r.prefetchMu.Lock()
+	if off >= r.prefetchOff && off < r.prefetchEnd {
+		off = r.prefetchEnd
+	} else {
+		r.prefetchOff = off
+	}
+	if off >= end {
+		r.prefetchMu.Unlock()
+		return
+	}
+	r.prefetchEnd = end
+	r.prefetchMu.Unlock()
+
+	metrics.GetOrRegisterCounter("lazychunkreader/prefetch", nil).Inc(1)
+	go func() {
+		if err := r.read(r.ctx, make([]byte, end-off), off, size); err != nil {
+			log.Trace("lazychunkreader.prefetch", "key", r.addr, "off", off, "err", err)
+		}
+	}()
+}
+
+// get retrieves the chunk with the reference. Branching chunks are
+// cached, as they are shared by the reads of subsequent ranges of data.
+func (r *LazyChunkReader) get(ctx context.Context, ref Reference) (ChunkData, error) {
+	r.branchMu.Lock()
+	chunkData, ok := r.branchCache[string(ref)]
+	r.branchMu.Unlock()
+	if ok {
+		metrics.GetOrRegisterCounter("lazychunkreader/branchcache/hit", nil).Inc(1)
+		return chunkData, nil
+	}
+	chunkData, err := r.getter.Get(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	r.cacheBranch(ref, chunkData)
+	return chunkData, nil
+}
+
+// cacheBranch caches the chunk data if it is of a branching chunk
+// and the cache is not full
+func (r *LazyChunkReader) cacheBranch(ref Reference, chunkData ChunkData) {
+	if len(chunkData) < 8 || !chunkData.Variable() && chunkData.Size() <= uint64(r.chunkSize) {
+		return
+	}
+	r.branchMu.Lock()
+	defer r.branchMu.Unlock()
+	if len(r.branchCache) < maxCachedBranches {
+		r.branchCache[string(ref)] = chunkData
 	}
-	log.Debug("lazychunkreader.readat.errc", "buff", len(b))
-	return len(b), nil
 }
 
 func (r *LazyChunkReader) join(ctx context.Context, b []byte, off int64, eoff int64, depth int, treeSize int64, chunkData ChunkData, parentWg *sync.WaitGroup, errC chan error, quitC chan bool) {
+	if chunkData.Variable() {
+		r.joinVariable(ctx, b, off, eoff, chunkData, parentWg, errC, quitC)
+		return
+	}
 	defer parentWg.Done()
 	// find appropriate block level
 	for chunkData.Size() < uint64(treeSize) && depth > r.depth {
-		treeSize /= r.branches
+		treeSize /= r.dataBranches()
 		depth--
 	}
 
@@ -513,6 +705,7 @@ func (r *LazyChunkReader) join(ctx context.Context, b []byte, off int64, eoff in
 		end = currentBranches
 	}
 
+	parent := chunkData
 	wg := &sync.WaitGroup{}
 	defer wg.Wait()
 	for i := start; i < end; i++ {
@@ -533,7 +726,13 @@ func (r *LazyChunkReader) join(ctx context.Context, b []byte, off int64, eoff in
 		go func(j int64) {
 			childAddress := chunkData[8+j*r.hashSize : 8+(j+1)*r.hashSize]
 			startTime := time.Now()
-			chunkData, err := r.getter.Get(ctx, Reference(childAddress))
+			chunkData, err := r.get(ctx, Reference(childAddress))
+			if err != nil && r.parities > 0 {
+				chunkData, err = r.recover(ctx, parent, j, treeSize)
+				if err == nil {
+					r.cacheBranch(Reference(childAddress), chunkData)
+				}
+			}
 			if err != nil {
 				metrics.GetOrRegisterResettingTimer("lcr/getter/get/err", nil).UpdateSince(startTime)
 				select {
@@ -555,11 +754,205 @@ func (r *LazyChunkReader) join(ctx context.Context, b []byte, off int64, eoff in
 			if soff < off {
 				soff = off
 			}
-			r.join(ctx, b[soff-off:seoff-off], soff-roff, seoff-roff, depth-1, treeSize/r.branches, chunkData, wg, errC, quitC)
+			r.join(ctx, b[soff-off:seoff-off], soff-roff, seoff-roff, depth-1, treeSize/r.dataBranches(), chunkData, wg, errC, quitC)
 		}(i)
 	} //for
 }
 
+// joinVariable reads the data of a chunk of content split with content defined
+// chunking. The children of intermediate chunks are located by their sizes
+// following their references, so only the ones spanning the data are retrieved.
+func (r *LazyChunkReader) joinVariable(ctx context.Context, b []byte, off int64, eoff int64, chunkData ChunkData, parentWg *sync.WaitGroup, errC chan error, quitC chan bool) {
+	defer parentWg.Done()
+
+	// leaf chunk
+	if !chunkData.Variable() {
+		if 8+eoff > int64(len(chunkData)) {
+			eoff = int64(len(chunkData)) - 8
+		}
+		copy(b, chunkData[8+off:8+eoff])
+		return
+	}
+
+	entrySize := r.hashSize + cdcSizeLength
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	var roff int64 // offset of the child
+	for i := int64(0); 8+(i+1)*entrySize <= int64(len(chunkData)) && roff < eoff; i++ {
+		entry := chunkData[8+i*entrySize : 8+(i+1)*entrySize]
+		reoff := roff + int64(binary.LittleEndian.Uint64(entry[r.hashSize:]))
+		if reoff <= off {
+			roff = reoff
+			continue
+		}
+		soff, seoff := roff, reoff
+		if soff < off {
+			soff = off
+		}
+		if seoff > eoff {
+			seoff = eoff
+		}
+		wg.Add(1)
+		go func(childAddress Address, roff, soff, seoff int64) {
+			startTime := time.Now()
+			chunkData, err := r.get(ctx, Reference(childAddress))
+			if err == nil && len(chunkData) < 8 {
+				err = fmt.Errorf("data length %v", len(chunkData))
+			}
+			if err != nil {
+				metrics.GetOrRegisterResettingTimer("lcr/getter/get/err", nil).UpdateSince(startTime)
+				select {
+				case errC <- fmt.Errorf("chunk %v-%v not found; key: %s: %v", soff, seoff, fmt.Sprintf("%x", childAddress), err):
+				case <-quitC:
+				}
+				wg.Done()
+				return
+			}
+			metrics.GetOrRegisterResettingTimer("lcr/getter/get", nil).UpdateSince(startTime)
+			r.joinVariable(ctx, b[soff-off:seoff-off], soff-roff, seoff-roff, chunkData, wg, errC, quitC)
+		}(Address(entry[:r.hashSize]), roff, soff, seoff)
+		roff = reoff
+	}
+}
+
+// dataBranches returns the maximal number of children of
+// branching nodes, which is less if the content is erasure coded
+func (r *LazyChunkReader) dataBranches() int64 {
+	return r.branches - r.parities
+}
+
+// recover reconstructs the data of the child at index i of the branching node
+// of erasure coded content from the data of its siblings and parity chunks.
+// It returns as soon as enough of them are retrieved.
+func (r *LazyChunkReader) recover(ctx context.Context, parent ChunkData, i int64, treeSize int64) (ChunkData, error) {
+	dataRefs := (int64(parent.Size()) + treeSize - 1) / treeSize
+	refs := dataRefs + r.parities
+	if int64(len(parent)) < 8+refs*r.hashSize {
+		return nil, fmt.Errorf("branching node with %d references, want %d", (int64(len(parent))-8)/r.hashSize, refs)
+	}
+	code, err := erasure.New(int(dataRefs), int(r.parities))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		index int64
+		data  ChunkData
+	}
+	// buffered for all siblings, so that getters do not block after return
+	resultC := make(chan result, refs)
+	for j := int64(0); j < refs; j++ {
+		if j == i {
+			continue
+		}
+		go func(j int64) {
+			data, err := r.getter.Get(ctx, Reference(parent[8+j*r.hashSize:8+(j+1)*r.hashSize]))
+			if err != nil {
+				data = nil
+			}
+			resultC <- result{index: j, data: data}
+		}(j)
+	}
+
+	shards := make([][]byte, refs)
+	var available int64
+	for n := int64(1); n < refs && available < dataRefs; n++ {
+		res := <-resultC
+		if res.data == nil {
+			continue
+		}
+		shards[res.index] = make([]byte, r.chunkSize+8)
+		copy(shards[res.index], res.data)
+		available++
+	}
+	if err := code.Reconstruct(shards); err != nil {
+		metrics.GetOrRegisterCounter("lazychunkreader/recover/err", nil).Inc(1)
+		return nil, err
+	}
+	metrics.GetOrRegisterCounter("lazychunkreader/recover", nil).Inc(1)
+
+	data := ChunkData(shards[i])
+	length := 8 + int64(data.Size()) // leaf chunks are not padded
+	if data.Parities() > 0 {
+		// branching chunks have as many references as their data requires
+		childTreeSize := treeSize / r.dataBranches()
+		length = 8 + ((int64(data.Size())+childTreeSize-1)/childTreeSize+r.parities)*r.hashSize
+	}
+	ref := parent[8+i*r.hashSize : 8+(i+1)*r.hashSize]
+	if length > int64(len(data)) {
+		metrics.GetOrRegisterCounter("lazychunkreader/recover/invalid", nil).Inc(1)
+		return nil, fmt.Errorf("recovered chunk %x: %w", ref, errRecoveredChunkInvalid)
+	}
+	data = data[:length]
+
+	// siblings or parity chunks may be corrupt
+	hashFunc := r.hashFunc
+	if hashFunc == nil {
+		hashFunc = MakeHashFunc(DefaultHash)
+	}
+	hasher := hashFunc()
+	hasher.Reset()
+	hasher.SetSpanBytes(data[:8])
+	hasher.Write(data[8:])
+	if !bytes.Equal(hasher.Sum(nil), ref) {
+		metrics.GetOrRegisterCounter("lazychunkreader/recover/invalid", nil).Inc(1)
+		return nil, fmt.Errorf("recovered chunk %x: %w", ref, errRecoveredChunkInvalid)
+	}
+	return data, nil
+}
+
+// checkRoot returns an error if the root chunk does not hold the references
+// to all the children its span requires, as it is the case with the data of
+// encrypted content decrypted with a wrong key
+func (r *LazyChunkReader) checkRoot(chunkData ChunkData) error {
+	if len(chunkData) < 8 {
+		return errInvalidRootChunk
+	}
+	size := int64(chunkData.Size())
+	if chunkData.Variable() {
+		// the sizes of the children of content defined chunks add up to the span
+		entrySize := r.hashSize + cdcSizeLength
+		if (int64(len(chunkData))-8)%entrySize != 0 {
+			return errInvalidRootChunk
+		}
+		var sum uint64
+		for i := int64(8); i < int64(len(chunkData)); i += entrySize {
+			sum += binary.LittleEndian.Uint64(chunkData[i+r.hashSize:])
+		}
+		if sum != chunkData.Size() {
+			return errInvalidRootChunk
+		}
+		return nil
+	}
+	if size <= r.chunkSize {
+		return nil
+	}
+	branches := r.branches - int64(chunkData.Parities())
+	if branches < 2 {
+		return errInvalidRootChunk
+	}
+	treeSize := r.chunkSize
+	for treeSize*branches < size {
+		treeSize *= branches
+	}
+	children := (size + treeSize - 1) / treeSize
+	if int64(len(chunkData)-8) < children*r.hashSize {
+		return errInvalidRootChunk
+	}
+	return nil
+}
+
+// errInvalidRootChunk is returned when the span of the root chunk
+// is not consistent with the references it holds
+var errInvalidRootChunk = errors.New("root chunk references do not cover its span")
+
+// errRecoveredChunkInvalid is returned when the data of a chunk reconstructed
+// from its siblings and parity chunks does not match its reference
+var errRecoveredChunkInvalid = errors.New("recovered chunk data does not match its reference")
+
 // Read keeps a cursor so cannot be called simulateously, see ReadAt
 func (r *LazyChunkReader) Read(b []byte) (read int, err error) {
 	log.Trace("lazychunkreader.read", "key", r.addr)
@@ -267,6 +267,61 @@ func TestRandomBrokenData(t *testing.T) {
 	}
 }
 
+// TestInvalidRootChunk tests that the size of content is not returned if
+// the span of its root chunk requires more references than the chunk holds
+func TestInvalidRootChunk(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		size  uint64
+		refs  int
+		sizes []uint64 // sizes of the children of content defined chunks
+		err   error
+	}{
+		{"leaf", 4096, 0, nil, nil},
+		{"complete", 3 * 4096, 3, nil, nil},
+		{"missing references", 3 * 4096, 2, nil, errInvalidRootChunk},
+		{"large span", 1 << 50, 4, nil, errInvalidRootChunk},
+		{"content defined", 3 * 4096, 0, []uint64{4096, 8192}, nil},
+		{"content defined sizes", 3 * 4096, 0, []uint64{4096, 4096}, errInvalidRootChunk},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			putGetter := newTestHasherStore(NewMapChunkStore(), SHA3Hash)
+			data := make([]byte, 8+tc.refs*32)
+			switch {
+			case tc.sizes != nil:
+				data = make([]byte, 8+len(tc.sizes)*(32+cdcSizeLength))
+				for i, size := range tc.sizes {
+					binary.LittleEndian.PutUint64(data[8+i*(32+cdcSizeLength)+32:], size)
+				}
+			case tc.refs == 0:
+				data = make([]byte, 8+tc.size)
+			}
+			span := tc.size
+			if tc.sizes != nil {
+				span |= spanVariableFlag
+			}
+			binary.LittleEndian.PutUint64(data, span)
+			ctx := context.Background()
+			addr, err := putGetter.Put(ctx, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			putGetter.Close()
+			if err := putGetter.Wait(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			size, err := TreeJoin(ctx, Address(addr), putGetter, 0).Size(ctx, nil)
+			if err != tc.err {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+			if err == nil && uint64(size) != tc.size {
+				t.Fatalf("got size %v, want %v", size, tc.size)
+			}
+		})
+	}
+}
+
 func benchReadAll(reader LazySectionReader) {
 	size, _ := reader.Size(context.TODO(), nil)
 	output := make([]byte, 1000)
@@ -368,6 +423,36 @@ func benchmarkSplitPyramidSHA3(n int, t *testing.B) {
 	}
 }
 
+// discardPutter is a Putter that neither hashes nor stores chunk data,
+// to measure the allocations of the splitter itself
+type discardPutter struct {
+	ref Reference
+}
+
+func (p *discardPutter) Put(context.Context, ChunkData) (Reference, error) { return p.ref, nil }
+func (p *discardPutter) RefSize() int64                                    { return int64(len(p.ref)) }
+func (p *discardPutter) Close()                                            {}
+func (p *discardPutter) Wait(context.Context) error                        { return nil }
+
+func benchmarkSplitPyramidDiscard(n int, t *testing.B) {
+	data := testutil.RandomBytes(1, n)
+	putter := &discardPutter{ref: make(Reference, 32)}
+
+	t.ReportAllocs()
+	t.ResetTimer()
+	for i := 0; i < t.N; i++ {
+		ctx := context.Background()
+		_, wait, err := PyramidSplit(ctx, bytes.NewReader(data), putter, nil, mockTag)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		err = wait(ctx)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+}
+
 func benchmarkSplitAppendPyramid(n, m int, t *testing.B) {
 	t.ReportAllocs()
 	for i := 0; i < t.N; i++ {
@@ -456,6 +541,10 @@ func BenchmarkSplitPyramidBMT_7(t *testing.B)  { benchmarkSplitPyramidBMT(100000
 
 // func BenchmarkSplitPyramidBMT_8(t *testing.B)  { benchmarkSplitPyramidBMT(100000000, t) }
 
+func BenchmarkSplitPyramidDiscard_4(t *testing.B) { benchmarkSplitPyramidDiscard(10000, t) }
+func BenchmarkSplitPyramidDiscard_5(t *testing.B) { benchmarkSplitPyramidDiscard(100000, t) }
+func BenchmarkSplitPyramidDiscard_6(t *testing.B) { benchmarkSplitPyramidDiscard(1000000, t) }
+
 func BenchmarkSplitAppendPyramid_2(t *testing.B)  { benchmarkSplitAppendPyramid(100, 1000, t) }
 func BenchmarkSplitAppendPyramid_2h(t *testing.B) { benchmarkSplitAppendPyramid(500, 1000, t) }
 func BenchmarkSplitAppendPyramid_3(t *testing.B)  { benchmarkSplitAppendPyramid(1000, 1000, t) }
@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"hash"
 	"sync"
+
+	"golang.org/x/crypto/sha3"
 )
 
 const KeyLength = 32
@@ -152,6 +154,16 @@ func GenerateRandomKey(l int) Key {
 	return key
 }
 
+// DeriveKey returns the key of a chunk encrypted under the master key, derived
+// from the seed that the reference of the chunk holds in place of the key,
+// so that the reference alone does not allow to decrypt the chunk
+func DeriveKey(master Key, seed []byte) Key {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(master)
+	h.Write(seed)
+	return h.Sum(nil)
+}
+
 func min(x, y int) int {
 	if x < y {
 		return x
@@ -0,0 +1,256 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package erasure implements a systematic Reed-Solomon erasure code over
+// GF(2^8). Data shards are extended with parity shards, so that any
+// data shards that are lost can be reconstructed from as many of the
+// remaining data or parity shards as there are data shards.
+package erasure
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTooFewShards is returned if there are not enough
+	// shards available to reconstruct the missing ones.
+	ErrTooFewShards = errors.New("too few shards")
+	// ErrShardSize is returned if the shards are not of the same size.
+	ErrShardSize = errors.New("shards of different size")
+)
+
+// MaxShards is the maximal number of data and parity shards.
+const MaxShards = 256
+
+// Code encodes and reconstructs a fixed number of data and parity shards.
+// The encoding matrix is the identity matrix for the data shards extended
+// with a Cauchy matrix for the parity shards, so that any square matrix of
+// its rows is invertible.
+type Code struct {
+	data   int
+	parity int
+	matrix [][]byte // (data+parity) x data encoding matrix
+}
+
+// New returns a Code with the given number of data and parity shards.
+func New(data, parity int) (*Code, error) {
+	if data <= 0 || parity < 0 || data+parity > MaxShards {
+		return nil, fmt.Errorf("invalid number of shards: data %d, parity %d", data, parity)
+	}
+	matrix := make([][]byte, data+parity)
+	for i := range matrix {
+		matrix[i] = make([]byte, data)
+		if i < data {
+			matrix[i][i] = 1
+			continue
+		}
+		for j := range matrix[i] {
+			// elements i and j are distinct, so their sum is not zero
+			matrix[i][j] = gfInv(byte(i) ^ byte(j))
+		}
+	}
+	return &Code{
+		data:   data,
+		parity: parity,
+		matrix: matrix,
+	}, nil
+}
+
+// Encode sets the parity shards computed from the data shards.
+// Shards must have all data shards first, followed by the parity
+// shards, which are allocated if nil.
+func (c *Code) Encode(shards [][]byte) error {
+	if len(shards) != c.data+c.parity {
+		return fmt.Errorf("got %d shards, want %d", len(shards), c.data+c.parity)
+	}
+	size, err := c.shardSize(shards[:c.data])
+	if err != nil {
+		return err
+	}
+	for i := c.data; i < len(shards); i++ {
+		if shards[i] == nil {
+			shards[i] = make([]byte, size)
+		}
+		if len(shards[i]) != size {
+			return ErrShardSize
+		}
+		mulRow(c.matrix[i], shards[:c.data], shards[i])
+	}
+	return nil
+}
+
+// Reconstruct sets all missing shards, the ones that are nil,
+// from the available ones. It returns ErrTooFewShards if less
+// shards are available than the number of data shards.
+func (c *Code) Reconstruct(shards [][]byte) error {
+	if len(shards) != c.data+c.parity {
+		return fmt.Errorf("got %d shards, want %d", len(shards), c.data+c.parity)
+	}
+	size, err := c.shardSize(shards)
+	if err != nil {
+		return err
+	}
+
+	// select the rows of the encoding matrix of the first
+	// available shards and invert them to decode the data
+	rows := make([][]byte, 0, c.data)
+	available := make([][]byte, 0, c.data)
+	for i, s := range shards {
+		if len(rows) == c.data {
+			break
+		}
+		if s != nil {
+			rows = append(rows, c.matrix[i])
+			available = append(available, s)
+		}
+	}
+	if len(rows) < c.data {
+		return ErrTooFewShards
+	}
+	decode, err := invert(rows)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < c.data; i++ {
+		if shards[i] == nil {
+			shards[i] = make([]byte, size)
+			mulRow(decode[i], available, shards[i])
+		}
+	}
+	for i := c.data; i < len(shards); i++ {
+		if shards[i] == nil {
+			shards[i] = make([]byte, size)
+			mulRow(c.matrix[i], shards[:c.data], shards[i])
+		}
+	}
+	return nil
+}
+
+// shardSize returns the size of the non nil shards
+// or ErrShardSize if they are not of the same size.
+func (c *Code) shardSize(shards [][]byte) (size int, err error) {
+	size = -1
+	for _, s := range shards {
+		if s == nil {
+			continue
+		}
+		if size == -1 {
+			size = len(s)
+		}
+		if len(s) != size {
+			return 0, ErrShardSize
+		}
+	}
+	if size == -1 {
+		return 0, ErrTooFewShards
+	}
+	return size, nil
+}
+
+// mulRow sets out to the linear combination of
+// the shards with coefficients of the matrix row.
+func mulRow(row []byte, shards [][]byte, out []byte) {
+	for k := range out {
+		out[k] = 0
+	}
+	for j, coef := range row {
+		if coef == 0 {
+			continue
+		}
+		for k, b := range shards[j] {
+			out[k] ^= gfMul(coef, b)
+		}
+	}
+}
+
+// invert returns the inverse of the square matrix
+// using Gauss-Jordan elimination.
+func invert(m [][]byte) ([][]byte, error) {
+	n := len(m)
+	// augmented matrix [m | I]
+	a := make([][]byte, n)
+	for i := range a {
+		a[i] = make([]byte, 2*n)
+		copy(a[i], m[i])
+		a[i][n+i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for r := col; r < n; r++ {
+			if a[r][col] != 0 {
+				pivot = r
+				break
+			}
+		}
+		if pivot == -1 {
+			return nil, errors.New("singular matrix")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		if inv := gfInv(a[col][col]); inv != 1 {
+			for k := range a[col] {
+				a[col][k] = gfMul(a[col][k], inv)
+			}
+		}
+		for r := 0; r < n; r++ {
+			if r == col || a[r][col] == 0 {
+				continue
+			}
+			f := a[r][col]
+			for k := range a[r] {
+				a[r][k] ^= gfMul(f, a[col][k])
+			}
+		}
+	}
+	inv := make([][]byte, n)
+	for i := range inv {
+		inv[i] = a[i][n:]
+	}
+	return inv, nil
+}
+
+// exp and log tables of GF(2^8) with the primitive polynomial
+// x^8 + x^4 + x^3 + x^2 + 1 and generator 2
+var (
+	gfExp [510]byte
+	gfLog [256]int
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfExp[i+255] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+}
+
+// gfMul multiplies two elements of GF(2^8).
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+// gfInv returns the multiplicative inverse of a non zero element of GF(2^8).
+func gfInv(a byte) byte {
+	return gfExp[255-gfLog[a]]
+}
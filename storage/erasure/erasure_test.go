@@ -0,0 +1,78 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package erasure
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// TestReconstruct tests that any missing shards are reconstructed
+// as long as as many shards are available as there are data shards.
+func TestReconstruct(t *testing.T) {
+	for _, tc := range []struct {
+		data   int
+		parity int
+	}{
+		{data: 1, parity: 1},
+		{data: 4, parity: 2},
+		{data: 112, parity: 16},
+		{data: 200, parity: 56},
+	} {
+		t.Run(fmt.Sprintf("data_%d_parity_%d", tc.data, tc.parity), func(t *testing.T) {
+			c, err := New(tc.data, tc.parity)
+			if err != nil {
+				t.Fatal(err)
+			}
+			shards := make([][]byte, tc.data+tc.parity)
+			for i := 0; i < tc.data; i++ {
+				shards[i] = make([]byte, 64)
+				rand.Read(shards[i])
+			}
+			if err := c.Encode(shards); err != nil {
+				t.Fatal(err)
+			}
+			want := make([][]byte, len(shards))
+			for i := range shards {
+				want[i] = append([]byte{}, shards[i]...)
+			}
+
+			// remove as many random shards as there are parity shards
+			for _, i := range rand.Perm(len(shards))[:tc.parity] {
+				shards[i] = nil
+			}
+			if err := c.Reconstruct(shards); err != nil {
+				t.Fatal(err)
+			}
+			for i := range shards {
+				if !bytes.Equal(shards[i], want[i]) {
+					t.Fatalf("shard %d not reconstructed", i)
+				}
+			}
+
+			// remove one shard too many
+			for _, i := range rand.Perm(len(shards))[:tc.parity+1] {
+				shards[i] = nil
+			}
+			if err := c.Reconstruct(shards); err != ErrTooFewShards {
+				t.Fatalf("got error %v, want %v", err, ErrTooFewShards)
+			}
+		})
+	}
+}
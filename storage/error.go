@@ -32,5 +32,5 @@ const (
 // Errors are the same as the ones in chunk package for backward compatibility.
 var (
 	ErrChunkNotFound = chunk.ErrChunkNotFound
-	ErrChunkInvalid  = chunk.ErrChunkNotFound
+	ErrChunkInvalid  = chunk.ErrChunkInvalid
 )
@@ -0,0 +1,120 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// fetchGroup coalesces concurrent fetches of the same chunk into a single
+// fetch and multiplexes its result to all callers. The shared fetch is not
+// bound to the context of any single caller, it is cancelled only when all
+// callers waiting for it are gone.
+type fetchGroup struct {
+	mu    sync.Mutex
+	calls map[string]*fetchCall
+}
+
+// fetchCall is a fetch in progress shared by its waiters
+type fetchCall struct {
+	done    chan struct{} // closed when the fetch returns
+	ch      Chunk
+	err     error
+	waiters int                // number of callers waiting for the result, guarded by fetchGroup.mu
+	cancel  context.CancelFunc // cancels the fetch context
+}
+
+// do calls fetch for the key unless a fetch for the same key is already in
+// progress, in which case it waits for its result. It returns when the
+// fetch is done or when ctx is done, whichever happens first. The fetch
+// context carries the values of ctx of the caller that started it.
+func (g *fetchGroup) do(ctx context.Context, key string, fetch func(ctx context.Context) (Chunk, error)) (Chunk, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*fetchCall)
+	}
+	c, ok := g.calls[key]
+	if ok {
+		c.waiters++
+		g.mu.Unlock()
+		metrics.GetOrRegisterCounter("netstore/get/coalesced", nil).Inc(1)
+	} else {
+		fctx, cancel := context.WithCancel(valueContext{ctx})
+		c = &fetchCall{
+			done:    make(chan struct{}),
+			waiters: 1,
+			cancel:  cancel,
+		}
+		g.calls[key] = c
+		g.mu.Unlock()
+		metrics.GetOrRegisterCounter("netstore/get/fetch", nil).Inc(1)
+
+		go func() {
+			c.ch, c.err = fetch(fctx)
+			g.mu.Lock()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			cancel()
+			close(c.done)
+		}()
+	}
+
+	select {
+	case <-c.done:
+		return c.ch, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			// nobody is interested in the result anymore, a new caller
+			// starts a new fetch instead of waiting for the cancelled one
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+			c.cancel()
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// valueContext is a context with the values of the wrapped context,
+// but without its deadline and cancellation
+type valueContext struct {
+	context.Context
+}
+
+// Deadline implements context.Context
+func (valueContext) Deadline() (deadline time.Time, ok bool) {
+	return time.Time{}, false
+}
+
+// Done implements context.Context
+func (valueContext) Done() <-chan struct{} {
+	return nil
+}
+
+// Err implements context.Context
+func (valueContext) Err() error {
+	return nil
+}
@@ -18,11 +18,15 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"sort"
 	"sync"
 
 	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/sctx"
+	"github.com/ethersphere/swarm/storage/encryption"
 	"github.com/ethersphere/swarm/storage/localstore"
 )
 
@@ -38,6 +42,24 @@ As the chunker produces chunks, FileStore dispatches them to its own chunk store
 implementation for storage or retrieval.
 */
 
+// Chunkers content can be split with, selected by sctx.SetChunker
+const (
+	ChunkerPyramid = "pyramid" // fixed size chunks, the default
+	ChunkerCDC     = "cdc"     // content defined chunks, see CDCSplit
+)
+
+var (
+	// errErasureEncrypted is returned by Store if the content
+	// is to be encrypted while erasure coding is enabled
+	errErasureEncrypted = errors.New("erasure coding of encrypted content is not supported")
+	// errCDCUnsupported is returned by Store if content defined chunking is
+	// selected for content to be encrypted or while erasure coding is enabled
+	errCDCUnsupported = errors.New("content defined chunking of encrypted or erasure coded content is not supported")
+	// errMasterKeyLength is returned if the master encryption key
+	// set with sctx.SetEncryptionKey is not of encryption.KeyLength
+	errMasterKeyLength = fmt.Errorf("master encryption key must be %d bytes long", encryption.KeyLength)
+)
+
 const (
 	defaultLDBCapacity   = 5000000 // capacity for LevelDB, by default 5*10^6*4096 bytes == 20GB
 	defaultCacheCapacity = 10000   // capacity for in-memory chunks' cache
@@ -47,16 +69,37 @@ type FileStore struct {
 	ChunkStore
 	putterStore ChunkStore
 	hashFunc    SwarmHasher
+	parities    int
+	processors  int
+	workers     int
+	bufferPool  *sync.Pool
 	tags        *chunk.Tags
 }
 
 type FileStoreParams struct {
 	Hash string
+	// Parities is the number of Reed-Solomon parity chunks of every branching
+	// chunk of stored content, with which missing chunks can be reconstructed
+	// on retrieval. Erasure coding is disabled if it is 0.
+	Parities int
+	// ChunkProcessors is the maximal number of routines hashing
+	// chunks of a file concurrently while it is split.
+	ChunkProcessors int
+	// StorageWorkers is the maximal number of chunks of a file
+	// put to the chunk store concurrently.
+	StorageWorkers int
+	// BufferPool is the pool of buffers file data is read into when it is
+	// split, with buffers of chunk.DefaultSize+8 bytes, which are allocated
+	// if the pool is empty. The pool is shared by all file stores if it is nil.
+	BufferPool *sync.Pool `toml:"-"`
 }
 
 func NewFileStoreParams() *FileStoreParams {
 	return &FileStoreParams{
-		Hash: DefaultHash,
+		Hash:            DefaultHash,
+		ChunkProcessors: ChunkProcessors,
+		StorageWorkers:  noOfStorageWorkers,
+		BufferPool:      chunkBufferPool,
 	}
 }
 
@@ -69,17 +112,32 @@ func NewLocalFileStore(datadir string, basekey []byte, tags *chunk.Tags) (*FileS
 	cleanup := func() {
 		localStore.Close()
 	}
-	return NewFileStore(chunk.NewValidatorStore(localStore, NewContentAddressValidator(MakeHashFunc(DefaultHash))), localStore, NewFileStoreParams(), tags), cleanup, nil
+	return NewFileStore(chunk.NewValidatorStore(localStore, NewContentAddressValidator(MakeHashFunc(DefaultHash)), NewSOCValidator(MakeHashFunc(DefaultHash))), localStore, NewFileStoreParams(), tags), cleanup, nil
 }
 
 func NewFileStore(store ChunkStore, putterStore ChunkStore, params *FileStoreParams, tags *chunk.Tags) *FileStore {
 	hashFunc := MakeHashFunc(params.Hash)
-	return &FileStore{
+	f := &FileStore{
 		ChunkStore:  store,
 		putterStore: putterStore,
 		hashFunc:    hashFunc,
+		parities:    params.Parities,
+		processors:  params.ChunkProcessors,
+		workers:     params.StorageWorkers,
+		bufferPool:  params.BufferPool,
 		tags:        tags,
 	}
+	// unset settings, such as of params loaded from older configs, fall back to defaults
+	if f.processors <= 0 {
+		f.processors = ChunkProcessors
+	}
+	if f.workers <= 0 {
+		f.workers = noOfStorageWorkers
+	}
+	if f.bufferPool == nil {
+		f.bufferPool = chunkBufferPool
+	}
+	return f
 }
 
 // Retrieve is a public API. Main entry point for document retrieval directly. Used by the
@@ -87,6 +145,8 @@ func NewFileStore(store ChunkStore, putterStore ChunkStore, params *FileStorePar
 // Chunk retrieval blocks on netStore requests with a timeout so reader will
 // report error if retrieval of chunks within requested range time out.
 // It returns a reader with the chunk data and whether the content was encrypted
+// Content encrypted under a master key is decrypted with the key set with
+// sctx.SetEncryptionKey in the context.
 func (f *FileStore) Retrieve(ctx context.Context, addr Address) (reader *LazyChunkReader, isEncrypted bool) {
 	isEncrypted = len(addr) > f.hashFunc().Size()
 	tag, err := f.tags.GetFromContext(ctx)
@@ -95,12 +155,18 @@ func (f *FileStore) Retrieve(ctx context.Context, addr Address) (reader *LazyChu
 	}
 
 	getter := NewHasherStore(f.ChunkStore, f.hashFunc, isEncrypted, tag)
+	if isEncrypted {
+		getter.masterKey = sctx.GetEncryptionKey(ctx)
+	}
 	reader = TreeJoin(ctx, addr, getter, 0)
+	reader.hashFunc = f.hashFunc
 	return
 }
 
 // Store is a public API. Main entry point for document storage directly. Used by the
 // FS-aware API and httpaccess
+// Content is encrypted under the master key set with sctx.SetEncryptionKey in the
+// context if any, with chunk keys derived from it instead of held by references.
 func (f *FileStore) Store(ctx context.Context, data io.Reader, size int64, toEncrypt bool) (addr Address, wait func(context.Context) error, err error) {
 	tag, err := f.tags.GetFromContext(ctx)
 	if err != nil {
@@ -111,8 +177,54 @@ func (f *FileStore) Store(ctx context.Context, data io.Reader, size int64, toEnc
 		tag = chunk.NewTag(0, "", 0, false)
 		//return nil, nil, err
 	}
-	putter := NewHasherStore(f.putterStore, f.hashFunc, toEncrypt, tag)
-	return PyramidSplit(ctx, data, putter, putter, tag)
+	masterKey := sctx.GetEncryptionKey(ctx)
+	if masterKey != nil {
+		if len(masterKey) != encryption.KeyLength {
+			return nil, nil, errMasterKeyLength
+		}
+		toEncrypt = true
+	}
+	putter := newHasherStore(f.putterStore, f.hashFunc, toEncrypt, tag, f.workers)
+	putter.masterKey = masterKey
+	switch chunker := sctx.GetChunker(ctx); chunker {
+	case "", ChunkerPyramid:
+	case ChunkerCDC:
+		if toEncrypt || f.parities > 0 {
+			return nil, nil, errCDCUnsupported
+		}
+		return CDCSplit(ctx, data, putter, tag)
+	default:
+		return nil, nil, fmt.Errorf("unknown chunker %q", chunker)
+	}
+	if f.parities > 0 {
+		if toEncrypt {
+			return nil, nil, errErasureEncrypted
+		}
+		return ErasureSplit(ctx, data, size, putter, f.parities, tag)
+	}
+	return f.split(ctx, data, putter, putter, tag)
+}
+
+// split splits the data with the pyramid chunker using the
+// concurrency and buffer pool settings of the FileStore
+func (f *FileStore) split(ctx context.Context, data io.Reader, putter Putter, getter Getter, tag *chunk.Tag) (Address, func(context.Context) error, error) {
+	params := NewPyramidSplitterParams(nil, data, putter, getter, chunk.DefaultSize)
+	params.processors = int64(f.processors)
+	params.bufferPool = f.bufferPool
+	return NewPyramidSplitter(params, tag).Split(ctx)
+}
+
+// Reencrypt stores the content with the address encrypted under the master key,
+// or with random chunk keys held by references if it is nil, so that content can
+// be shared with a new key. The content is decrypted with the master key set
+// with sctx.SetEncryptionKey in the context, if it is encrypted under one.
+func (f *FileStore) Reencrypt(ctx context.Context, addr Address, masterKey []byte) (Address, func(context.Context) error, error) {
+	reader, _ := f.Retrieve(ctx, addr)
+	size, err := reader.Size(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f.Store(sctx.SetEncryptionKey(ctx, masterKey), reader, size, true)
 }
 
 func (f *FileStore) HashSize() int {
@@ -120,15 +232,27 @@ func (f *FileStore) HashSize() int {
 }
 
 // GetAllReferences is a public API. This endpoint returns all chunk hashes (only) for a given file
+// Chunks are stored in the ChunkStore of the FileStore, see References to only compute the hashes
 func (f *FileStore) GetAllReferences(ctx context.Context, data io.Reader) (addrs AddressCollection, err error) {
+	return f.references(ctx, data, f.ChunkStore)
+}
+
+// References returns all chunk hashes for a given file, as GetAllReferences does,
+// but without storing any chunks, as they are only hashed in memory
+func (f *FileStore) References(ctx context.Context, data io.Reader) (addrs AddressCollection, err error) {
+	return f.references(ctx, data, &FakeChunkStore{})
+}
+
+// references splits the data putting chunks to the store and returns their sorted hashes
+func (f *FileStore) references(ctx context.Context, data io.Reader, store ChunkStore) (addrs AddressCollection, err error) {
 	tag := chunk.NewTag(0, "ephemeral-tag", 0, false) //this tag is just a mock ephemeral tag since we don't want to save these results
 
 	// create a special kind of putter, which only will store the references
 	putter := &hashExplorer{
-		hasherStore: NewHasherStore(f.ChunkStore, f.hashFunc, false, tag),
+		hasherStore: newHasherStore(store, f.hashFunc, false, tag, f.workers),
 	}
 	// do the actual splitting anyway, no way around it
-	_, wait, err := PyramidSplit(ctx, data, putter, putter, tag)
+	_, wait, err := f.split(ctx, data, putter, putter, tag)
 	if err != nil {
 		return nil, err
 	}
@@ -23,9 +23,14 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/sctx"
+	"github.com/ethersphere/swarm/storage/encryption"
 	"github.com/ethersphere/swarm/storage/localstore"
 	"github.com/ethersphere/swarm/testutil"
 )
@@ -169,6 +174,124 @@ func testFileStoreCapacity(toEncrypt bool, t *testing.T) {
 	}
 }
 
+// TestFileStoreErasure tests that erasure coded content is retrieved
+// if no more chunks are missing under any branching chunk than the
+// number of its parities, and that it is not if more are missing.
+func TestFileStoreErasure(t *testing.T) {
+	parities := 16
+	branches := chunk.DefaultSize/AddressLength - parities
+	// two full subtrees under the root and a third with two chunks
+	size := 2*branches*chunk.DefaultSize + 5000
+
+	store := NewMapChunkStore()
+	params := NewFileStoreParams()
+	params.Parities = parities
+	fileStore := NewFileStore(store, store, params, chunk.NewTags())
+
+	slice := testutil.RandomBytes(1, size)
+	ctx := context.Background()
+	addr, wait, err := fileStore.Store(ctx, bytes.NewReader(slice), int64(size), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// ref returns the i-th reference of the chunk with the address
+	ref := func(addr Address, i int) Address {
+		ch, err := store.Get(ctx, chunk.ModeGetRequest, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Address(ch.Data()[8+i*AddressLength : 8+(i+1)*AddressLength])
+	}
+	remove := func(addr Address) {
+		store.mu.Lock()
+		delete(store.chunks, addr.Hex())
+		store.mu.Unlock()
+	}
+	retrieve := func() ([]byte, error) {
+		reader, _ := fileStore.Retrieve(ctx, addr)
+		data := make([]byte, size)
+		_, err := reader.ReadAt(data, 0)
+		if err != io.EOF {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	// remove as many chunks under every branching chunk as there are parities
+	for i, leaves := range []int{parities, parities, 2} {
+		child := ref(addr, i)
+		for j := 0; j < leaves; j++ {
+			remove(ref(child, j))
+		}
+	}
+	remove(ref(addr, 0))
+
+	data, err := retrieve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, slice) {
+		t.Fatal("retrieved data not equal to stored data")
+	}
+
+	// remove one more chunk under a branching chunk
+	remove(ref(ref(addr, 1), parities))
+	if _, err := retrieve(); err == nil {
+		t.Fatal("retrieved data with too many missing chunks")
+	}
+}
+
+// TestFileStoreErasureCorrupt tests that erasure coded content is not
+// retrieved if a missing chunk is reconstructed from corrupt parity chunks
+func TestFileStoreErasureCorrupt(t *testing.T) {
+	parities := 16
+	size := 5000
+
+	store := NewMapChunkStore()
+	params := NewFileStoreParams()
+	params.Parities = parities
+	fileStore := NewFileStore(store, store, params, chunk.NewTags())
+
+	slice := testutil.RandomBytes(1, size)
+	ctx := context.Background()
+	addr, wait, err := fileStore.Store(ctx, bytes.NewReader(slice), int64(size), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := store.Get(ctx, chunk.ModeGetRequest, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := func(i int) string {
+		return Address(root.Data()[8+i*AddressLength : 8+(i+1)*AddressLength]).Hex()
+	}
+	store.mu.Lock()
+	// the root chunk has references to two leaf chunks and the parity chunks
+	delete(store.chunks, ref(0))
+	for i := 2; i < 2+parities; i++ {
+		ch := store.chunks[ref(i)]
+		data := make([]byte, len(ch.Data()))
+		copy(data, ch.Data())
+		data[8] ^= 0xff
+		store.chunks[ref(i)] = NewChunk(ch.Address(), data)
+	}
+	store.mu.Unlock()
+
+	reader, _ := fileStore.Retrieve(ctx, addr)
+	data := make([]byte, size)
+	if _, err := reader.ReadAt(data, 0); err == nil || err == io.EOF {
+		t.Fatalf("got error %v, want error for chunk recovered from corrupt parities", err)
+	}
+}
+
 // TestGetAllReferences only tests that GetAllReferences returns an expected
 // number of references for a given file
 func TestGetAllReferences(t *testing.T) {
@@ -200,3 +323,268 @@ func TestGetAllReferences(t *testing.T) {
 		}
 	}
 }
+
+// TestReferences tests that References returns the same references
+// as GetAllReferences without storing any chunks.
+func TestReferences(t *testing.T) {
+	store := NewMapChunkStore()
+	fileStore := NewFileStore(store, store, NewFileStoreParams(), chunk.NewTags())
+
+	slice := testutil.RandomBytes(1, 1000000)
+	refs, err := fileStore.References(context.Background(), bytes.NewReader(slice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(store.chunks); l != 0 {
+		t.Fatalf("got %d stored chunks, want 0", l)
+	}
+
+	addrs, err := fileStore.GetAllReferences(context.Background(), bytes.NewReader(slice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != len(addrs) {
+		t.Fatalf("got %d references, want %d", len(refs), len(addrs))
+	}
+	for i := range refs {
+		if !bytes.Equal(refs[i], addrs[i]) {
+			t.Fatalf("got reference %s at %d, want %s", refs[i], i, addrs[i])
+		}
+	}
+}
+
+// TestFileStoreParallelism tests that content is stored with the same
+// address regardless of the concurrency and buffer pool settings.
+func TestFileStoreParallelism(t *testing.T) {
+	size := 1000000
+	slice := testutil.RandomBytes(1, size)
+	ctx := context.Background()
+
+	store := func(params *FileStoreParams) Address {
+		s := NewMapChunkStore()
+		fileStore := NewFileStore(s, s, params, chunk.NewTags())
+		addr, wait, err := fileStore.Store(ctx, bytes.NewReader(slice), int64(size), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return addr
+	}
+	want := store(NewFileStoreParams())
+
+	for _, tc := range []struct {
+		processors int
+		workers    int
+		pool       bool
+	}{
+		{1, 1, false},
+		{1, 150, true},
+		{32, 1, true},
+		{0, 0, false},
+	} {
+		params := NewFileStoreParams()
+		params.ChunkProcessors = tc.processors
+		params.StorageWorkers = tc.workers
+		params.BufferPool = nil
+		if tc.pool {
+			params.BufferPool = &sync.Pool{}
+		}
+		if addr := store(params); !bytes.Equal(addr, want) {
+			t.Errorf("processors %d, workers %d, pool %v: got address %s, want %s", tc.processors, tc.workers, tc.pool, addr, want)
+		}
+	}
+}
+
+// TestFileStoreMasterKey tests that content encrypted under a master key
+// is only decrypted with it and that it can be encrypted under another one.
+func TestFileStoreMasterKey(t *testing.T) {
+	store := NewMapChunkStore()
+	fileStore := NewFileStore(store, store, NewFileStoreParams(), chunk.NewTags())
+
+	size := 500000
+	slice := testutil.RandomBytes(1, size)
+	key := testutil.RandomBytes(2, encryption.KeyLength)
+	ctx := sctx.SetEncryptionKey(context.Background(), key)
+
+	addr, wait, err := fileStore.Store(ctx, bytes.NewReader(slice), int64(size), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if l := len(addr); l != fileStore.HashSize()+encryption.KeyLength {
+		t.Fatalf("got address of length %d, want encrypted reference", l)
+	}
+
+	// retrieve returns whether the content with the address is retrieved
+	// with the master key in the context
+	retrieve := func(ctx context.Context, addr Address) bool {
+		reader, isEncrypted := fileStore.Retrieve(ctx, addr)
+		if !isEncrypted {
+			t.Fatal("content is not encrypted")
+		}
+		got := make([]byte, size)
+		if _, err := reader.ReadAt(got, 0); err != nil && err != io.EOF {
+			return false
+		}
+		return bytes.Equal(got, slice)
+	}
+	if !retrieve(ctx, addr) {
+		t.Fatal("content not retrieved with the master key")
+	}
+	if retrieve(context.Background(), addr) {
+		t.Fatal("content retrieved without the master key")
+	}
+	otherCtx := sctx.SetEncryptionKey(context.Background(), testutil.RandomBytes(3, encryption.KeyLength))
+	if retrieve(otherCtx, addr) {
+		t.Fatal("content retrieved with another master key")
+	}
+
+	// rotate the key
+	newKey := testutil.RandomBytes(4, encryption.KeyLength)
+	newAddr, wait, err := fileStore.Reencrypt(ctx, addr, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !retrieve(sctx.SetEncryptionKey(context.Background(), newKey), newAddr) {
+		t.Fatal("content not retrieved with the new master key")
+	}
+	if retrieve(ctx, newAddr) {
+		t.Fatal("content retrieved with the old master key")
+	}
+
+	ctx = sctx.SetEncryptionKey(context.Background(), key[:16])
+	if _, _, err := fileStore.Store(ctx, bytes.NewReader(slice), int64(size), false); err != errMasterKeyLength {
+		t.Fatalf("got error %v, want %v", err, errMasterKeyLength)
+	}
+}
+
+// countingChunkStore counts the chunks retrieved from the embedded store
+type countingChunkStore struct {
+	*MapChunkStore
+	gets int64
+}
+
+func (s *countingChunkStore) Get(ctx context.Context, mode chunk.ModeGet, ref Address) (Chunk, error) {
+	atomic.AddInt64(&s.gets, 1)
+	return s.MapChunkStore.Get(ctx, mode, ref)
+}
+
+// TestFileStoreRangeRead tests that reading a range of content only
+// retrieves the chunks of the branches spanning the range, retrieving
+// branching chunks once, and that the configured number of chunks
+// following the range are prefetched.
+func TestFileStoreRangeRead(t *testing.T) {
+	size := 5000000
+	branches := chunk.DefaultSize / AddressLength
+	store := &countingChunkStore{MapChunkStore: NewMapChunkStore()}
+	fileStore := NewFileStore(store, store, NewFileStoreParams(), chunk.NewTags())
+
+	slice := testutil.RandomBytes(1, size)
+	ctx := context.Background()
+	addr, wait, err := fileStore.Store(ctx, bytes.NewReader(slice), int64(size), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, _ := fileStore.Retrieve(ctx, addr)
+	// expectGets checks the number of chunks retrieved since the last check,
+	// waiting for any prefetching to finish
+	var gets int64
+	expectGets := func(want int64) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			got := atomic.LoadInt64(&store.gets) - gets
+			if got == want {
+				gets += got
+				return
+			}
+			if got > want || time.Now().After(deadline) {
+				t.Fatalf("got %d retrieved chunks, want %d", got, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	read := func(off, length int64) {
+		t.Helper()
+		b := make([]byte, length)
+		n, err := reader.ReadAt(b, off)
+		if err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b[:n], slice[off:off+int64(n)]) {
+			t.Fatalf("read data at %d differs", off)
+		}
+	}
+
+	// root chunk
+	if _, err := reader.Size(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	expectGets(1)
+	// one branching chunk and a leaf chunk under it
+	read(3000000, 100)
+	expectGets(2)
+	// the next leaf chunk under the same branching chunk
+	read(3000000+chunk.DefaultSize, 100)
+	expectGets(1)
+	// the last two leaf chunks of one branching chunk
+	// and the first leaf chunk of the next one
+	off := int64(7*branches*chunk.DefaultSize - 2*chunk.DefaultSize)
+	read(off, 3*chunk.DefaultSize)
+	expectGets(5)
+	// past the end
+	if n, err := reader.ReadAt(make([]byte, 10), int64(size)); n != 0 || err != io.EOF {
+		t.Fatalf("got %d, %v reading past the end, want 0, EOF", n, err)
+	}
+	expectGets(0)
+
+	reader.SetPrefetch(4)
+	off = int64(2 * branches * chunk.DefaultSize)
+	// one branching chunk and a leaf chunk under it
+	// and the four leaf chunks following it
+	read(off, chunk.DefaultSize)
+	expectGets(2 + 4)
+	// the read leaf chunk and the one following the prefetched ones
+	read(off+chunk.DefaultSize, chunk.DefaultSize)
+	expectGets(1 + 1)
+}
+
+func benchmarkFileStoreStore(processors, workers int, t *testing.B) {
+	params := NewFileStoreParams()
+	params.ChunkProcessors = processors
+	params.StorageWorkers = workers
+	fileStore := NewFileStore(&FakeChunkStore{}, &FakeChunkStore{}, params, chunk.NewTags())
+	n := 1000000
+
+	t.ReportAllocs()
+	for i := 0; i < t.N; i++ {
+		data := testutil.RandomReader(i, n)
+
+		ctx := context.Background()
+		_, wait, err := fileStore.Store(ctx, data, int64(n), false)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		err = wait(ctx)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+}
+
+func BenchmarkFileStoreStore_1_1(t *testing.B)    { benchmarkFileStoreStore(1, 1, t) }
+func BenchmarkFileStoreStore_1_150(t *testing.B)  { benchmarkFileStoreStore(1, 150, t) }
+func BenchmarkFileStoreStore_8_1(t *testing.B)    { benchmarkFileStoreStore(8, 1, t) }
+func BenchmarkFileStoreStore_8_150(t *testing.B)  { benchmarkFileStoreStore(8, 150, t) }
+func BenchmarkFileStoreStore_32_150(t *testing.B) { benchmarkFileStoreStore(32, 150, t) }
@@ -0,0 +1,165 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/log"
+)
+
+// maxGatewayContentSize is the maximal size of the content under a chunk
+// address that is downloaded from a gateway
+const maxGatewayContentSize = 64 * 1024 * 1024
+
+// errGatewayContentMismatch is returned if the content downloaded from
+// a gateway does not hash to the requested chunk address
+var errGatewayContentMismatch = errors.New("gateway content does not match the chunk address")
+
+// GatewayFallback fetches chunks that could not be retrieved from the
+// network from trusted HTTP gateways. The content under the chunk address
+// is downloaded over the bzz-raw scheme and split into chunks, which are
+// stored in the NetStore if the content hashes to the chunk address.
+type GatewayFallback struct {
+	netStore *NetStore
+	urls     []string
+	client   *http.Client
+	params   *FileStoreParams
+	logger   log.Logger
+}
+
+// NewGatewayFallback returns a GatewayFallback that stores chunks in the
+// NetStore and fetches them from gateways with the provided urls, in order
+func NewGatewayFallback(ns *NetStore, urls []string) *GatewayFallback {
+	return &GatewayFallback{
+		netStore: ns,
+		urls:     urls,
+		client:   http.DefaultClient,
+		params:   NewFileStoreParams(),
+		logger:   ns.logger,
+	}
+}
+
+// Fetch downloads the chunk with the provided address from the first
+// gateway that serves it and stores the chunk with the chunks of the
+// content under it in the NetStore. It is a NetStore FallbackFunc.
+func (g *GatewayFallback) Fetch(ctx context.Context, ref Address) (Chunk, error) {
+	var err error
+	for _, url := range g.urls {
+		var ch Chunk
+		ch, err = g.fetch(ctx, url, ref)
+		if err == nil {
+			metrics.GetOrRegisterCounter("netstore/fallback/gateway/fetch", nil).Inc(1)
+			return ch, nil
+		}
+		g.logger.Debug("gateway fallback fetch", "gateway", url, "ref", ref, "err", err)
+		metrics.GetOrRegisterCounter("netstore/fallback/gateway/fail", nil).Inc(1)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	if err == nil {
+		err = errors.New("no gateways")
+	}
+	return nil, err
+}
+
+// fetch downloads the content under the chunk address from the gateway
+// and stores its chunks if they hash to the chunk address
+func (g *GatewayFallback) fetch(ctx context.Context, url string, ref Address) (Chunk, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(url, "/")+"/bzz-raw:/"+ref.Hex(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := g.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status %s", res.Status)
+	}
+	if res.ContentLength > maxGatewayContentSize {
+		return nil, fmt.Errorf("content size %d exceeds limit", res.ContentLength)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxGatewayContentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxGatewayContentSize {
+		return nil, errors.New("content size exceeds limit")
+	}
+
+	// split the content to the chunks it was uploaded as
+	c := &chunkCollector{Store: g.netStore.Store}
+	addr, wait, err := NewFileStore(c, c, g.params, chunk.NewTags()).Store(ctx, bytes.NewReader(data), int64(len(data)), false)
+	if err != nil {
+		return nil, err
+	}
+	if err := wait(ctx); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(addr, ref) {
+		return nil, errGatewayContentMismatch
+	}
+
+	chunks := c.all()
+	if _, err := g.netStore.Put(ctx, chunk.ModePutRequest, chunks...); err != nil {
+		return nil, err
+	}
+	for _, ch := range chunks {
+		if bytes.Equal(ch.Address(), ref) {
+			return ch, nil
+		}
+	}
+	return nil, errGatewayContentMismatch
+}
+
+// chunkCollector is a chunk store that collects the chunks that are put
+// instead of storing them, so that they are stored only when verified
+type chunkCollector struct {
+	chunk.Store
+	chunks []Chunk
+	mu     sync.Mutex
+}
+
+// Put collects the chunks
+func (c *chunkCollector) Put(_ context.Context, _ chunk.ModePut, chs ...Chunk) ([]bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.chunks = append(c.chunks, chs...)
+	return make([]bool, len(chs)), nil
+}
+
+// all returns the collected chunks
+func (c *chunkCollector) all() []Chunk {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.chunks
+}
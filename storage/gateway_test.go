@@ -0,0 +1,142 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/testutil"
+)
+
+// newTestGateway returns a gateway server that serves the content
+// under the provided address over the bzz-raw scheme
+func newTestGateway(addr Address, content []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bzz-raw:/"+addr.Hex() {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+}
+
+// TestNetStoreGatewayFallback tests that a chunk that can not be retrieved
+// from the network is fetched from a gateway and verified before stored.
+func TestNetStoreGatewayFallback(t *testing.T) {
+	// content that spans multiple chunks
+	content := testutil.RandomBytes(1, 3*chunk.DefaultSize)
+	store := NewMapChunkStore()
+	fileStore := NewFileStore(store, store, NewFileStoreParams(), chunk.NewTags())
+	ctx := context.Background()
+	addr, wait, err := fileStore.Store(ctx, bytes.NewReader(content), int64(len(content)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	root, err := store.Get(ctx, chunk.ModeGetRequest, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a gateway that serves other content under the address
+	bad := newTestGateway(addr, content[:len(content)-1])
+	defer bad.Close()
+	good := newTestGateway(addr, content)
+	defer good.Close()
+
+	n := NewNetStore(NewMapChunkStore(), network.RandomBzzAddr())
+	n.RemoteGet = func(ctx context.Context, req *Request, localID enode.ID) (*enode.ID, func(), error) {
+		return nil, func() {}, ErrNoSuitablePeer
+	}
+
+	n.Fallback = NewGatewayFallback(n, []string{bad.URL}).Fetch
+	_, err = n.Get(ctx, chunk.ModeGetRequest, NewRequest(addr))
+	if !errors.Is(err, errGatewayContentMismatch) {
+		t.Fatalf("got error %v, want %v", err, errGatewayContentMismatch)
+	}
+	if has, _ := n.Store.Has(ctx, addr); has {
+		t.Fatal("chunk with mismatching content stored")
+	}
+
+	n.Fallback = NewGatewayFallback(n, []string{bad.URL, good.URL}).Fetch
+	ch, err := n.Get(ctx, chunk.ModeGetRequest, NewRequest(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ch.Data(), root.Data()) {
+		t.Fatal("got wrong chunk data")
+	}
+
+	// chunks of the content under the address are stored as well
+	for _, ch := range store.chunks {
+		if has, _ := n.Store.Has(ctx, ch.Address()); !has {
+			t.Fatalf("chunk %s not stored", ch.Address())
+		}
+	}
+}
+
+// TestNetStoreGatewayFallbackMissing tests that a chunk that was recently
+// not found on the network is fetched from a gateway without searching
+// the network again.
+func TestNetStoreGatewayFallbackMissing(t *testing.T) {
+	content := testutil.RandomBytes(1, chunk.DefaultSize)
+	store := NewMapChunkStore()
+	fileStore := NewFileStore(store, store, NewFileStoreParams(), chunk.NewTags())
+	ctx := context.Background()
+	addr, wait, err := fileStore.Store(ctx, bytes.NewReader(content), int64(len(content)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	gateway := newTestGateway(addr, content)
+	defer gateway.Close()
+
+	n := NewNetStore(NewMapChunkStore(), network.RandomBzzAddr())
+	requests := 0
+	n.RemoteGet = func(ctx context.Context, req *Request, localID enode.ID) (*enode.ID, func(), error) {
+		requests++
+		return nil, func() {}, ErrNoSuitablePeer
+	}
+	n.Fallback = NewGatewayFallback(n, []string{gateway.URL}).Fetch
+	n.addMissing(addr)
+
+	ch, err := n.Get(ctx, chunk.ModeGetRequest, NewRequest(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ch.Address(), addr) {
+		t.Fatalf("got chunk %s, want %s", ch.Address(), addr)
+	}
+	if requests != 0 {
+		t.Fatalf("got %v network requests for missing chunk, want 0", requests)
+	}
+	if n.isMissing(addr) {
+		t.Fatal("chunk fetched from the gateway is missing")
+	}
+}
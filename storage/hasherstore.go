@@ -40,6 +40,7 @@ type hasherStore struct {
 	store     ChunkStore
 	tag       *chunk.Tag
 	toEncrypt bool
+	masterKey encryption.Key // master key chunk keys are derived from, if set
 	doWait    sync.Once
 	hashFunc  SwarmHasher
 	hashSize  int           // content hash size
@@ -55,6 +56,12 @@ type hasherStore struct {
 // With the HasherStore you can put and get chunk data (which is just []byte) into a ChunkStore
 // and the hasherStore will take core of encryption/decryption of data if necessary
 func NewHasherStore(store ChunkStore, hashFunc SwarmHasher, toEncrypt bool, tag *chunk.Tag) *hasherStore {
+	return newHasherStore(store, hashFunc, toEncrypt, tag, noOfStorageWorkers)
+}
+
+// newHasherStore creates a hasherStore which stores at most
+// the given number of chunks concurrently
+func newHasherStore(store ChunkStore, hashFunc SwarmHasher, toEncrypt bool, tag *chunk.Tag, workers int) *hasherStore {
 	hashSize := hashFunc().Size()
 	refSize := int64(hashSize)
 	if toEncrypt {
@@ -72,7 +79,7 @@ func NewHasherStore(store ChunkStore, hashFunc SwarmHasher, toEncrypt bool, tag
 		waitC:     make(chan error),
 		doneC:     make(chan struct{}),
 		quitC:     make(chan struct{}),
-		workers:   make(chan Chunk, noOfStorageWorkers),
+		workers:   make(chan Chunk, workers),
 	}
 	return h
 }
@@ -80,8 +87,9 @@ func NewHasherStore(store ChunkStore, hashFunc SwarmHasher, toEncrypt bool, tag
 // Put stores the chunkData into the ChunkStore of the hasherStore and returns the reference.
 // If hasherStore has a chunkEncryption object, the data will be encrypted.
 // Asynchronous function, the data will not necessarily be stored when it returns.
+// The stored chunk does not share the chunkData, which can be reused once Put returns.
 func (h *hasherStore) Put(ctx context.Context, chunkData ChunkData) (Reference, error) {
-	c := chunkData
+	var c ChunkData
 	var encryptionKey encryption.Key
 	if h.toEncrypt {
 		var err error
@@ -89,6 +97,9 @@ func (h *hasherStore) Put(ctx context.Context, chunkData ChunkData) (Reference,
 		if err != nil {
 			return nil, err
 		}
+	} else {
+		c = make(ChunkData, len(chunkData))
+		copy(c, chunkData)
 	}
 	chunk := h.createChunk(c)
 	h.storeChunk(ctx, chunk)
@@ -119,7 +130,7 @@ func (h *hasherStore) Get(ctx context.Context, ref Reference) (ChunkData, error)
 	toDecrypt := (encryptionKey != nil)
 	if toDecrypt {
 		var err error
-		chunkData, err = h.decryptChunkData(chunkData, encryptionKey)
+		chunkData, err = h.decryptChunkData(chunkData, h.chunkKey(encryptionKey))
 		if err != nil {
 			return nil, err
 		}
@@ -240,13 +251,23 @@ func (h *hasherStore) RefSize() int64 {
 	return h.refSize
 }
 
+// chunkKey returns the encryption key of a chunk from the key in its reference,
+// which is the seed the encryption key is derived from under a master key
+func (h *hasherStore) chunkKey(key encryption.Key) encryption.Key {
+	if h.masterKey == nil {
+		return key
+	}
+	return encryption.DeriveKey(h.masterKey, key)
+}
+
 func (h *hasherStore) encrypt(chunkData ChunkData) (encryption.Key, []byte, []byte, error) {
 	key := encryption.GenerateRandomKey(encryption.KeyLength)
-	encryptedSpan, err := h.newSpanEncryption(key).Encrypt(chunkData[:8])
+	chunkKey := h.chunkKey(key)
+	encryptedSpan, err := h.newSpanEncryption(chunkKey).Encrypt(chunkData[:8])
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	encryptedData, err := h.newDataEncryption(key).Encrypt(chunkData[8:])
+	encryptedData, err := h.newDataEncryption(chunkKey).Encrypt(chunkData[8:])
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -0,0 +1,118 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/shed"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// AccessStats holds the estimated number of
+// requests for a chunk and the time of the last
+// recorded request.
+type AccessStats struct {
+	Address chunk.Address
+	// AccessCount is the estimated number of requests,
+	// as only a sample of requests is recorded.
+	AccessCount uint64
+	// LastAccessTimestamp is the time of the last
+	// recorded request in Unix nanoseconds.
+	LastAccessTimestamp int64
+}
+
+// AccessStatsIterateFunc is called for every chunk
+// with recorded access statistics in AccessStatsIterate.
+type AccessStatsIterateFunc func(s AccessStats) (stop bool, err error)
+
+// AccessStats returns access statistics for a chunk with
+// the provided address. If no requests are recorded for
+// the chunk, AccessStats with zero values is returned.
+func (db *DB) AccessStats(addr chunk.Address) (s AccessStats, err error) {
+	item, err := db.accessStatsIndex.Get(addressToItem(addr))
+	switch err {
+	case nil:
+	case leveldb.ErrNotFound:
+		return AccessStats{Address: addr}, nil
+	default:
+		return AccessStats{}, err
+	}
+	return accessStatsFromItem(item), nil
+}
+
+// AccessStatsIterate calls the provided function for every chunk
+// with recorded access statistics, in the order of addresses,
+// starting from the provided address, or from the first one if it
+// is nil.
+func (db *DB) AccessStatsIterate(fn AccessStatsIterateFunc, start chunk.Address) (err error) {
+	var opts *shed.IterateOptions
+	if start != nil {
+		opts = &shed.IterateOptions{
+			StartFrom: &shed.Item{
+				Address: start,
+			},
+		}
+	}
+	return db.accessStatsIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		return fn(accessStatsFromItem(item))
+	}, opts)
+}
+
+// accessStatsFromItem creates AccessStats
+// from access statistics index item.
+func accessStatsFromItem(item shed.Item) AccessStats {
+	return AccessStats{
+		Address:             item.Address,
+		AccessCount:         item.AccessCount,
+		LastAccessTimestamp: item.AccessTimestamp,
+	}
+}
+
+// incAccessStatsInBatch records a request for a chunk if it is
+// selected by sampling. Every recorded request increases the access
+// count by the inverse of sample rate, so that the count is an
+// estimate of the total number of requests. This function must
+// be called under batchMu lock.
+func (db *DB) incAccessStatsInBatch(batch *leveldb.Batch, item shed.Item) (err error) {
+	rate := db.accessStatsSampleRate
+	if rate <= 0 {
+		return nil
+	}
+	if rate < 1 && rand.Float64() >= rate {
+		return nil
+	}
+	var weight uint64 = 1
+	if rate < 1 {
+		weight = uint64(math.Round(1 / rate))
+	}
+
+	i, err := db.accessStatsIndex.Get(item)
+	switch err {
+	case nil:
+		item.AccessCount = i.AccessCount
+	case leveldb.ErrNotFound:
+		item.AccessCount = 0
+	default:
+		return err
+	}
+	item.AccessCount += weight
+	item.AccessTimestamp = now()
+	return db.accessStatsIndex.PutInBatch(batch, item)
+}
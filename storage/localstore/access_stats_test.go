@@ -0,0 +1,169 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// TestDB_AccessStats validates that chunk requests are
+// recorded in access statistics and that statistics
+// are removed with the chunk.
+func TestDB_AccessStats(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		AccessStatsSampleRate: 1,
+	})
+	defer cleanupFunc()
+
+	testHookUpdateGCChan := make(chan struct{})
+	defer setTestHookUpdateGC(func() {
+		testHookUpdateGCChan <- struct{}{}
+	})()
+
+	var accessTimestamp int64 = 1000
+	defer setNow(func() int64 {
+		return accessTimestamp
+	})()
+
+	chunks := generateTestRandomChunks(3)
+	if _, err := db.Put(context.Background(), chunk.ModePutUpload, chunks...); err != nil {
+		t.Fatal(err)
+	}
+	// only the first chunk is synced and added to the gc index,
+	// but requests are recorded for not synced chunks, too
+	if err := db.Set(context.Background(), chunk.ModeSetSyncPull, chunks[0].Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(ch chunk.Chunk, count int) {
+		t.Helper()
+		for i := 0; i < count; i++ {
+			accessTimestamp++
+			if _, err := db.Get(context.Background(), chunk.ModeGetRequest, ch.Address()); err != nil {
+				t.Fatal(err)
+			}
+			<-testHookUpdateGCChan
+		}
+	}
+	get(chunks[0], 3)
+	get(chunks[1], 1)
+
+	for _, tc := range []struct {
+		ch                  chunk.Chunk
+		accessCount         uint64
+		lastAccessTimestamp int64
+	}{
+		{ch: chunks[0], accessCount: 3, lastAccessTimestamp: 1003},
+		{ch: chunks[1], accessCount: 1, lastAccessTimestamp: 1004},
+		{ch: chunks[2], accessCount: 0, lastAccessTimestamp: 0},
+	} {
+		s, err := db.AccessStats(tc.ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(s.Address, tc.ch.Address()) {
+			t.Errorf("got address %s, want %s", s.Address, tc.ch.Address())
+		}
+		if s.AccessCount != tc.accessCount {
+			t.Errorf("chunk %s: got access count %v, want %v", tc.ch.Address(), s.AccessCount, tc.accessCount)
+		}
+		if s.LastAccessTimestamp != tc.lastAccessTimestamp {
+			t.Errorf("chunk %s: got last access timestamp %v, want %v", tc.ch.Address(), s.LastAccessTimestamp, tc.lastAccessTimestamp)
+		}
+	}
+
+	var count int
+	err := db.AccessStatsIterate(func(s AccessStats) (stop bool, err error) {
+		if s.AccessCount == 0 {
+			t.Errorf("chunk %s: got zero access count", s.Address)
+		}
+		count++
+		return false, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("got %v iterated chunks, want %v", count, 2)
+	}
+
+	if err := db.Set(context.Background(), chunk.ModeSetRemove, chunks[0].Address()); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("access stats index count", newItemsCountTest(db.accessStatsIndex, 1))
+}
+
+// TestDB_AccessStats_sampleRate validates that access counts
+// are incremented by the inverse of sample rate and that no
+// statistics are recorded when they are disabled.
+func TestDB_AccessStats_sampleRate(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		sampleRate float64
+		weight     uint64
+	}{
+		{name: "disabled", sampleRate: 0},
+		{name: "half", sampleRate: 0.5, weight: 2},
+		{name: "tenth", sampleRate: 0.1, weight: 10},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, cleanupFunc := newTestDB(t, &Options{
+				AccessStatsSampleRate: tc.sampleRate,
+			})
+			defer cleanupFunc()
+
+			testHookUpdateGCChan := make(chan struct{})
+			defer setTestHookUpdateGC(func() {
+				testHookUpdateGCChan <- struct{}{}
+			})()
+
+			ch := generateTestRandomChunk()
+			if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+				t.Fatal(err)
+			}
+
+			requestCount := 200
+			for i := 0; i < requestCount; i++ {
+				if _, err := db.Get(context.Background(), chunk.ModeGetRequest, ch.Address()); err != nil {
+					t.Fatal(err)
+				}
+				<-testHookUpdateGCChan
+			}
+
+			s, err := db.AccessStats(ch.Address())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.weight == 0 {
+				if s.AccessCount != 0 {
+					t.Errorf("got access count %v, want 0", s.AccessCount)
+				}
+				return
+			}
+			if s.AccessCount%tc.weight != 0 {
+				t.Errorf("got access count %v, want a multiple of %v", s.AccessCount, tc.weight)
+			}
+			if s.AccessCount == 0 || s.AccessCount > uint64(requestCount)*tc.weight {
+				t.Errorf("got access count %v, want in range (0, %v]", s.AccessCount, uint64(requestCount)*tc.weight)
+			}
+		})
+	}
+}
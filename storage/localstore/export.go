@@ -23,11 +23,11 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"sync"
 
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/shed"
+	"github.com/syndtr/goleveldb/leveldb"
 )
 
 const (
@@ -40,10 +40,72 @@ const (
 	currentExportVersion = "2"
 )
 
+// importBatchSize is the number of chunks that are
+// stored in a single Put call on Import.
+var importBatchSize = 100
+
+// ProgressFunc is called during Export and Import with the number
+// of chunks processed so far and the address of the last one.
+type ProgressFunc func(count int64, last chunk.Address)
+
+// ExportOptions holds optional parameters for ExportWithOptions.
+type ExportOptions struct {
+	// Start is the address of the first chunk to be exported.
+	// Chunks are exported in the ascending order of their addresses,
+	// so an interrupted export can be resumed to a new archive
+	// by setting Start to the address after the last one reported
+	// by Progress function.
+	Start chunk.Address
+	// Progress is called after every ProgressInterval exported chunks
+	// and after the last one.
+	Progress ProgressFunc
+	// ProgressInterval is the number of chunks between Progress calls.
+	// If it is 0, defaultProgressInterval is used.
+	ProgressInterval int64
+}
+
+// ImportOptions holds optional parameters for ImportWithOptions.
+type ImportOptions struct {
+	// ResumeKey identifies the imported archive. If it is not empty,
+	// the number of imported chunks is stored in the database after
+	// every batch, and a subsequent import with the same key skips
+	// chunks that are already imported. The stored progress is
+	// removed when the import completes successfully.
+	ResumeKey string
+	// Progress is called after every stored batch of chunks.
+	Progress ProgressFunc
+}
+
+// defaultProgressInterval is the number of chunks between
+// Export Progress calls if ProgressInterval is not set.
+const defaultProgressInterval = 1000
+
+// importProgress is persisted in the database during Import
+// with ImportOptions.ResumeKey.
+type importProgress struct {
+	ResumeKey string
+	Count     uint64
+}
+
 // Export writes a tar structured data to the writer of
 // all chunks in the retrieval data index. It returns the
 // number of chunks exported.
 func (db *DB) Export(w io.Writer) (count int64, err error) {
+	return db.ExportWithOptions(w, nil)
+}
+
+// ExportWithOptions writes a tar structured data to the writer of
+// chunks in the retrieval data index, configured by provided options.
+// It returns the number of chunks exported.
+func (db *DB) ExportWithOptions(w io.Writer, o *ExportOptions) (count int64, err error) {
+	if o == nil {
+		o = new(ExportOptions)
+	}
+	interval := o.ProgressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+
 	tw := tar.NewWriter(w)
 	defer tw.Close()
 
@@ -58,6 +120,15 @@ func (db *DB) Export(w io.Writer) (count int64, err error) {
 		return 0, err
 	}
 
+	var options *shed.IterateOptions
+	if o.Start != nil {
+		options = &shed.IterateOptions{
+			StartFrom: &shed.Item{
+				Address: o.Start,
+			},
+		}
+	}
+	var last chunk.Address
 	err = db.retrievalDataIndex.Iterate(func(item shed.Item) (stop bool, err error) {
 
 		hdr := &tar.Header{
@@ -73,134 +144,161 @@ func (db *DB) Export(w io.Writer) (count int64, err error) {
 			return false, err
 		}
 		count++
+		last = item.Address
+		if o.Progress != nil && count%interval == 0 {
+			o.Progress(count, last)
+		}
 		return false, nil
-	}, nil)
-
-	return count, err
+	}, options)
+	if err != nil {
+		return count, err
+	}
+	if o.Progress != nil && count%interval != 0 {
+		o.Progress(count, last)
+	}
+	return count, nil
 }
 
 // Import reads a tar structured data from the reader and
 // stores chunks in the database. It returns the number of
 // chunks imported.
 func (db *DB) Import(r io.Reader, legacy bool) (count int64, err error) {
-	tr := tar.NewReader(r)
+	return db.ImportWithOptions(r, nil)
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	errC := make(chan error)
-	doneC := make(chan struct{})
-	tokenPool := make(chan struct{}, 100)
-	var wg sync.WaitGroup
-	go func() {
-		var (
-			firstFile = true
-			// if exportVersionFilename file is not present
-			// assume legacy version
-			version = legacyExportVersion
-		)
-		for {
-			hdr, err := tr.Next()
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				select {
-				case errC <- err:
-				case <-ctx.Done():
-				}
-			}
-			if firstFile {
-				firstFile = false
-				if hdr.Name == exportVersionFilename {
-					data, err := ioutil.ReadAll(tr)
-					if err != nil {
-						select {
-						case errC <- err:
-						case <-ctx.Done():
-						}
-					}
-					version = string(data)
-					continue
-				}
-			}
+// ImportWithOptions reads a tar structured data from the reader and
+// stores chunks in the database, configured by provided options.
+// It returns the number of chunks imported, including the ones
+// skipped on a resumed import.
+func (db *DB) ImportWithOptions(r io.Reader, o *ImportOptions) (count int64, err error) {
+	if db.readOnly {
+		return 0, ErrReadOnly
+	}
+	if o == nil {
+		o = new(ImportOptions)
+	}
 
-			if len(hdr.Name) != 64 {
-				log.Warn("ignoring non-chunk file", "name", hdr.Name)
-				continue
-			}
+	var skip int64
+	if o.ResumeKey != "" {
+		var p importProgress
+		if err := db.importProgress.Get(&p); err != nil && err != leveldb.ErrNotFound {
+			return 0, err
+		}
+		if p.ResumeKey == o.ResumeKey {
+			skip = int64(p.Count)
+			log.Info("resuming import", "key", o.ResumeKey, "skip", skip)
+		}
+	}
 
-			keybytes, err := hex.DecodeString(hdr.Name)
-			if err != nil {
-				log.Warn("ignoring invalid chunk file", "name", hdr.Name, "err", err)
-				continue
+	tr := tar.NewReader(r)
+
+	var (
+		firstFile = true
+		// if exportVersionFilename file is not present
+		// assume legacy version
+		version = legacyExportVersion
+		batch   = make([]chunk.Chunk, 0, importBatchSize)
+	)
+
+	// store puts chunks from the batch to the database
+	// and persists the import progress
+	store := func() (err error) {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := db.Put(context.Background(), chunk.ModePutUpload, batch...); err != nil {
+			return err
+		}
+		count += int64(len(batch))
+		last := batch[len(batch)-1].Address()
+		batch = batch[:0]
+		if o.ResumeKey != "" {
+			if err := db.importProgress.Put(importProgress{
+				ResumeKey: o.ResumeKey,
+				Count:     uint64(count),
+			}); err != nil {
+				return err
 			}
+		}
+		if o.Progress != nil {
+			o.Progress(count, last)
+		}
+		return nil
+	}
 
-			data, err := ioutil.ReadAll(tr)
-			if err != nil {
-				select {
-				case errC <- err:
-				case <-ctx.Done():
-				}
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
 			}
-			key := chunk.Address(keybytes)
-
-			var ch chunk.Chunk
-			switch version {
-			case legacyExportVersion:
-				// LDBStore Export exported chunk data prefixed with the chunk key.
-				// That is not necessary, as the key is in the chunk filename,
-				// but backward compatibility needs to be preserved.
-				ch = chunk.NewChunk(key, data[32:])
-			case currentExportVersion:
-				ch = chunk.NewChunk(key, data)
-			default:
-				select {
-				case errC <- fmt.Errorf("unsupported export data version %q", version):
-				case <-ctx.Done():
+			return count, err
+		}
+		if firstFile {
+			firstFile = false
+			if hdr.Name == exportVersionFilename {
+				data, err := ioutil.ReadAll(tr)
+				if err != nil {
+					return count, err
 				}
+				version = string(data)
+				continue
 			}
-			tokenPool <- struct{}{}
-			wg.Add(1)
-
-			go func() {
-				_, err := db.Put(ctx, chunk.ModePutUpload, ch)
-				select {
-				case errC <- err:
-				case <-ctx.Done():
-					wg.Done()
-					<-tokenPool
-				default:
-					_, err := db.Put(ctx, chunk.ModePutUpload, ch)
-					if err != nil {
-						errC <- err
-					}
-					wg.Done()
-					<-tokenPool
-				}
-			}()
+		}
 
+		if len(hdr.Name) != 64 {
+			log.Warn("ignoring non-chunk file", "name", hdr.Name)
+			continue
+		}
+
+		keybytes, err := hex.DecodeString(hdr.Name)
+		if err != nil {
+			log.Warn("ignoring invalid chunk file", "name", hdr.Name, "err", err)
+			continue
+		}
+
+		if skip > 0 {
+			// chunk is already imported
+			skip--
 			count++
+			continue
 		}
-		wg.Wait()
-		close(doneC)
-	}()
 
-	// wait for all chunks to be stored
-	for {
-		select {
-		case err := <-errC:
-			if err != nil {
-				return count, err
-			}
-		case <-ctx.Done():
-			return count, ctx.Err()
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return count, err
+		}
+		key := chunk.Address(keybytes)
+
+		var ch chunk.Chunk
+		switch version {
+		case legacyExportVersion:
+			// LDBStore Export exported chunk data prefixed with the chunk key.
+			// That is not necessary, as the key is in the chunk filename,
+			// but backward compatibility needs to be preserved.
+			ch = chunk.NewChunk(key, data[32:])
+		case currentExportVersion:
+			ch = chunk.NewChunk(key, data)
 		default:
-			select {
-			case <-doneC:
-				return count, nil
-			default:
+			return count, fmt.Errorf("unsupported export data version %q", version)
+		}
+
+		batch = append(batch, ch)
+		if len(batch) >= importBatchSize {
+			if err := store(); err != nil {
+				return count, err
 			}
 		}
 	}
+	if err := store(); err != nil {
+		return count, err
+	}
+
+	if o.ResumeKey != "" {
+		// import is completed, there is nothing to resume
+		if err := db.importProgress.Put(importProgress{}); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
 }
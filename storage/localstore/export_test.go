@@ -19,6 +19,7 @@ package localstore
 import (
 	"bytes"
 	"context"
+	"sort"
 	"testing"
 
 	"github.com/ethersphere/swarm/chunk"
@@ -78,3 +79,166 @@ func TestExportImport(t *testing.T) {
 		}
 	}
 }
+
+// TestExportWithOptions validates that ExportWithOptions exports
+// only chunks from the start address and reports the progress.
+func TestExportWithOptions(t *testing.T) {
+	db1, cleanup1 := newTestDB(t, nil)
+	defer cleanup1()
+
+	var chunkCount = 100
+
+	addrs := make([]chunk.Address, 0, chunkCount)
+	for i := 0; i < chunkCount; i++ {
+		ch := generateTestRandomChunk()
+
+		_, err := db1.Put(context.Background(), chunk.ModePutUpload, ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, ch.Address())
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i], addrs[j]) < 0
+	})
+
+	start := 40
+	wantChunksCount := int64(chunkCount - start)
+
+	var progressCalls int
+	var progressCount int64
+	var progressLast chunk.Address
+
+	var buf bytes.Buffer
+	c, err := db1.ExportWithOptions(&buf, &ExportOptions{
+		Start: addrs[start],
+		Progress: func(count int64, last chunk.Address) {
+			progressCalls++
+			progressCount = count
+			progressLast = last
+		},
+		ProgressInterval: 25,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != wantChunksCount {
+		t.Errorf("got export count %v, want %v", c, wantChunksCount)
+	}
+	if progressCalls != 3 {
+		t.Errorf("got %v progress calls, want %v", progressCalls, 3)
+	}
+	if progressCount != wantChunksCount {
+		t.Errorf("got progress count %v, want %v", progressCount, wantChunksCount)
+	}
+	if !bytes.Equal(progressLast, addrs[chunkCount-1]) {
+		t.Errorf("got progress last address %s, want %s", progressLast, addrs[chunkCount-1])
+	}
+
+	db2, cleanup2 := newTestDB(t, nil)
+	defer cleanup2()
+
+	c, err = db2.Import(&buf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != wantChunksCount {
+		t.Errorf("got import count %v, want %v", c, wantChunksCount)
+	}
+
+	for i, addr := range addrs {
+		has, err := db2.Has(context.Background(), addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := i >= start; has != want {
+			t.Errorf("chunk %s: got has %v, want %v", addr.Hex(), has, want)
+		}
+	}
+}
+
+// TestImportResume validates that an interrupted import
+// with a resume key continues where it stopped.
+func TestImportResume(t *testing.T) {
+	defer func(s int) { importBatchSize = s }(importBatchSize)
+	importBatchSize = 10
+
+	db1, cleanup1 := newTestDB(t, nil)
+	defer cleanup1()
+
+	var chunkCount = 100
+
+	chunks := make(map[string][]byte, chunkCount)
+	for i := 0; i < chunkCount; i++ {
+		ch := generateTestRandomChunk()
+
+		_, err := db1.Put(context.Background(), chunk.ModePutUpload, ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks[string(ch.Address())] = ch.Data()
+	}
+
+	var buf bytes.Buffer
+	if _, err := db1.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+	archive := buf.Bytes()
+
+	db2, cleanup2 := newTestDB(t, nil)
+	defer cleanup2()
+
+	resumeKey := "test-archive"
+
+	// interrupt the import in the middle of the archive
+	c, err := db2.ImportWithOptions(bytes.NewReader(archive[:len(archive)/2+100]), &ImportOptions{
+		ResumeKey: resumeKey,
+	})
+	if err == nil {
+		t.Fatal("expected error on interrupted import")
+	}
+	if c == 0 || c >= int64(chunkCount) {
+		t.Fatalf("got interrupted import count %v", c)
+	}
+	interruptedCount := c
+
+	var progressCount int64
+	c, err = db2.ImportWithOptions(bytes.NewReader(archive), &ImportOptions{
+		ResumeKey: resumeKey,
+		Progress: func(count int64, _ chunk.Address) {
+			if progressCount == 0 && count <= interruptedCount {
+				t.Errorf("got first progress count %v, want more than %v", count, interruptedCount)
+			}
+			progressCount = count
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != int64(chunkCount) {
+		t.Errorf("got import count %v, want %v", c, chunkCount)
+	}
+	if progressCount != int64(chunkCount) {
+		t.Errorf("got progress count %v, want %v", progressCount, chunkCount)
+	}
+
+	for a, want := range chunks {
+		addr := chunk.Address([]byte(a))
+		ch, err := db2.Get(context.Background(), chunk.ModeGetRequest, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ch.Data(); !bytes.Equal(got, want) {
+			t.Fatalf("chunk %s: got data %x, want %x", addr.Hex(), got, want)
+		}
+	}
+
+	// the progress must be removed after a successful import
+	var p importProgress
+	if err := db2.importProgress.Get(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.ResumeKey != "" || p.Count != 0 {
+		t.Errorf("got import progress %+v after completed import", p)
+	}
+}
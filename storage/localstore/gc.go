@@ -21,6 +21,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/shed"
 	"github.com/syndtr/goleveldb/leveldb"
 )
@@ -47,6 +48,17 @@ var (
 func (db *DB) collectGarbageWorker() {
 	defer close(db.collectGarbageWorkerDone)
 
+	// a garbage collection run that is not done
+	// outside of garbage collection windows is
+	// deferred until a window opens
+	var deferred bool
+	var windowCheck <-chan time.Time
+	if len(db.gcWindows) > 0 {
+		ticker := time.NewTicker(gcWindowCheckInterval)
+		defer ticker.Stop()
+		windowCheck = ticker.C
+	}
+
 	for {
 		select {
 		case <-db.collectGarbageTrigger:
@@ -57,14 +69,38 @@ func (db *DB) collectGarbageWorker() {
 			if err != nil {
 				log.Error("localstore collect garbage", "err", err)
 			}
-			// check if another gc run is needed
-			if !done {
-				db.triggerGarbageCollection()
-			}
 
 			if testHookCollectGarbage != nil {
 				testHookCollectGarbage(collectedCount)
 			}
+
+			// throttle garbage collection not to
+			// exceed the configured rate limit
+			if wait := db.gcRateLimitWait(collectedCount); wait > 0 {
+				metrics.GetOrRegisterResettingTimer("localstore/gc/ratelimit-wait", nil).Update(wait)
+				select {
+				case <-time.After(wait):
+				case <-db.close:
+					return
+				}
+			}
+
+			// check if another gc run is needed
+			if !done {
+				if db.inGCWindow() {
+					db.triggerGarbageCollection()
+				} else {
+					// only a single batch is removed
+					// outside of garbage collection windows
+					deferred = true
+					metrics.GetOrRegisterCounter("localstore/gc/deferred", nil).Inc(1)
+				}
+			}
+		case <-windowCheck:
+			if deferred && db.inGCWindow() {
+				deferred = false
+				db.triggerGarbageCollection()
+			}
 		case <-db.close:
 			return
 		}
@@ -80,11 +116,26 @@ func (db *DB) collectGarbageWorker() {
 func (db *DB) collectGarbage() (collectedCount uint64, done bool, err error) {
 	metricName := "localstore/gc"
 	metrics.GetOrRegisterCounter(metricName, nil).Inc(1)
-	defer totalTimeMetric(metricName, time.Now())
+	start := time.Now()
+	defer totalTimeMetric(metricName, start)
+	var collectedBytes uint64
 	defer func() {
 		if err != nil {
 			metrics.GetOrRegisterCounter(metricName+"/error", nil).Inc(1)
 		}
+		// keep the round for inspection
+		round := GCRound{
+			Start:          start,
+			Duration:       time.Since(start),
+			CollectedCount: collectedCount,
+			CollectedBytes: collectedBytes,
+			Done:           done,
+		}
+		if err != nil {
+			round.CollectedBytes = 0
+			round.Error = err.Error()
+		}
+		db.gcRounds.add(round)
 	}()
 
 	batch := new(leveldb.Batch)
@@ -108,35 +159,82 @@ func (db *DB) collectGarbage() (collectedCount uint64, done bool, err error) {
 	}
 	metrics.GetOrRegisterGauge(metricName+"/gcsize", nil).Update(int64(gcSize))
 
+	storedSize, err := db.storedSize.Get()
+	if err != nil {
+		return 0, true, err
+	}
+	metrics.GetOrRegisterGauge(metricName+"/storedsize", nil).Update(int64(storedSize))
+
 	done = true
-	err = db.gcIndex.Iterate(func(item shed.Item) (stop bool, err error) {
-		if gcSize-collectedCount <= target {
-			return true, nil
+	var count uint64
+	if gcSize > target {
+		count = gcSize - target
+	}
+	// the number of chunks needed to reach the target
+	// size in bytes is estimated with the maximal chunk
+	// data size, another gc run is triggered if the
+	// estimate is not sufficient
+	var excessBytes uint64
+	if targetBytes := db.gcTargetBytes(); targetBytes > 0 && storedSize > targetBytes {
+		excessBytes = storedSize - targetBytes
+		if c := (excessBytes + chunk.MaxDataSize - 1) / chunk.MaxDataSize; c > count {
+			count = c
 		}
+	}
+	if count >= gcBatchSize {
+		// bach size limit reached,
+		// another gc run is needed
+		count = gcBatchSize
+		done = false
+	}
+	candidates, err := db.gcStrategy.Select(db.gcIterate(), count)
+	if err != nil {
+		return 0, false, err
+	}
+	if uint64(len(candidates)) > count {
+		candidates = candidates[:count]
+	}
+	for _, c := range candidates {
+		item := gcCandidateToItem(c)
 
 		metrics.GetOrRegisterGauge(metricName+"/storets", nil).Update(item.StoreTimestamp)
 		metrics.GetOrRegisterGauge(metricName+"/accessts", nil).Update(item.AccessTimestamp)
 
+		i, err := db.retrievalDataIndex.Get(item)
+		switch err {
+		case nil:
+			collectedBytes += uint64(len(i.Data))
+		case leveldb.ErrNotFound:
+		default:
+			return 0, false, err
+		}
+
 		// delete from retrieve, pull, gc
 		db.retrievalDataIndex.DeleteInBatch(batch, item)
 		db.retrievalAccessIndex.DeleteInBatch(batch, item)
+		db.accessStatsIndex.DeleteInBatch(batch, item)
 		db.pullIndex.DeleteInBatch(batch, item)
 		db.gcIndex.DeleteInBatch(batch, item)
 		collectedCount++
-		if collectedCount >= gcBatchSize {
-			// bach size limit reached,
-			// another gc run is needed
-			done = false
-			return true, nil
-		}
-		return false, nil
-	}, nil)
-	if err != nil {
-		return 0, false, err
+	}
+	if collectedCount < count {
+		// the strategy did not select enough chunks,
+		// there is no reason for another gc run
+		done = true
+	} else if excessBytes > collectedBytes {
+		// chunks are smaller than estimated,
+		// another gc run is needed
+		done = false
 	}
 	metrics.GetOrRegisterCounter(metricName+"/collected-count", nil).Inc(int64(collectedCount))
+	metrics.GetOrRegisterCounter(metricName+"/collected-bytes", nil).Inc(int64(collectedBytes))
 
 	db.gcSize.PutInBatch(batch, gcSize-collectedCount)
+	if collectedBytes > storedSize {
+		// protect uint64 undeflow
+		collectedBytes = storedSize
+	}
+	db.storedSize.PutInBatch(batch, storedSize-collectedBytes)
 
 	err = db.shed.WriteBatch(batch)
 	if err != nil {
@@ -214,6 +312,26 @@ func (db *DB) gcTarget() (target uint64) {
 	return uint64(float64(db.capacity) * gcTargetRatio)
 }
 
+// gcTargetBytes returns the absolute value for garbage collection
+// target size in bytes, calculated from db.capacityBytes and gcTargetRatio.
+// If it returns 0, there is no target size in bytes.
+func (db *DB) gcTargetBytes() (target uint64) {
+	return uint64(float64(db.capacityBytes) * gcTargetRatio)
+}
+
+// FreeCapacity returns the number of chunks that can be
+// stored before the database reaches its capacity.
+func (db *DB) FreeCapacity() (free uint64, err error) {
+	gcSize, err := db.gcSize.Get()
+	if err != nil {
+		return 0, err
+	}
+	if gcSize >= db.capacity {
+		return 0, nil
+	}
+	return db.capacity - gcSize, nil
+}
+
 // triggerGarbageCollection signals collectGarbageWorker
 // to call collectGarbage.
 func (db *DB) triggerGarbageCollection() {
@@ -258,6 +376,75 @@ func (db *DB) incGCSizeInBatch(batch *leveldb.Batch, change int64) (err error) {
 	return nil
 }
 
+// incStoredSizeInBatch changes storedSize field value
+// by change which can be negative. This function
+// must be called under batchMu lock.
+func (db *DB) incStoredSizeInBatch(batch *leveldb.Batch, change int64) (err error) {
+	if change == 0 {
+		return nil
+	}
+	storedSize, err := db.storedSize.Get()
+	if err != nil {
+		return err
+	}
+
+	var new uint64
+	if change > 0 {
+		new = storedSize + uint64(change)
+	} else {
+		// 'change' is an int64 and is negative
+		// a conversion is needed with correct sign
+		c := uint64(-change)
+		if c > storedSize {
+			// protect uint64 underflow, the stored size is
+			// only an estimate and must not block the batch
+			log.Warn("localstore stored size underflow", "size", storedSize, "change", change)
+			metrics.GetOrRegisterCounter("localstore/storedsize/underflow", nil).Inc(1)
+			c = storedSize
+		}
+		new = storedSize - c
+	}
+	db.storedSize.PutInBatch(batch, new)
+
+	// trigger garbage collection if we reached the capacity in bytes
+	if db.capacityBytes > 0 && new >= db.capacityBytes {
+		db.triggerGarbageCollection()
+	}
+	return nil
+}
+
+// initStoredSize counts the size of chunk data in retrieval
+// data index and stores it if storedSize field is not set.
+// It is required for databases that were created before
+// the stored size was tracked.
+func (db *DB) initStoredSize() (err error) {
+	storedSize, err := db.storedSize.Get()
+	if err != nil {
+		return err
+	}
+	if storedSize > 0 {
+		return nil
+	}
+	err = db.retrievalDataIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		storedSize += uint64(len(item.Data))
+		return false, nil
+	}, nil)
+	if err != nil {
+		return err
+	}
+	if storedSize == 0 {
+		return nil
+	}
+	log.Info("localstore stored size initialized", "size", storedSize)
+	return db.storedSize.Put(storedSize)
+}
+
+// StoredSize returns the size in bytes of all
+// chunk data stored in the database.
+func (db *DB) StoredSize() (size uint64, err error) {
+	return db.storedSize.Get()
+}
+
 // testHookCollectGarbage is a hook that can provide
 // information when a garbage collection run is done
 // and how many items it removed.
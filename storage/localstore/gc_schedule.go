@@ -0,0 +1,75 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"time"
+)
+
+// gcWindowCheckInterval is the interval at which garbage
+// collection worker checks if a garbage collection window
+// is open in order to continue a deferred garbage collection.
+var gcWindowCheckInterval = time.Minute
+
+// GCWindow is a daily time window in UTC timezone in which
+// garbage collection can run until the target size is reached.
+// Start and End are offsets from midnight. If End is before
+// Start, the window spans over midnight.
+type GCWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// contains returns true if the time of the day
+// of the provided time is in the window.
+func (w GCWindow) contains(t time.Time) bool {
+	t = t.UTC()
+	d := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second +
+		time.Duration(t.Nanosecond())
+	if w.End < w.Start {
+		return d >= w.Start || d < w.End
+	}
+	return d >= w.Start && d < w.End
+}
+
+// inGCWindow returns true if the current time is in any of
+// the configured garbage collection windows or if there
+// are no windows configured.
+func (db *DB) inGCWindow() bool {
+	if len(db.gcWindows) == 0 {
+		return true
+	}
+	t := time.Unix(0, now())
+	for _, w := range db.gcWindows {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// gcRateLimitWait returns the duration that garbage collection
+// worker needs to wait after collectedCount chunks are removed,
+// in order not to exceed the configured rate limit.
+func (db *DB) gcRateLimitWait(collectedCount uint64) time.Duration {
+	if db.gcRateLimit <= 0 || collectedCount == 0 {
+		return 0
+	}
+	return time.Duration(float64(collectedCount) / db.gcRateLimit * float64(time.Second))
+}
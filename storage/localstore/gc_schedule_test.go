@@ -0,0 +1,223 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// TestGCWindow_contains validates time of the day
+// checks for garbage collection windows.
+func TestGCWindow_contains(t *testing.T) {
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name   string
+		window GCWindow
+		t      time.Time
+		want   bool
+	}{
+		{
+			name:   "before",
+			window: GCWindow{Start: 2 * time.Hour, End: 4 * time.Hour},
+			t:      day.Add(time.Hour),
+			want:   false,
+		},
+		{
+			name:   "start",
+			window: GCWindow{Start: 2 * time.Hour, End: 4 * time.Hour},
+			t:      day.Add(2 * time.Hour),
+			want:   true,
+		},
+		{
+			name:   "inside",
+			window: GCWindow{Start: 2 * time.Hour, End: 4 * time.Hour},
+			t:      day.Add(3 * time.Hour),
+			want:   true,
+		},
+		{
+			name:   "end",
+			window: GCWindow{Start: 2 * time.Hour, End: 4 * time.Hour},
+			t:      day.Add(4 * time.Hour),
+			want:   false,
+		},
+		{
+			name:   "over midnight before",
+			window: GCWindow{Start: 22 * time.Hour, End: 2 * time.Hour},
+			t:      day.Add(23 * time.Hour),
+			want:   true,
+		},
+		{
+			name:   "over midnight after",
+			window: GCWindow{Start: 22 * time.Hour, End: 2 * time.Hour},
+			t:      day.Add(time.Hour),
+			want:   true,
+		},
+		{
+			name:   "over midnight outside",
+			window: GCWindow{Start: 22 * time.Hour, End: 2 * time.Hour},
+			t:      day.Add(12 * time.Hour),
+			want:   false,
+		},
+		{
+			name:   "other timezone",
+			window: GCWindow{Start: 2 * time.Hour, End: 4 * time.Hour},
+			t:      day.Add(3 * time.Hour).In(time.FixedZone("test", 5*60*60)),
+			want:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.window.contains(tc.t)
+			if got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestDB_gcRateLimitWait validates the duration that garbage
+// collection waits to respect the configured rate limit.
+func TestDB_gcRateLimitWait(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		GCRateLimit: 100,
+	})
+	defer cleanupFunc()
+
+	for _, tc := range []struct {
+		count uint64
+		want  time.Duration
+	}{
+		{count: 0, want: 0},
+		{count: 1, want: 10 * time.Millisecond},
+		{count: 200, want: 2 * time.Second},
+	} {
+		got := db.gcRateLimitWait(tc.count)
+		if got != tc.want {
+			t.Errorf("count %v: got wait %v, want %v", tc.count, got, tc.want)
+		}
+	}
+
+	db.gcRateLimit = 0
+	if got := db.gcRateLimitWait(200); got != 0 {
+		t.Errorf("got wait %v without rate limit, want 0", got)
+	}
+}
+
+// TestDB_collectGarbageWorker_gcWindows validates that only a single
+// garbage collection batch is removed outside of garbage collection
+// windows and that the rest is removed when a window opens.
+func TestDB_collectGarbageWorker_gcWindows(t *testing.T) {
+	defer func(s uint64) { gcBatchSize = s }(gcBatchSize)
+	gcBatchSize = 5
+
+	defer func(i time.Duration) { gcWindowCheckInterval = i }(gcWindowCheckInterval)
+	gcWindowCheckInterval = 10 * time.Millisecond
+
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var currentTime time.Time
+	var mu sync.Mutex
+	setCurrentTime := func(t time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		currentTime = t
+	}
+	setCurrentTime(day.Add(12 * time.Hour))
+	defer setNow(func() int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		currentTime = currentTime.Add(time.Nanosecond)
+		return currentTime.UnixNano()
+	})()
+
+	capacity := 100
+
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity: uint64(capacity),
+		GCWindows: []GCWindow{
+			{Start: time.Hour, End: 2 * time.Hour},
+		},
+	})
+	testHookCollectGarbageChan := make(chan uint64)
+	defer setTestHookCollectGarbage(func(collectedCount uint64) {
+		select {
+		case testHookCollectGarbageChan <- collectedCount:
+		case <-db.close:
+		}
+	})()
+	defer cleanupFunc()
+
+	for i := 0; i < capacity; i++ {
+		ch := generateTestRandomChunk()
+
+		_, err := db.Put(context.Background(), chunk.ModePutUpload, ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-testHookCollectGarbageChan:
+	case <-time.After(10 * time.Second):
+		t.Fatal("collect garbage timeout")
+	}
+
+	select {
+	case <-testHookCollectGarbageChan:
+		t.Fatal("garbage collected outside of the window")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	gcSize, err := db.gcSize.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := uint64(capacity) - gcBatchSize; gcSize != want {
+		t.Errorf("got gc size %v outside of the window, want %v", gcSize, want)
+	}
+
+	// open the window
+	setCurrentTime(day.Add(90 * time.Minute))
+
+	gcTarget := db.gcTarget()
+	for {
+		select {
+		case <-testHookCollectGarbageChan:
+		case <-time.After(10 * time.Second):
+			t.Fatal("collect garbage timeout")
+		}
+		gcSize, err := db.gcSize.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gcSize == gcTarget {
+			break
+		}
+	}
+
+	t.Run("gc index count", newItemsCountTest(db.gcIndex, int(gcTarget)))
+
+	t.Run("gc size", newIndexGCSizeTest(db))
+}
@@ -0,0 +1,177 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"sort"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/shed"
+)
+
+// GCCandidate holds information about a chunk from the garbage
+// collection index that can be removed from the database.
+type GCCandidate struct {
+	Address         chunk.Address
+	AccessTimestamp int64
+	BinID           uint64
+	// proximity order between chunk address
+	// and database base key
+	PO uint8
+	// estimated number of requests for the chunk,
+	// set only if access statistics are enabled
+	AccessCount uint64
+}
+
+// GCIterateFunc iterates over garbage collection candidates in the
+// ascending order of their access timestamps, the least recently used
+// chunks first, calling the provided function for every one of them.
+type GCIterateFunc func(fn func(c GCCandidate) (stop bool, err error)) (err error)
+
+// GCStrategy selects chunks that should be removed on a garbage
+// collection run. Implementations must not return more than count
+// candidates and must return only candidates provided by iterate function.
+type GCStrategy interface {
+	Select(iterate GCIterateFunc, count uint64) (candidates []GCCandidate, err error)
+}
+
+// LRUGCStrategy selects the least recently accessed chunks
+// for garbage collection. It is the default GCStrategy.
+type LRUGCStrategy struct{}
+
+// Select returns count least recently accessed chunks.
+func (LRUGCStrategy) Select(iterate GCIterateFunc, count uint64) (candidates []GCCandidate, err error) {
+	if count == 0 {
+		return nil, nil
+	}
+	err = iterate(func(c GCCandidate) (stop bool, err error) {
+		candidates = append(candidates, c)
+		return uint64(len(candidates)) >= count, nil
+	})
+	return candidates, err
+}
+
+// ProximityGCStrategy selects chunks that are the farthest from the database
+// base key, among the least recently accessed ones. This keeps chunks from the
+// node's neighbourhood, that it is responsible for, longer in the database.
+type ProximityGCStrategy struct {
+	// Window is the multiplier of the number of requested candidates
+	// that defines how many least recently accessed chunks are
+	// considered for the selection. Values less than 1 are
+	// treated as 1, which is the same as LRUGCStrategy.
+	Window uint64
+}
+
+// Select returns count chunks with the lowest proximity order from
+// the window of least recently accessed chunks.
+func (s ProximityGCStrategy) Select(iterate GCIterateFunc, count uint64) (candidates []GCCandidate, err error) {
+	window := s.Window
+	if window < 1 {
+		window = 1
+	}
+	candidates, err = LRUGCStrategy{}.Select(iterate, count*window)
+	if err != nil {
+		return nil, err
+	}
+	// stable sort preserves access order of chunks in the same bin
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].PO < candidates[j].PO
+	})
+	if uint64(len(candidates)) > count {
+		candidates = candidates[:count]
+	}
+	return candidates, nil
+}
+
+// AccessFrequencyGCStrategy selects chunks that are the least frequently
+// requested, among the least recently accessed ones. It requires access
+// statistics to be enabled with AccessStatsSampleRate option, otherwise
+// it is the same as LRUGCStrategy.
+type AccessFrequencyGCStrategy struct {
+	// Window is the multiplier of the number of requested candidates
+	// that defines how many least recently accessed chunks are
+	// considered for the selection. Values less than 1 are
+	// treated as 1, which is the same as LRUGCStrategy.
+	Window uint64
+}
+
+// Select returns count chunks with the lowest access count from
+// the window of least recently accessed chunks.
+func (s AccessFrequencyGCStrategy) Select(iterate GCIterateFunc, count uint64) (candidates []GCCandidate, err error) {
+	window := s.Window
+	if window < 1 {
+		window = 1
+	}
+	candidates, err = LRUGCStrategy{}.Select(iterate, count*window)
+	if err != nil {
+		return nil, err
+	}
+	// stable sort preserves access order of chunks
+	// with the same access count
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].AccessCount < candidates[j].AccessCount
+	})
+	if uint64(len(candidates)) > count {
+		candidates = candidates[:count]
+	}
+	return candidates, nil
+}
+
+// gcIterate returns a GCIterateFunc that iterates over gc index.
+// Chunks stored or accessed within the garbage collection protection
+// period are not provided as candidates. As gc index is ordered by
+// access timestamps, which are never before store timestamps, the
+// iteration stops at the first protected chunk.
+func (db *DB) gcIterate() GCIterateFunc {
+	return func(fn func(c GCCandidate) (stop bool, err error)) (err error) {
+		protect := db.gcProtectionPeriod > 0
+		protectedSince := now() - int64(db.gcProtectionPeriod)
+		return db.gcIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+			if protect && item.AccessTimestamp > protectedSince {
+				// chunk and all the following ones are recently
+				// stored and may not be synced to other nodes yet
+				metrics.GetOrRegisterCounter("localstore/gc/protected", nil).Inc(1)
+				return true, nil
+			}
+			c := GCCandidate{
+				Address:         item.Address,
+				AccessTimestamp: item.AccessTimestamp,
+				BinID:           item.BinID,
+				PO:              db.po(item.Address),
+			}
+			if db.accessStatsSampleRate > 0 {
+				s, err := db.AccessStats(item.Address)
+				if err != nil {
+					return true, err
+				}
+				c.AccessCount = s.AccessCount
+			}
+			return fn(c)
+		}, nil)
+	}
+}
+
+// gcCandidateToItem creates new Item with fields from the GCCandidate
+// that are required to remove a chunk from indexes.
+func gcCandidateToItem(c GCCandidate) shed.Item {
+	return shed.Item{
+		Address:         c.Address,
+		AccessTimestamp: c.AccessTimestamp,
+		BinID:           c.BinID,
+	}
+}
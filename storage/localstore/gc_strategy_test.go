@@ -0,0 +1,262 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// newTestGCIterateFunc returns a GCIterateFunc that iterates
+// over provided candidates in their order.
+func newTestGCIterateFunc(candidates []GCCandidate) GCIterateFunc {
+	return func(fn func(c GCCandidate) (stop bool, err error)) (err error) {
+		for _, c := range candidates {
+			stop, err := fn(c)
+			if err != nil {
+				return err
+			}
+			if stop {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+// TestLRUGCStrategy validates that LRUGCStrategy
+// selects the first count candidates.
+func TestLRUGCStrategy(t *testing.T) {
+	candidates := []GCCandidate{
+		{AccessTimestamp: 1, PO: 3},
+		{AccessTimestamp: 2, PO: 0},
+		{AccessTimestamp: 3, PO: 1},
+		{AccessTimestamp: 4, PO: 0},
+	}
+	for _, count := range []uint64{0, 1, 3, 10} {
+		got, err := LRUGCStrategy{}.Select(newTestGCIterateFunc(candidates), count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := count
+		if want > uint64(len(candidates)) {
+			want = uint64(len(candidates))
+		}
+		if uint64(len(got)) != want {
+			t.Fatalf("got %v candidates for count %v, want %v", len(got), count, want)
+		}
+		for i, c := range got {
+			if c.AccessTimestamp != candidates[i].AccessTimestamp {
+				t.Errorf("got candidate with access timestamp %v at %v, want %v", c.AccessTimestamp, i, candidates[i].AccessTimestamp)
+			}
+		}
+	}
+}
+
+// TestProximityGCStrategy validates that ProximityGCStrategy selects
+// candidates with the lowest proximity orders within its window.
+func TestProximityGCStrategy(t *testing.T) {
+	candidates := []GCCandidate{
+		{AccessTimestamp: 1, PO: 3},
+		{AccessTimestamp: 2, PO: 0},
+		{AccessTimestamp: 3, PO: 1},
+		{AccessTimestamp: 4, PO: 0},
+		{AccessTimestamp: 5, PO: 0},
+	}
+	for _, tc := range []struct {
+		window uint64
+		count  uint64
+		want   []int64
+	}{
+		{window: 0, count: 2, want: []int64{2, 1}},
+		{window: 1, count: 2, want: []int64{2, 1}},
+		{window: 2, count: 2, want: []int64{2, 4}},
+		{window: 2, count: 1, want: []int64{2}},
+		{window: 10, count: 3, want: []int64{2, 4, 5}},
+	} {
+		got, err := ProximityGCStrategy{Window: tc.window}.Select(newTestGCIterateFunc(candidates), tc.count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("window %v count %v: got %v candidates, want %v", tc.window, tc.count, len(got), len(tc.want))
+		}
+		for i, c := range got {
+			if c.AccessTimestamp != tc.want[i] {
+				t.Errorf("window %v count %v: got candidate with access timestamp %v at %v, want %v", tc.window, tc.count, c.AccessTimestamp, i, tc.want[i])
+			}
+		}
+	}
+}
+
+// TestAccessFrequencyGCStrategy validates that AccessFrequencyGCStrategy
+// selects candidates with the lowest access counts within its window.
+func TestAccessFrequencyGCStrategy(t *testing.T) {
+	candidates := []GCCandidate{
+		{AccessTimestamp: 1, AccessCount: 9},
+		{AccessTimestamp: 2, AccessCount: 1},
+		{AccessTimestamp: 3, AccessCount: 4},
+		{AccessTimestamp: 4, AccessCount: 1},
+		{AccessTimestamp: 5, AccessCount: 0},
+	}
+	for _, tc := range []struct {
+		window uint64
+		count  uint64
+		want   []int64
+	}{
+		{window: 0, count: 2, want: []int64{2, 1}},
+		{window: 1, count: 2, want: []int64{2, 1}},
+		{window: 2, count: 2, want: []int64{2, 4}},
+		{window: 2, count: 1, want: []int64{2}},
+		{window: 10, count: 3, want: []int64{5, 2, 4}},
+	} {
+		got, err := AccessFrequencyGCStrategy{Window: tc.window}.Select(newTestGCIterateFunc(candidates), tc.count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("window %v count %v: got %v candidates, want %v", tc.window, tc.count, len(got), len(tc.want))
+		}
+		for i, c := range got {
+			if c.AccessTimestamp != tc.want[i] {
+				t.Errorf("window %v count %v: got candidate with access timestamp %v at %v, want %v", tc.window, tc.count, c.AccessTimestamp, i, tc.want[i])
+			}
+		}
+	}
+}
+
+// TestDB_collectGarbageWorker_proximityStrategy validates that garbage
+// collection with ProximityGCStrategy keeps the database at the
+// target size.
+func TestDB_collectGarbageWorker_proximityStrategy(t *testing.T) {
+	chunkCount := 150
+
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity:   100,
+		GCStrategy: ProximityGCStrategy{Window: 3},
+	})
+	testHookCollectGarbageChan := make(chan uint64)
+	defer setTestHookCollectGarbage(func(collectedCount uint64) {
+		select {
+		case testHookCollectGarbageChan <- collectedCount:
+		case <-db.close:
+		}
+	})()
+	defer cleanupFunc()
+
+	for i := 0; i < chunkCount; i++ {
+		ch := generateTestRandomChunk()
+
+		_, err := db.Put(context.Background(), chunk.ModePutUpload, ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gcTarget := db.gcTarget()
+
+	for {
+		select {
+		case <-testHookCollectGarbageChan:
+		case <-time.After(10 * time.Second):
+			t.Error("collect garbage timeout")
+		}
+		gcSize, err := db.gcSize.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gcSize == gcTarget {
+			break
+		}
+	}
+
+	t.Run("gc index count", newItemsCountTest(db.gcIndex, int(gcTarget)))
+
+	t.Run("gc size", newIndexGCSizeTest(db))
+}
+
+// TestDB_gcIterate_protectionPeriod validates that chunks stored or
+// accessed within GCProtectionPeriod are not provided as candidates.
+func TestDB_gcIterate_protectionPeriod(t *testing.T) {
+	var currentTime int64 = 1000
+	defer setNow(func() int64 {
+		return currentTime
+	})()
+
+	db, cleanupFunc := newTestDB(t, &Options{
+		GCProtectionPeriod: time.Hour,
+	})
+	defer cleanupFunc()
+
+	put := func(count int) (addrs []chunk.Address) {
+		t.Helper()
+		for i := 0; i < count; i++ {
+			ch := generateTestRandomChunk()
+			if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address()); err != nil {
+				t.Fatal(err)
+			}
+			addrs = append(addrs, ch.Address())
+		}
+		return addrs
+	}
+
+	old := put(5)
+	currentTime += int64(2 * time.Hour)
+	put(5)
+
+	// access an old chunk within the protection period
+	if _, err := db.Get(context.Background(), chunk.ModeGetRequest, old[0]); err != nil {
+		t.Fatal(err)
+	}
+	db.updateGCWG.Wait()
+
+	var got []chunk.Address
+	err := db.gcIterate()(func(c GCCandidate) (stop bool, err error) {
+		got = append(got, c.Address)
+		return false, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := old[1:]
+	if len(got) != len(want) {
+		t.Fatalf("got %v candidates, want %v", len(got), len(want))
+	}
+	for _, a := range want {
+		var found bool
+		for _, g := range got {
+			if bytes.Equal(a, g) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("chunk %s is not a candidate", a)
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -134,6 +135,96 @@ func testDBCollectGarbageWorker(t *testing.T) {
 	})
 }
 
+// TestDB_collectGarbageWorker_protectionPeriod validates that chunks
+// stored within GCProtectionPeriod are not garbage collected.
+func TestDB_collectGarbageWorker_protectionPeriod(t *testing.T) {
+	var currentTime int64 = 1000
+	var mu sync.Mutex
+	defer setNow(func() int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return currentTime
+	})()
+
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity:           100,
+		GCProtectionPeriod: time.Hour,
+	})
+	testHookCollectGarbageChan := make(chan uint64)
+	defer setTestHookCollectGarbage(func(collectedCount uint64) {
+		select {
+		case testHookCollectGarbageChan <- collectedCount:
+		case <-db.close:
+		}
+	})()
+	defer cleanupFunc()
+
+	put := func() chunk.Address {
+		t.Helper()
+		ch := generateTestRandomChunk()
+		if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address()); err != nil {
+			t.Fatal(err)
+		}
+		return ch.Address()
+	}
+
+	chunkCount := 110
+	for i := 0; i < chunkCount; i++ {
+		put()
+	}
+
+	// wait for all gc runs triggered by puts
+	// above the capacity
+	for {
+		select {
+		case collectedCount := <-testHookCollectGarbageChan:
+			if collectedCount != 0 {
+				t.Fatalf("got %v collected chunks in protection period", collectedCount)
+			}
+			continue
+		case <-time.After(100 * time.Millisecond):
+		}
+		break
+	}
+
+	t.Run("gc index count", newItemsCountTest(db.gcIndex, chunkCount))
+
+	// protection period passed for already stored chunks
+	mu.Lock()
+	currentTime += int64(2 * time.Hour)
+	mu.Unlock()
+
+	last := put()
+
+	gcTarget := db.gcTarget()
+	for {
+		select {
+		case <-testHookCollectGarbageChan:
+		case <-time.After(10 * time.Second):
+			t.Fatal("collect garbage timeout")
+		}
+		gcSize, err := db.gcSize.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gcSize == gcTarget {
+			break
+		}
+	}
+
+	t.Run("gc size", newIndexGCSizeTest(db))
+
+	t.Run("get the last chunk", func(t *testing.T) {
+		_, err := db.Get(context.Background(), chunk.ModeGetLookup, last)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
 // Pin a file, upload chunks to go past the gc limit to trigger GC,
 // check if the pinned files are still around and removed from gcIndex
 func TestPinGC(t *testing.T) {
@@ -467,6 +558,227 @@ func TestDB_gcSize(t *testing.T) {
 	t.Run("gc index size", newIndexGCSizeTest(db))
 }
 
+// TestDB_FreeCapacity tests that the free capacity is reduced
+// by the chunks that are added to the garbage collection index.
+func TestDB_FreeCapacity(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity: 100,
+	})
+	defer cleanupFunc()
+
+	free, err := db.FreeCapacity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if free != 100 {
+		t.Fatalf("got free capacity %v, want %v", free, 100)
+	}
+
+	count := 10
+	for i := 0; i < count; i++ {
+		ch := generateTestRandomChunk()
+
+		_, err := db.Put(context.Background(), chunk.ModePutUpload, ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	free, err = db.FreeCapacity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if free != 90 {
+		t.Fatalf("got free capacity %v, want %v", free, 90)
+	}
+}
+
+// TestDB_collectGarbageWorker_capacityBytes tests garbage collection
+// runs triggered by the size of stored chunk data in bytes.
+func TestDB_collectGarbageWorker_capacityBytes(t *testing.T) {
+	chunkCount := 150
+
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity:      1000,
+		CapacityBytes: 100 * chunk.DefaultSize,
+	})
+	testHookCollectGarbageChan := make(chan uint64)
+	defer setTestHookCollectGarbage(func(collectedCount uint64) {
+		select {
+		case testHookCollectGarbageChan <- collectedCount:
+		case <-db.close:
+		}
+	})()
+	defer cleanupFunc()
+
+	addrs := make([]chunk.Address, 0)
+
+	// upload random chunks
+	for i := 0; i < chunkCount; i++ {
+		ch := generateTestRandomChunk()
+
+		_, err := db.Put(context.Background(), chunk.ModePutUpload, ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addrs = append(addrs, ch.Address())
+	}
+
+	gcTargetBytes := db.gcTargetBytes()
+
+	for {
+		select {
+		case <-testHookCollectGarbageChan:
+		case <-time.After(10 * time.Second):
+			t.Fatal("collect garbage timeout")
+		}
+		storedSize, err := db.StoredSize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if storedSize <= gcTargetBytes {
+			break
+		}
+	}
+
+	wantCount := int(gcTargetBytes / chunk.DefaultSize)
+
+	t.Run("retrieval data index count", newItemsCountTest(db.retrievalDataIndex, wantCount))
+
+	t.Run("gc index count", newItemsCountTest(db.gcIndex, wantCount))
+
+	t.Run("gc size", newIndexGCSizeTest(db))
+
+	t.Run("stored size", newStoredSizeTest(db))
+
+	t.Run("only first inserted chunks should be removed", func(t *testing.T) {
+		for i := 0; i < chunkCount-wantCount; i++ {
+			_, err := db.Get(context.Background(), chunk.ModeGetRequest, addrs[i])
+			if err != chunk.ErrChunkNotFound {
+				t.Errorf("got error %v, want %v", err, chunk.ErrChunkNotFound)
+			}
+		}
+	})
+}
+
+// TestDB_storedSize validates that the size of stored chunk data
+// is tracked on put and remove and that it is counted on
+// database opening if it is not set.
+func TestDB_storedSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localstore-stored-size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	baseKey := make([]byte, 32)
+	if _, err := rand.Read(baseKey); err != nil {
+		t.Fatal(err)
+	}
+	db, err := New(dir, baseKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := make([]chunk.Chunk, 0)
+	for _, mode := range []chunk.ModePut{chunk.ModePutUpload, chunk.ModePutSync, chunk.ModePutRequest} {
+		for i := 0; i < 10; i++ {
+			ch := generateTestRandomChunk()
+
+			_, err := db.Put(context.Background(), mode, ch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			chunks = append(chunks, ch)
+		}
+	}
+
+	t.Run("after put", newStoredSizeTest(db))
+
+	// putting existing chunks must not change the stored size
+	_, err = db.Put(context.Background(), chunk.ModePutRequest, chunks...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("after existing put", newStoredSizeTest(db))
+
+	err = db.Set(context.Background(), chunk.ModeSetRemove, chunks[0].Address(), chunks[15].Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("after remove", newStoredSizeTest(db))
+
+	// removing more data than the stored size
+	// must clamp the stored size to zero
+	if err := db.storedSize.Put(1); err != nil {
+		t.Fatal(err)
+	}
+	err = db.Set(context.Background(), chunk.ModeSetRemove, chunks[1].Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	storedSize, err := db.StoredSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedSize != 0 {
+		t.Errorf("got stored size %v after underflow, want 0", storedSize)
+	}
+
+	// reset the stored size as it would be
+	// in databases created before it was tracked
+	if err := db.storedSize.Put(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = New(dir, baseKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	t.Run("after open", newStoredSizeTest(db))
+}
+
+// newStoredSizeTest returns a test function that validates if DB.storedSize
+// value is the same as the size of chunk data in DB.retrievalDataIndex.
+func newStoredSizeTest(db *DB) func(t *testing.T) {
+	return func(t *testing.T) {
+		t.Helper()
+
+		var want uint64
+		err := db.retrievalDataIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+			want += uint64(len(item.Data))
+			return
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := db.StoredSize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got stored size %v, want %v", got, want)
+		}
+	}
+}
+
 // setTestHookCollectGarbage sets testHookCollectGarbage and
 // returns a function that will reset it to the
 // value before the change.
@@ -0,0 +1,150 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/shed"
+)
+
+// gcRoundsLength is the number of the most recent garbage
+// collection rounds that are kept for inspection.
+var gcRoundsLength = 10
+
+// Inspection holds information about the database state.
+type Inspection struct {
+	// Indices are sizes of all indexes and fields
+	// as returned by DebugIndices.
+	Indices map[string]int `json:"indices"`
+	// Capacity, GCSize and GCTarget are in number of chunks.
+	Capacity uint64 `json:"capacity"`
+	GCSize   uint64 `json:"gcSize"`
+	GCTarget uint64 `json:"gcTarget"`
+	// CapacityBytes, StoredSize and GCTargetBytes are in bytes.
+	CapacityBytes uint64 `json:"capacityBytes"`
+	StoredSize    uint64 `json:"storedSize"`
+	GCTargetBytes uint64 `json:"gcTargetBytes"`
+	// Bins are numbers of chunks in pull index
+	// for every proximity order bin.
+	Bins []uint64 `json:"bins"`
+	// GCRounds are the most recent garbage
+	// collection rounds, the last one first.
+	GCRounds []GCRound `json:"gcRounds"`
+}
+
+// GCRound holds information about a single
+// garbage collection run.
+type GCRound struct {
+	Start          time.Time     `json:"start"`
+	Duration       time.Duration `json:"duration"`
+	CollectedCount uint64        `json:"collectedCount"`
+	CollectedBytes uint64        `json:"collectedBytes"`
+	Done           bool          `json:"done"`
+	Error          string        `json:"error,omitempty"`
+}
+
+// gcRounds keeps a limited number of
+// the most recent garbage collection rounds.
+type gcRounds struct {
+	rounds []GCRound
+	mu     sync.Mutex
+}
+
+// add adds a new round and removes the oldest
+// one if the length limit is reached.
+func (r *gcRounds) add(round GCRound) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rounds = append(r.rounds, round)
+	if l := len(r.rounds); l > gcRoundsLength {
+		r.rounds = append(r.rounds[:0:0], r.rounds[l-gcRoundsLength:]...)
+	}
+}
+
+// list returns rounds with the last one first.
+func (r *gcRounds) list() (rounds []GCRound) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rounds = make([]GCRound, 0, len(r.rounds))
+	for i := len(r.rounds) - 1; i >= 0; i-- {
+		rounds = append(rounds, r.rounds[i])
+	}
+	return rounds
+}
+
+// Inspect returns information about the database state, including
+// index sizes, garbage collection targets, numbers of chunks in
+// every bin and the most recent garbage collection rounds. It
+// iterates over all indexes, so it should be used only for
+// debugging.
+func (db *DB) Inspect() (i *Inspection, err error) {
+	i = &Inspection{
+		Capacity:      db.capacity,
+		GCTarget:      db.gcTarget(),
+		CapacityBytes: db.capacityBytes,
+		GCTargetBytes: db.gcTargetBytes(),
+		Bins:          make([]uint64, chunk.MaxPO+1),
+		GCRounds:      db.gcRounds.list(),
+	}
+	i.Indices, err = db.DebugIndices()
+	if err != nil {
+		return nil, err
+	}
+	i.GCSize, err = db.gcSize.Get()
+	if err != nil {
+		return nil, err
+	}
+	i.StoredSize, err = db.storedSize.Get()
+	if err != nil {
+		return nil, err
+	}
+	err = db.pullIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		i.Bins[db.po(item.Address)]++
+		return false, nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// InspectHandler returns an HTTP handler that responds
+// with a JSON encoded Inspection of the database.
+func (db *DB) InspectHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		i, err := db.Inspect()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(i); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
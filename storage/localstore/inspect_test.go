@@ -0,0 +1,153 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// TestDB_Inspect validates that Inspect returns
+// index sizes, bin counts and garbage collection rounds.
+func TestDB_Inspect(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity: 10,
+	})
+	testHookCollectGarbageChan := make(chan uint64)
+	defer setTestHookCollectGarbage(func(collectedCount uint64) {
+		select {
+		case testHookCollectGarbageChan <- collectedCount:
+		case <-db.close:
+		}
+	})()
+	defer cleanupFunc()
+
+	chunkCount := 15
+	for i := 0; i < chunkCount; i++ {
+		ch := generateTestRandomChunk()
+		if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gcTarget := db.gcTarget()
+	for {
+		select {
+		case <-testHookCollectGarbageChan:
+		case <-time.After(10 * time.Second):
+			t.Fatal("collect garbage timeout")
+		}
+		gcSize, err := db.gcSize.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gcSize == gcTarget {
+			break
+		}
+	}
+
+	i, err := db.Inspect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Capacity != 10 {
+		t.Errorf("got capacity %v, want %v", i.Capacity, 10)
+	}
+	if i.GCSize != gcTarget {
+		t.Errorf("got gc size %v, want %v", i.GCSize, gcTarget)
+	}
+	if i.GCTarget != gcTarget {
+		t.Errorf("got gc target %v, want %v", i.GCTarget, gcTarget)
+	}
+	if i.Indices["pullIndex"] != int(gcTarget) {
+		t.Errorf("got pull index size %v, want %v", i.Indices["pullIndex"], gcTarget)
+	}
+	if len(i.Bins) != chunk.MaxPO+1 {
+		t.Errorf("got %v bins, want %v", len(i.Bins), chunk.MaxPO+1)
+	}
+	var binsCount uint64
+	for _, c := range i.Bins {
+		binsCount += c
+	}
+	if binsCount != gcTarget {
+		t.Errorf("got bins count %v, want %v", binsCount, gcTarget)
+	}
+	if len(i.GCRounds) == 0 {
+		t.Fatal("got no gc rounds")
+	}
+	var collectedCount uint64
+	for _, r := range i.GCRounds {
+		if r.Error != "" {
+			t.Errorf("got gc round error %q", r.Error)
+		}
+		collectedCount += r.CollectedCount
+	}
+	if want := uint64(chunkCount) - gcTarget; collectedCount != want {
+		t.Errorf("got collected count %v, want %v", collectedCount, want)
+	}
+
+	t.Run("http handler", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		db.InspectHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/localstore", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %v, want %v", rec.Code, http.StatusOK)
+		}
+		var got Inspection
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.GCSize != gcTarget {
+			t.Errorf("got gc size %v, want %v", got.GCSize, gcTarget)
+		}
+
+		rec = httptest.NewRecorder()
+		db.InspectHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/localstore", nil))
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("got status %v, want %v", rec.Code, http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// TestGCRounds validates that only the most recent
+// garbage collection rounds are kept.
+func TestGCRounds(t *testing.T) {
+	defer func(l int) { gcRoundsLength = l }(gcRoundsLength)
+	gcRoundsLength = 3
+
+	var r gcRounds
+	for i := uint64(1); i <= 5; i++ {
+		r.add(GCRound{CollectedCount: i})
+	}
+	got := r.list()
+	if len(got) != 3 {
+		t.Fatalf("got %v rounds, want %v", len(got), 3)
+	}
+	for i, want := range []uint64{5, 4, 3} {
+		if got[i].CollectedCount != want {
+			t.Errorf("round %v: got collected count %v, want %v", i, got[i].CollectedCount, want)
+		}
+	}
+}
@@ -19,6 +19,7 @@ package localstore
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"os"
 	"runtime/pprof"
 	"sync"
@@ -42,6 +43,13 @@ var (
 	// is updated in parallel and one of the updates
 	// takes longer then the configured timeout duration.
 	ErrAddressLockTimeout = errors.New("address lock timeout")
+	// ErrReadOnly is returned when the database is opened
+	// with ReadOnly option and a function that changes
+	// it is called.
+	ErrReadOnly = errors.New("read-only database")
+	// ErrDBClosed is returned when a Put call is
+	// made after the database is closed.
+	ErrDBClosed = errors.New("db closed")
 )
 
 var (
@@ -88,14 +96,45 @@ type DB struct {
 
 	// pin files Index
 	pinIndex shed.Index
+	// sampled chunk access counts
+	accessStatsIndex shed.Index
+	// fraction of requests recorded in accessStatsIndex
+	accessStatsSampleRate float64
 
 	// field that stores number of intems in gc index
 	gcSize shed.Uint64Field
 
+	// field that stores the progress of resumable import
+	importProgress shed.StructField
+
 	// garbage collection is triggered when gcSize exceeds
 	// the capacity value
 	capacity uint64
 
+	// field that stores the number of bytes of chunk data
+	// in retrieval data index
+	storedSize shed.Uint64Field
+
+	// garbage collection is triggered also when storedSize
+	// exceeds the capacityBytes value, if it is not zero
+	capacityBytes uint64
+
+	// selects chunks to be removed on garbage collection
+	gcStrategy GCStrategy
+
+	// maximal number of chunks removed per second
+	// on garbage collection, 0 for no limit
+	gcRateLimit float64
+	// garbage collection runs until the target size is
+	// reached only in these windows, if any are set
+	gcWindows []GCWindow
+	// chunks stored within this period are
+	// not removed on garbage collection
+	gcProtectionPeriod time.Duration
+
+	// the most recent garbage collection rounds
+	gcRounds gcRounds
+
 	// triggers garbage collection event loop
 	collectGarbageTrigger chan struct{}
 
@@ -111,6 +150,13 @@ type DB struct {
 
 	batchMu sync.Mutex
 
+	// receives Put calls that are coalesced into shared
+	// batches, nil if put batching is disabled
+	putBatchC          chan *putBatchRequest
+	putBatchMaxChunks  int
+	putBatchDelay      time.Duration
+	putBatchWorkerDone chan struct{}
+
 	// this channel is closed when close function is called
 	// to terminate other goroutines
 	close chan struct{}
@@ -122,6 +168,9 @@ type DB struct {
 
 	putToGCCheck func([]byte) bool
 
+	// database does not allow any changes
+	readOnly bool
+
 	// wait for all subscriptions to finish before closing
 	// underlaying LevelDB to prevent possible panics from
 	// iterators
@@ -139,6 +188,10 @@ type Options struct {
 	// Capacity is a limit that triggers garbage collection when
 	// number of items in gcIndex equals or exceeds it.
 	Capacity uint64
+	// CapacityBytes is a limit that triggers garbage collection when
+	// the size of stored chunk data in bytes equals or exceeds it.
+	// Value 0 disables the byte based limit.
+	CapacityBytes uint64
 	// MetricsPrefix defines a prefix for metrics names.
 	MetricsPrefix string
 	Tags          *chunk.Tags
@@ -146,6 +199,54 @@ type Options struct {
 	// to verify whether that chunk needs to be Set and added to
 	// garbage collection index too
 	PutToGCCheck func([]byte) bool
+	// ReadOnly opens an existing database without allowing any
+	// changes to it. Functions that change the database return
+	// ErrReadOnly and garbage collection is not started. Schema
+	// migrations can not be run on a read-only database. Fields and
+	// indexes that were added to the schema after the database was
+	// created are empty.
+	// LevelDB allows only one process to have the database open,
+	// so this option does not allow opening the database while
+	// another process has it open for writing.
+	ReadOnly bool
+	// MigrationDryRun prevents running schema migrations on an existing
+	// database. If migrations are required, New returns
+	// MigrationDryRunError with their names.
+	MigrationDryRun bool
+	// MigrationBackupPath is a path of a new database where all data is
+	// copied before schema migrations are run, if it is not empty.
+	MigrationBackupPath string
+	// PutBatchMaxChunks enables coalescing of concurrent Put calls
+	// with ModePutUpload and ModePutSync into shared batches, with
+	// at most this number of chunks. Value 0 disables coalescing.
+	PutBatchMaxChunks int
+	// PutBatchDelay is the maximal time that a coalesced Put call
+	// waits for other calls before its batch is written. If it is 0,
+	// only calls that are already waiting are added to the batch.
+	PutBatchDelay time.Duration
+	// GCProtectionPeriod is the period after a chunk is stored or
+	// last accessed in which it is not removed on garbage collection.
+	// It gives time to chunks received by pull or push sync to be
+	// synced to other nodes. If there are not enough chunks that can
+	// be removed, the database can exceed its capacity until the
+	// period passes.
+	GCProtectionPeriod time.Duration
+	// AccessStatsSampleRate is the fraction of chunk requests, in
+	// range (0,1], that are recorded in access statistics. Sampling
+	// limits the number of writes on requests. Value 0 disables
+	// access statistics.
+	AccessStatsSampleRate float64
+	// GCStrategy selects chunks to be removed on garbage collection.
+	// If it is nil, the least recently accessed chunks are removed.
+	GCStrategy GCStrategy
+	// GCRateLimit is the maximal number of chunks removed per second
+	// on garbage collection. Value 0 disables the limit.
+	GCRateLimit float64
+	// GCWindows are daily time windows in which garbage collection
+	// removes chunks until the target size is reached. Outside of
+	// them, only a single batch is removed when capacity is reached.
+	// If no windows are set, garbage collection is not restricted.
+	GCWindows []GCWindow
 }
 
 // New returns a new DB.  All fields and indexes are initialized
@@ -164,9 +265,15 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 	}
 
 	db = &DB{
-		capacity: o.Capacity,
-		baseKey:  baseKey,
-		tags:     o.Tags,
+		capacity:              o.Capacity,
+		capacityBytes:         o.CapacityBytes,
+		gcStrategy:            o.GCStrategy,
+		gcRateLimit:           o.GCRateLimit,
+		gcWindows:             o.GCWindows,
+		gcProtectionPeriod:    o.GCProtectionPeriod,
+		accessStatsSampleRate: o.AccessStatsSampleRate,
+		baseKey:               baseKey,
+		tags:                  o.Tags,
 		// channel collectGarbageTrigger
 		// needs to be buffered with the size of 1
 		// to signal another event if it
@@ -175,15 +282,28 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 		close:                    make(chan struct{}),
 		collectGarbageWorkerDone: make(chan struct{}),
 		putToGCCheck:             o.PutToGCCheck,
+		readOnly:                 o.ReadOnly,
 	}
 	if db.capacity <= 0 {
 		db.capacity = defaultCapacity
 	}
+	if db.gcStrategy == nil {
+		db.gcStrategy = LRUGCStrategy{}
+	}
+	if o.PutBatchMaxChunks > 0 && !o.ReadOnly {
+		db.putBatchC = make(chan *putBatchRequest)
+		db.putBatchMaxChunks = o.PutBatchMaxChunks
+		db.putBatchDelay = o.PutBatchDelay
+	}
 	if maxParallelUpdateGC > 0 {
 		db.updateGCSem = make(chan struct{}, maxParallelUpdateGC)
 	}
 
-	db.shed, err = shed.NewDB(path, o.MetricsPrefix)
+	if db.readOnly {
+		db.shed, err = shed.NewReadOnlyDB(path, o.MetricsPrefix)
+	} else {
+		db.shed, err = shed.NewDB(path, o.MetricsPrefix)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -197,7 +317,12 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 	if err != nil {
 		return nil, err
 	}
-	if schemaName == "" {
+	if db.readOnly {
+		if schemaName != DbSchemaCurrent {
+			db.shed.Close()
+			return nil, fmt.Errorf("read-only localstore schema %q requires migrations to %q", schemaName, DbSchemaCurrent)
+		}
+	} else if schemaName == "" {
 		// initial new localstore run
 		err := db.schemaName.Put(DbSchemaCurrent)
 		if err != nil {
@@ -205,8 +330,12 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 		}
 	} else {
 		// execute possible migrations
-		err = db.migrate(schemaName)
+		err = db.migrate(schemaName, o.MigrationDryRun, o.MigrationBackupPath)
 		if err != nil {
+			if _, ok := err.(*MigrationDryRunError); ok {
+				// the database is not used any further
+				db.shed.Close()
+			}
 			return nil, err
 		}
 	}
@@ -216,6 +345,16 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 	if err != nil {
 		return nil, err
 	}
+	// Persist stored chunk data size.
+	db.storedSize, err = db.shed.NewUint64Field("stored-size")
+	if err != nil {
+		return nil, err
+	}
+	// Persist progress of resumable import.
+	db.importProgress, err = db.shed.NewStructField("import-progress")
+	if err != nil {
+		return nil, err
+	}
 	// Functions for retrieval data index.
 	var (
 		encodeValueFunc func(fields shed.Item) (value []byte, err error)
@@ -410,6 +549,32 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 		return nil, err
 	}
 
+	// Create a index structure for storing sampled chunk access counts
+	// and the last recorded access timestamps
+	db.accessStatsIndex, err = db.shed.NewIndex("Hash->AccessCount|AccessTimestamp", shed.IndexFuncs{
+		EncodeKey: func(fields shed.Item) (key []byte, err error) {
+			return fields.Address, nil
+		},
+		DecodeKey: func(key []byte) (e shed.Item, err error) {
+			e.Address = key
+			return e, nil
+		},
+		EncodeValue: func(fields shed.Item) (value []byte, err error) {
+			b := make([]byte, 16)
+			binary.BigEndian.PutUint64(b[:8], fields.AccessCount)
+			binary.BigEndian.PutUint64(b[8:16], uint64(fields.AccessTimestamp))
+			return b, nil
+		},
+		DecodeValue: func(keyItem shed.Item, value []byte) (e shed.Item, err error) {
+			e.AccessCount = binary.BigEndian.Uint64(value[:8])
+			e.AccessTimestamp = int64(binary.BigEndian.Uint64(value[8:16]))
+			return e, nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// Create a index structure for excluding pinned chunks from gcIndex
 	db.gcExcludeIndex, err = db.shed.NewIndex("Hash->nil", shed.IndexFuncs{
 		EncodeKey: func(fields shed.Item) (key []byte, err error) {
@@ -430,6 +595,25 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 		return nil, err
 	}
 
+	if db.readOnly {
+		// no garbage collection is needed
+		// for a read-only database
+		close(db.collectGarbageWorkerDone)
+		return db, nil
+	}
+
+	// count the stored data size for databases
+	// created before it was tracked
+	err = db.initStoredSize()
+	if err != nil {
+		return nil, err
+	}
+
+	if db.putBatchC != nil {
+		db.putBatchWorkerDone = make(chan struct{})
+		go db.putBatchWorker()
+	}
+
 	// start garbage collection worker
 	go db.collectGarbageWorker()
 	return db, nil
@@ -447,6 +631,11 @@ func (db *DB) Close() (err error) {
 		// wait for gc worker to
 		// return before closing the shed
 		<-db.collectGarbageWorkerDone
+		if db.putBatchWorkerDone != nil {
+			// wait for the last put batch
+			// to be written
+			<-db.putBatchWorkerDone
+		}
 		close(done)
 	}()
 	select {
@@ -478,6 +667,7 @@ func (db *DB) DebugIndices() (indexInfo map[string]int, err error) {
 		"gcIndex":              db.gcIndex,
 		"gcExcludeIndex":       db.gcExcludeIndex,
 		"pinIndex":             db.pinIndex,
+		"accessStatsIndex":     db.accessStatsIndex,
 	} {
 		indexSize, err := v.Count()
 		if err != nil {
@@ -490,6 +680,11 @@ func (db *DB) DebugIndices() (indexInfo map[string]int, err error) {
 		return indexInfo, err
 	}
 	indexInfo["gcSize"] = int(val)
+	val, err = db.storedSize.Get()
+	if err != nil {
+		return indexInfo, err
+	}
+	indexInfo["storedSize"] = int(val)
 
 	return indexInfo, err
 }
@@ -19,6 +19,7 @@ package localstore
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -136,6 +137,196 @@ func TestDB_updateGCSem(t *testing.T) {
 	}
 }
 
+// TestDB_readOnly validates that a database opened with ReadOnly
+// option returns stored chunks and ErrReadOnly on changes.
+func TestDB_readOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	baseKey := make([]byte, 32)
+	if _, err := rand.Read(baseKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(dir, baseKey, &Options{ReadOnly: true}); err == nil {
+		t.Fatal("read-only database opened without existing database")
+	}
+
+	db, err := New(dir, baseKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := generateTestRandomChunk()
+	if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = New(dir, baseKey, &Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, mode := range []chunk.ModeGet{
+		chunk.ModeGetRequest,
+		chunk.ModeGetSync,
+		chunk.ModeGetLookup,
+	} {
+		got, err := db.Get(context.Background(), mode, ch.Address())
+		if err != nil {
+			t.Fatalf("get mode %s: %v", mode, err)
+		}
+		if !bytes.Equal(got.Data(), ch.Data()) {
+			t.Errorf("get mode %s: got data %x, want %x", mode, got.Data(), ch.Data())
+		}
+	}
+
+	t.Run("access index count", newItemsCountTest(db.retrievalAccessIndex, 0))
+
+	if _, err := db.Put(context.Background(), chunk.ModePutUpload, generateTestRandomChunk()); err != ErrReadOnly {
+		t.Errorf("got put error %v, want %v", err, ErrReadOnly)
+	}
+	if err := db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address()); err != ErrReadOnly {
+		t.Errorf("got set error %v, want %v", err, ErrReadOnly)
+	}
+	if err := db.Pin(context.Background(), ch.Address()); err != ErrReadOnly {
+		t.Errorf("got pin error %v, want %v", err, ErrReadOnly)
+	}
+	if _, err := db.Import(bytes.NewReader(nil), false); err != ErrReadOnly {
+		t.Errorf("got import error %v, want %v", err, ErrReadOnly)
+	}
+}
+
+// TestDB_readOnlyPreviousSchema validates that a database created before
+// fields and indexes were added to the schema can be opened with ReadOnly
+// option without changing the stored schema.
+func TestDB_readOnlyPreviousSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	baseKey := make([]byte, 32)
+	if _, err := rand.Read(baseKey); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := New(dir, baseKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := generateTestRandomChunk()
+	if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// remove the fields and indexes that were added
+	// to the schema after the database was created
+	newFields := []string{"stored-size", "import-progress"}
+	newIndexes := []string{"Hash->AccessCount|AccessTimestamp"}
+	ldb, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := readTestSchema(t, ldb)
+	for _, name := range newFields {
+		delete(schema.Fields, name)
+		if err := ldb.Delete(append([]byte{1}, name...), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for id, index := range schema.Indexes {
+		for _, name := range newIndexes {
+			if index.Name == name {
+				delete(schema.Indexes, id)
+			}
+		}
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ldb.Put([]byte{0}, data, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ldb.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = New(dir, baseKey, &Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.Get(context.Background(), chunk.ModeGetRequest, ch.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Data(), ch.Data()) {
+		t.Errorf("got data %x, want %x", got.Data(), ch.Data())
+	}
+	storedSize, err := db.StoredSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedSize != 0 {
+		t.Errorf("got stored size %v, want 0", storedSize)
+	}
+	t.Run("access stats index count", newItemsCountTest(db.accessStatsIndex, 0))
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ldb, err = leveldb.OpenFile(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+	schema = readTestSchema(t, ldb)
+	for _, name := range newFields {
+		if _, ok := schema.Fields[name]; ok {
+			t.Errorf("field %q stored in schema by read-only database", name)
+		}
+	}
+	for _, index := range schema.Indexes {
+		for _, name := range newIndexes {
+			if index.Name == name {
+				t.Errorf("index %q stored in schema by read-only database", name)
+			}
+		}
+	}
+}
+
+// testSchema is the schema of fields and indexes
+// stored by shed package in LevelDB.
+type testSchema struct {
+	Fields  map[string]json.RawMessage `json:"fields"`
+	Indexes map[string]struct {
+		Name string `json:"name"`
+	} `json:"indexes"`
+}
+
+// readTestSchema reads the shed schema from LevelDB.
+func readTestSchema(t *testing.T, ldb *leveldb.DB) (s testSchema) {
+	t.Helper()
+
+	data, err := ldb.Get([]byte{0}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
 // newTestDB is a helper function that constructs a
 // temporary database and returns a cleanup function that must
 // be called to remove the data.
@@ -20,6 +20,8 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
@@ -30,6 +32,26 @@ import (
 var errMissingCurrentSchema = errors.New("could not find current db schema")
 var errMissingTargetSchema = errors.New("could not find target db schema")
 
+// MigrationDryRunError is returned by New when Options.MigrationDryRun
+// is set and the database requires migrations. The database is not
+// changed in that case.
+type MigrationDryRunError struct {
+	// SchemaName is the current schema name of the database.
+	SchemaName string
+	// Migrations are names of schemas that the database would be
+	// migrated to, in the order of execution.
+	Migrations []string
+}
+
+func (e *MigrationDryRunError) Error() string {
+	return fmt.Sprintf("localstore schema %q requires migrations to %s", e.SchemaName, strings.Join(e.Migrations, ", "))
+}
+
+// migrationBackupBatchSize limits the number of key/value pairs
+// in a single leveldb batch when the database is backed up
+// before migrations.
+var migrationBackupBatchSize = 1000
+
 type migration struct {
 	name string             // name of the schema
 	fn   func(db *DB) error // the migration function that needs to be performed in order to get to the current schema name
@@ -44,7 +66,11 @@ var schemaMigrations = []migration{
 	{name: DbSchemaDiwali, fn: migrateSanctuary},
 }
 
-func (db *DB) migrate(schemaName string) error {
+// migrate runs all migrations from the provided schema name to the
+// current one. If dryRun is true, MigrationDryRunError is returned
+// instead of running them. If backupPath is not empty, all database
+// data is copied to a new database on that path before migrations.
+func (db *DB) migrate(schemaName string, dryRun bool, backupPath string) error {
 	migrations, err := getMigrations(schemaName, DbSchemaCurrent, schemaMigrations)
 	if err != nil {
 		return fmt.Errorf("error getting migrations for current schema (%s): %v", schemaName, err)
@@ -55,6 +81,24 @@ func (db *DB) migrate(schemaName string) error {
 		return nil
 	}
 
+	if dryRun {
+		e := &MigrationDryRunError{
+			SchemaName: schemaName,
+		}
+		for _, m := range migrations {
+			e.Migrations = append(e.Migrations, m.name)
+			log.Info("localstore migration pending", "schemaName", m.name)
+		}
+		return e
+	}
+
+	if backupPath != "" {
+		log.Info("backing up localstore before migrations", "path", backupPath)
+		if err := db.backup(backupPath); err != nil {
+			return fmt.Errorf("backup localstore to %s: %v", backupPath, err)
+		}
+	}
+
 	log.Info("need to run data migrations on localstore", "numMigrations", len(migrations), "schemaName", schemaName)
 	for i := 0; i < len(migrations); i++ {
 		err := migrations[i].fn(db)
@@ -74,6 +118,41 @@ func (db *DB) migrate(schemaName string) error {
 	return nil
 }
 
+// backup copies all key/value pairs from the database
+// to a new leveldb database on the provided path.
+func (db *DB) backup(path string) (err error) {
+	if _, err := os.Stat(path); err == nil {
+		return errors.New("backup path already exists")
+	}
+	b, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := b.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+
+	it := db.shed.NewIterator()
+	defer it.Release()
+
+	batch := new(leveldb.Batch)
+	for it.Next() {
+		batch.Put(it.Key(), it.Value())
+		if batch.Len() >= migrationBackupBatchSize {
+			if err := b.Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return b.Write(batch, nil)
+}
+
 // migrationFn is a function that takes a localstore.DB and
 // returns an error if a migration has failed
 type migrationFn func(db *DB) error
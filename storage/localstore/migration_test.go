@@ -17,6 +17,8 @@
 package localstore
 
 import (
+	"bytes"
+	"context"
 	"io"
 	"io/ioutil"
 	"log"
@@ -308,6 +310,193 @@ func TestMigrationFailTo(t *testing.T) {
 	}
 }
 
+// TestMigrationDryRun validates that migrations are not run and
+// the database is not changed when MigrationDryRun option is set.
+func TestMigrationDryRun(t *testing.T) {
+	defer func(v []migration, s string) {
+		schemaMigrations = v
+		DbSchemaCurrent = s
+	}(schemaMigrations, DbSchemaCurrent)
+
+	DbSchemaCurrent = DbSchemaHalloween
+
+	ran := false
+	schemaMigrations = []migration{
+		{name: DbSchemaHalloween, fn: func(db *DB) error { return nil }},
+		{name: DbSchemaSanctuary, fn: func(db *DB) error {
+			ran = true
+			return nil
+		}},
+		{name: DbSchemaDiwali, fn: func(db *DB) error {
+			ran = true
+			return nil
+		}},
+	}
+
+	dir, err := ioutil.TempDir("", "localstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	baseKey := make([]byte, 32)
+	if _, err := rand.Read(baseKey); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := New(dir, baseKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	DbSchemaCurrent = DbSchemaDiwali
+
+	_, err = New(dir, baseKey, &Options{MigrationDryRun: true})
+	e, ok := err.(*MigrationDryRunError)
+	if !ok {
+		t.Fatalf("got error %v, want MigrationDryRunError", err)
+	}
+	if e.SchemaName != DbSchemaHalloween {
+		t.Errorf("got schema name %q, want %q", e.SchemaName, DbSchemaHalloween)
+	}
+	if strings.Join(e.Migrations, ",") != DbSchemaSanctuary+","+DbSchemaDiwali {
+		t.Errorf("got migrations %v, want %v", e.Migrations, []string{DbSchemaSanctuary, DbSchemaDiwali})
+	}
+	if ran {
+		t.Error("migration ran on dry run")
+	}
+
+	// the database must be closed and unchanged after the dry run
+	db, err = New(dir, baseKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if !ran {
+		t.Error("migration did not run")
+	}
+}
+
+// TestMigrationBackup validates that the database is copied
+// to the backup path before migrations are run.
+func TestMigrationBackup(t *testing.T) {
+	defer func(v []migration, s string) {
+		schemaMigrations = v
+		DbSchemaCurrent = s
+	}(schemaMigrations, DbSchemaCurrent)
+
+	defer func(s int) { migrationBackupBatchSize = s }(migrationBackupBatchSize)
+	migrationBackupBatchSize = 7
+
+	DbSchemaCurrent = DbSchemaSanctuary
+
+	schemaMigrations = []migration{
+		{name: DbSchemaSanctuary, fn: func(db *DB) error { return nil }},
+		{name: DbSchemaDiwali, fn: func(db *DB) error {
+			// mark the database to validate that
+			// the backup is made before migration
+			f, err := db.shed.NewStringField("migration-test")
+			if err != nil {
+				return err
+			}
+			return f.Put("migrated")
+		}},
+	}
+
+	dir, err := ioutil.TempDir("", "localstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	baseKey := make([]byte, 32)
+	if _, err := rand.Read(baseKey); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := New(dir, baseKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := generateTestRandomChunks(10)
+	if _, err := db.Put(context.Background(), chunk.ModePutUpload, chunks...); err != nil {
+		t.Fatal(err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	DbSchemaCurrent = DbSchemaDiwali
+
+	backupDir, err := ioutil.TempDir("", "localstore-backup-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(backupDir)
+	backupPath := path.Join(backupDir, "backup")
+
+	db, err = New(dir, baseKey, &Options{MigrationBackupPath: backupPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mark, err := db.shed.NewStringField("migration-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := mark.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "migrated" {
+		t.Errorf("got migration mark %q, want %q", v, "migrated")
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// open the backup with the old schema
+	DbSchemaCurrent = DbSchemaSanctuary
+
+	backup, err := New(backupPath, baseKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	schemaName, err := backup.schemaName.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schemaName != DbSchemaSanctuary {
+		t.Errorf("got backup schema name %q, want %q", schemaName, DbSchemaSanctuary)
+	}
+	mark, err = backup.shed.NewStringField("migration-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err = mark.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "" {
+		t.Errorf("got backup migration mark %q, want empty", v)
+	}
+	for _, ch := range chunks {
+		got, err := backup.Get(context.Background(), chunk.ModeGetLookup, ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Data(), ch.Data()) {
+			t.Errorf("chunk %s: got data %x, want %x", ch.Address(), got.Data(), ch.Data())
+		}
+	}
+}
+
 // TestMigrateSanctuaryFixture migrates an actual Sanctuary localstore
 // to the most recent schema.
 func TestMigrateSanctuaryFixture(t *testing.T) {
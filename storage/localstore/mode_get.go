@@ -89,6 +89,11 @@ func (db *DB) get(mode chunk.ModeGet, addr chunk.Address) (out shed.Item, err er
 // for Get or GetMulti to update access time and gc indexes
 // for all returned chunks.
 func (db *DB) updateGCItems(items ...shed.Item) {
+	if db.readOnly {
+		// access timestamps are not
+		// updated in read-only database
+		return
+	}
 	if db.updateGCSem != nil {
 		// wait before creating new goroutines
 		// if updateGCSem buffer id full
@@ -131,6 +136,11 @@ func (db *DB) updateGC(item shed.Item) (err error) {
 
 	batch := new(leveldb.Batch)
 
+	err = db.incAccessStatsInBatch(batch, item)
+	if err != nil {
+		return err
+	}
+
 	// update accessTimeStamp in retrieve, gc
 
 	i, err := db.retrievalAccessIndex.Get(item)
@@ -145,6 +155,10 @@ func (db *DB) updateGC(item shed.Item) (err error) {
 	if item.AccessTimestamp == 0 {
 		// chunk is not yet synced
 		// do not add it to the gc index
+		if batch.Len() > 0 {
+			// write access statistics
+			return db.shed.WriteBatch(batch)
+		}
 		return nil
 	}
 	// delete current entry from the gc index
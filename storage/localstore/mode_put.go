@@ -17,7 +17,6 @@
 package localstore
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"time"
@@ -38,7 +37,11 @@ func (db *DB) Put(ctx context.Context, mode chunk.ModePut, chs ...chunk.Chunk) (
 	metrics.GetOrRegisterCounter(metricName, nil).Inc(1)
 	defer totalTimeMetric(metricName, time.Now())
 
-	exist, err = db.put(mode, chs...)
+	if db.putBatchC != nil && (mode == chunk.ModePutUpload || mode == chunk.ModePutSync) {
+		exist, err = db.putBatched(ctx, mode, chs...)
+	} else {
+		exist, err = db.put(mode, chs...)
+	}
 	if err != nil {
 		metrics.GetOrRegisterCounter(metricName+"/error", nil).Inc(1)
 	}
@@ -54,105 +57,135 @@ func (db *DB) Put(ctx context.Context, mode chunk.ModePut, chs ...chunk.Chunk) (
 // slice. This is the same behaviour as if the same chunks are passed one by one
 // in multiple put method calls.
 func (db *DB) put(mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err error) {
+	if db.readOnly {
+		return nil, ErrReadOnly
+	}
+
 	// protect parallel updates
 	db.batchMu.Lock()
 	defer db.batchMu.Unlock()
 
-	batch := new(leveldb.Batch)
+	b := newPutBatch()
 
-	// variables that provide information for operations
-	// to be done after write batch function successfully executes
-	var gcSizeChange int64                      // number to add or subtract from gcSize
-	var triggerPushFeed bool                    // signal push feed subscriptions to iterate
-	triggerPullFeed := make(map[uint8]struct{}) // signal pull feed subscriptions to iterate
+	exist, err = db.putInBatch(b, mode, chs...)
+	if err != nil {
+		return nil, err
+	}
 
-	exist = make([]bool, len(chs))
+	err = db.writePutBatch(b)
+	if err != nil {
+		return nil, err
+	}
+	return exist, nil
+}
 
+// putBatch holds a write batch and information for operations
+// to be done after the batch is successfully written. A single
+// putBatch can hold chunks from multiple put calls.
+type putBatch struct {
+	batch *leveldb.Batch
 	// A lazy populated map of bin ids to properly set
 	// BinID values for new chunks based on initial value from database
 	// and incrementing them.
 	// Values from this map are stored with the batch
-	binIDs := make(map[uint8]uint64)
+	binIDs map[uint8]uint64
+	// addresses of chunks already added to the batch
+	addrs map[string]struct{}
+
+	gcSizeChange     int64              // number to add or subtract from gcSize
+	storedSizeChange int64              // number of bytes to add to storedSize
+	triggerPushFeed  bool               // signal push feed subscriptions to iterate
+	triggerPullFeed  map[uint8]struct{} // signal pull feed subscriptions to iterate
+}
 
+// newPutBatch returns a new empty putBatch.
+func newPutBatch() *putBatch {
+	return &putBatch{
+		batch:           new(leveldb.Batch),
+		binIDs:          make(map[uint8]uint64),
+		addrs:           make(map[string]struct{}),
+		triggerPullFeed: make(map[uint8]struct{}),
+	}
+}
+
+// putInBatch updates indexes for chunks in the provided putBatch.
+// Chunks that are already in the batch are reported as existing.
+// It must be called with batchMu locked.
+func (db *DB) putInBatch(b *putBatch, mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err error) {
+	var putFunc func(*leveldb.Batch, map[uint8]uint64, shed.Item) (bool, int64, error)
 	switch mode {
 	case chunk.ModePutRequest:
-		for i, ch := range chs {
-			if containsChunk(ch.Address(), chs[:i]...) {
-				exist[i] = true
-				continue
-			}
-			exists, c, err := db.putRequest(batch, binIDs, chunkToItem(ch))
-			if err != nil {
-				return nil, err
-			}
-			exist[i] = exists
-			gcSizeChange += c
-		}
-
+		putFunc = db.putRequest
 	case chunk.ModePutUpload:
-		for i, ch := range chs {
-			if containsChunk(ch.Address(), chs[:i]...) {
-				exist[i] = true
-				continue
-			}
-			exists, c, err := db.putUpload(batch, binIDs, chunkToItem(ch))
-			if err != nil {
-				return nil, err
-			}
-			exist[i] = exists
-			if !exists {
+		putFunc = db.putUpload
+	case chunk.ModePutSync:
+		putFunc = db.putSync
+	default:
+		return nil, ErrInvalidMode
+	}
+
+	exist = make([]bool, len(chs))
+
+	for i, ch := range chs {
+		if _, ok := b.addrs[string(ch.Address())]; ok {
+			exist[i] = true
+			continue
+		}
+		exists, c, err := putFunc(b.batch, b.binIDs, chunkToItem(ch))
+		if err != nil {
+			return nil, err
+		}
+		b.addrs[string(ch.Address())] = struct{}{}
+		exist[i] = exists
+		if !exists {
+			switch mode {
+			case chunk.ModePutUpload:
 				// chunk is new so, trigger subscription feeds
 				// after the batch is successfully written
-				triggerPullFeed[db.po(ch.Address())] = struct{}{}
-				triggerPushFeed = true
-			}
-			gcSizeChange += c
-		}
-
-	case chunk.ModePutSync:
-		for i, ch := range chs {
-			if containsChunk(ch.Address(), chs[:i]...) {
-				exist[i] = true
-				continue
-			}
-			exists, c, err := db.putSync(batch, binIDs, chunkToItem(ch))
-			if err != nil {
-				return nil, err
-			}
-			exist[i] = exists
-			if !exists {
+				b.triggerPullFeed[db.po(ch.Address())] = struct{}{}
+				b.triggerPushFeed = true
+			case chunk.ModePutSync:
 				// chunk is new so, trigger pull subscription feed
 				// after the batch is successfully written
-				triggerPullFeed[db.po(ch.Address())] = struct{}{}
+				b.triggerPullFeed[db.po(ch.Address())] = struct{}{}
 			}
-			gcSizeChange += c
+			b.storedSizeChange += int64(len(ch.Data()))
 		}
+		b.gcSizeChange += c
+	}
+	return exist, nil
+}
 
-	default:
-		return nil, ErrInvalidMode
+// writePutBatch writes the putBatch to the database and
+// triggers subscriptions for new chunks.
+// It must be called with batchMu locked.
+func (db *DB) writePutBatch(b *putBatch) (err error) {
+	for po, id := range b.binIDs {
+		db.binIDs.PutInBatch(b.batch, uint64(po), id)
 	}
 
-	for po, id := range binIDs {
-		db.binIDs.PutInBatch(batch, uint64(po), id)
+	err = db.incGCSizeInBatch(b.batch, b.gcSizeChange)
+	if err != nil {
+		return err
 	}
 
-	err = db.incGCSizeInBatch(batch, gcSizeChange)
+	err = db.incStoredSizeInBatch(b.batch, b.storedSizeChange)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	err = db.shed.WriteBatch(batch)
+	err = db.shed.WriteBatch(b.batch)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	for po := range triggerPullFeed {
+	for po := range b.triggerPullFeed {
 		db.triggerPullSubscriptions(po)
 	}
-	if triggerPushFeed {
+	if b.triggerPushFeed {
 		db.triggerPushSubscriptions()
 	}
-	return exist, nil
+	return nil
 }
 
 // putRequest adds an Item to the batch by updating required indexes:
@@ -345,14 +378,3 @@ func (db *DB) incBinID(binIDs map[uint8]uint64, po uint8) (id uint64, err error)
 	binIDs[po]++
 	return binIDs[po], nil
 }
-
-// containsChunk returns true if the chunk with a specific address
-// is present in the provided chunk slice.
-func containsChunk(addr chunk.Address, chs ...chunk.Chunk) bool {
-	for _, c := range chs {
-		if bytes.Equal(addr, c.Address()) {
-			return true
-		}
-	}
-	return false
-}
@@ -49,6 +49,10 @@ func (db *DB) Set(ctx context.Context, mode chunk.ModeSet, addrs ...chunk.Addres
 // It acquires lockAddr to protect two calls
 // of this function for the same address in parallel.
 func (db *DB) set(mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
+	if db.readOnly {
+		return ErrReadOnly
+	}
+
 	// protect parallel updates
 	db.batchMu.Lock()
 	defer db.batchMu.Unlock()
@@ -58,6 +62,7 @@ func (db *DB) set(mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
 	// variables that provide information for operations
 	// to be done after write batch function successfully executes
 	var gcSizeChange int64                      // number to add or subtract from gcSize
+	var storedSizeChange int64                  // number of bytes to add or subtract from storedSize
 	triggerPullFeed := make(map[uint8]struct{}) // signal pull feed subscriptions to iterate
 
 	switch mode {
@@ -90,11 +95,12 @@ func (db *DB) set(mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
 
 	case chunk.ModeSetRemove:
 		for _, addr := range addrs {
-			c, err := db.setRemove(batch, addr)
+			c, s, err := db.setRemove(batch, addr)
 			if err != nil {
 				return err
 			}
 			gcSizeChange += c
+			storedSizeChange += s
 		}
 
 	case chunk.ModeSetPin:
@@ -106,10 +112,11 @@ func (db *DB) set(mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
 		}
 	case chunk.ModeSetUnpin:
 		for _, addr := range addrs {
-			err := db.setUnpin(batch, addr)
+			c, err := db.setUnpin(batch, addr)
 			if err != nil {
 				return err
 			}
+			gcSizeChange += c
 		}
 
 	default:
@@ -121,6 +128,11 @@ func (db *DB) set(mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
 		return err
 	}
 
+	err = db.incStoredSizeInBatch(batch, storedSizeChange)
+	if err != nil {
+		return err
+	}
+
 	err = db.shed.WriteBatch(batch)
 	if err != nil {
 		return err
@@ -190,8 +202,8 @@ func (db *DB) setAccess(batch *leveldb.Batch, binIDs map[uint8]uint64, addr chun
 // setSync adds the chunk to the garbage collection after syncing by updating indexes
 // - ModeSetSyncPull - the corresponding tag is incremented, pull index item tag value
 //	 is then set to 0 to prevent duplicate increments for the same chunk synced multiple times
-// - ModeSetSyncPush - the corresponding tag is incremented, then item is removed
-//   from push sync index
+// - ModeSetSyncPush - item is removed from push sync index, the corresponding tag
+//   is not incremented as the pusher counts synced chunks by their receipts
 // - update to gc index happens given item does not exist in pin index
 // Provided batch is updated.
 func (db *DB) setSync(batch *leveldb.Batch, addr chunk.Address, mode chunk.ModeSet) (gcSizeChange int64, err error) {
@@ -278,8 +290,6 @@ func (db *DB) setSync(batch *leveldb.Batch, addr chunk.Address, mode chunk.ModeS
 				if t.Anonymous {
 					return 0, errors.New("got an anonymous chunk in push sync index")
 				}
-
-				t.Inc(chunk.StateSynced)
 			}
 		}
 
@@ -318,8 +328,9 @@ func (db *DB) setSync(batch *leveldb.Batch, addr chunk.Address, mode chunk.ModeS
 
 // setRemove removes the chunk by updating indexes:
 //  - delete from retrieve, pull, gc
+// It returns changes of the gcSize and storedSize.
 // Provided batch is updated.
-func (db *DB) setRemove(batch *leveldb.Batch, addr chunk.Address) (gcSizeChange int64, err error) {
+func (db *DB) setRemove(batch *leveldb.Batch, addr chunk.Address) (gcSizeChange, storedSizeChange int64, err error) {
 	item := addressToItem(addr)
 
 	// need to get access timestamp here as it is not
@@ -331,17 +342,19 @@ func (db *DB) setRemove(batch *leveldb.Batch, addr chunk.Address) (gcSizeChange
 		item.AccessTimestamp = i.AccessTimestamp
 	case leveldb.ErrNotFound:
 	default:
-		return 0, err
+		return 0, 0, err
 	}
 	i, err = db.retrievalDataIndex.Get(item)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 	item.StoreTimestamp = i.StoreTimestamp
 	item.BinID = i.BinID
+	storedSizeChange = -int64(len(i.Data))
 
 	db.retrievalDataIndex.DeleteInBatch(batch, item)
 	db.retrievalAccessIndex.DeleteInBatch(batch, item)
+	db.accessStatsIndex.DeleteInBatch(batch, item)
 	db.pullIndex.DeleteInBatch(batch, item)
 	db.gcIndex.DeleteInBatch(batch, item)
 	// a check is needed for decrementing gcSize
@@ -351,7 +364,7 @@ func (db *DB) setRemove(batch *leveldb.Batch, addr chunk.Address) (gcSizeChange
 		gcSizeChange = -1
 	}
 
-	return gcSizeChange, nil
+	return gcSizeChange, storedSizeChange, nil
 }
 
 // setPin increments pin counter for the chunk by updating
@@ -385,14 +398,17 @@ func (db *DB) setPin(batch *leveldb.Batch, addr chunk.Address) (err error) {
 }
 
 // setUnpin decrements pin counter for the chunk by updating pin index.
+// When the pin counter reaches 0, the chunk is removed from gc exclude
+// index and added back to the gc index if it was already accessed or synced,
+// so that it can be garbage collected again.
 // Provided batch is updated.
-func (db *DB) setUnpin(batch *leveldb.Batch, addr chunk.Address) (err error) {
+func (db *DB) setUnpin(batch *leveldb.Batch, addr chunk.Address) (gcSizeChange int64, err error) {
 	item := addressToItem(addr)
 
 	// Get the existing pin counter of the chunk
 	pinnedChunk, err := db.pinIndex.Get(item)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Decrement the pin counter or
@@ -400,9 +416,48 @@ func (db *DB) setUnpin(batch *leveldb.Batch, addr chunk.Address) (err error) {
 	if pinnedChunk.PinCounter > 1 {
 		item.PinCounter = pinnedChunk.PinCounter - 1
 		db.pinIndex.PutInBatch(batch, item)
-	} else {
-		db.pinIndex.DeleteInBatch(batch, item)
+		return 0, nil
 	}
+	db.pinIndex.DeleteInBatch(batch, item)
+	db.gcExcludeIndex.DeleteInBatch(batch, item)
 
-	return nil
+	i, err := db.retrievalAccessIndex.Get(item)
+	switch err {
+	case nil:
+		item.AccessTimestamp = i.AccessTimestamp
+	case leveldb.ErrNotFound:
+		// the chunk is not accessed or synced yet,
+		// it will be added to gc index when it is
+		return 0, nil
+	default:
+		return 0, err
+	}
+	i, err = db.retrievalDataIndex.Get(item)
+	switch err {
+	case nil:
+		item.BinID = i.BinID
+	case leveldb.ErrNotFound:
+		// the chunk is not stored,
+		// no need to update gc index
+		return 0, nil
+	default:
+		return 0, err
+	}
+
+	// a check is needed for incrementing gcSize
+	// as the chunk may still be in gc index if
+	// the exclusion is not yet processed by gc
+	ok, err := db.gcIndex.Has(item)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		err = db.gcIndex.PutInBatch(batch, item)
+		if err != nil {
+			return 0, err
+		}
+		gcSizeChange++
+	}
+
+	return gcSizeChange, nil
 }
@@ -0,0 +1,74 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/shed"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Pin increments pin counters for chunks with provided addresses.
+// Pinned chunks are excluded from garbage collection until
+// their pin counters are decremented to zero with Unpin.
+func (db *DB) Pin(ctx context.Context, addrs ...chunk.Address) (err error) {
+	return db.Set(ctx, chunk.ModeSetPin, addrs...)
+}
+
+// Unpin decrements pin counters for chunks with provided addresses.
+// When a pin counter reaches zero, the chunk becomes subject to
+// garbage collection again.
+func (db *DB) Unpin(ctx context.Context, addrs ...chunk.Address) (err error) {
+	return db.Set(ctx, chunk.ModeSetUnpin, addrs...)
+}
+
+// PinCounter returns the pin counter for the chunk with the provided address.
+// If the chunk is not pinned, chunk.ErrChunkNotFound is returned.
+func (db *DB) PinCounter(addr chunk.Address) (pinCounter uint64, err error) {
+	item, err := db.pinIndex.Get(addressToItem(addr))
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return 0, chunk.ErrChunkNotFound
+		}
+		return 0, err
+	}
+	return item.PinCounter, nil
+}
+
+// PinnedIterateFunc is the callback function used by PinnedIterate.
+// Returning true for stop will end the iteration.
+type PinnedIterateFunc func(addr chunk.Address, pinCounter uint64) (stop bool, err error)
+
+// PinnedIterate calls the provided function for every pinned chunk
+// in the ascending order of their addresses. If the start address
+// is not nil, iteration begins from the chunk with that address, or
+// the first one after it.
+func (db *DB) PinnedIterate(fn PinnedIterateFunc, start chunk.Address) (err error) {
+	var options *shed.IterateOptions
+	if start != nil {
+		options = &shed.IterateOptions{
+			StartFrom: &shed.Item{
+				Address: start,
+			},
+		}
+	}
+	return db.pinIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		return fn(item.Address, item.PinCounter)
+	}, options)
+}
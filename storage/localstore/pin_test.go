@@ -0,0 +1,180 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// TestPinCounter validates that Pin and Unpin
+// change pin counters returned by PinCounter.
+func TestPinCounter(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, nil)
+	defer cleanupFunc()
+
+	ch := generateTestRandomChunk()
+
+	_, err := db.Put(context.Background(), chunk.ModePutUpload, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.PinCounter(ch.Address())
+	if err != chunk.ErrChunkNotFound {
+		t.Fatalf("got error %v, want %v", err, chunk.ErrChunkNotFound)
+	}
+
+	for want := uint64(1); want <= 3; want++ {
+		err = db.Pin(context.Background(), ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := db.PinCounter(ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got pin counter %v, want %v", got, want)
+		}
+	}
+
+	for want := uint64(2); want > 0; want-- {
+		err = db.Unpin(context.Background(), ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := db.PinCounter(ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got pin counter %v, want %v", got, want)
+		}
+	}
+
+	err = db.Unpin(context.Background(), ch.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.PinCounter(ch.Address())
+	if err != chunk.ErrChunkNotFound {
+		t.Fatalf("got error %v, want %v", err, chunk.ErrChunkNotFound)
+	}
+}
+
+// TestPinnedIterate validates that PinnedIterate iterates
+// over all pinned chunks in address order and that the
+// start address is respected.
+func TestPinnedIterate(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, nil)
+	defer cleanupFunc()
+
+	chunkCount := 10
+
+	addrs := make([]chunk.Address, 0, chunkCount)
+	for i := 0; i < chunkCount; i++ {
+		ch := generateTestRandomChunk()
+
+		_, err := db.Put(context.Background(), chunk.ModePutUpload, ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Pin(context.Background(), ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, ch.Address())
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i], addrs[j]) < 0
+	})
+
+	for _, start := range []int{0, 3, chunkCount - 1} {
+		var got []chunk.Address
+		err := db.PinnedIterate(func(addr chunk.Address, pinCounter uint64) (stop bool, err error) {
+			if pinCounter != 1 {
+				t.Errorf("got pin counter %v, want 1", pinCounter)
+			}
+			got = append(got, addr)
+			return false, nil
+		}, addrs[start])
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := addrs[start:]
+		if len(got) != len(want) {
+			t.Fatalf("got %v pinned chunks, want %v", len(got), len(want))
+		}
+		for i := range want {
+			if !bytes.Equal(got[i], want[i]) {
+				t.Errorf("got address %s at %v, want %s", got[i], i, want[i])
+			}
+		}
+	}
+}
+
+// TestUnpinGC validates that chunks are added back to
+// the gc index when they are unpinned.
+func TestUnpinGC(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity: 100,
+	})
+	defer cleanupFunc()
+
+	chunkCount := 10
+
+	addrs := make([]chunk.Address, 0, chunkCount)
+	for i := 0; i < chunkCount; i++ {
+		ch := generateTestRandomChunk()
+
+		_, err := db.Put(context.Background(), chunk.ModePutUpload, ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Pin(context.Background(), ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, ch.Address())
+	}
+
+	t.Run("gc index count", newItemsCountTest(db.gcIndex, 0))
+
+	t.Run("gc size", newIndexGCSizeTest(db))
+
+	err := db.Unpin(context.Background(), addrs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("pin index count", newItemsCountTest(db.pinIndex, 0))
+
+	t.Run("gc exclude index count", newItemsCountTest(db.gcExcludeIndex, 0))
+
+	t.Run("gc index count", newItemsCountTest(db.gcIndex, chunkCount))
+
+	t.Run("gc size", newIndexGCSizeTest(db))
+}
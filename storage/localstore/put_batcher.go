@@ -0,0 +1,160 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// putBatchRequest is a single Put call that is
+// coalesced with other calls into a shared batch.
+type putBatchRequest struct {
+	mode   chunk.ModePut
+	chs    []chunk.Chunk
+	result chan putBatchResult
+}
+
+// putBatchResult is sent to the caller of a
+// coalesced Put when the shared batch is written.
+type putBatchResult struct {
+	exist []bool
+	err   error
+}
+
+// putBatched sends chunks to the put batch worker and
+// waits for the shared batch to be written.
+func (db *DB) putBatched(ctx context.Context, mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err error) {
+	r := &putBatchRequest{
+		mode:   mode,
+		chs:    chs,
+		result: make(chan putBatchResult, 1),
+	}
+	select {
+	case db.putBatchC <- r:
+	case <-db.close:
+		return nil, ErrDBClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	// the worker always sends the result for
+	// a received request, even on close
+	res := <-r.result
+	return res.exist, res.err
+}
+
+// putBatchWorker receives Put calls and writes them in shared
+// batches. A batch is written when it holds putBatchMaxChunks
+// chunks, or when putBatchDelay passes after the first call
+// is received. If putBatchDelay is 0, only calls that are
+// already waiting are added to the batch.
+func (db *DB) putBatchWorker() {
+	defer close(db.putBatchWorkerDone)
+
+	for {
+		var reqs []*putBatchRequest
+		select {
+		case r := <-db.putBatchC:
+			reqs = append(reqs, r)
+		case <-db.close:
+			return
+		}
+		count := len(reqs[0].chs)
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if db.putBatchDelay > 0 {
+			timer = time.NewTimer(db.putBatchDelay)
+			timeout = timer.C
+		}
+	collect:
+		for count < db.putBatchMaxChunks {
+			if timeout == nil {
+				select {
+				case r := <-db.putBatchC:
+					reqs = append(reqs, r)
+					count += len(r.chs)
+				default:
+					break collect
+				}
+				continue
+			}
+			select {
+			case r := <-db.putBatchC:
+				reqs = append(reqs, r)
+				count += len(r.chs)
+			case <-timeout:
+				break collect
+			case <-db.close:
+				break collect
+			}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		db.writePutBatchRequests(reqs)
+	}
+}
+
+// writePutBatchRequests writes chunks from all requests in a single
+// batch and sends results to their callers. If any of the requests
+// fails, requests are written one by one, so that the error is
+// returned only to the caller that caused it.
+func (db *DB) writePutBatchRequests(reqs []*putBatchRequest) {
+	metrics.GetOrRegisterCounter("localstore/putBatch", nil).Inc(1)
+	metrics.GetOrRegisterCounter("localstore/putBatch/requests", nil).Inc(int64(len(reqs)))
+
+	exist, err := db.putMulti(reqs)
+	if err != nil {
+		metrics.GetOrRegisterCounter("localstore/putBatch/fallback", nil).Inc(1)
+		for _, r := range reqs {
+			e, err := db.put(r.mode, r.chs...)
+			r.result <- putBatchResult{exist: e, err: err}
+		}
+		return
+	}
+	for i, r := range reqs {
+		r.result <- putBatchResult{exist: exist[i]}
+	}
+}
+
+// putMulti writes chunks from all requests in a single batch.
+func (db *DB) putMulti(reqs []*putBatchRequest) (exist [][]bool, err error) {
+	// protect parallel updates
+	db.batchMu.Lock()
+	defer db.batchMu.Unlock()
+
+	b := newPutBatch()
+
+	exist = make([][]bool, len(reqs))
+	for i, r := range reqs {
+		exist[i], err = db.putInBatch(b, r.mode, r.chs...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = db.writePutBatch(b)
+	if err != nil {
+		return nil, err
+	}
+	return exist, nil
+}
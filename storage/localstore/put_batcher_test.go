@@ -0,0 +1,162 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// TestDB_putBatcher validates that concurrent Put calls coalesced
+// into shared batches store all chunks and update indexes
+// as they would be stored one by one.
+func TestDB_putBatcher(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		delay time.Duration
+	}{
+		{name: "no delay", delay: 0},
+		{name: "with delay", delay: 10 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, cleanupFunc := newTestDB(t, &Options{
+				PutBatchMaxChunks: 16,
+				PutBatchDelay:     tc.delay,
+			})
+			defer cleanupFunc()
+
+			chunks := generateTestRandomChunks(100)
+
+			// the same chunk is put multiple times
+			// concurrently to validate deduplication
+			duplicate := chunks[0]
+
+			var wg sync.WaitGroup
+			errC := make(chan error, 2*len(chunks))
+			var duplicateNewCount int
+			var mu sync.Mutex
+			put := func(ch chunk.Chunk, mode chunk.ModePut) {
+				defer wg.Done()
+				exist, err := db.Put(context.Background(), mode, ch)
+				if err == nil && !exist[0] && bytes.Equal(ch.Address(), duplicate.Address()) {
+					mu.Lock()
+					duplicateNewCount++
+					mu.Unlock()
+				}
+				errC <- err
+			}
+			for i, ch := range chunks {
+				mode := chunk.ModePutUpload
+				if i%2 == 0 {
+					mode = chunk.ModePutSync
+				}
+				wg.Add(2)
+				go put(ch, mode)
+				go put(duplicate, chunk.ModePutSync)
+			}
+			wg.Wait()
+			close(errC)
+			for err := range errC {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if duplicateNewCount != 1 {
+				t.Errorf("got duplicate chunk stored %v times, want 1", duplicateNewCount)
+			}
+
+			for _, ch := range chunks {
+				got, err := db.Get(context.Background(), chunk.ModeGetLookup, ch.Address())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got.Data(), ch.Data()) {
+					t.Errorf("chunk %s: got data %x, want %x", ch.Address(), got.Data(), ch.Data())
+				}
+			}
+
+			t.Run("retrieve indexes", newItemsCountTest(db.retrievalDataIndex, len(chunks)))
+			t.Run("pull index count", newItemsCountTest(db.pullIndex, len(chunks)))
+			t.Run("push index count", newItemsCountTest(db.pushIndex, len(chunks)/2))
+			t.Run("gc size", newIndexGCSizeTest(db))
+		})
+	}
+}
+
+// TestDB_putBatcher_invalidMode validates that an error in one of
+// the coalesced calls is not returned to other calls.
+func TestDB_putBatcher_invalidMode(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		PutBatchMaxChunks: 16,
+	})
+	defer cleanupFunc()
+
+	ch := generateTestRandomChunk()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if _, err := db.putBatched(context.Background(), chunk.ModePut(100), generateTestRandomChunk()); err != ErrInvalidMode {
+			t.Errorf("got error %v, want %v", err, ErrInvalidMode)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+			t.Error(err)
+		}
+	}()
+	wg.Wait()
+
+	if _, err := db.Get(context.Background(), chunk.ModeGetLookup, ch.Address()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDB_putBatcher_closed validates that Put calls
+// return ErrDBClosed after the database is closed.
+func TestDB_putBatcher_closed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := New(dir, make([]byte, 32), &Options{
+		PutBatchMaxChunks: 16,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Put(context.Background(), chunk.ModePutUpload, generateTestRandomChunk())
+	if err != ErrDBClosed {
+		t.Errorf("got error %v, want %v", err, ErrDBClosed)
+	}
+}
@@ -17,6 +17,7 @@
 package localstore
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"sync"
@@ -38,6 +39,48 @@ import (
 // Make sure that you check the second returned parameter from the channel to stop iteration when its value
 // is false.
 func (db *DB) SubscribePull(ctx context.Context, bin uint8, since, until uint64) (c <-chan chunk.Descriptor, stop func()) {
+	return db.SubscribePullWithOptions(ctx, bin, since, until, nil)
+}
+
+// SubscribePullOptions holds optional parameters for SubscribePullWithOptions
+// that limit which chunks are sent to the subscription channel. Only chunks
+// that satisfy all set parameters are sent.
+type SubscribePullOptions struct {
+	// Tag limits the subscription to chunks uploaded with the tag of this
+	// uid, if it is not 0. Tags of anonymous uploads are removed from the
+	// pull syncing index after the chunks are synced, so those chunks are
+	// not sent after they are synced.
+	Tag uint32
+	// AddressPrefix limits the subscription to chunks with addresses
+	// that start with the prefix, if it is not empty.
+	AddressPrefix []byte
+	// Filter is a custom function that returns true for chunks that
+	// should be sent, if it is not nil.
+	Filter func(addr chunk.Address, tag uint32) bool
+}
+
+// match returns true if the pull index item satisfies all options.
+func (o *SubscribePullOptions) match(item shed.Item) bool {
+	if o == nil {
+		return true
+	}
+	if o.Tag != 0 && item.Tag != o.Tag {
+		return false
+	}
+	if len(o.AddressPrefix) > 0 && !bytes.HasPrefix(item.Address, o.AddressPrefix) {
+		return false
+	}
+	if o.Filter != nil && !o.Filter(item.Address, item.Tag) {
+		return false
+	}
+	return true
+}
+
+// SubscribePullWithOptions is the same as SubscribePull, but it sends
+// to the returned channel only chunks that satisfy provided options.
+// Bin IDs of chunks that are not sent are still accounted in since and
+// until interval.
+func (db *DB) SubscribePullWithOptions(ctx context.Context, bin uint8, since, until uint64, o *SubscribePullOptions) (c <-chan chunk.Descriptor, stop func()) {
 	metricName := "localstore/SubscribePull"
 	metrics.GetOrRegisterCounter(metricName, nil).Inc(1)
 
@@ -95,6 +138,16 @@ func (db *DB) SubscribePull(ctx context.Context, bin uint8, since, until uint64)
 					if until > 0 && item.BinID > until {
 						return true, errStopSubscription
 					}
+					if !o.match(item) {
+						if until > 0 && item.BinID == until {
+							return true, errStopSubscription
+						}
+						count++
+						// skip the chunk and set it
+						// as the next iteration start item
+						sinceItem = &item
+						return false, nil
+					}
 					select {
 					case chunkDescriptors <- chunk.Descriptor{
 						Address: item.Address,
@@ -525,6 +525,88 @@ func TestDB_SubscribePull_rangeOnRemovedChunks(t *testing.T) {
 	}
 }
 
+// TestDB_SubscribePullWithOptions validates that pull subscriptions
+// with options send only chunks that satisfy them, and that the
+// until bin id is respected when the last chunk is filtered out.
+func TestDB_SubscribePullWithOptions(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, nil)
+	defer cleanupFunc()
+
+	chunkCount := 100
+	tag := uint32(42)
+	prefix := []byte{0x80}
+
+	tagged := make(map[string]struct{})
+	prefixed := make(map[string]struct{})
+	last := make(map[uint8]uint64)
+	for i := 0; i < chunkCount; i++ {
+		ch := generateTestRandomChunk()
+		if i%3 == 0 {
+			ch = ch.WithTagID(tag)
+			tagged[string(ch.Address())] = struct{}{}
+		}
+		if bytes.HasPrefix(ch.Address(), prefix) {
+			prefixed[string(ch.Address())] = struct{}{}
+		}
+
+		_, err := db.Put(context.Background(), chunk.ModePutUpload, ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		last[db.po(ch.Address())]++
+	}
+
+	for _, tc := range []struct {
+		name    string
+		options *SubscribePullOptions
+		want    map[string]struct{}
+	}{
+		{
+			name:    "tag",
+			options: &SubscribePullOptions{Tag: tag},
+			want:    tagged,
+		},
+		{
+			name:    "address prefix",
+			options: &SubscribePullOptions{AddressPrefix: prefix},
+			want:    prefixed,
+		},
+		{
+			name: "filter",
+			options: &SubscribePullOptions{Filter: func(addr chunk.Address, tagID uint32) bool {
+				_, ok := tagged[string(addr)]
+				return ok && tagID == tag
+			}},
+			want: tagged,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			got := make(map[string]struct{})
+			for bin, until := range last {
+				ch, stop := db.SubscribePullWithOptions(ctx, bin, 0, until, tc.options)
+				for d := range ch {
+					got[string(d.Address)] = struct{}{}
+				}
+				stop()
+				if err := ctx.Err(); err != nil {
+					t.Fatalf("bin %v: %v", bin, err)
+				}
+			}
+			if len(got) != len(tc.want) {
+				t.Errorf("got %v chunks, want %v", len(got), len(tc.want))
+			}
+			for a := range got {
+				if _, ok := tc.want[a]; !ok {
+					t.Errorf("got unexpected chunk %x", a)
+				}
+			}
+		})
+	}
+}
+
 // uploadRandomChunksBin uploads random chunks to database and adds them to
 // the map of addresses ber bin.
 func uploadRandomChunksBin(t *testing.T, db *DB, addrs map[uint8][]chunk.Address, addrsMu *sync.Mutex, wantedChunksCount *int, count int) {
@@ -0,0 +1,195 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package mock
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Fault defines how FaultStore misbehaves for a chunk.
+type Fault struct {
+	// Delay is the duration that Get, Put, HasKey
+	// and Delete methods wait before they are executed.
+	Delay time.Duration
+	// Drop makes Get return ErrNotFound, HasKey return
+	// false, and Put and Delete return without changing
+	// chunk data.
+	Drop bool
+	// Corrupt makes Get return chunk data
+	// with the first byte changed.
+	Corrupt bool
+}
+
+// FaultStore is a GlobalStorer that passes all calls to another
+// GlobalStorer, except for chunks with configured faults, for which
+// it can delay, drop or corrupt data. It is used in tests to simulate
+// slow or malicious storage. Faults can be configured for a chunk on
+// all nodes or only on a specific node, in which case they take
+// precedence.
+type FaultStore struct {
+	GlobalStorer
+	faults     map[string]Fault
+	nodeFaults map[common.Address]map[string]Fault
+	mu         sync.RWMutex
+}
+
+// NewFaultStore creates a new FaultStore that
+// passes calls to the provided GlobalStorer.
+func NewFaultStore(store GlobalStorer) *FaultStore {
+	return &FaultStore{
+		GlobalStorer: store,
+		faults:       make(map[string]Fault),
+		nodeFaults:   make(map[common.Address]map[string]Fault),
+	}
+}
+
+// SetFault sets the fault for a chunk with
+// the provided key on all nodes.
+func (s *FaultStore) SetFault(key []byte, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults[string(key)] = f
+}
+
+// SetNodeFault sets the fault for a chunk with the
+// provided key only on a node with the provided address.
+func (s *FaultStore) SetNodeFault(addr common.Address, key []byte, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.nodeFaults[addr]; !ok {
+		s.nodeFaults[addr] = make(map[string]Fault)
+	}
+	s.nodeFaults[addr][string(key)] = f
+}
+
+// RemoveFault removes all faults for a chunk with the provided key.
+func (s *FaultStore) RemoveFault(key []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.faults, string(key))
+	for _, faults := range s.nodeFaults {
+		delete(faults, string(key))
+	}
+}
+
+// ClearFaults removes all configured faults.
+func (s *FaultStore) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = make(map[string]Fault)
+	s.nodeFaults = make(map[common.Address]map[string]Fault)
+}
+
+// fault returns the fault for a chunk
+// with the key on the node with the address.
+func (s *FaultStore) fault(addr common.Address, key []byte) (f Fault, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if f, ok := s.nodeFaults[addr][string(key)]; ok {
+		return f, true
+	}
+	f, ok = s.faults[string(key)]
+	return f, ok
+}
+
+// Get returns chunk data from the underlying store,
+// applying the fault configured for the chunk.
+func (s *FaultStore) Get(addr common.Address, key []byte) (data []byte, err error) {
+	f, ok := s.fault(addr, key)
+	if !ok {
+		return s.GlobalStorer.Get(addr, key)
+	}
+	if f.Delay > 0 {
+		time.Sleep(f.Delay)
+	}
+	if f.Drop {
+		return nil, ErrNotFound
+	}
+	data, err = s.GlobalStorer.Get(addr, key)
+	if err != nil {
+		return nil, err
+	}
+	if f.Corrupt && len(data) > 0 {
+		corrupted := make([]byte, len(data))
+		copy(corrupted, data)
+		corrupted[0] ^= 0xff
+		data = corrupted
+	}
+	return data, nil
+}
+
+// Put stores chunk data in the underlying store,
+// applying the fault configured for the chunk.
+func (s *FaultStore) Put(addr common.Address, key []byte, data []byte) error {
+	f, ok := s.fault(addr, key)
+	if !ok {
+		return s.GlobalStorer.Put(addr, key, data)
+	}
+	if f.Delay > 0 {
+		time.Sleep(f.Delay)
+	}
+	if f.Drop {
+		return nil
+	}
+	return s.GlobalStorer.Put(addr, key, data)
+}
+
+// HasKey checks if chunk data is in the underlying store,
+// applying the fault configured for the chunk.
+func (s *FaultStore) HasKey(addr common.Address, key []byte) bool {
+	f, ok := s.fault(addr, key)
+	if !ok {
+		return s.GlobalStorer.HasKey(addr, key)
+	}
+	if f.Delay > 0 {
+		time.Sleep(f.Delay)
+	}
+	if f.Drop {
+		return false
+	}
+	return s.GlobalStorer.HasKey(addr, key)
+}
+
+// Delete removes chunk data from the underlying store,
+// applying the fault configured for the chunk.
+func (s *FaultStore) Delete(addr common.Address, key []byte) error {
+	f, ok := s.fault(addr, key)
+	if !ok {
+		return s.GlobalStorer.Delete(addr, key)
+	}
+	if f.Delay > 0 {
+		time.Sleep(f.Delay)
+	}
+	if f.Drop {
+		return nil
+	}
+	return s.GlobalStorer.Delete(addr, key)
+}
+
+// NewNodeStore returns a new instance of NodeStore that
+// retrieves and stores chunk data through the FaultStore.
+func (s *FaultStore) NewNodeStore(addr common.Address) *NodeStore {
+	return NewNodeStore(addr, s)
+}
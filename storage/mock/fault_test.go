@@ -0,0 +1,158 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package mock_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethersphere/swarm/storage/mock"
+	"github.com/ethersphere/swarm/storage/mock/mem"
+	"github.com/ethersphere/swarm/storage/mock/test"
+)
+
+// TestFaultStore runs a test for a FaultStore
+// without any faults configured.
+func TestFaultStore(t *testing.T) {
+	test.MockStore(t, mock.NewFaultStore(mem.NewGlobalStore()), 100)
+}
+
+// TestFaultStore_faults validates that FaultStore
+// delays, drops and corrupts chunks with faults.
+func TestFaultStore_faults(t *testing.T) {
+	store := mock.NewFaultStore(mem.NewGlobalStore())
+
+	addr1 := common.HexToAddress("0x1")
+	addr2 := common.HexToAddress("0x2")
+	node1 := store.NewNodeStore(addr1)
+	node2 := store.NewNodeStore(addr2)
+
+	key := []byte("key")
+	data := []byte("data")
+
+	for _, n := range []*mock.NodeStore{node1, node2} {
+		if err := n.Put(key, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(t *testing.T, n *mock.NodeStore, wantData []byte, wantErr error) {
+		t.Helper()
+		got, err := n.Get(key)
+		if err != wantErr {
+			t.Fatalf("got error %v, want %v", err, wantErr)
+		}
+		if !bytes.Equal(got, wantData) {
+			t.Errorf("got data %q, want %q", got, wantData)
+		}
+	}
+
+	t.Run("drop", func(t *testing.T) {
+		store.SetFault(key, mock.Fault{Drop: true})
+		defer store.ClearFaults()
+
+		get(t, node1, nil, mock.ErrNotFound)
+		get(t, node2, nil, mock.ErrNotFound)
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		store.SetFault(key, mock.Fault{Corrupt: true})
+		defer store.ClearFaults()
+
+		get(t, node1, []byte("\x9bata"), nil)
+	})
+
+	t.Run("delay", func(t *testing.T) {
+		delay := 50 * time.Millisecond
+		store.SetFault(key, mock.Fault{Delay: delay})
+		defer store.ClearFaults()
+
+		start := time.Now()
+		get(t, node1, data, nil)
+		if d := time.Since(start); d < delay {
+			t.Errorf("got get duration %v, want at least %v", d, delay)
+		}
+	})
+
+	t.Run("node fault", func(t *testing.T) {
+		store.SetFault(key, mock.Fault{Corrupt: true})
+		store.SetNodeFault(addr1, key, mock.Fault{Drop: true})
+		defer store.ClearFaults()
+
+		get(t, node1, nil, mock.ErrNotFound)
+		get(t, node2, []byte("\x9bata"), nil)
+	})
+
+	t.Run("remove fault", func(t *testing.T) {
+		store.SetFault(key, mock.Fault{Drop: true})
+		store.SetNodeFault(addr1, key, mock.Fault{Drop: true})
+		store.RemoveFault(key)
+
+		get(t, node1, data, nil)
+		get(t, node2, data, nil)
+	})
+
+	t.Run("drop put", func(t *testing.T) {
+		newKey := []byte("new key")
+		store.SetFault(newKey, mock.Fault{Drop: true})
+		defer store.ClearFaults()
+
+		if err := node1.Put(newKey, data); err != nil {
+			t.Fatal(err)
+		}
+		store.ClearFaults()
+		if store.HasKey(addr1, newKey) {
+			t.Error("dropped chunk is stored")
+		}
+	})
+
+	t.Run("drop has key", func(t *testing.T) {
+		store.SetFault(key, mock.Fault{Drop: true})
+		defer store.ClearFaults()
+
+		if store.HasKey(addr1, key) {
+			t.Error("dropped chunk is found")
+		}
+	})
+
+	t.Run("drop delete", func(t *testing.T) {
+		store.SetNodeFault(addr1, key, mock.Fault{Drop: true})
+		if err := node1.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+		store.ClearFaults()
+
+		get(t, node1, data, nil)
+	})
+
+	t.Run("delay delete", func(t *testing.T) {
+		delay := 50 * time.Millisecond
+		store.SetFault(key, mock.Fault{Delay: delay})
+		defer store.ClearFaults()
+
+		start := time.Now()
+		if err := node2.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+		if d := time.Since(start); d < delay {
+			t.Errorf("got delete duration %v, want at least %v", d, delay)
+		}
+		get(t, node2, nil, mock.ErrNotFound)
+	})
+}
@@ -24,6 +24,9 @@
 //  - mem - in memory map backend
 //  - rpc - RPC client that can connect to other backends
 //
+// FaultStore can wrap any of them to delay, drop or corrupt
+// data of specific chunks in tests.
+//
 // Mock storages can implement Importer and Exporter interfaces
 // for importing and exporting all chunk data that they contain.
 // The exported file is a tar archive with all files named by
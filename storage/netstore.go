@@ -32,7 +32,6 @@ import (
 	lru "github.com/hashicorp/golang-lru"
 	olog "github.com/opentracing/opentracing-go/log"
 	"github.com/syndtr/goleveldb/leveldb"
-	"golang.org/x/sync/singleflight"
 
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/network"
@@ -41,6 +40,8 @@ import (
 const (
 	// capacity for the fetchers LRU cache
 	fetchersCapacity = 500000
+	// capacity for the LRU cache of chunks not found on the network
+	missingCapacity = 10000
 )
 
 var (
@@ -88,6 +89,10 @@ func (fi *Fetcher) SafeClose(ch chunk.Chunk) {
 
 type RemoteGetFunc func(ctx context.Context, req *Request, localID enode.ID) (*enode.ID, func(), error)
 
+// FallbackFunc fetches a chunk that could not be retrieved from the network
+// from another source and stores it in the NetStore
+type FallbackFunc func(ctx context.Context, ref Address) (Chunk, error)
+
 // NetStore is an extension of LocalStore
 // it implements the ChunkStore interface
 // on request it initiates remote cloud retrieval
@@ -95,18 +100,22 @@ type NetStore struct {
 	chunk.Store
 	LocalID      enode.ID // our local enode - used when issuing RetrieveRequests
 	fetchers     *lru.Cache
+	missing      *lru.Cache // addresses of chunks not found on the network with the time they were last requested
 	putMu        sync.Mutex
-	requestGroup singleflight.Group
+	requestGroup fetchGroup // coalesces concurrent fetches of the same chunk
 	RemoteGet    RemoteGetFunc
+	Fallback     FallbackFunc // optional, used if the chunk is not retrieved from the network within the FallbackTimeout
 	logger       log.Logger
 }
 
 // NewNetStore creates a new NetStore using the provided chunk.Store and localID of the node.
 func NewNetStore(store chunk.Store, baseAddr *network.BzzAddr) *NetStore {
 	fetchers, _ := lru.New(fetchersCapacity)
+	missing, _ := lru.New(missingCapacity)
 
 	return &NetStore{
 		fetchers: fetchers,
+		missing:  missing,
 		Store:    store,
 		LocalID:  baseAddr.ID(),
 		logger:   log.NewBaseAddressLogger(baseAddr.ShortString()),
@@ -121,6 +130,7 @@ func (n *NetStore) Put(ctx context.Context, mode chunk.ModePut, chs ...Chunk) ([
 	n.putMu.Lock()
 	for i, ch := range chs {
 		n.logger.Trace("netstore.put", "index", i, "ref", ch.Address().String(), "mode", mode)
+		n.missing.Remove(ch.Address().String())
 		fi, ok := n.fetchers.Get(ch.Address().String())
 		if ok {
 			// we need SafeClose, because it is possible for a chunk to both be
@@ -160,6 +170,36 @@ func (n *NetStore) Put(ctx context.Context, mode chunk.ModePut, chs ...Chunk) ([
 	return exist, nil
 }
 
+// Deliver delivers chunks to all requestor peers using the fetchers stored
+// in the fetchers cache, without storing them in the localstore. Chunks are
+// validated if the underlying store is a chunk.Validator.
+func (n *NetStore) Deliver(ctx context.Context, chs ...Chunk) error {
+	if v, ok := n.Store.(chunk.Validator); ok {
+		for _, ch := range chs {
+			if !v.Validate(ch) {
+				return chunk.ErrChunkInvalid
+			}
+		}
+	}
+
+	n.putMu.Lock()
+	defer n.putMu.Unlock()
+
+	for _, ch := range chs {
+		n.logger.Trace("netstore.deliver", "ref", ch.Address().String())
+		n.missing.Remove(ch.Address().String())
+		fi, ok := n.fetchers.Get(ch.Address().String())
+		if ok {
+			fii := fi.(*Fetcher)
+			fii.SafeClose(ch)
+
+			metrics.GetOrRegisterResettingTimer(fmt.Sprintf("netstore/fetcher/lifetime/%s", fii.CreatedBy), nil).UpdateSince(fii.CreatedAt)
+			n.fetchers.Remove(ch.Address().String())
+		}
+	}
+	return nil
+}
+
 // Close chunk store
 func (n *NetStore) Close() error {
 	return n.Store.Close()
@@ -182,16 +222,28 @@ func (n *NetStore) Get(ctx context.Context, mode chunk.ModeGet, req *Request) (c
 
 		n.logger.Trace("netstore.chunk-not-in-localstore", "ref", ref.String())
 
-		v, err, _ := n.requestGroup.Do(ref.String(), func() (interface{}, error) {
+		// do not search the network again for a chunk that was recently not found,
+		// unless it can be fetched from the Fallback
+		if n.Fallback == nil && n.isMissing(ref) {
+			metrics.GetOrRegisterCounter("netstore/get/missing", nil).Inc(1)
+			n.logger.Trace("netstore.chunk-missing", "ref", ref.String())
+			return nil, ErrChunkNotFound
+		}
+
+		// concurrent requests for the same chunk share a single fetch
+		// which is cancelled only when all of them are cancelled
+		ch, err = n.requestGroup.do(ctx, ref.String(), func(ctx context.Context) (Chunk, error) {
 			// currently we issue a retrieve request if a fetcher
 			// has already been created by a syncer for that particular chunk.
 			// so it is possible to
 			// have 2 in-flight requests for the same chunk - one by a
 			// syncer (offered/wanted/deliver flow) and one from
 			// here - retrieve request
+			var ch Chunk
 			fi, _, ok := n.GetOrCreateFetcher(ctx, ref, "request")
 			if ok {
-				ch, err = n.RemoteFetch(ctx, req, fi)
+				var err error
+				ch, err = n.fetch(ctx, req, fi)
 				if err != nil {
 					return nil, err
 				}
@@ -204,15 +256,17 @@ func (n *NetStore) Get(ctx context.Context, mode chunk.ModeGet, req *Request) (c
 
 			return ch, nil
 		})
-
 		if err != nil {
+			if isContextError(err) && ctx.Err() != nil {
+				metrics.GetOrRegisterCounter("netstore/get/cancelled", nil).Inc(1)
+			}
 			n.logger.Trace(err.Error(), "ref", ref)
 			return nil, err
 		}
 
-		n.logger.Trace("netstore.singleflight returned", "ref", ref.String(), "err", err)
+		n.logger.Trace("netstore.fetch returned", "ref", ref.String())
 
-		return v.(Chunk), nil
+		return ch, nil
 	}
 	n.logger.Trace("netstore.get returned", "ref", ref.String())
 
@@ -224,6 +278,16 @@ func (n *NetStore) Get(ctx context.Context, mode chunk.ModeGet, req *Request) (c
 	return ch, nil
 }
 
+// GetSOC retrieves the single owner chunk with the given id and owner
+// and returns it parsed, with the owner signature verified.
+func (n *NetStore) GetSOC(ctx context.Context, mode chunk.ModeGet, id, owner []byte) (*chunk.SOC, error) {
+	ch, err := n.Get(ctx, mode, NewRequest(chunk.SOCAddress(id, owner)))
+	if err != nil {
+		return nil, err
+	}
+	return NewSOCValidator(MakeHashFunc(DefaultHash)).Parse(ch)
+}
+
 // RemoteFetch is handling the retry mechanism when making a chunk request to our peers.
 // For a given chunk Request, we call RemoteGet, which selects the next eligible peer and
 // issues a RetrieveRequest and we wait for a delivery. If a delivery doesn't arrive within the SearchTimeout
@@ -234,6 +298,7 @@ func (n *NetStore) RemoteFetch(ctx context.Context, req *Request, fi *Fetcher) (
 	metrics.GetOrRegisterCounter("remote/fetch", nil).Inc(1)
 
 	ref := req.Addr
+	requested := false
 
 	for {
 		metrics.GetOrRegisterCounter("remote/fetch/inner", nil).Inc(1)
@@ -252,9 +317,15 @@ func (n *NetStore) RemoteFetch(ctx context.Context, req *Request, fi *Fetcher) (
 			n.logger.Trace(err.Error(), "ref", ref)
 			osp.LogFields(olog.String("err", err.Error()))
 			osp.Finish()
+			n.removeFetcher(ref, fi)
+			if requested {
+				// all suitable peers were asked and none delivered
+				n.addMissing(ref)
+			}
 			return nil, ErrNoSuitablePeer
 		}
 		defer cleanup()
+		requested = true
 
 		// add peer to the set of peers to skip from now
 		n.logger.Trace("remote.fetch, adding peer to skip", "ref", ref, "peer", currentPeer.String())
@@ -279,17 +350,117 @@ func (n *NetStore) RemoteFetch(ctx context.Context, req *Request, fi *Fetcher) (
 
 			osp.LogFields(olog.Bool("fail", true))
 			osp.Finish()
+			n.removeFetcher(ref, fi)
 			return nil, ctx.Err()
 		}
 	}
 }
 
+// fetch retrieves the chunk from the network and, if the Fallback is set and
+// the chunk is not retrieved within the FallbackTimeout, from the Fallback.
+// Chunks recently not found on the network are fetched only from the Fallback.
+func (n *NetStore) fetch(ctx context.Context, req *Request, fi *Fetcher) (Chunk, error) {
+	if n.Fallback == nil {
+		return n.RemoteFetch(ctx, req, fi)
+	}
+
+	var ch Chunk
+	var err error
+	if n.isMissing(req.Addr) {
+		metrics.GetOrRegisterCounter("netstore/get/missing", nil).Inc(1)
+		n.removeFetcher(req.Addr, fi)
+		err = ErrChunkNotFound
+	} else {
+		rctx, cancel := context.WithTimeout(ctx, timeouts.FallbackTimeout)
+		defer cancel()
+		ch, err = n.RemoteFetch(rctx, req, fi)
+		if err == nil || ctx.Err() != nil {
+			return ch, err
+		}
+	}
+
+	n.logger.Trace("netstore.fallback", "ref", req.Addr, "err", err)
+	metrics.GetOrRegisterCounter("netstore/fallback", nil).Inc(1)
+	ch, ferr := n.Fallback(ctx, req.Addr)
+	if ferr != nil {
+		metrics.GetOrRegisterCounter("netstore/fallback/fail", nil).Inc(1)
+		return nil, fmt.Errorf("network: %v, fallback: %w", err, ferr)
+	}
+	return ch, nil
+}
+
+// removeFetcher removes the fetcher of a chunk that is no longer requested,
+// if it is not delivered and not used by the syncer, so that a subsequent
+// request starts with a clean state
+func (n *NetStore) removeFetcher(ref Address, fi *Fetcher) {
+	n.putMu.Lock()
+	defer n.putMu.Unlock()
+
+	select {
+	case <-fi.Delivered:
+		return
+	default:
+	}
+	if fi.CreatedBy != "request" || fi.RequestedBySyncer {
+		return
+	}
+	if v, ok := n.fetchers.Peek(ref.String()); ok && v == fi {
+		n.fetchers.Remove(ref.String())
+	}
+}
+
+// addMissing records that the chunk was not found on the network
+func (n *NetStore) addMissing(ref Address) {
+	n.missing.Add(ref.String(), time.Now())
+}
+
+// isMissing returns true if the chunk was not found on the network within
+// the MissingChunkTTL
+func (n *NetStore) isMissing(ref Address) bool {
+	v, ok := n.missing.Get(ref.String())
+	if !ok {
+		return false
+	}
+	if time.Since(v.(time.Time)) > timeouts.MissingChunkTTL {
+		n.missing.Remove(ref.String())
+		return false
+	}
+	return true
+}
+
+// isContextError returns true if the error is caused by a cancelled or
+// expired context
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
 // Has is the storage layer entry point to query the underlying
 // database to return if it has a chunk or not.
 func (n *NetStore) Has(ctx context.Context, ref Address) (bool, error) {
 	return n.Store.Has(ctx, ref)
 }
 
+// HasMulti is the storage layer entry point to query the underlying
+// database to return if it has chunks with provided addresses, in a
+// single index pass. It does not fetch chunks from the network.
+// It is used for the batches of addresses offered by syncing peers,
+// retrieve requests and push synced chunks are not batched as each of
+// their messages carries a single chunk.
+func (n *NetStore) HasMulti(ctx context.Context, refs ...Address) ([]bool, error) {
+	metrics.GetOrRegisterCounter("netstore/hasmulti", nil).Inc(1)
+
+	return n.Store.HasMulti(ctx, refs...)
+}
+
+// GetMulti returns chunks with provided addresses from the underlying
+// database. It does not fetch chunks from the network and returns an
+// error if any of the chunks is not found locally.
+func (n *NetStore) GetMulti(ctx context.Context, mode chunk.ModeGet, refs ...Address) ([]Chunk, error) {
+	metrics.GetOrRegisterCounter("netstore/getmulti", nil).Inc(1)
+
+	return n.Store.GetMulti(ctx, mode, refs...)
+}
+
 // GetOrCreateFetcher returns the Fetcher for a given chunk, if this chunk is not in the LocalStore.
 // If the chunk is in the LocalStore, it returns nil for the Fetcher and ok == false
 func (n *NetStore) GetOrCreateFetcher(ctx context.Context, ref Address, interestedParty string) (f *Fetcher, loaded bool, ok bool) {
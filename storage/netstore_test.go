@@ -0,0 +1,301 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/timeouts"
+)
+
+// newTestNetStoreWithRemoteGet returns a NetStore which signals on the returned
+// channel every time a chunk is requested from the network
+func newTestNetStoreWithRemoteGet() (*NetStore, chan struct{}) {
+	n := NewNetStore(NewMapChunkStore(), network.RandomBzzAddr())
+	remoteGetC := make(chan struct{}, 10)
+	n.RemoteGet = func(ctx context.Context, req *Request, localID enode.ID) (*enode.ID, func(), error) {
+		remoteGetC <- struct{}{}
+		return &enode.ID{}, func() {}, nil
+	}
+	return n, remoteGetC
+}
+
+// TestNetStoreGetCancel tests that a cancelled request returns the context
+// error and does not leave the fetcher for the chunk behind.
+func TestNetStoreGetCancel(t *testing.T) {
+	n, remoteGetC := newTestNetStoreWithRemoteGet()
+
+	ch := GenerateRandomChunk(chunk.DefaultSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errC := make(chan error)
+	go func() {
+		_, err := n.Get(ctx, chunk.ModeGetRequest, NewRequest(ch.Address()))
+		errC <- err
+	}()
+
+	select {
+	case <-remoteGetC:
+	case <-time.After(time.Second):
+		t.Fatal("chunk not requested from the network")
+	}
+	cancel()
+
+	select {
+	case err := <-errC:
+		if err != context.Canceled {
+			t.Fatalf("got error %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("get not cancelled")
+	}
+
+	// the fetcher is removed when the network fetch returns
+	for i := 0; n.fetchers.Len() != 0; i++ {
+		if i == 100 {
+			t.Fatalf("got %v fetchers, want 0", n.fetchers.Len())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestNetStoreGetCancelShared tests that a request for a chunk does not fail
+// if another request for the same chunk, which started the network fetch, is
+// cancelled, and that the shared network fetch continues.
+func TestNetStoreGetCancelShared(t *testing.T) {
+	n, remoteGetC := newTestNetStoreWithRemoteGet()
+
+	ch := GenerateRandomChunk(chunk.DefaultSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errC := make(chan error)
+	go func() {
+		_, err := n.Get(ctx, chunk.ModeGetRequest, NewRequest(ch.Address()))
+		errC <- err
+	}()
+
+	select {
+	case <-remoteGetC:
+	case <-time.After(time.Second):
+		t.Fatal("chunk not requested from the network")
+	}
+
+	type result struct {
+		ch  Chunk
+		err error
+	}
+	resultC := make(chan result)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		got, err := n.Get(ctx, chunk.ModeGetRequest, NewRequest(ch.Address()))
+		resultC <- result{ch: got, err: err}
+	}()
+
+	// give the second request time to join the in-flight fetch
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-errC; err != context.Canceled {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+
+	// the second request keeps waiting for the same network fetch
+	select {
+	case <-remoteGetC:
+		t.Fatal("chunk requested from the network again")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if _, err := n.Put(context.Background(), chunk.ModePutRequest, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-resultC:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if !bytes.Equal(r.ch.Address(), ch.Address()) {
+			t.Fatalf("got chunk %s, want %s", r.ch.Address(), ch.Address())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("chunk not delivered")
+	}
+}
+
+// TestNetStoreGetCoalesced tests that concurrent requests for the same chunk
+// result in a single network fetch and all of them get the delivered chunk.
+func TestNetStoreGetCoalesced(t *testing.T) {
+	n, remoteGetC := newTestNetStoreWithRemoteGet()
+
+	ch := GenerateRandomChunk(chunk.DefaultSize)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const count = 10
+	errC := make(chan error, count)
+	for i := 0; i < count; i++ {
+		go func() {
+			got, err := n.Get(ctx, chunk.ModeGetRequest, NewRequest(ch.Address()))
+			if err == nil && !bytes.Equal(got.Address(), ch.Address()) {
+				t.Errorf("got chunk %s, want %s", got.Address(), ch.Address())
+			}
+			errC <- err
+		}()
+	}
+
+	select {
+	case <-remoteGetC:
+	case <-time.After(time.Second):
+		t.Fatal("chunk not requested from the network")
+	}
+
+	// give all requests time to join the in-flight fetch
+	time.Sleep(50 * time.Millisecond)
+
+	if _, err := n.Put(context.Background(), chunk.ModePutRequest, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < count; i++ {
+		select {
+		case err := <-errC:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("chunk not delivered")
+		}
+	}
+
+	select {
+	case <-remoteGetC:
+		t.Fatal("chunk requested from the network more than once")
+	default:
+	}
+}
+
+// TestNetStoreGetMissing tests that a chunk that was not found on the network
+// is not requested again until the MissingChunkTTL passes or it is stored.
+func TestNetStoreGetMissing(t *testing.T) {
+	defer func(search, ttl time.Duration) {
+		timeouts.SearchTimeout = search
+		timeouts.MissingChunkTTL = ttl
+	}(timeouts.SearchTimeout, timeouts.MissingChunkTTL)
+	timeouts.SearchTimeout = 10 * time.Millisecond
+	timeouts.MissingChunkTTL = 200 * time.Millisecond
+
+	n := NewNetStore(NewMapChunkStore(), network.RandomBzzAddr())
+	requests := 0
+	n.RemoteGet = func(ctx context.Context, req *Request, localID enode.ID) (*enode.ID, func(), error) {
+		requests++
+		// the first peer does not deliver, there are no other peers
+		if req.SkipPeer(enode.ID{}.String()) {
+			return nil, func() {}, ErrNoSuitablePeer
+		}
+		return &enode.ID{}, func() {}, nil
+	}
+
+	ch := GenerateRandomChunk(chunk.DefaultSize)
+	get := func() (Chunk, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		return n.Get(ctx, chunk.ModeGetRequest, NewRequest(ch.Address()))
+	}
+
+	if _, err := get(); err != ErrNoSuitablePeer {
+		t.Fatalf("got error %v, want %v", err, ErrNoSuitablePeer)
+	}
+	if requests != 2 {
+		t.Fatalf("got %v network requests, want 2", requests)
+	}
+
+	if _, err := get(); err != ErrChunkNotFound {
+		t.Fatalf("got error %v, want %v", err, ErrChunkNotFound)
+	}
+	if requests != 2 {
+		t.Fatalf("got %v network requests for missing chunk, want 2", requests)
+	}
+
+	// the chunk is requested again after the ttl
+	time.Sleep(timeouts.MissingChunkTTL)
+	if _, err := get(); err != ErrNoSuitablePeer {
+		t.Fatalf("got error %v, want %v", err, ErrNoSuitablePeer)
+	}
+	if requests != 4 {
+		t.Fatalf("got %v network requests, want 4", requests)
+	}
+
+	// a stored chunk is not missing any more
+	if _, err := n.Put(context.Background(), chunk.ModePutRequest, ch); err != nil {
+		t.Fatal(err)
+	}
+	if n.isMissing(ch.Address()) {
+		t.Fatal("stored chunk is missing")
+	}
+	got, err := get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Address(), ch.Address()) {
+		t.Fatalf("got chunk %s, want %s", got.Address(), ch.Address())
+	}
+}
+
+// TestNetStoreGetSOC tests that a single owner chunk is retrieved
+// by its id and owner and that invalid ones are not stored.
+func TestNetStoreGetSOC(t *testing.T) {
+	n := NewNetStore(chunk.NewValidatorStore(NewMapChunkStore(), NewSOCValidator(MakeHashFunc(DefaultHash))), network.RandomBzzAddr())
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, chunk.SOCIDSize)
+	wrapped := GenerateRandomChunk(chunk.MaxSOCWrappedDataSize - 8)
+	s, err := chunk.NewSOC(id, wrapped, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := s.Chunk()
+
+	invalid := append([]byte{}, ch.Data()...)
+	invalid[len(invalid)-1]++
+	if _, err := n.Put(context.Background(), chunk.ModePutUpload, chunk.NewChunk(ch.Address(), invalid)); err != chunk.ErrChunkInvalid {
+		t.Fatalf("got error %v, want %v", err, chunk.ErrChunkInvalid)
+	}
+
+	if _, err := n.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+		t.Fatal(err)
+	}
+	got, err := n.GetSOC(context.Background(), chunk.ModeGetRequest, id, crypto.PubkeyToAddress(key.PublicKey).Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Wrapped.Address(), wrapped.Address()) {
+		t.Fatalf("got wrapped chunk %s, want %s", got.Wrapped.Address(), wrapped.Address())
+	}
+}
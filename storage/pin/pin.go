@@ -37,6 +37,11 @@ const (
 )
 
 var (
+	// ErrContentNotFound is returned when pinning content that is not in the local store
+	ErrContentNotFound = errors.New("content not found")
+	// ErrNotPinned is returned when unpinning content that is not pinned
+	ErrNotPinned = errors.New("content not pinned")
+
 	errInvalidChunkData      = errors.New("invalid chunk data")
 	errInvalidUnmarshallData = errors.New("invalid data length")
 )
@@ -46,7 +51,7 @@ var (
 type PinInfo struct {
 	Address    storage.Address
 	IsRaw      bool
-	FileSize   uint64
+	FileSize   uint64 // size of the file or the total size of the files in the collection
 	PinCounter uint64
 }
 
@@ -108,12 +113,16 @@ func NewAPI(lstore *localstore.DB, stateStore state.Store, params *storage.FileS
 // the file should be present in the local database. This function is called
 // from two places 1) Just after the file is uploaded 2) anytime after
 // uploading the file using the pin command. This function can pin both
-// encrypted and non-encrypted files.
+// encrypted and non-encrypted files. It returns ErrContentNotFound if the
+// root chunk is not in the local database.
 func (p *API) PinFiles(addr []byte, isRaw bool, credentials string) error {
 	hasChunk, err := p.db.Has(context.Background(), chunk.Address(p.removeDecryptionKeyFromChunkHash(addr)))
+	if err != nil {
+		return err
+	}
 	if !hasChunk {
 		log.Error("Could not pin hash. File not uploaded", "rootHash", hex.EncodeToString(addr))
-		return err
+		return ErrContentNotFound
 	}
 
 	// Walk the root hash and pin all the chunks
@@ -137,16 +146,12 @@ func (p *API) PinFiles(addr []byte, isRaw bool, credentials string) error {
 	// Check if the root hash is already pinned and add it to the pinInfo struct
 	pinInfo, err := p.getPinnedFile(addr)
 	if err != nil {
-		// Get the file size from the root chunk first 8 bytes
-		hashFunc := storage.MakeHashFunc(storage.DefaultHash)
-		isEncrypted := len(addr) > hashFunc().Size()
-		getter := storage.NewHasherStore(p.db, hashFunc, isEncrypted, chunk.NewTag(0, "show-chunks-tag", 0, false))
-		chunkData, err := getter.Get(context.Background(), addr)
+		// Get the size of the file or of all files in the collection
+		fileSize, err := p.contentSize(addr, isRaw, credentials)
 		if err != nil {
-			log.Error("Error getting chunk data from localstore.", "Address", hex.EncodeToString(addr))
+			log.Error("Error getting content size from localstore.", "Address", hex.EncodeToString(addr), "err", err)
 			return nil
 		}
-		fileSize := chunkData.Size()
 
 		// Get the pin counter from the pinIndex
 		pinCounter, err := p.getPinCounterOfChunk(chunk.Address(p.removeDecryptionKeyFromChunkHash(addr)))
@@ -185,12 +190,16 @@ func (p *API) PinFiles(addr []byte, isRaw bool, credentials string) error {
 // UnPinFiles is used to unpin an already pinned file. It takes the root
 // hash of the file and walks down the merkle tree unpinning all the chunks
 // that are encountered on the way. The pre-requisite is that the file should
-// have been already pinned using the PinFiles function. This function can
-// be called only from an external command.
+// have been already pinned using the PinFiles function, otherwise it
+// returns ErrNotPinned. This function can be called only from an external
+// command.
 func (p *API) UnpinFiles(addr []byte, credentials string) error {
 	pinInfo, err := p.getPinnedFile(addr)
 	if err != nil {
 		log.Error("Root hash is not pinned", "rootHash", hex.EncodeToString(addr), "err", err)
+		if err == state.ErrNotFound {
+			return ErrNotPinned
+		}
 		return err
 	}
 
@@ -404,10 +413,20 @@ QuitChunkFor:
 				}
 				fileSizeLock.Unlock()
 
-				if subTreeSize > chunk.DefaultSize {
+				if chunkData.Variable() {
+					// this is a tree chunk of content defined chunks
+					// load the tree's branches, skipping the sizes following their references
+					entrySize := hashSize + 8
+					for i := 0; i < (datalen-8)/entrySize; i++ {
+						brAddr := make([]byte, hashSize)
+						start := (i * entrySize) + 8
+						copy(brAddr[:], chunkData[start:start+hashSize])
+						chunkHashesC <- storage.Reference(brAddr)
+					}
+				} else if subTreeSize > chunk.DefaultSize {
 					// this is a tree chunk
-					// load the tree's branches
-					branches := (datalen - 8) / hashSize
+					// load the tree's branches, without parity references of erasure coded content
+					branches := (datalen-8)/hashSize - chunkData.Parities()
 					for i := 0; i < branches; i++ {
 						brAddr := make([]byte, hashSize)
 						start := (i * hashSize) + 8
@@ -418,7 +437,7 @@ QuitChunkFor:
 				} else {
 					// this is a data chunk
 					fileSizeLock.Lock()
-					rcvdFileSize = rcvdFileSize + chunk.DefaultSize
+					rcvdFileSize = rcvdFileSize + subTreeSize
 					got := rcvdFileSize
 					need := actualFileSize
 					fileSizeLock.Unlock()
@@ -451,6 +470,48 @@ QuitChunkFor:
 	return <-chunkErrC
 }
 
+// contentSize returns the size of the raw file or the total size
+// of the files in the collection with the root hash
+func (p *API) contentSize(addr []byte, isRaw bool, credentials string) (uint64, error) {
+	if isRaw {
+		return p.fileSize(addr)
+	}
+	walker, err := p.api.NewManifestWalker(context.Background(), storage.Address(addr),
+		p.api.Decryptor(context.Background(), credentials), nil)
+	if err != nil {
+		return 0, err
+	}
+	var size uint64
+	err = walker.Walk(func(entry *api.ManifestEntry) error {
+		if entry.ContentType == api.ManifestType || entry.Hash == "" {
+			return nil
+		}
+		fileAddr, err := hex.DecodeString(entry.Hash)
+		if err != nil {
+			return err
+		}
+		fileSize, err := p.fileSize(fileAddr)
+		if err != nil {
+			return err
+		}
+		size += fileSize
+		return nil
+	})
+	return size, err
+}
+
+// fileSize returns the size of the file from the span of its root chunk
+func (p *API) fileSize(addr []byte) (uint64, error) {
+	hashFunc := storage.MakeHashFunc(storage.DefaultHash)
+	isEncrypted := len(addr) > hashFunc().Size()
+	getter := storage.NewHasherStore(p.db, hashFunc, isEncrypted, chunk.NewTag(0, "show-chunks-tag", 0, false))
+	chunkData, err := getter.Get(context.Background(), addr)
+	if err != nil {
+		return 0, err
+	}
+	return chunkData.Size(), nil
+}
+
 func (p *API) removeDecryptionKeyFromChunkHash(ref []byte) []byte {
 	// remove the decryption key from the encrypted file hash
 	isEncrypted := len(ref) > p.hashSize
@@ -157,6 +157,9 @@ func TestListPinInfo(t *testing.T) {
 	if pinInfo.IsRaw {
 		t.Fatalf("IsRaw expected is false got is true")
 	}
+	if pinInfo.FileSize != 8*10000 {
+		t.Fatalf("file size expected is %d got is %d", 8*10000, pinInfo.FileSize)
+	}
 
 	// Pin it once more and check if the counters increases
 	err = p.PinFiles(hash, false, "")
@@ -211,6 +214,14 @@ func TestListPinInfo(t *testing.T) {
 	if err == nil {
 		t.Fatalf("uploaded collection is still pinned")
 	}
+
+	// Unpinning and pinning missing content fails
+	if err := p.UnpinFiles(hash, ""); err != ErrNotPinned {
+		t.Fatalf("unpin error expected is %v got is %v", ErrNotPinned, err)
+	}
+	if err := p.PinFiles(make([]byte, len(hash)), false, ""); err != ErrContentNotFound {
+		t.Fatalf("pin error expected is %v got is %v", ErrContentNotFound, err)
+	}
 }
 
 func getPinApiAndFileStore(t *testing.T) (*API, *storage.FileStore, func()) {
@@ -21,7 +21,6 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
-	"io/ioutil"
 	"sync"
 	"time"
 
@@ -71,9 +70,19 @@ const (
 	splitTimeout    = time.Minute * 5
 )
 
+// chunkBufferPool is the pool of buffers of maximal chunk data length
+// shared by pyramid chunkers to read data into before it is split
+var chunkBufferPool = &sync.Pool{
+	New: func() interface{} {
+		return make([]byte, chunk.DefaultSize+8)
+	},
+}
+
 type PyramidSplitterParams struct {
 	SplitterParams
-	getter Getter
+	getter     Getter
+	processors int64      // maximal number of routines hashing chunks concurrently
+	bufferPool *sync.Pool // pool of buffers to read data into
 }
 
 func NewPyramidSplitterParams(addr Address, reader io.Reader, putter Putter, getter Getter, chunkSize int64) *PyramidSplitterParams {
@@ -88,7 +97,9 @@ func NewPyramidSplitterParams(addr Address, reader io.Reader, putter Putter, get
 			putter: putter,
 			addr:   addr,
 		},
-		getter: getter,
+		getter:     getter,
+		processors: ChunkProcessors,
+		bufferPool: chunkBufferPool,
 	}
 }
 
@@ -132,6 +143,7 @@ type chunkJob struct {
 	key      Address
 	chunk    []byte
 	parentWg *sync.WaitGroup
+	buf      []byte // pooled buffer of the chunk data, released once it is put
 }
 
 type PyramidChunker struct {
@@ -143,6 +155,8 @@ type PyramidChunker struct {
 	getter      Getter
 	key         Address
 	tag         *chunk.Tag
+	processors  int64
+	bufferPool  *sync.Pool
 	workerCount int64
 	workerLock  sync.RWMutex
 	jobC        chan *chunkJob
@@ -164,8 +178,10 @@ func NewPyramidSplitter(params *PyramidSplitterParams, tag *chunk.Tag) (pc *Pyra
 	pc.getter = params.getter
 	pc.key = params.addr
 	pc.tag = tag
+	pc.processors = params.processors
+	pc.bufferPool = params.bufferPool
 	pc.workerCount = 0
-	pc.jobC = make(chan *chunkJob, 2*ChunkProcessors)
+	pc.jobC = make(chan *chunkJob, 2*pc.processors)
 	pc.wg = &sync.WaitGroup{}
 	pc.errC = make(chan error)
 	pc.quitC = make(chan bool)
@@ -285,6 +301,11 @@ func (pc *PyramidChunker) processor(ctx context.Context, id int64) {
 
 func (pc *PyramidChunker) processChunk(ctx context.Context, id int64, job *chunkJob) {
 	ref, err := pc.putter.Put(ctx, job.chunk)
+	// the putter does not keep the chunk data once it is hashed and
+	// handed over to the store, so its buffer can be reused
+	if job.buf != nil {
+		pc.bufferPool.Put(job.buf)
+	}
 	if err != nil {
 		select {
 		case pc.errC <- err:
@@ -338,7 +359,7 @@ func (pc *PyramidChunker) loadTree(ctx context.Context) error {
 		level:         depth - 1,
 		branchCount:   branchCount,
 		subtreeSize:   uint64(chunkSize),
-		chunk:         chunkData,
+		chunk:         pc.treeChunk(chunkData),
 		key:           pc.key,
 		index:         0,
 		updatePending: true,
@@ -364,7 +385,7 @@ func (pc *PyramidChunker) loadTree(ctx context.Context) error {
 					level:         lvl - 1,
 					branchCount:   bewBranchCount,
 					subtreeSize:   newChunkSize,
-					chunk:         newChunkData,
+					chunk:         pc.treeChunk(newChunkData),
 					key:           key,
 					index:         0,
 					updatePending: true,
@@ -383,6 +404,13 @@ func (pc *PyramidChunker) loadTree(ctx context.Context) error {
 	return nil
 }
 
+// treeChunk copies the loaded data of an intermediate chunk into a buffer
+// that can hold all of its branches, as branches are added when appending
+func (pc *PyramidChunker) treeChunk(data ChunkData) []byte {
+	c := make([]byte, pc.chunkSize+8)
+	return c[:copy(c, data)]
+}
+
 func (pc *PyramidChunker) prepareChunks(ctx context.Context, isAppend bool) {
 	defer pc.wg.Done()
 
@@ -431,29 +459,32 @@ func (pc *PyramidChunker) prepareChunks(ctx context.Context, isAppend bool) {
 
 	for index := 0; ; index++ {
 		var err error
-		chunkData := make([]byte, pc.chunkSize+8)
+		// data is read into a pooled buffer that is released when the
+		// chunk is put, or here if no data chunk is created from it
+		buf, _ := pc.bufferPool.Get().([]byte)
+		if int64(len(buf)) < pc.chunkSize+8 {
+			buf = make([]byte, pc.chunkSize+8)
+		}
 
 		var readBytes int
 
 		if unfinishedChunkData != nil {
-			copy(chunkData, unfinishedChunkData)
+			copy(buf, unfinishedChunkData)
 			readBytes += int(unfinishedChunkSize)
 			unfinishedChunkData = nil
 			log.Trace("pyramid.chunker: found unfinished chunk", "readBytes", readBytes)
 		}
 
-		var res []byte
-		res, err = ioutil.ReadAll(io.LimitReader(pc.reader, int64(len(chunkData)-(8+readBytes))))
+		var n int
+		n, err = io.ReadFull(pc.reader, buf[8+readBytes:pc.chunkSize+8])
 
-		// hack for ioutil.ReadAll:
-		// a successful call to ioutil.ReadAll returns err == nil, not err == EOF, whereas we
-		// want to propagate the io.EOF error
-		if len(res) == 0 && err == nil {
-			err = io.EOF
+		// a partially filled buffer is not the end of data, which is
+		// signalled by io.EOF only when no more data is read
+		if err == io.ErrUnexpectedEOF {
+			err = nil
 		}
-		copy(chunkData[8+readBytes:], res)
 
-		readBytes += len(res)
+		readBytes += n
 
 		if err != nil {
 			if err == io.EOF || err == io.ErrUnexpectedEOF {
@@ -462,6 +493,7 @@ func (pc *PyramidChunker) prepareChunks(ctx context.Context, isAppend bool) {
 
 				// Check if we are appending or the chunk is the only one.
 				if parent.branchCount == 1 && (pc.depth() == 0 || isAppend) {
+					pc.bufferPool.Put(buf)
 					// Data is exactly one chunk.. pick the last chunk key as root
 					chunkWG.Wait()
 					lastChunksAddress := parent.chunk[8 : 8+pc.hashSize]
@@ -469,6 +501,7 @@ func (pc *PyramidChunker) prepareChunks(ctx context.Context, isAppend bool) {
 					break
 				}
 			} else {
+				pc.bufferPool.Put(buf)
 				pc.quit()
 				break
 			}
@@ -476,10 +509,11 @@ func (pc *PyramidChunker) prepareChunks(ctx context.Context, isAppend bool) {
 
 		// Data ended in chunk boundary.. just signal to start bulding tree
 		if readBytes == 0 {
+			pc.bufferPool.Put(buf)
 			pc.buildTree(isAppend, parent, chunkWG, true, nil)
 			break
 		} else {
-			pkey := pc.enqueueDataChunk(chunkData, uint64(readBytes), parent, chunkWG)
+			pkey := pc.enqueueDataChunk(buf, uint64(readBytes), parent, chunkWG)
 
 			// update tree related parent data structures
 			parent.subtreeSize += uint64(readBytes)
@@ -519,7 +553,7 @@ func (pc *PyramidChunker) prepareChunks(ctx context.Context, isAppend bool) {
 		}
 
 		workers := pc.getWorkerCount()
-		if int64(len(pc.jobC)) > workers && workers < ChunkProcessors {
+		if int64(len(pc.jobC)) > workers && workers < pc.processors {
 			pc.incrementWorkerCount()
 			go pc.processor(ctx, pc.workerCount)
 		}
@@ -653,7 +687,7 @@ func (pc *PyramidChunker) enqueueTreeChunk(ent *TreeEntry, chunkWG *sync.WaitGro
 		ent.key = make([]byte, pc.hashSize)
 		chunkWG.Add(1)
 		select {
-		case pc.jobC <- &chunkJob{ent.key, ent.chunk[:ent.branchCount*pc.hashSize+8], chunkWG}:
+		case pc.jobC <- &chunkJob{ent.key, ent.chunk[:ent.branchCount*pc.hashSize+8], chunkWG, nil}:
 		case <-pc.quitC:
 		}
 
@@ -674,7 +708,7 @@ func (pc *PyramidChunker) enqueueDataChunk(chunkData []byte, size uint64, parent
 
 	chunkWG.Add(1)
 	select {
-	case pc.jobC <- &chunkJob{pkey, chunkData[:size+8], chunkWG}:
+	case pc.jobC <- &chunkJob{pkey, chunkData[:size+8], chunkWG, chunkData}:
 	case <-pc.quitC:
 	}
 
@@ -32,6 +32,8 @@ type Request struct {
 	Addr        Address  // chunk address
 	Origin      enode.ID // who is sending us that request? we compare Origin to the suggested peer from RequestFromPeers
 	PeersToSkip sync.Map // peers not to request chunk from
+	TTL         uint8    // number of hops a request from Origin may still be forwarded over
+	Background  bool     // request is made by a background process, such as repair, and has the lowest priority
 }
 
 // NewRequest returns a new instance of Request based on chunk address skip check and
@@ -0,0 +1,106 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package tiered
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// FileStore is a ColdStore that keeps every chunk in a separate file
+// named by the hexadecimal chunk address, in directories named by the
+// first byte of the address.
+type FileStore struct {
+	dir string
+}
+
+// FileStore implements ColdStore.
+var _ ColdStore = &FileStore{}
+
+// NewFileStore returns a new FileStore
+// that keeps files in the provided directory.
+func NewFileStore(dir string) (s *FileStore, err error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{
+		dir: dir,
+	}, nil
+}
+
+// path returns the file path for the chunk address.
+func (s *FileStore) path(addr chunk.Address) string {
+	h := hex.EncodeToString(addr)
+	if len(h) < 2 {
+		return filepath.Join(s.dir, h)
+	}
+	return filepath.Join(s.dir, h[:2], h)
+}
+
+// Get returns chunk data from its file.
+func (s *FileStore) Get(addr chunk.Address) (data []byte, err error) {
+	data, err = ioutil.ReadFile(s.path(addr))
+	if os.IsNotExist(err) {
+		return nil, chunk.ErrChunkNotFound
+	}
+	return data, err
+}
+
+// Put writes chunk data to a temporary file and
+// renames it, so that partial files are never read.
+func (s *FileStore) Put(addr chunk.Address, data []byte) (err error) {
+	p := s.path(addr)
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// Has returns true if the chunk file exists.
+func (s *FileStore) Has(addr chunk.Address) (yes bool, err error) {
+	_, err = os.Stat(s.path(addr))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete removes the chunk file.
+func (s *FileStore) Delete(addr chunk.Address) (err error) {
+	err = os.Remove(s.path(addr))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tiered provides a chunk.Store that keeps chunks in two tiers:
+// a hot tier that is a regular chunk.Store, usually a localstore whose
+// garbage collection removes the least recently used chunks, and a cold
+// tier that keeps all stored chunks on a cheaper storage backend.
+// Chunks that are not found in the hot tier are read from the cold tier
+// and promoted to the hot tier when they are requested.
+package tiered
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/log"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// ColdStore is a storage backend for the cold tier.
+// Implementations must return chunk.ErrChunkNotFound
+// from Get if the chunk is not stored.
+type ColdStore interface {
+	Get(addr chunk.Address) (data []byte, err error)
+	Put(addr chunk.Address, data []byte) (err error)
+	Has(addr chunk.Address) (yes bool, err error)
+	Delete(addr chunk.Address) (err error)
+}
+
+// Store implements chunk.Store by passing calls to the hot tier
+// chunk.Store, writing every new chunk also to the cold tier and
+// reading chunks from the cold tier if they are not in the hot one.
+type Store struct {
+	chunk.Store
+	cold ColdStore
+}
+
+// Store implements chunk.Store.
+var _ chunk.Store = &Store{}
+
+// New returns a new Store with the provided hot and cold tiers.
+func New(hot chunk.Store, cold ColdStore) *Store {
+	return &Store{
+		Store: hot,
+		cold:  cold,
+	}
+}
+
+// Put stores chunks in the hot tier and writes chunks
+// that are new to the hot tier to the cold tier.
+// As the chunks are stored once they are in the hot tier, failures to
+// write them to the cold tier are only logged and counted in metrics.
+func (s *Store) Put(ctx context.Context, mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err error) {
+	exist, err = s.Store.Put(ctx, mode, chs...)
+	if err != nil {
+		return nil, err
+	}
+	for i, ch := range chs {
+		if exist[i] {
+			continue
+		}
+		if err := s.cold.Put(ch.Address(), ch.Data()); err != nil {
+			metrics.GetOrRegisterCounter("tiered/cold/put/error", nil).Inc(1)
+			log.Error("tiered: cold tier put", "addr", ch.Address(), "err", err)
+		}
+	}
+	return exist, nil
+}
+
+// Get returns a chunk from the hot tier, or from the cold tier if it is
+// not found in the hot one. Chunks from the cold tier are promoted to
+// the hot tier if ModeGetRequest is used.
+func (s *Store) Get(ctx context.Context, mode chunk.ModeGet, addr chunk.Address) (ch chunk.Chunk, err error) {
+	ch, err = s.Store.Get(ctx, mode, addr)
+	if err != chunk.ErrChunkNotFound {
+		return ch, err
+	}
+	data, err := s.cold.Get(addr)
+	if err != nil {
+		return nil, err
+	}
+	metrics.GetOrRegisterCounter("tiered/cold/get", nil).Inc(1)
+	ch = chunk.NewChunk(addr, data)
+	if mode == chunk.ModeGetRequest {
+		if _, err := s.Store.Put(ctx, chunk.ModePutRequest, ch); err != nil {
+			return nil, err
+		}
+		metrics.GetOrRegisterCounter("tiered/promote", nil).Inc(1)
+	}
+	return ch, nil
+}
+
+// GetMulti returns chunks from the hot tier, or from both tiers
+// if any of them is not found in the hot one.
+func (s *Store) GetMulti(ctx context.Context, mode chunk.ModeGet, addrs ...chunk.Address) (chs []chunk.Chunk, err error) {
+	chs, err = s.Store.GetMulti(ctx, mode, addrs...)
+	if err != chunk.ErrChunkNotFound {
+		return chs, err
+	}
+	chs = make([]chunk.Chunk, len(addrs))
+	for i, addr := range addrs {
+		chs[i], err = s.Get(ctx, mode, addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return chs, nil
+}
+
+// Has returns true if the chunk is in any of the tiers.
+func (s *Store) Has(ctx context.Context, addr chunk.Address) (yes bool, err error) {
+	yes, err = s.Store.Has(ctx, addr)
+	if err != nil || yes {
+		return yes, err
+	}
+	return s.cold.Has(addr)
+}
+
+// HasMulti returns true for every chunk that is in any of the tiers.
+func (s *Store) HasMulti(ctx context.Context, addrs ...chunk.Address) (yes []bool, err error) {
+	yes, err = s.Store.HasMulti(ctx, addrs...)
+	if err != nil {
+		return nil, err
+	}
+	for i, addr := range addrs {
+		if yes[i] {
+			continue
+		}
+		yes[i], err = s.cold.Has(addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return yes, nil
+}
+
+// Set updates the hot tier and removes chunks
+// from the cold tier if ModeSetRemove is used.
+// Chunks that are not in the hot tier, for example as they are
+// garbage collected, are still removed from the cold tier.
+func (s *Store) Set(ctx context.Context, mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
+	err = s.Store.Set(ctx, mode, addrs...)
+	if mode != chunk.ModeSetRemove {
+		return err
+	}
+	if isNotFound(err) && len(addrs) > 1 {
+		// the batch of all addresses is discarded,
+		// so set the chunks that are in the hot tier one by one
+		err = nil
+		for _, addr := range addrs {
+			if e := s.Store.Set(ctx, mode, addr); e != nil && !isNotFound(e) {
+				err = e
+			}
+		}
+	}
+	if isNotFound(err) {
+		err = nil
+	}
+	for _, addr := range addrs {
+		if e := s.cold.Delete(addr); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// isNotFound returns whether the error from the hot tier
+// is returned for chunks that are not stored in it
+func isNotFound(err error) bool {
+	return err == chunk.ErrChunkNotFound || err == leveldb.ErrNotFound
+}
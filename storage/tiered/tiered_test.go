@@ -0,0 +1,252 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package tiered
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethersphere/swarm/chunk"
+	chunktesting "github.com/ethersphere/swarm/chunk/testing"
+	"github.com/ethersphere/swarm/storage/localstore"
+)
+
+// newTestStore returns a Store with a localstore hot tier,
+// FileStore cold tier and a function to remove them.
+func newTestStore(t *testing.T) (s *Store, hot *localstore.DB, cold *FileStore, cleanupFunc func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "tiered-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hot, err = localstore.New(dir+"/hot", make([]byte, 32), nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	cold, err = NewFileStore(dir + "/cold")
+	if err != nil {
+		hot.Close()
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return New(hot, cold), hot, cold, func() {
+		hot.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// TestStore validates that chunks are written to both tiers,
+// read from the cold tier when they are removed from the hot
+// one and promoted to the hot tier on request.
+func TestStore(t *testing.T) {
+	s, hot, cold, cleanupFunc := newTestStore(t)
+	defer cleanupFunc()
+
+	ctx := context.Background()
+	chunks := chunktesting.GenerateTestRandomChunks(3)
+
+	if _, err := s.Put(ctx, chunk.ModePutUpload, chunks...); err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range chunks {
+		has, err := cold.Has(ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !has {
+			t.Errorf("chunk %s not in cold tier", ch.Address())
+		}
+	}
+
+	// simulate garbage collection in the hot tier
+	if err := hot.Set(ctx, chunk.ModeSetRemove, chunks[0].Address(), chunks[1].Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	yes, err := s.HasMulti(ctx, chunkAddresses(chunks)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, y := range yes {
+		if !y {
+			t.Errorf("chunk %v not found", i)
+		}
+	}
+
+	// lookup does not promote chunks
+	got, err := s.Get(ctx, chunk.ModeGetLookup, chunks[0].Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Data(), chunks[0].Data()) {
+		t.Errorf("got data %x, want %x", got.Data(), chunks[0].Data())
+	}
+	if has, _ := hot.Has(ctx, chunks[0].Address()); has {
+		t.Error("chunk promoted on lookup")
+	}
+
+	// request promotes chunks
+	if _, err := s.Get(ctx, chunk.ModeGetRequest, chunks[1].Address()); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := hot.Has(ctx, chunks[1].Address()); !has {
+		t.Error("chunk not promoted on request")
+	}
+
+	gotChunks, err := s.GetMulti(ctx, chunk.ModeGetLookup, chunkAddresses(chunks)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ch := range gotChunks {
+		if !bytes.Equal(ch.Data(), chunks[i].Data()) {
+			t.Errorf("chunk %v: got data %x, want %x", i, ch.Data(), chunks[i].Data())
+		}
+	}
+
+	// remove from both tiers
+	if err := s.Set(ctx, chunk.ModeSetRemove, chunks[2].Address()); err != nil {
+		t.Fatal(err)
+	}
+	if has, err := s.Has(ctx, chunks[2].Address()); err != nil || has {
+		t.Errorf("got has %v error %v for removed chunk, want false", has, err)
+	}
+	if _, err := s.Get(ctx, chunk.ModeGetRequest, chunks[2].Address()); err != chunk.ErrChunkNotFound {
+		t.Errorf("got error %v, want %v", err, chunk.ErrChunkNotFound)
+	}
+
+	// remove chunks garbage collected from the hot tier from the cold tier
+	if err := s.Set(ctx, chunk.ModeSetRemove, chunks[0].Address(), chunks[1].Address()); err != nil {
+		t.Fatal(err)
+	}
+	for i, ch := range chunks[:2] {
+		if has, err := s.Has(ctx, ch.Address()); err != nil || has {
+			t.Errorf("chunk %v: got has %v error %v for removed chunk, want false", i, has, err)
+		}
+	}
+}
+
+// TestStoreSetNotFound tests that not found errors from the hot tier are
+// ignored only for the removal of chunks that are not in the hot tier.
+func TestStoreSetNotFound(t *testing.T) {
+	s, hot, _, cleanupFunc := newTestStore(t)
+	defer cleanupFunc()
+
+	ctx := context.Background()
+	ch := chunktesting.GenerateTestRandomChunk()
+	if _, err := s.Put(ctx, chunk.ModePutUpload, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	// unpin of a chunk that is not pinned
+	if err := s.Set(ctx, chunk.ModeSetUnpin, ch.Address()); !isNotFound(err) {
+		t.Errorf("got error %v, want not found", err)
+	}
+
+	// removal of a chunk garbage collected from the hot tier
+	if err := hot.Set(ctx, chunk.ModeSetRemove, ch.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, chunk.ModeSetRemove, ch.Address()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// failingColdStore is a ColdStore that fails to store chunks.
+type failingColdStore struct {
+	ColdStore
+}
+
+func (failingColdStore) Put(chunk.Address, []byte) error {
+	return errors.New("cold tier failure")
+}
+
+// TestStorePutColdFailure tests that chunks stored in the hot tier are
+// reported as stored even if they could not be written to the cold tier.
+func TestStorePutColdFailure(t *testing.T) {
+	_, hot, cold, cleanupFunc := newTestStore(t)
+	defer cleanupFunc()
+	s := New(hot, failingColdStore{cold})
+
+	ctx := context.Background()
+	ch := chunktesting.GenerateTestRandomChunk()
+	exist, err := s.Put(ctx, chunk.ModePutUpload, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exist[0] {
+		t.Error("new chunk reported as existing")
+	}
+	if has, _ := hot.Has(ctx, ch.Address()); !has {
+		t.Error("chunk not in hot tier")
+	}
+}
+
+// TestFileStore validates FileStore methods.
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tiered-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := chunktesting.GenerateTestRandomChunk()
+
+	if _, err := s.Get(ch.Address()); err != chunk.ErrChunkNotFound {
+		t.Errorf("got error %v, want %v", err, chunk.ErrChunkNotFound)
+	}
+	if err := s.Put(ch.Address(), ch.Data()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.Get(ch.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, ch.Data()) {
+		t.Errorf("got data %x, want %x", data, ch.Data())
+	}
+	if has, err := s.Has(ch.Address()); err != nil || !has {
+		t.Errorf("got has %v error %v, want true", has, err)
+	}
+	if err := s.Delete(ch.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if has, err := s.Has(ch.Address()); err != nil || has {
+		t.Errorf("got has %v error %v, want false", has, err)
+	}
+	if err := s.Delete(ch.Address()); err != nil {
+		t.Errorf("got error %v on deleting missing chunk", err)
+	}
+}
+
+func chunkAddresses(chunks []chunk.Chunk) []chunk.Address {
+	addrs := make([]chunk.Address, len(chunks))
+	for i, ch := range chunks {
+		addrs[i] = ch.Address()
+	}
+	return addrs
+}
@@ -160,6 +160,8 @@ type Reference []byte
 
 // Putter is responsible to store data and create a reference for it
 type Putter interface {
+	// Put must not keep the chunk data after it returns,
+	// as the caller may reuse it
 	Put(context.Context, ChunkData) (Reference, error)
 	// RefSize returns the length of the Reference created by this Putter
 	RefSize() int64
@@ -174,9 +176,31 @@ type Getter interface {
 	Get(context.Context, Reference) (ChunkData, error)
 }
 
+// spanSizeMask masks the size in the span of chunk data. The most significant
+// byte of the span of intermediate chunks of erasure coded content holds the
+// number of parity references, which follow the references of the children.
+const spanSizeMask = 1<<56 - 1
+
+// spanVariableFlag is set in the span of intermediate chunks of content split
+// with content defined chunking, whose children are of variable size. Their
+// data holds the size of every child after its reference.
+const spanVariableFlag = 1 << 63
+
 // NOTE: this returns invalid data if chunk is encrypted
 func (c ChunkData) Size() uint64 {
-	return binary.LittleEndian.Uint64(c[:8])
+	return binary.LittleEndian.Uint64(c[:8]) & spanSizeMask
+}
+
+// Parities returns the number of parity references
+// of an intermediate chunk of erasure coded content
+func (c ChunkData) Parities() int {
+	return int(c[7] &^ (spanVariableFlag >> 56))
+}
+
+// Variable returns whether the chunk data is of an intermediate
+// chunk of content split with content defined chunking
+func (c ChunkData) Variable() bool {
+	return binary.LittleEndian.Uint64(c[:8])&spanVariableFlag != 0
 }
 
 type ChunkValidator = chunk.Validator
@@ -197,18 +221,53 @@ func NewContentAddressValidator(hasher SwarmHasher) *ContentAddressValidator {
 // Validate that the given key is a valid content address for the given data
 func (v *ContentAddressValidator) Validate(ch Chunk) bool {
 	data := ch.Data()
-	if l := len(data); l < 9 || l > chunk.DefaultSize+8 {
-		// log.Error("invalid chunk size", "chunk", addr.Hex(), "size", l)
+	if l := len(data); l < 9 || l > chunk.MaxDataSize {
 		return false
 	}
 
-	hasher := v.Hasher()
+	return bytes.Equal(contentAddress(v.Hasher, data), ch.Address())
+}
+
+// SOCValidator validates single owner chunks by checking that the
+// owner signature over the id and the wrapped chunk content address
+// matches the chunk address
+type SOCValidator struct {
+	Hasher SwarmHasher
+}
+
+// NewSOCValidator returns a new SOCValidator which uses the
+// hasher to compute the content address of wrapped chunks
+func NewSOCValidator(hasher SwarmHasher) *SOCValidator {
+	return &SOCValidator{
+		Hasher: hasher,
+	}
+}
+
+// Validate that the given chunk is a valid single owner chunk
+func (v *SOCValidator) Validate(ch Chunk) bool {
+	_, err := v.Parse(ch)
+	return err == nil
+}
+
+// Parse returns the single owner chunk of the given chunk
+// or chunk.ErrChunkInvalid if it is not a valid one
+func (v *SOCValidator) Parse(ch Chunk) (*chunk.SOC, error) {
+	return chunk.ParseSOC(ch, func(data []byte) Address {
+		if len(data) < 9 {
+			return nil
+		}
+		return contentAddress(v.Hasher, data)
+	})
+}
+
+// contentAddress returns the content address of the
+// chunk data which is prefixed with its span
+func contentAddress(h SwarmHasher, data []byte) Address {
+	hasher := h()
 	hasher.Reset()
 	hasher.SetSpanBytes(data[:8])
 	hasher.Write(data[8:])
-	hash := hasher.Sum(nil)
-
-	return bytes.Equal(hash, ch.Address())
+	return hasher.Sum(nil)
 }
 
 type ChunkStore = chunk.Store
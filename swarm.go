@@ -18,6 +18,7 @@ package swarm
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"errors"
 	"fmt"
@@ -27,6 +28,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
@@ -64,6 +66,7 @@ import (
 
 var (
 	updateGaugesPeriod = 5 * time.Second
+	tagsPersistPeriod  = 10 * time.Second
 	startCounter       = metrics.NewRegisteredCounter("stack/start", nil)
 	stopCounter        = metrics.NewRegisteredCounter("stack/stop", nil)
 	uptimeGauge        = metrics.NewRegisteredGauge("stack/uptime", nil)
@@ -82,6 +85,7 @@ type Swarm struct {
 	bzzEth            *bzzeth.BzzEth
 	privateKey        *ecdsa.PrivateKey
 	netStore          *storage.NetStore
+	validators        *chunk.ValidatorChain
 	sfs               *fuse.SwarmFS // need this to cleanup all the active mounts on node exit
 	ps                *pss.Pss
 	pushSync          *pushsync.Pusher
@@ -89,10 +93,12 @@ type Swarm struct {
 	swap              *swap.Swap
 	stateStore        *state.DBStore
 	tags              *chunk.Tags
+	tagsQuit          chan struct{} // closed to stop persisting tags periodically
 	accountingMetrics *protocols.AccountingMetrics
 	cleanupFuncs      []func() error
 	pinAPI            *pin.API // API object implements all pinning related commands
 	inspector         *api.Inspector
+	debugHandler      *debugHandler // serves the debug endpoints of this instance
 
 	tracerClose io.Closer
 }
@@ -124,6 +130,14 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 		BootnodeMode: config.BootnodeMode,
 		SyncEnabled:  config.SyncEnabled,
 	}
+	if config.LightNodeEnabled {
+		bzzconfig.LightPolicy = network.LightNodePolicy{
+			NoRetrieve: config.LightNoRetrieve,
+			NoSync:     config.LightNoSync,
+		}
+		// the stream protocol is not run if sync streams are not served
+		bzzconfig.SyncEnabled = config.SyncEnabled && !config.LightNoSync
+	}
 
 	// Swap initialization
 	if config.SwapEnabled {
@@ -207,20 +221,19 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 
 	feedsHandler = feed.NewHandler(fhParams)
 	self.tags = chunk.NewTags()
-	err = self.stateStore.Get("tags", self.tags)
-	if err != nil {
-		if err == state.ErrNotFound {
-			self.tags = chunk.NewTags()
-		} else {
-			return nil, err
-		}
-	} else {
-		log.Info("loaded saved tags successfully from state store")
+	if err := self.tags.Load(self.stateStore); err != nil {
+		return nil, err
 	}
+	log.Info("loaded saved tags successfully from state store", "count", len(self.tags.All()))
 
+	kp := network.NewKadParams()
+	kp.PeerFilter, err = network.NewPeerFilter(self.stateStore)
+	if err != nil {
+		return nil, err
+	}
 	to := network.NewKademlia(
 		common.FromHex(config.BzzKey),
-		network.NewKadParams(),
+		kp,
 	)
 
 	localStore, err := localstore.New(config.ChunkDbPath, config.BaseKey, &localstore.Options{
@@ -235,12 +248,29 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 	lstore := chunk.NewValidatorStore(
 		localStore,
 		storage.NewContentAddressValidator(storage.MakeHashFunc(storage.DefaultHash)),
+		storage.NewSOCValidator(storage.MakeHashFunc(storage.DefaultHash)),
 		feedsHandler,
 	)
+	self.validators = lstore.ValidatorChain
 
 	self.netStore = storage.NewNetStore(lstore, bzzconfig.Address)
-	self.retrieval = retrieval.New(to, self.netStore, bzzconfig.Address, self.swap)
+	retrievalOptions := &retrieval.Options{
+		// only send requests to peers that serve them on behalf of others
+		CapabilityIndex: network.CapabilityIndexRelayRetrieve,
+		RelayRequests:   config.RelayRequests,
+	}
+	if config.LightNodeEnabled {
+		// light nodes do not relay requests for chunks they do not store
+		retrievalOptions.ForwardingPolicy = retrieval.ForwardNone
+		retrievalOptions.DisableServing = config.LightNoRetrieve
+		// nor deliveries between peers, as they do not advertise it
+		retrievalOptions.DisableRelay = true
+	}
+	self.retrieval = retrieval.NewWithOptions(to, self.netStore, bzzconfig.Address, self.swap, retrievalOptions)
 	self.netStore.RemoteGet = self.retrieval.RequestFromPeers
+	if len(config.FallbackGateways) > 0 {
+		self.netStore.Fallback = storage.NewGatewayFallback(self.netStore, config.FallbackGateways).Fetch
+	}
 
 	feedsHandler.SetStore(self.netStore)
 
@@ -250,12 +280,33 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 	}
 
 	syncProvider := stream.NewSyncProvider(self.netStore, to, bzzconfig.Address, syncing, false)
-	self.streamer = stream.New(self.stateStore, bzzconfig.Address, syncProvider)
+	if config.SyncWithinRadius {
+		// chunks outside of the storage radius (neighbourhood depth) would be garbage collected
+		// shortly after they are synced, so only the bins within it are synced
+		syncProvider = stream.NewSyncProviderWithinRadius(self.netStore, to, bzzconfig.Address, syncing)
+	}
+	self.streamer = stream.NewWithOptions(self.stateStore, bzzconfig.Address, &stream.Options{
+		MinBatchSize:       config.SyncMinBatchSize,
+		MaxBatchSize:       config.SyncMaxBatchSize,
+		MaxInFlightBatches: config.SyncMaxInFlight,
+	}, syncProvider)
 
 	// Swarm Hash Merklised Chunking for Arbitrary-length Document/File storage
 	lnetStore := storage.NewLNetStore(self.netStore)
 	self.fileStore = storage.NewFileStore(lnetStore, localStore, self.config.FileStoreParams, self.tags)
 
+	if !config.LightNodeEnabled {
+		// chunks within the neighbourhood depth are protected from garbage collection,
+		// so it is advertised to peers as the storage radius
+		bzzconfig.StorageInfo = func() network.StorageInfo {
+			free, err := localStore.FreeCapacity()
+			if err != nil {
+				log.Warn("could not get free storage capacity", "err", err)
+			}
+			return network.StorageInfo{Radius: uint8(to.NeighbourhoodDepth()), FreeCapacity: free}
+		}
+	}
+
 	log.Debug("Setup local storage")
 	self.bzz = network.NewBzz(bzzconfig, to, self.stateStore, stream.Spec, self.retrieval.Spec(), self.streamer.Run, self.retrieval.Run)
 	self.bzzEth = bzzeth.New(self.netStore, to)
@@ -272,8 +323,8 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 	if config.PushSyncEnabled {
 		// expire time for push-sync messages should be lower than regular chat-like messages to avoid network flooding
 		pubsub := pss.NewPubSub(self.ps, 20*time.Second)
-		self.pushSync = pushsync.NewPusher(localStore, pubsub, self.tags)
-		self.storer = pushsync.NewStorer(self.netStore, pubsub)
+		self.pushSync = pushsync.NewPusher(localStore, pubsub, self.tags, self.stateStore)
+		self.storer = pushsync.NewStorer(self.netStore, pubsub, self.privateKey)
 	}
 
 	self.api = api.NewAPI(self.fileStore, self.dns, self.rns, feedsHandler, self.privateKey, self.tags)
@@ -285,10 +336,51 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 	self.sfs = fuse.NewSwarmFS(self.api)
 	log.Debug("Initialized FUSE filesystem")
 	self.inspector = api.NewInspector(self.api, self.bzz.Hive, self.netStore, self.streamer, localStore)
+	self.debugHandler = newDebugHandler(localStore, self.bzz.Hive)
 
 	return self, nil
 }
 
+// DebugHandler returns the HTTP handler that serves localstore inspection on
+// /debug/localstore and kademlia status on /debug/kademlia paths for this
+// Swarm instance, to be mounted on a debug server. It responds with
+// 503 Service Unavailable after the instance is stopped.
+func (s *Swarm) DebugHandler() http.Handler {
+	return s.debugHandler
+}
+
+// debugHandler serves the debug endpoints of a Swarm instance
+// until it is stopped, when the references to its components
+// are released
+type debugHandler struct {
+	mux *http.ServeMux
+	mu  sync.RWMutex
+}
+
+func newDebugHandler(db *localstore.DB, hive *network.Hive) *debugHandler {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/localstore", db.InspectHandler())
+	mux.Handle("/debug/kademlia", hive.KademliaStatusHandler())
+	return &debugHandler{mux: mux}
+}
+
+func (h *debugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	mux := h.mux
+	h.mu.RUnlock()
+	if mux == nil {
+		http.Error(w, "swarm is stopped", http.StatusServiceUnavailable)
+		return
+	}
+	mux.ServeHTTP(w, r)
+}
+
+func (h *debugHandler) stop() {
+	h.mu.Lock()
+	h.mux = nil
+	h.mu.Unlock()
+}
+
 // parseResolverAPIAddress parses string according to format
 // [tld:][contract-addr@]url and returns ClientConfig structure
 // with endpoint, contract address and TLD.
@@ -432,6 +524,19 @@ func (s *Swarm) Start(srv *p2p.Server) error {
 		}
 	}(startTime)
 
+	// persist tags periodically for the upload progress to survive crashes
+	s.tagsQuit = make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-time.After(tagsPersistPeriod):
+				s.persistTags()
+			case <-s.tagsQuit:
+				return
+			}
+		}
+	}()
+
 	startCounter.Inc(1)
 	if err := s.streamer.Start(srv); err != nil {
 		return err
@@ -439,9 +544,20 @@ func (s *Swarm) Start(srv *p2p.Server) error {
 	return s.retrieval.Start(srv)
 }
 
+// WaitWarmRestart blocks until the node is done reconnecting to the peers
+// it was connected to before the restart, or the context is done. It
+// returns true if any of them was reconnected.
+func (s *Swarm) WaitWarmRestart(ctx context.Context) bool {
+	return s.bzz.WaitWarmRestart(ctx)
+}
+
 // Stop stops all component services.
 // Implements the node.Service interface.
 func (s *Swarm) Stop() error {
+	if s.debugHandler != nil {
+		s.debugHandler.stop()
+	}
+
 	if s.tracerClose != nil {
 		err := s.tracerClose.Close()
 		tracing.FinishSpans()
@@ -471,11 +587,11 @@ func (s *Swarm) Stop() error {
 		log.Error("retrieval stop", "err", err)
 	}
 
+	if s.tagsQuit != nil {
+		close(s.tagsQuit)
+	}
 	if s.tags != nil {
-		err := s.stateStore.Put("tags", s.tags)
-		if err != nil {
-			log.Error("had an error persisting tags", "err", err)
-		}
+		s.persistTags()
 	}
 
 	if s.storer != nil {
@@ -508,6 +624,19 @@ func (s *Swarm) Stop() error {
 	return err
 }
 
+// persistTags deletes the completed tags according to the retention
+// and persists the rest in the state store
+func (s *Swarm) persistTags() {
+	var policy chunk.DeletePolicy = chunk.KeepCompleted
+	if s.config.TagsRetention > 0 {
+		policy = chunk.DeleteCompletedAfter(s.config.TagsRetention)
+	}
+	s.tags.DeleteCompleted(policy, time.Now())
+	if err := s.tags.Save(s.stateStore); err != nil {
+		log.Error("had an error persisting tags", "err", err)
+	}
+}
+
 // Protocols implements the node.Service interface
 func (s *Swarm) Protocols() (protos []p2p.Protocol) {
 	if s.config.BootnodeMode {
@@ -559,6 +688,7 @@ func (s *Swarm) APIs() []rpc.API {
 	}
 
 	apis = append(apis, s.bzz.APIs()...)
+	apis = append(apis, s.retrieval.APIs()...)
 
 	// this is a workaround disabling syncing altogether from a node but
 	// must be changed when multiple stream implementations are at hand
@@ -583,6 +713,13 @@ func (s *Swarm) RegisterPssProtocol(topic *pssmessage.Topic, spec *protocols.Spe
 	return pss.RegisterProtocol(s.ps, topic, spec, targetprotocol, options)
 }
 
+// RegisterValidator registers custom validators of chunk types
+// which are used to validate chunks on ingest, along with the
+// content address, single owner chunk and feed update validators.
+func (s *Swarm) RegisterValidator(validators ...chunk.Validator) {
+	s.validators.Register(validators...)
+}
+
 // Info represents the current Swarm node's configuration
 type Info struct {
 	*api.Config
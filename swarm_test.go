@@ -17,10 +17,14 @@
 package swarm
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"runtime"
@@ -34,6 +38,7 @@ import (
 	"github.com/ethersphere/swarm/api"
 	"github.com/ethersphere/swarm/network"
 	"github.com/ethersphere/swarm/sctx"
+	"github.com/ethersphere/swarm/storage/localstore"
 	"github.com/ethersphere/swarm/testutil"
 )
 
@@ -506,3 +511,75 @@ func testLocalStoreAndRetrieve(t *testing.T, swarm *Swarm, n int, randomData boo
 		}
 	}
 }
+
+// TestDebugHandler tests that the debug handlers of Swarm instances
+// serve their own components and stop serving when they are stopped.
+func TestDebugHandler(t *testing.T) {
+	swarms := make([]*Swarm, 2)
+	for i := range swarms {
+		dir, err := ioutil.TempDir("", "swarm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		config := api.NewConfig()
+		config.Path = dir
+		privkey, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodekey, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.Init(privkey, nodekey)
+
+		swarms[i], err = NewSwarm(config, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	data := testutil.RandomBytes(1, 10000)
+	_, wait, err := swarms[0].api.Store(ctx, bytes.NewReader(data), int64(len(data)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, s := range swarms {
+		rec := httptest.NewRecorder()
+		s.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/localstore", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("swarm %v: got status %v, want %v", i, rec.Code, http.StatusOK)
+		}
+		var inspection localstore.Inspection
+		if err := json.Unmarshal(rec.Body.Bytes(), &inspection); err != nil {
+			t.Fatal(err)
+		}
+		if stored := inspection.StoredSize > 0; stored != (i == 0) {
+			t.Errorf("swarm %v: got stored size %v", i, inspection.StoredSize)
+		}
+
+		rec = httptest.NewRecorder()
+		s.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/kademlia", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("swarm %v: got kademlia status %v, want %v", i, rec.Code, http.StatusOK)
+		}
+	}
+
+	for i, s := range swarms {
+		if err := s.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		s.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/localstore", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("swarm %v: got status %v after stop, want %v", i, rec.Code, http.StatusServiceUnavailable)
+		}
+	}
+}